@@ -11,6 +11,15 @@ type Config struct {
 	DatabaseURL     string
 	ImagesDirectory string
 	VideosDirectory string
+	UploadsDirectory string
+	// Media storage
+	StorageBackend    string
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3PathStyle       bool
 	// Email configuration
 	EmailEnabled    bool
 	AWSRegion       string
@@ -19,6 +28,10 @@ type Config struct {
 	SenderEmail     string
 	SenderName      string
 	BaseURL         string
+	// Access requests
+	AccessRequestExpiryDays int
+	// Bump animations
+	BumpAnimationFPS int
 }
 
 var AppConfig *Config
@@ -31,6 +44,15 @@ func InitConfig() {
 		DatabaseURL:     getEnvWithDefault("DATABASE_URL", "./data/sqlite/core.db"),
 		ImagesDirectory: getEnvWithDefault("IMAGES_DIRECTORY", "./data/images"),
 		VideosDirectory: getEnvWithDefault("VIDEOS_DIRECTORY", "./data/videos"),
+		UploadsDirectory: getEnvWithDefault("UPLOADS_DIRECTORY", "./data/uploads"),
+		// Media storage
+		StorageBackend:    getEnvWithDefault("STORAGE_BACKEND", "local"),
+		S3Endpoint:        getEnvWithDefault("S3_ENDPOINT", ""),
+		S3Region:          getEnvWithDefault("S3_REGION", "us-east-1"),
+		S3Bucket:          getEnvWithDefault("S3_BUCKET", ""),
+		S3AccessKeyID:     getEnvWithDefault("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey: getEnvWithDefault("S3_SECRET_ACCESS_KEY", ""),
+		S3PathStyle:       GetEnvAsBool("S3_PATH_STYLE", true),
 		// Email configuration
 		EmailEnabled:    GetEnvAsBool("EMAIL_ENABLED", false),
 		AWSRegion:       getEnvWithDefault("AWS_REGION", "us-east-1"),
@@ -39,6 +61,10 @@ func InitConfig() {
 		SenderEmail:     getEnvWithDefault("SENDER_EMAIL", "noreply@example.com"),
 		SenderName:      getEnvWithDefault("SENDER_NAME", "40Weeks"),
 		BaseURL:         getEnvWithDefault("BASE_URL", "http://localhost:8080"),
+		// Access requests
+		AccessRequestExpiryDays: GetEnvAsInt("ACCESS_REQUEST_EXPIRY_DAYS", 14),
+		// Bump animations
+		BumpAnimationFPS: GetEnvAsInt("BUMP_ANIMATION_FPS", 2),
 	}
 }
 
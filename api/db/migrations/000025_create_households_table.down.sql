@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_village_members_household_id;
+ALTER TABLE village_members DROP COLUMN household_id;
+DROP INDEX IF EXISTS idx_households_pregnancy_id;
+DROP TABLE IF EXISTS households;
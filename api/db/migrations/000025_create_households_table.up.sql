@@ -0,0 +1,58 @@
+-- Households group one or more village member contact addresses under a single
+-- displayed name and relationship (e.g. "John & Jane" with two emails)
+CREATE TABLE households (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    pregnancy_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    relationship TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (pregnancy_id) REFERENCES pregnancies (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_households_pregnancy_id ON households(pregnancy_id);
+
+ALTER TABLE village_members ADD COLUMN household_id INTEGER REFERENCES households (id) ON DELETE SET NULL;
+
+-- Existing multi-email joins were stored as "Name (1)", "Name (2)", ... one row per address,
+-- in the order they were added. Each numbered row is folded into the household of the nearest
+-- "Name (1)" row before it with the same name and relationship, which started its join. Every
+-- other member gets a household of their own, so people who only share a name stay apart.
+CREATE TEMP TABLE member_households AS
+SELECT id AS member_id, pregnancy_id, name, relationship, created_at, updated_at,
+    CASE
+        WHEN name GLOB '* ([0-9])' THEN substr(name, 1, length(name) - 4)
+        WHEN name GLOB '* ([0-9][0-9])' THEN substr(name, 1, length(name) - 5)
+    END AS base_name,
+    CASE
+        WHEN name GLOB '* ([0-9])' THEN CAST(substr(name, -2, 1) AS INTEGER)
+        WHEN name GLOB '* ([0-9][0-9])' THEN CAST(substr(name, -3, 2) AS INTEGER)
+    END AS suffix,
+    id AS household_id
+FROM village_members;
+
+UPDATE member_households
+SET household_id = COALESCE((
+    SELECT MAX(first.member_id) FROM member_households first
+    WHERE first.pregnancy_id = member_households.pregnancy_id
+    AND first.relationship = member_households.relationship
+    AND first.base_name = member_households.base_name
+    AND first.suffix = 1
+    AND first.member_id <= member_households.member_id
+), member_id)
+WHERE suffix IS NOT NULL;
+
+-- Households are numbered after the member whose row starts them
+INSERT INTO households (id, pregnancy_id, name, relationship, created_at, updated_at)
+SELECT household_id, pregnancy_id, COALESCE(MAX(CASE WHEN member_id = household_id THEN base_name END), MAX(CASE WHEN member_id = household_id THEN name END)),
+    relationship, MIN(created_at), MAX(updated_at)
+FROM member_households
+GROUP BY household_id;
+
+UPDATE village_members
+SET household_id = (SELECT household_id FROM member_households WHERE member_id = village_members.id),
+    name = COALESCE((SELECT base_name FROM member_households WHERE member_id = village_members.id), name);
+
+DROP TABLE member_households;
+
+CREATE INDEX idx_village_members_household_id ON village_members(household_id);
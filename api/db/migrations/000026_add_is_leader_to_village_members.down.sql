@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_village_members_is_leader;
+ALTER TABLE village_members DROP COLUMN is_leader;
@@ -0,0 +1,4 @@
+-- Village leaders can manage the village on the parents' behalf using their own login
+ALTER TABLE village_members ADD COLUMN is_leader BOOLEAN DEFAULT FALSE;
+
+CREATE INDEX idx_village_members_is_leader ON village_members(pregnancy_id, is_leader);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS tell_wave_circles;
+DROP INDEX IF EXISTS idx_tell_wave_members_village_member_id;
+DROP TABLE IF EXISTS tell_wave_members;
+DROP INDEX IF EXISTS idx_tell_waves_pregnancy_id;
+DROP TABLE IF EXISTS tell_waves;
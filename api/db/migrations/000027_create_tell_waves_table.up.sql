@@ -0,0 +1,33 @@
+-- A tell wave is one stage of the parents' announcement plan, e.g. "Grandparents"
+-- at week 10. Members can be assigned directly or through a relationship circle.
+CREATE TABLE tell_waves (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    pregnancy_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    target_week INTEGER,
+    target_date DATE,
+    reminder_sent_at DATETIME,
+    completed_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (pregnancy_id) REFERENCES pregnancies (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_tell_waves_pregnancy_id ON tell_waves(pregnancy_id);
+
+CREATE TABLE tell_wave_members (
+    wave_id INTEGER NOT NULL,
+    village_member_id INTEGER NOT NULL,
+    PRIMARY KEY (wave_id, village_member_id),
+    FOREIGN KEY (wave_id) REFERENCES tell_waves (id) ON DELETE CASCADE,
+    FOREIGN KEY (village_member_id) REFERENCES village_members (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_tell_wave_members_village_member_id ON tell_wave_members(village_member_id);
+
+CREATE TABLE tell_wave_circles (
+    wave_id INTEGER NOT NULL,
+    relationship TEXT NOT NULL,
+    PRIMARY KEY (wave_id, relationship),
+    FOREIGN KEY (wave_id) REFERENCES tell_waves (id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+-- Tokens are kept on rollback since unsubscribe links in emails already sent must keep working
+
+-- Placeholder comment to indicate this migration is not reversed
+SELECT 'Migration 000028 is not reversed - unsubscribe tokens are kept for links already sent' AS notice;
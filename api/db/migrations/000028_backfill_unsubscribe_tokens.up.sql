@@ -0,0 +1,4 @@
+-- Tokens were never generated at insert time, so give every existing member one
+UPDATE village_members
+SET unsubscribe_token = lower(hex(randomblob(16)))
+WHERE unsubscribe_token IS NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_village_members_delivery_frequency;
+ALTER TABLE village_members DROP COLUMN last_digest_sent_at;
+ALTER TABLE village_members DROP COLUMN delivery_frequency;
@@ -0,0 +1,5 @@
+-- How a villager wants update and milestone emails: immediate, daily, weekly or none
+ALTER TABLE village_members ADD COLUMN delivery_frequency TEXT NOT NULL DEFAULT 'immediate' CHECK (delivery_frequency IN ('immediate', 'daily', 'weekly', 'none'));
+ALTER TABLE village_members ADD COLUMN last_digest_sent_at DATETIME;
+
+CREATE INDEX idx_village_members_delivery_frequency ON village_members(delivery_frequency);
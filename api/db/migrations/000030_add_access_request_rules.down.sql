@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_access_requests_expires_at;
+ALTER TABLE access_requests DROP COLUMN expires_at;
+ALTER TABLE access_requests DROP COLUMN referrer;
+DROP INDEX IF EXISTS idx_access_request_rules_pregnancy_id;
+DROP TABLE IF EXISTS access_request_rules;
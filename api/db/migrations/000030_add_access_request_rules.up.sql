@@ -0,0 +1,19 @@
+-- Rules the parents set to approve access requests automatically
+CREATE TABLE access_request_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    pregnancy_id INTEGER NOT NULL,
+    rule_type TEXT NOT NULL CHECK (rule_type IN ('email_domain', 'referrer', 'relationship')),
+    value TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (pregnancy_id) REFERENCES pregnancies(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_access_request_rules_pregnancy_id ON access_request_rules(pregnancy_id);
+
+-- Who the requester says referred them, and when the request lapses if nobody acts on it
+ALTER TABLE access_requests ADD COLUMN referrer TEXT;
+ALTER TABLE access_requests ADD COLUMN expires_at DATETIME;
+
+UPDATE access_requests SET expires_at = datetime(created_at, '+14 days') WHERE expires_at IS NULL;
+
+CREATE INDEX idx_access_requests_expires_at ON access_requests(expires_at);
@@ -0,0 +1 @@
+ALTER TABLE access_requests DROP COLUMN decision_nonce;
@@ -0,0 +1,3 @@
+-- Per-request secret mixed into the signed approve/deny links in the owner's notification email.
+-- Requests are deleted once decided, so each link works only once.
+ALTER TABLE access_requests ADD COLUMN decision_nonce TEXT;
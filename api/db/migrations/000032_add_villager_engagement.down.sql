@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS update_views;
+DROP INDEX IF EXISTS idx_engagement_events_pregnancy_id;
+DROP INDEX IF EXISTS idx_engagement_events_member;
+DROP TABLE IF EXISTS engagement_events;
+DROP INDEX IF EXISTS idx_email_notifications_tracking_id;
+ALTER TABLE email_notifications DROP COLUMN clicked_at;
+ALTER TABLE email_notifications DROP COLUMN opened_at;
+ALTER TABLE email_notifications DROP COLUMN tracking_id;
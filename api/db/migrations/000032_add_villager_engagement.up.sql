@@ -0,0 +1,33 @@
+-- Open/click tracking for emails sent to village members
+ALTER TABLE email_notifications ADD COLUMN tracking_id TEXT;
+ALTER TABLE email_notifications ADD COLUMN opened_at DATETIME;
+ALTER TABLE email_notifications ADD COLUMN clicked_at DATETIME;
+
+CREATE UNIQUE INDEX idx_email_notifications_tracking_id ON email_notifications(tracking_id);
+
+-- Every timeline visit, email open and link click by a village member
+CREATE TABLE engagement_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    pregnancy_id INTEGER NOT NULL,
+    village_member_id INTEGER NOT NULL,
+    event_type TEXT NOT NULL CHECK (event_type IN ('timeline_visit', 'email_open', 'email_click')),
+    email_notification_id INTEGER,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (pregnancy_id) REFERENCES pregnancies(id) ON DELETE CASCADE,
+    FOREIGN KEY (village_member_id) REFERENCES village_members(id) ON DELETE CASCADE,
+    FOREIGN KEY (email_notification_id) REFERENCES email_notifications(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_engagement_events_member ON engagement_events(village_member_id, created_at);
+CREATE INDEX idx_engagement_events_pregnancy_id ON engagement_events(pregnancy_id);
+
+-- First time each member saw each update, on the timeline or through its email
+CREATE TABLE update_views (
+    update_id INTEGER NOT NULL,
+    village_member_id INTEGER NOT NULL,
+    source TEXT NOT NULL CHECK (source IN ('timeline', 'email')),
+    first_viewed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (update_id, village_member_id),
+    FOREIGN KEY (update_id) REFERENCES pregnancy_updates(id) ON DELETE CASCADE,
+    FOREIGN KEY (village_member_id) REFERENCES village_members(id) ON DELETE CASCADE
+);
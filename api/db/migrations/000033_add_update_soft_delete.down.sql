@@ -0,0 +1,3 @@
+ALTER TABLE pregnancy_events DROP COLUMN retracted_at;
+DROP INDEX IF EXISTS idx_pregnancy_updates_deleted_at;
+ALTER TABLE pregnancy_updates DROP COLUMN deleted_at;
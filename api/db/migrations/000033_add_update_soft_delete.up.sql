@@ -0,0 +1,7 @@
+-- Deleted updates sit in a recycle bin until they are purged
+ALTER TABLE pregnancy_updates ADD COLUMN deleted_at DATETIME;
+
+CREATE INDEX idx_pregnancy_updates_deleted_at ON pregnancy_updates(deleted_at);
+
+-- Events retracted along with their update (e.g. update_posted) are hidden but restorable
+ALTER TABLE pregnancy_events ADD COLUMN retracted_at DATETIME;
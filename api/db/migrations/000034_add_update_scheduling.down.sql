@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_pregnancy_updates_scheduled;
+ALTER TABLE pregnancy_updates DROP COLUMN publish_week;
+ALTER TABLE pregnancy_updates DROP COLUMN publish_at;
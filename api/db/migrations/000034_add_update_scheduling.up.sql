@@ -0,0 +1,6 @@
+-- Updates can be written ahead of time and shared automatically at a set time
+-- or when the pregnancy reaches a given week
+ALTER TABLE pregnancy_updates ADD COLUMN publish_at DATETIME;
+ALTER TABLE pregnancy_updates ADD COLUMN publish_week INTEGER;
+
+CREATE INDEX idx_pregnancy_updates_scheduled ON pregnancy_updates(is_shared, publish_at, publish_week);
@@ -0,0 +1,6 @@
+ALTER TABLE pregnancies DROP COLUMN cover_photo_renditions;
+
+ALTER TABLE update_photos DROP COLUMN processed_at;
+ALTER TABLE update_photos DROP COLUMN renditions;
+ALTER TABLE update_photos DROP COLUMN height;
+ALTER TABLE update_photos DROP COLUMN width;
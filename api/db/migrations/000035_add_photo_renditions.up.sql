@@ -0,0 +1,8 @@
+-- Resized copies of uploaded photos, generated in the background after upload.
+-- renditions is a JSON array of {size, filename, width, height}.
+ALTER TABLE update_photos ADD COLUMN width INTEGER;
+ALTER TABLE update_photos ADD COLUMN height INTEGER;
+ALTER TABLE update_photos ADD COLUMN renditions TEXT;
+ALTER TABLE update_photos ADD COLUMN processed_at DATETIME;
+
+ALTER TABLE pregnancies ADD COLUMN cover_photo_renditions TEXT;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_update_photos_captured_at;
+ALTER TABLE update_photos DROP COLUMN captured_at;
@@ -0,0 +1,4 @@
+-- When each photo or video was taken, read from EXIF or the video's movie header
+ALTER TABLE update_photos ADD COLUMN captured_at DATETIME;
+
+CREATE INDEX idx_update_photos_captured_at ON update_photos(captured_at);
@@ -0,0 +1,26 @@
+CREATE TABLE update_photos (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    update_id INTEGER NOT NULL,
+    filename TEXT NOT NULL,
+    original_filename TEXT NOT NULL,
+    file_size INTEGER,
+    caption TEXT,
+    sort_order INTEGER DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    width INTEGER,
+    height INTEGER,
+    renditions TEXT,
+    processed_at DATETIME,
+    captured_at DATETIME,
+    FOREIGN KEY (update_id) REFERENCES pregnancy_updates (id) ON DELETE CASCADE
+);
+
+INSERT INTO update_photos (id, update_id, filename, original_filename, file_size, caption, sort_order,
+                           created_at, width, height, renditions, processed_at, captured_at)
+SELECT id, update_id, filename, original_filename, file_size, caption, sort_order,
+       created_at, width, height, renditions, processed_at, captured_at
+FROM update_media;
+
+DROP TABLE update_media;
+
+CREATE INDEX idx_update_photos_captured_at ON update_photos(captured_at);
@@ -0,0 +1,45 @@
+-- Photos and videos attached to updates, replacing update_photos which held both without saying
+-- which was which
+CREATE TABLE update_media (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    update_id INTEGER NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('photo', 'video')),
+    mime_type TEXT NOT NULL,
+    filename TEXT NOT NULL,
+    original_filename TEXT NOT NULL,
+    file_size INTEGER,
+    width INTEGER,
+    height INTEGER,
+    duration_seconds REAL,
+    checksum TEXT,
+    caption TEXT,
+    sort_order INTEGER DEFAULT 0,
+    renditions TEXT,
+    processed_at DATETIME,
+    captured_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (update_id) REFERENCES pregnancy_updates (id) ON DELETE CASCADE
+);
+
+-- Existing rows are typed by extension, the only thing uploads were checked against. Their
+-- checksums are left empty for the background media job to fill in from the files.
+INSERT INTO update_media (id, update_id, kind, mime_type, filename, original_filename, file_size,
+                          width, height, caption, sort_order, renditions, processed_at, captured_at, created_at)
+SELECT id, update_id,
+       CASE WHEN lower(filename) LIKE '%.mp4' OR lower(filename) LIKE '%.mov' THEN 'video' ELSE 'photo' END,
+       CASE
+           WHEN lower(filename) LIKE '%.mp4' THEN 'video/mp4'
+           WHEN lower(filename) LIKE '%.mov' THEN 'video/quicktime'
+           WHEN lower(filename) LIKE '%.png' THEN 'image/png'
+           WHEN lower(filename) LIKE '%.gif' THEN 'image/gif'
+           WHEN lower(filename) LIKE '%.webp' THEN 'image/webp'
+           ELSE 'image/jpeg'
+       END,
+       filename, original_filename, file_size,
+       width, height, caption, sort_order, renditions, processed_at, captured_at, created_at
+FROM update_photos;
+
+DROP TABLE update_photos;
+
+CREATE INDEX idx_update_media_update_id ON update_media(update_id);
+CREATE INDEX idx_update_media_captured_at ON update_media(captured_at);
@@ -0,0 +1,17 @@
+-- Resumable uploads of large photos and videos, received in chunks before they are attached to
+-- an update. Abandoned uploads are removed once they expire.
+CREATE TABLE media_uploads (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    filename TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    received_size INTEGER NOT NULL DEFAULT 0,
+    checksum TEXT,
+    completed_at DATETIME,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_media_uploads_user_id ON media_uploads(user_id);
+CREATE INDEX idx_media_uploads_expires_at ON media_uploads(expires_at);
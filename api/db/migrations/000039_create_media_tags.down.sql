@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_media_tags_tag;
+DROP TABLE IF EXISTS media_tags;
@@ -0,0 +1,12 @@
+-- Tags on update photos and videos, built-in ones such as ultrasound and bump or the parents'
+-- own, for galleries that gather tagged media across updates
+CREATE TABLE media_tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    media_id INTEGER NOT NULL,
+    tag TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (media_id) REFERENCES update_media (id) ON DELETE CASCADE,
+    UNIQUE (media_id, tag)
+);
+
+CREATE INDEX idx_media_tags_tag ON media_tags(tag);
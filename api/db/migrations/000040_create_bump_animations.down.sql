@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_bump_animations_status;
+DROP INDEX IF EXISTS idx_bump_animations_pregnancy_id;
+DROP TABLE IF EXISTS bump_animations;
@@ -0,0 +1,19 @@
+-- Bump-progression animations, generated in the background from bump-tagged photos and
+-- posted as a new update when done
+CREATE TABLE bump_animations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    pregnancy_id INTEGER NOT NULL,
+    fps INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    frame_count INTEGER,
+    update_id INTEGER,
+    error TEXT,
+    started_at DATETIME,
+    completed_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (pregnancy_id) REFERENCES pregnancies (id),
+    FOREIGN KEY (update_id) REFERENCES pregnancy_updates (id)
+);
+
+CREATE INDEX idx_bump_animations_pregnancy_id ON bump_animations(pregnancy_id);
+CREATE INDEX idx_bump_animations_status ON bump_animations(status);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_update_comments_parent_id;
+DROP INDEX IF EXISTS idx_update_comments_update_id;
+DROP TABLE IF EXISTS update_comments;
+DROP INDEX IF EXISTS idx_update_reactions_update_id;
+DROP TABLE IF EXISTS update_reactions;
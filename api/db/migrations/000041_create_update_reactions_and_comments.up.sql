@@ -0,0 +1,36 @@
+-- Village members' emoji reactions to shared updates, one of each emoji per member
+CREATE TABLE update_reactions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    update_id INTEGER NOT NULL,
+    village_member_id INTEGER NOT NULL,
+    emoji TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (update_id) REFERENCES pregnancy_updates (id),
+    FOREIGN KEY (village_member_id) REFERENCES village_members (id),
+    UNIQUE (update_id, village_member_id, emoji)
+);
+
+CREATE INDEX idx_update_reactions_update_id ON update_reactions(update_id);
+
+-- Comments on shared updates, by a village member or one of the parents. Replies point to the
+-- comment that starts their thread. The author's name is kept so a comment outlives its
+-- author's removal from the village.
+CREATE TABLE update_comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    update_id INTEGER NOT NULL,
+    parent_id INTEGER,
+    village_member_id INTEGER,
+    user_id INTEGER,
+    author_name TEXT NOT NULL,
+    body TEXT NOT NULL,
+    hidden_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (update_id) REFERENCES pregnancy_updates (id),
+    FOREIGN KEY (parent_id) REFERENCES update_comments (id),
+    FOREIGN KEY (village_member_id) REFERENCES village_members (id),
+    FOREIGN KEY (user_id) REFERENCES users (id),
+    CHECK ((village_member_id IS NULL) != (user_id IS NULL))
+);
+
+CREATE INDEX idx_update_comments_update_id ON update_comments(update_id);
+CREATE INDEX idx_update_comments_parent_id ON update_comments(parent_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_guestbook_messages_pregnancy_id;
+DROP TABLE IF EXISTS guestbook_messages;
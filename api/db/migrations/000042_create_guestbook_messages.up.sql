@@ -0,0 +1,18 @@
+-- Messages villagers leave for the baby, each either shown on the timeline or kept hidden
+-- until the parents reveal it
+CREATE TABLE guestbook_messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    pregnancy_id INTEGER NOT NULL,
+    village_member_id INTEGER,
+    author_name TEXT NOT NULL,
+    body TEXT NOT NULL,
+    photo_filename TEXT,
+    photo_mime_type TEXT,
+    on_timeline BOOLEAN NOT NULL DEFAULT FALSE,
+    revealed_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (pregnancy_id) REFERENCES pregnancies (id),
+    FOREIGN KEY (village_member_id) REFERENCES village_members (id)
+);
+
+CREATE INDEX idx_guestbook_messages_pregnancy_id ON guestbook_messages(pregnancy_id);
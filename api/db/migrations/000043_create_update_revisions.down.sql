@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_update_revisions_update_id;
+DROP TABLE IF EXISTS update_revisions;
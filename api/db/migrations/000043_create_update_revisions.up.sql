@@ -0,0 +1,15 @@
+-- Earlier versions of updates. Each edit keeps the version it replaced, with who made the edit
+-- and when, so it can be compared with what followed or restored.
+CREATE TABLE update_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    update_id INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT,
+    is_shared BOOLEAN NOT NULL DEFAULT FALSE,
+    edited_by INTEGER,
+    edited_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (update_id) REFERENCES pregnancy_updates (id),
+    FOREIGN KEY (edited_by) REFERENCES users (id)
+);
+
+CREATE INDEX idx_update_revisions_update_id ON update_revisions(update_id);
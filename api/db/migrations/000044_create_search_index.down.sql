@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS search_index_comment_delete;
+DROP TRIGGER IF EXISTS search_index_comment_edit;
+DROP TRIGGER IF EXISTS search_index_comment_insert;
+DROP TRIGGER IF EXISTS search_index_caption_delete;
+DROP TRIGGER IF EXISTS search_index_caption_edit;
+DROP TRIGGER IF EXISTS search_index_caption_insert;
+DROP TRIGGER IF EXISTS search_index_event_delete;
+DROP TRIGGER IF EXISTS search_index_event_edit;
+DROP TRIGGER IF EXISTS search_index_event_insert;
+DROP TRIGGER IF EXISTS search_index_update_delete;
+DROP TRIGGER IF EXISTS search_index_update_edit;
+DROP TRIGGER IF EXISTS search_index_update_insert;
+DROP TABLE IF EXISTS search_index;
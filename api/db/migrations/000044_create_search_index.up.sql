@@ -0,0 +1,90 @@
+-- Full-text index over update titles and content, event titles and descriptions, photo and
+-- video captions and comments. Triggers keep it current as they are added, edited and deleted.
+-- Each row's rowid is its item's id times four plus a number for its kind, so a trigger can
+-- find it without scanning: 0 for updates, 1 for events, 2 for captions and 3 for comments.
+-- update_posted events are left out, since they repeat their update.
+CREATE VIRTUAL TABLE search_index USING fts5(
+    kind UNINDEXED,
+    item_id UNINDEXED,
+    title,
+    body,
+    tokenize = 'porter unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER search_index_update_insert AFTER INSERT ON pregnancy_updates BEGIN
+    INSERT INTO search_index (rowid, kind, item_id, title, body)
+    VALUES (new.id * 4, 'update', new.id, new.title, COALESCE(new.content, ''));
+END;
+
+CREATE TRIGGER search_index_update_edit AFTER UPDATE OF title, content ON pregnancy_updates BEGIN
+    DELETE FROM search_index WHERE rowid = old.id * 4;
+    INSERT INTO search_index (rowid, kind, item_id, title, body)
+    VALUES (new.id * 4, 'update', new.id, new.title, COALESCE(new.content, ''));
+END;
+
+CREATE TRIGGER search_index_update_delete AFTER DELETE ON pregnancy_updates BEGIN
+    DELETE FROM search_index WHERE rowid = old.id * 4;
+END;
+
+CREATE TRIGGER search_index_event_insert AFTER INSERT ON pregnancy_events
+WHEN new.event_type != 'update_posted' BEGIN
+    INSERT INTO search_index (rowid, kind, item_id, title, body)
+    VALUES (new.id * 4 + 1, 'event', new.id, new.event_title, COALESCE(new.event_description, ''));
+END;
+
+CREATE TRIGGER search_index_event_edit AFTER UPDATE OF event_type, event_title, event_description ON pregnancy_events BEGIN
+    DELETE FROM search_index WHERE rowid = old.id * 4 + 1;
+    INSERT INTO search_index (rowid, kind, item_id, title, body)
+    SELECT new.id * 4 + 1, 'event', new.id, new.event_title, COALESCE(new.event_description, '')
+    WHERE new.event_type != 'update_posted';
+END;
+
+CREATE TRIGGER search_index_event_delete AFTER DELETE ON pregnancy_events BEGIN
+    DELETE FROM search_index WHERE rowid = old.id * 4 + 1;
+END;
+
+CREATE TRIGGER search_index_caption_insert AFTER INSERT ON update_media
+WHEN COALESCE(new.caption, '') != '' BEGIN
+    INSERT INTO search_index (rowid, kind, item_id, title, body)
+    VALUES (new.id * 4 + 2, 'caption', new.id, '', new.caption);
+END;
+
+CREATE TRIGGER search_index_caption_edit AFTER UPDATE OF caption ON update_media BEGIN
+    DELETE FROM search_index WHERE rowid = old.id * 4 + 2;
+    INSERT INTO search_index (rowid, kind, item_id, title, body)
+    SELECT new.id * 4 + 2, 'caption', new.id, '', new.caption
+    WHERE COALESCE(new.caption, '') != '';
+END;
+
+CREATE TRIGGER search_index_caption_delete AFTER DELETE ON update_media BEGIN
+    DELETE FROM search_index WHERE rowid = old.id * 4 + 2;
+END;
+
+CREATE TRIGGER search_index_comment_insert AFTER INSERT ON update_comments BEGIN
+    INSERT INTO search_index (rowid, kind, item_id, title, body)
+    VALUES (new.id * 4 + 3, 'comment', new.id, '', new.body);
+END;
+
+CREATE TRIGGER search_index_comment_edit AFTER UPDATE OF body ON update_comments BEGIN
+    DELETE FROM search_index WHERE rowid = old.id * 4 + 3;
+    INSERT INTO search_index (rowid, kind, item_id, title, body)
+    VALUES (new.id * 4 + 3, 'comment', new.id, '', new.body);
+END;
+
+CREATE TRIGGER search_index_comment_delete AFTER DELETE ON update_comments BEGIN
+    DELETE FROM search_index WHERE rowid = old.id * 4 + 3;
+END;
+
+-- Index what is already there
+INSERT INTO search_index (rowid, kind, item_id, title, body)
+SELECT id * 4, 'update', id, title, COALESCE(content, '') FROM pregnancy_updates;
+
+INSERT INTO search_index (rowid, kind, item_id, title, body)
+SELECT id * 4 + 1, 'event', id, event_title, COALESCE(event_description, '')
+FROM pregnancy_events WHERE event_type != 'update_posted';
+
+INSERT INTO search_index (rowid, kind, item_id, title, body)
+SELECT id * 4 + 2, 'caption', id, '', caption FROM update_media WHERE COALESCE(caption, '') != '';
+
+INSERT INTO search_index (rowid, kind, item_id, title, body)
+SELECT id * 4 + 3, 'comment', id, '', body FROM update_comments;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_village_members_leader_claim_token;
+DROP INDEX IF EXISTS idx_village_members_leader_user_id;
+ALTER TABLE village_members DROP COLUMN leader_claim_token;
+ALTER TABLE village_members DROP COLUMN leader_user_id;
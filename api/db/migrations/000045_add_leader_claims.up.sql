@@ -0,0 +1,8 @@
+-- A leader's role belongs to the account that redeemed the claim link emailed to them when
+-- they were made a leader, not to whichever account has their email address. Leaders made
+-- before this have to be sent a new link.
+ALTER TABLE village_members ADD COLUMN leader_user_id INTEGER REFERENCES users (id);
+ALTER TABLE village_members ADD COLUMN leader_claim_token TEXT;
+
+CREATE INDEX idx_village_members_leader_user_id ON village_members(leader_user_id);
+CREATE UNIQUE INDEX idx_village_members_leader_claim_token ON village_members(leader_claim_token);
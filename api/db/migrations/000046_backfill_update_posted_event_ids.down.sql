@@ -0,0 +1,2 @@
+-- The backfilled update IDs are left in place: they can't be told apart from IDs recorded when
+-- the events were created, and nothing before this migration minds them being there.
@@ -0,0 +1,27 @@
+-- update_posted events from before the recycle bin only recorded the update's title, so deleting
+-- or purging an update couldn't find its event. Link each of them to the update it announced: one
+-- whose content (or title, when it had none) is the event's description, else the update shared
+-- within a minute of the event, preferring whichever was shared closest to the event.
+UPDATE pregnancy_events
+SET event_data = json_set(COALESCE(event_data, '{}'), '$.update_id', (
+    SELECT candidate.id
+    FROM (
+        SELECT pu.id,
+               COALESCE(NULLIF(pu.content, ''), pu.title) = pregnancy_events.event_description AS same_text,
+               ABS(julianday(COALESCE(pu.shared_at, pu.created_at)) - julianday(pregnancy_events.created_at)) * 86400 AS seconds_apart
+        FROM pregnancy_updates pu
+        WHERE pu.pregnancy_id = pregnancy_events.pregnancy_id
+    ) candidate
+    WHERE candidate.same_text OR candidate.seconds_apart <= 60
+    ORDER BY candidate.same_text DESC, candidate.seconds_apart, candidate.id
+    LIMIT 1
+))
+WHERE event_type = 'update_posted'
+  AND json_extract(event_data, '$.update_id') IS NULL
+  AND EXISTS (
+    SELECT 1
+    FROM pregnancy_updates pu
+    WHERE pu.pregnancy_id = pregnancy_events.pregnancy_id
+      AND (COALESCE(NULLIF(pu.content, ''), pu.title) = pregnancy_events.event_description
+           OR ABS(julianday(COALESCE(pu.shared_at, pu.created_at)) - julianday(pregnancy_events.created_at)) * 86400 <= 60)
+  );
@@ -0,0 +1 @@
+ALTER TABLE access_requests DROP COLUMN owner_notified_at;
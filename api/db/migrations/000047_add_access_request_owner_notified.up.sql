@@ -0,0 +1,3 @@
+-- When the parents were emailed about an access request, so a requester resubmitting a
+-- confirmation link that no longer matches a rule doesn't email them again each time
+ALTER TABLE access_requests ADD COLUMN owner_notified_at DATETIME;
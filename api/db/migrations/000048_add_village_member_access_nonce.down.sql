@@ -0,0 +1 @@
+ALTER TABLE village_members DROP COLUMN access_nonce;
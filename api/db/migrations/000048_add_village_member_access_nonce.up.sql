@@ -0,0 +1,6 @@
+-- A villager's own timeline link carries a token signed from this nonce, kept apart from the
+-- unsubscribe token that goes out in every email's List-Unsubscribe header. Only the nonce is
+-- stored, and replacing it turns off every link sent so far.
+ALTER TABLE village_members ADD COLUMN access_nonce TEXT;
+
+UPDATE village_members SET access_nonce = lower(hex(randomblob(16)));
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"simple-go/api/config"
+	"simple-go/api/db"
+)
+
+// AccessRequestLinkResponse describes the request a signed approve/deny link acts on
+type AccessRequestLinkResponse struct {
+	Action  string               `json:"action"`
+	Request *AccessRequestRecord `json:"request"`
+}
+
+// AccessRequestLinkHandler handles the signed approve/deny links from the owner's access request
+// email (/api/access-requests/{id}/{approve|deny}?sig=...), and the verify/vouch links that let a
+// request one of the parents' rules matched through. The link itself is the credential, so no
+// login is needed. GET describes the request for the confirmation page and POST carries out the
+// decision; keeping the decision off GET stops mail link scanners from approving people.
+func AccessRequestLinkHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/access-requests/")
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 {
+		http.Error(w, "Invalid URL path", http.StatusBadRequest)
+		return
+	}
+
+	requestID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, "Invalid request ID", http.StatusBadRequest)
+		return
+	}
+
+	action := parts[1]
+	confirming := action == accessConfirmVerify || action == accessConfirmVouch
+	if action != "approve" && action != "deny" && !confirming {
+		http.Error(w, "Action must be 'approve', 'deny', 'verify' or 'vouch'", http.StatusBadRequest)
+		return
+	}
+
+	// The nonce is only readable while the request is pending and unexpired, and the request is
+	// deleted once decided, so each link can only be used once
+	nonce, err := GetAccessRequestDecisionNonce(requestID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Access request not found or already processed", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error getting access request nonce: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if !validAccessRequestSignature(requestID, action, nonce, r.URL.Query().Get("sig")) {
+		http.Error(w, "Invalid or expired link", http.StatusForbidden)
+		return
+	}
+
+	req, _, err := GetPendingAccessRequestByID(requestID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Access request not found or already processed", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error getting access request: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		// The message was written for the parents, not the referrer
+		if action == accessConfirmVouch {
+			req.Message = ""
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AccessRequestLinkResponse{
+			Action:  action,
+			Request: req,
+		})
+
+	case http.MethodPost:
+		// The body is optional and only carries a personal note for the requester
+		var body ManageAccessRequestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if confirming {
+			confirmAccessRequest(w, req, action, nonce)
+			return
+		}
+
+		if err := ProcessAccessRequest(req, action, strings.TrimSpace(body.Note)); err != nil {
+			if errors.Is(err, ErrAccessRequestProcessed) {
+				http.Error(w, "Access request already processed", http.StatusConflict)
+				return
+			}
+			log.Printf("Error processing access request: %v", err)
+			http.Error(w, "Failed to add village member", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Access request %d decided via emailed link: %s", requestID, action)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "success",
+			"action":  action,
+			"message": action + "d successfully",
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// confirmAccessRequest approves a request once the requester or their referrer confirms it, as
+// long as a rule still lets it in that way. Otherwise it goes to the parents after all.
+func confirmAccessRequest(w http.ResponseWriter, req *AccessRequestRecord, action, nonce string) {
+	rule, err := FindMatchingAccessRule(req.PregnancyID, req.Email, req.Relationship, req.Referrer)
+	if err != nil {
+		log.Printf("Error checking access request rules: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if rule == nil || accessRuleConfirmation(rule) != action {
+		notifyOwnerOfAccessRequest(req, nonce)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "pending",
+			"action":  action,
+			"message": "The parents will review this request",
+		})
+		return
+	}
+
+	if err := ProcessAccessRequest(req, "approve", ""); err != nil {
+		if errors.Is(err, ErrAccessRequestProcessed) {
+			http.Error(w, "Access request already processed", http.StatusConflict)
+			return
+		}
+		log.Printf("Error processing access request: %v", err)
+		http.Error(w, "Failed to add village member", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Access request %d approved by %s rule %d after %s", req.ID, rule.RuleType, rule.ID, action)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"action":  action,
+		"message": "approved successfully",
+	})
+}
+
+// AccessRequestConfirmURL builds the signed link that confirms a rule-matched access request,
+// where action is accessConfirmVerify or accessConfirmVouch
+func AccessRequestConfirmURL(requestID int, action, nonce string) string {
+	baseURL := strings.TrimSuffix(config.AppConfig.BaseURL, "/")
+	return fmt.Sprintf("%s/access-request/%d/%s?sig=%s", baseURL, requestID, action, signAccessRequestDecision(requestID, action, nonce))
+}
+
+// AccessRequestDecisionURLs builds the signed approve and deny links for an access request
+func AccessRequestDecisionURLs(requestID int, nonce string) (string, string) {
+	baseURL := strings.TrimSuffix(config.AppConfig.BaseURL, "/")
+	approveURL := fmt.Sprintf("%s/access-request/%d/approve?sig=%s", baseURL, requestID, signAccessRequestDecision(requestID, "approve", nonce))
+	denyURL := fmt.Sprintf("%s/access-request/%d/deny?sig=%s", baseURL, requestID, signAccessRequestDecision(requestID, "deny", nonce))
+	return approveURL, denyURL
+}
+
+// signAccessRequestDecision signs one decision on one request, so an approve link can't be
+// turned into a deny link or pointed at another request
+func signAccessRequestDecision(requestID int, action, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(config.AppConfig.JWTSecret))
+	fmt.Fprintf(mac, "access-request:%d:%s:%s", requestID, action, nonce)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func validAccessRequestSignature(requestID int, action, nonce, signature string) bool {
+	if nonce == "" || signature == "" {
+		return false
+	}
+	expected := signAccessRequestDecision(requestID, action, nonce)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// Database functions
+
+// GetAccessRequestDecisionNonce returns the link secret for a pending, unexpired access request
+func GetAccessRequestDecisionNonce(requestID int) (string, error) {
+	var nonce string
+	err := db.GetDB().QueryRow(`
+		SELECT COALESCE(decision_nonce, '')
+		FROM access_requests
+		WHERE id = ? AND status = 'pending' AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+	`, requestID).Scan(&nonce)
+	if err != nil {
+		return "", err
+	}
+	return nonce, nil
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"testing"
+
+	"simple-go/api/config"
+)
+
+func TestSignAccessRequestDecision(t *testing.T) {
+	previous := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = previous })
+	config.AppConfig = &config.Config{JWTSecret: "test-secret"}
+	signature := signAccessRequestDecision(12, "approve", "nonce")
+
+	if again := signAccessRequestDecision(12, "approve", "nonce"); again != signature {
+		t.Errorf("Expected the same signature, got %s and %s", signature, again)
+	}
+	tampered := []byte(signature)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name      string
+		requestID int
+		action    string
+		nonce     string
+		signature string
+		expected  bool
+	}{
+		{"same decision", 12, "approve", "nonce", signature, true},
+		{"approve link used to deny", 12, "deny", "nonce", signature, false},
+		{"approve link used to verify", 12, "verify", "nonce", signature, false},
+		{"other request", 13, "approve", "nonce", signature, false},
+		{"new nonce", 12, "approve", "other-nonce", signature, false},
+		{"no nonce", 12, "approve", "", signAccessRequestDecision(12, "approve", ""), false},
+		{"no signature", 12, "approve", "nonce", "", false},
+		{"tampered signature", 12, "approve", "nonce", string(tampered), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := validAccessRequestSignature(tt.requestID, tt.action, tt.nonce, tt.signature); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+
+	// Signatures depend on the secret, so links stop working if it changes
+	config.AppConfig = &config.Config{JWTSecret: "other-secret"}
+	if validAccessRequestSignature(12, "approve", "nonce", signature) {
+		t.Error("Expected a signature from another secret to be rejected")
+	}
+}
@@ -4,15 +4,19 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"simple-go/api/db"
 	"simple-go/api/middleware"
 	"simple-go/api/models"
-	"simple-go/api/services/email"
+	emailservice "simple-go/api/services/email"
 )
 
 // AccessRequestRecord represents an access request from the database
@@ -23,11 +27,18 @@ type AccessRequestRecord struct {
 	Name         string `json:"name"`
 	Relationship string `json:"relationship"`
 	Message      string `json:"message"`
+	Referrer     string `json:"referrer"`
 	Status       string `json:"status"`
+	ExpiresAt    string `json:"expires_at"`
 	CreatedAt    string `json:"created_at"`
 	UpdatedAt    string `json:"updated_at"`
 }
 
+// ManageAccessRequestBody is the optional body when approving or denying an access request
+type ManageAccessRequestBody struct {
+	Note string `json:"note"` // personal note included in the email to the requester
+}
+
 // GetAccessRequestsHandler returns all pending access requests for the current user's pregnancy
 func GetAccessRequestsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -63,40 +74,12 @@ func GetAccessRequestsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get all pending access requests for this pregnancy
-	rows, err := db.GetDB().Query(`
-		SELECT id, pregnancy_id, email, name, relationship, message, status, created_at, updated_at
-		FROM access_requests
-		WHERE pregnancy_id = ? AND status = 'pending'
-		ORDER BY created_at DESC
-	`, pregnancyID)
-
+	requests, err := GetPendingAccessRequests(pregnancyID)
 	if err != nil {
 		log.Printf("Error getting access requests: %v", err)
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var requests []AccessRequestRecord
-	for rows.Next() {
-		var req AccessRequestRecord
-		err := rows.Scan(
-			&req.ID,
-			&req.PregnancyID,
-			&req.Email,
-			&req.Name,
-			&req.Relationship,
-			&req.Message,
-			&req.Status,
-			&req.CreatedAt,
-			&req.UpdatedAt,
-		)
-		if err != nil {
-			log.Printf("Error scanning access request: %v", err)
-			continue
-		}
-		requests = append(requests, req)
-	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(requests)
@@ -137,121 +120,249 @@ func ManageAccessRequestHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The body is optional and only carries a personal note for the requester
+	var body ManageAccessRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
 	// Get the access request and verify it belongs to the user's pregnancy
+	req, pregnancyUserID, err := GetPendingAccessRequestByID(requestID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Access request not found or already processed", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error getting access request: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Verify the request belongs to the authenticated user's pregnancy
+	if pregnancyUserID != userID {
+		http.Error(w, "Access denied: This request doesn't belong to your pregnancy", http.StatusForbidden)
+		return
+	}
+
+	if err := ProcessAccessRequest(req, action, strings.TrimSpace(body.Note)); err != nil {
+		if errors.Is(err, ErrAccessRequestProcessed) {
+			http.Error(w, "Access request already processed", http.StatusConflict)
+			return
+		}
+		log.Printf("Error processing access request: %v", err)
+		http.Error(w, "Failed to add village member", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"action": action,
+		"message": action + "d successfully",
+	})
+}
+
+// Database functions
+
+// GetPendingAccessRequests returns all pending access requests for a pregnancy
+func GetPendingAccessRequests(pregnancyID int) ([]AccessRequestRecord, error) {
+	rows, err := db.GetDB().Query(`
+		SELECT id, pregnancy_id, email, name, relationship, message, COALESCE(referrer, ''), status, COALESCE(expires_at, ''), created_at, updated_at
+		FROM access_requests
+		WHERE pregnancy_id = ? AND status = 'pending' AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+		ORDER BY created_at DESC
+	`, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []AccessRequestRecord
+	for rows.Next() {
+		var req AccessRequestRecord
+		err := rows.Scan(
+			&req.ID,
+			&req.PregnancyID,
+			&req.Email,
+			&req.Name,
+			&req.Relationship,
+			&req.Message,
+			&req.Referrer,
+			&req.Status,
+			&req.ExpiresAt,
+			&req.CreatedAt,
+			&req.UpdatedAt,
+		)
+		if err != nil {
+			log.Printf("Error scanning access request: %v", err)
+			continue
+		}
+		requests = append(requests, req)
+	}
+
+	return requests, nil
+}
+
+// GetPendingAccessRequestByID returns a pending, unexpired access request along with the
+// user ID of the pregnancy owner it was made to
+func GetPendingAccessRequestByID(requestID int) (*AccessRequestRecord, int, error) {
+	return getPendingAccessRequest(requestID, false)
+}
+
+// getPendingAccessRequest looks up a pending access request, leaving out expired ones unless
+// includeExpired is set
+func getPendingAccessRequest(requestID int, includeExpired bool) (*AccessRequestRecord, int, error) {
 	var req AccessRequestRecord
 	var pregnancyUserID int
-	err = db.GetDB().QueryRow(`
-		SELECT ar.id, ar.pregnancy_id, ar.email, ar.name, ar.relationship, ar.message, ar.status, ar.created_at, ar.updated_at, p.user_id
+	err := db.GetDB().QueryRow(`
+		SELECT ar.id, ar.pregnancy_id, ar.email, ar.name, ar.relationship, ar.message, COALESCE(ar.referrer, ''), ar.status, COALESCE(ar.expires_at, ''), ar.created_at, ar.updated_at, p.user_id
 		FROM access_requests ar
 		JOIN pregnancies p ON p.id = ar.pregnancy_id
-		WHERE ar.id = ? AND ar.status = 'pending'
-	`, requestID).Scan(
+		WHERE ar.id = ? AND ar.status = 'pending' AND (? OR ar.expires_at IS NULL OR ar.expires_at > CURRENT_TIMESTAMP)
+	`, requestID, includeExpired).Scan(
 		&req.ID,
 		&req.PregnancyID,
 		&req.Email,
 		&req.Name,
 		&req.Relationship,
 		&req.Message,
+		&req.Referrer,
 		&req.Status,
+		&req.ExpiresAt,
 		&req.CreatedAt,
 		&req.UpdatedAt,
 		&pregnancyUserID,
 	)
 
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Access request not found or already processed", http.StatusNotFound)
-			return
-		}
-		log.Printf("Error getting access request: %v", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
-		return
+		return nil, 0, err
 	}
 
-	// Verify the request belongs to the authenticated user's pregnancy
-	if pregnancyUserID != userID {
-		http.Error(w, "Access denied: This request doesn't belong to your pregnancy", http.StatusForbidden)
-		return
+	return &req, pregnancyUserID, nil
+}
+
+// ErrAccessRequestProcessed is returned when someone else approved, denied or expired the
+// access request first
+var ErrAccessRequestProcessed = errors.New("access request already processed")
+
+// ProcessAccessRequest approves or denies a pending access request. Approving adds the
+// requester to the village; either way the requester is emailed the outcome along with
+// the optional personal note.
+func ProcessAccessRequest(req *AccessRequestRecord, action string, note string) error {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	if action == "approve" {
-		// Add the person to the village
-		_, err = db.GetDB().Exec(`
-			INSERT INTO village_members (pregnancy_id, name, email, relationship, is_told, created_at, updated_at)
-			VALUES (?, ?, ?, ?, TRUE, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-		`, req.PregnancyID, req.Name, req.Email, req.Relationship)
+	// Claim the request before acting on it, so a link used twice or racing the dashboard
+	// can't add the member or email the requester a second time
+	result, err := tx.Exec(`DELETE FROM access_requests WHERE id = ? AND status = 'pending'`, req.ID)
+	if err != nil {
+		return fmt.Errorf("failed to claim access request: %w", err)
+	}
+	if claimed, err := result.RowsAffected(); err != nil {
+		return err
+	} else if claimed == 0 {
+		return ErrAccessRequestProcessed
+	}
 
+	var household *models.Household
+	if action == "approve" {
+		// Add the person to the village as their own household. They asked to join, so this
+		// is recorded like an invite join and the approval email below doubles as their welcome.
+		household, err = createHouseholdMembers(tx, req.PregnancyID, req.Name, []string{req.Email}, req.Relationship, true)
 		if err != nil {
-			log.Printf("Error adding village member: %v", err)
-			http.Error(w, "Failed to add village member", http.StatusInternalServerError)
-			return
+			return fmt.Errorf("failed to add village member: %w", err)
 		}
+	}
 
-		log.Printf("Access request approved: %s (%s) added to pregnancy %d village", req.Name, req.Email, req.PregnancyID)
+	if err := tx.Commit(); err != nil {
+		return err
+	}
 
-		// Send welcome email to the newly approved member
-		emailService, err := email.NewEmailService()
-		if err != nil {
-			log.Printf("Error creating email service: %v", err)
-		} else {
-			// Get full pregnancy details for the email
-			var pregnancy models.Pregnancy
-			err = db.GetDB().QueryRow(`
-				SELECT id, user_id, share_id, baby_name, partner_name, partner_email, due_date, created_at, updated_at, is_active, cover_photo_filename
-				FROM pregnancies
-				WHERE id = ?
-			`, req.PregnancyID).Scan(
-				&pregnancy.ID,
-				&pregnancy.UserID,
-				&pregnancy.ShareID,
-				&pregnancy.BabyName,
-				&pregnancy.PartnerName,
-				&pregnancy.PartnerEmail,
-				&pregnancy.DueDate,
-				&pregnancy.CreatedAt,
-				&pregnancy.UpdatedAt,
-				&pregnancy.IsActive,
-				&pregnancy.CoverPhotoFilename,
-			)
-
-			if err != nil {
-				log.Printf("Error getting pregnancy details for welcome email: %v", err)
-			} else {
-				// Create a village member struct from the access request
-				villageMember := &models.VillageMember{
-					PregnancyID:  req.PregnancyID,
-					Name:         req.Name,
-					Email:        req.Email,
-					Relationship: req.Relationship,
-				}
-
-				// Send welcome email asynchronously
-				go func() {
-					ctx := context.Background()
-					err := emailService.SendWelcomeEmail(ctx, villageMember, &pregnancy)
-					if err != nil {
-						log.Printf("Error sending welcome email to %s: %v", req.Email, err)
-					} else {
-						log.Printf("Welcome email sent to newly approved member: %s", req.Email)
-					}
-				}()
-			}
-		}
+	var member *models.VillageMember
+	outcome := models.AccessOutcomeDenied
+	if action == "approve" {
+		recordHouseholdJoined(req.PregnancyID, household, req.Name, req.Relationship, true, true)
+		member = &household.Members[0]
+		outcome = models.AccessOutcomeApproved
+		log.Printf("Access request approved: %s (%s) added to pregnancy %d village", req.Name, req.Email, req.PregnancyID)
 	} else {
 		log.Printf("Access request denied: %s (%s) for pregnancy %d", req.Name, req.Email, req.PregnancyID)
 	}
+	sendAccessRequestOutcome(req, member, outcome, note)
 
-	// Delete the access request (approved or denied)
-	_, err = db.GetDB().Exec(`DELETE FROM access_requests WHERE id = ?`, requestID)
+	return nil
+}
+
+// ExpireAccessRequests removes pending access requests nobody acted on in time and lets
+// the requesters know
+func ExpireAccessRequests() error {
+	rows, err := db.GetDB().Query(`
+		SELECT id FROM access_requests
+		WHERE status = 'pending' AND expires_at IS NOT NULL AND expires_at <= CURRENT_TIMESTAMP
+	`)
 	if err != nil {
-		log.Printf("Error deleting access request: %v", err)
-		// Don't fail the request if we can't delete - the action was successful
+		return err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "success",
-		"action": action,
-		"message": action + "d successfully",
-	})
-}
\ No newline at end of file
+	var requestIDs []int
+	for rows.Next() {
+		var requestID int
+		if err := rows.Scan(&requestID); err != nil {
+			rows.Close()
+			return err
+		}
+		requestIDs = append(requestIDs, requestID)
+	}
+	rows.Close()
+
+	for _, requestID := range requestIDs {
+		req, _, err := getPendingAccessRequest(requestID, true)
+		if err != nil {
+			log.Printf("Failed to get expired access request %d: %v", requestID, err)
+			continue
+		}
+
+		result, err := db.GetDB().Exec(`DELETE FROM access_requests WHERE id = ? AND status = 'pending'`, req.ID)
+		if err != nil {
+			log.Printf("Failed to delete expired access request %d: %v", req.ID, err)
+			continue
+		}
+		if claimed, err := result.RowsAffected(); err != nil || claimed == 0 {
+			// Decided just before it expired
+			continue
+		}
+
+		log.Printf("Access request expired: %s (%s) for pregnancy %d", req.Name, req.Email, req.PregnancyID)
+		sendAccessRequestOutcome(req, nil, models.AccessOutcomeExpired, "")
+	}
+
+	return nil
+}
+
+// sendAccessRequestOutcome emails the requester in the background to avoid blocking the response
+func sendAccessRequestOutcome(req *AccessRequestRecord, member *models.VillageMember, outcome, note string) {
+	go func() {
+		pregnancy, err := GetPregnancyByID(req.PregnancyID)
+		if err != nil {
+			log.Printf("Failed to get pregnancy for access request email: %v", err)
+			return
+		}
+
+		emailService, err := emailservice.NewEmailService()
+		if err != nil {
+			log.Printf("Failed to initialize email service for access request email: %v", err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		if err := emailService.SendAccessRequestOutcome(ctx, pregnancy, member, req.Name, req.Email, outcome, note); err != nil {
+			log.Printf("Failed to send %s access request email to %s: %v", outcome, req.Email, err)
+		}
+	}()
+}
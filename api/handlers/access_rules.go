@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"simple-go/api/db"
+	"simple-go/api/middleware"
+	"simple-go/api/models"
+)
+
+// CreateAccessRuleRequest represents a request to add an auto-approval rule
+type CreateAccessRuleRequest struct {
+	RuleType string `json:"rule_type"`
+	Value    string `json:"value"`
+}
+
+// AccessRulesHandler lists or adds the auto-approval rules for the user's pregnancy
+// (/api/village-members/access-rules)
+func AccessRulesHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get user's pregnancy (either as owner or partner)
+	pregnancy, err := GetActivePregnancyForUser(claims.UserID)
+	if err != nil {
+		log.Printf("Database error getting pregnancy: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if pregnancy == nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rules, err := GetAccessRequestRules(pregnancy.ID)
+		if err != nil {
+			log.Printf("Database error getting access rules: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		if rules == nil {
+			rules = []*models.AccessRequestRule{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rules)
+
+	case http.MethodPost:
+		var req CreateAccessRuleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		req.Value = strings.TrimSpace(req.Value)
+		if !models.IsValidAccessRuleType(req.RuleType) {
+			http.Error(w, "Rule type must be email_domain, referrer or relationship", http.StatusBadRequest)
+			return
+		}
+		if req.Value == "" {
+			http.Error(w, "Value is required", http.StatusBadRequest)
+			return
+		}
+
+		switch req.RuleType {
+		case models.AccessRuleEmailDomain:
+			req.Value = strings.TrimPrefix(strings.ToLower(req.Value), "@")
+			if req.Value == "" || strings.Contains(req.Value, "@") {
+				http.Error(w, "Invalid email domain", http.StatusBadRequest)
+				return
+			}
+
+		case models.AccessRuleReferrer:
+			// Referrer rules vouch for people referred by one of this village's members
+			memberID, err := strconv.Atoi(req.Value)
+			if err != nil {
+				http.Error(w, "Referrer rules need a village member ID", http.StatusBadRequest)
+				return
+			}
+			member, err := GetVillageMemberByID(memberID)
+			if err != nil && err != sql.ErrNoRows {
+				log.Printf("Database error getting village member: %v", err)
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			if member == nil || member.PregnancyID != pregnancy.ID {
+				http.Error(w, "Village member not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		rule, err := CreateAccessRequestRule(pregnancy.ID, req.RuleType, req.Value)
+		if err != nil {
+			log.Printf("Failed to create access rule: %v", err)
+			http.Error(w, "Failed to create access rule", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(rule)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// DeleteAccessRuleHandler removes an auto-approval rule (/api/village-members/access-rules/{id})
+func DeleteAccessRuleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ruleID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/village-members/access-rules/"))
+	if err != nil {
+		http.Error(w, "Invalid rule ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get user's pregnancy (either as owner or partner)
+	pregnancy, err := GetActivePregnancyForUser(claims.UserID)
+	if err != nil {
+		log.Printf("Database error getting pregnancy: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if pregnancy == nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	deleted, err := DeleteAccessRequestRule(ruleID, pregnancy.ID)
+	if err != nil {
+		log.Printf("Failed to delete access rule: %v", err)
+		http.Error(w, "Failed to delete access rule", http.StatusInternalServerError)
+		return
+	}
+
+	if !deleted {
+		http.Error(w, "Access rule not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Ways an access request that matches a rule is confirmed before the rule approves it. Rules
+// only look at what the requester typed, so someone has to prove it first.
+const (
+	accessConfirmVerify = "verify" // the requester confirms they own the email address
+	accessConfirmVouch  = "vouch"  // the member named as referrer confirms they referred them
+)
+
+// accessRuleConfirmation returns how a request matching the rule has to be confirmed
+func accessRuleConfirmation(rule *models.AccessRequestRule) string {
+	if rule.RuleType == models.AccessRuleReferrer {
+		return accessConfirmVouch
+	}
+	return accessConfirmVerify
+}
+
+// FindMatchingAccessRule returns the first rule that approves the request once it's confirmed,
+// or nil if the parents need to decide. The referrer is matched against existing members by name or email.
+func FindMatchingAccessRule(pregnancyID int, email, relationship, referrer string) (*models.AccessRequestRule, error) {
+	rules, err := GetAccessRequestRules(pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	var referrerMember *models.VillageMember
+	if referrer = strings.TrimSpace(referrer); referrer != "" {
+		members, err := GetVillageMembersByPregnancyID(pregnancyID)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			if strings.EqualFold(member.Email, referrer) || strings.EqualFold(member.Name, referrer) {
+				referrerMember = member
+				break
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		if rule.Matches(email, relationship, referrerMember) {
+			return rule, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Database functions
+
+func GetAccessRequestRules(pregnancyID int) ([]*models.AccessRequestRule, error) {
+	query := `
+		SELECT id, pregnancy_id, rule_type, value, created_at
+		FROM access_request_rules
+		WHERE pregnancy_id = ?
+		ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := db.GetDB().Query(query, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*models.AccessRequestRule
+	for rows.Next() {
+		rule := &models.AccessRequestRule{}
+		err := rows.Scan(
+			&rule.ID,
+			&rule.PregnancyID,
+			&rule.RuleType,
+			&rule.Value,
+			&rule.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func CreateAccessRequestRule(pregnancyID int, ruleType, value string) (*models.AccessRequestRule, error) {
+	query := `
+		INSERT INTO access_request_rules (pregnancy_id, rule_type, value)
+		VALUES (?, ?, ?)
+		RETURNING id, pregnancy_id, rule_type, value, created_at
+	`
+
+	rule := &models.AccessRequestRule{}
+	err := db.GetDB().QueryRow(query, pregnancyID, ruleType, value).Scan(
+		&rule.ID,
+		&rule.PregnancyID,
+		&rule.RuleType,
+		&rule.Value,
+		&rule.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func DeleteAccessRequestRule(ruleID, pregnancyID int) (bool, error) {
+	result, err := db.GetDB().Exec(`DELETE FROM access_request_rules WHERE id = ? AND pregnancy_id = ?`, ruleID, pregnancyID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
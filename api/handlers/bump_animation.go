@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-go/api/config"
+	"simple-go/api/db"
+	"simple-go/api/middleware"
+	"simple-go/api/models"
+	"simple-go/api/services/images"
+)
+
+// CreateBumpAnimationRequest optionally sets the frame rate of a bump animation
+type CreateBumpAnimationRequest struct {
+	FPS *int `json:"fps"`
+}
+
+// CreateBumpAnimationHandler queues an animation of the active pregnancy's bump-tagged photos,
+// one per week. It's generated in the background and posted as a new, private update.
+func CreateBumpAnimationHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateBumpAnimationRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	fps := config.AppConfig.BumpAnimationFPS
+	if req.FPS != nil {
+		fps = *req.FPS
+	}
+	if fps < images.MinAnimationFPS || fps > images.MaxAnimationFPS {
+		http.Error(w, fmt.Sprintf("fps must be between %d and %d", images.MinAnimationFPS, images.MaxAnimationFPS), http.StatusBadRequest)
+		return
+	}
+
+	pregnancyID, err := getActivePregnancyID(claims.UserID)
+	if err != nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	// One animation at a time; the next one would be made from the same photos
+	var inProgress int
+	err = db.GetDB().QueryRow(`
+		SELECT COUNT(*) FROM bump_animations WHERE pregnancy_id = ? AND status IN (?, ?)`,
+		pregnancyID, models.BumpAnimationPending, models.BumpAnimationProcessing).Scan(&inProgress)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if inProgress > 0 {
+		http.Error(w, "A bump animation is already being made", http.StatusConflict)
+		return
+	}
+
+	result, err := db.GetDB().Exec(`
+		INSERT INTO bump_animations (pregnancy_id, fps, status) VALUES (?, ?, ?)`,
+		pregnancyID, fps, models.BumpAnimationPending)
+	if err != nil {
+		log.Printf("Failed to queue bump animation: %v", err)
+		http.Error(w, "Failed to start bump animation", http.StatusInternalServerError)
+		return
+	}
+	id, _ := result.LastInsertId()
+
+	animation, err := getBumpAnimation(int(id), pregnancyID)
+	if err != nil {
+		http.Error(w, "Failed to fetch bump animation", http.StatusInternalServerError)
+		return
+	}
+
+	go processBumpAnimation(animation.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(animation)
+}
+
+// GetBumpAnimationsHandler lists the active pregnancy's bump animations, newest first
+func GetBumpAnimationsHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pregnancyID, err := getActivePregnancyID(claims.UserID)
+	if err != nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	animations, err := getBumpAnimations(pregnancyID)
+	if err != nil {
+		log.Printf("Failed to get bump animations: %v", err)
+		http.Error(w, "Failed to retrieve bump animations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"animations": animations,
+	})
+}
+
+// GetBumpAnimationHandler returns one of the active pregnancy's bump animations, to follow it
+// until it's done
+func GetBumpAnimationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/bump-animations/"), "/"))
+	if err != nil {
+		http.Error(w, "Invalid bump animation ID", http.StatusBadRequest)
+		return
+	}
+
+	pregnancyID, err := getActivePregnancyID(claims.UserID)
+	if err != nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	animation, err := getBumpAnimation(id, pregnancyID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Bump animation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(animation)
+}
+
+// ProcessPendingBumpAnimations generates the bump animations still waiting, such as ones
+// queued just before a restart or interrupted by one
+func ProcessPendingBumpAnimations() error {
+	rows, err := db.GetDB().Query(`
+		SELECT id FROM bump_animations
+		WHERE status = ? OR (status = ? AND started_at < ?)
+		ORDER BY id`,
+		models.BumpAnimationPending, models.BumpAnimationProcessing, time.Now().Add(-models.BumpAnimationTimeout))
+	if err != nil {
+		return err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		processBumpAnimation(id)
+	}
+	return nil
+}
+
+// processBumpAnimation generates a bump animation and posts it, recording why if it can't.
+// An animation another run has already claimed is left alone.
+func processBumpAnimation(id int) {
+	animation, claimed, err := claimBumpAnimation(id)
+	if err != nil {
+		log.Printf("Failed to claim bump animation %d: %v", id, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	updateID, frameCount, err := generateBumpAnimation(context.Background(), animation)
+	if err != nil {
+		log.Printf("Failed to generate bump animation %d: %v", id, err)
+		if err := failBumpAnimation(id, err.Error()); err != nil {
+			log.Printf("Failed to record failure of bump animation %d: %v", id, err)
+		}
+		return
+	}
+
+	if err := completeBumpAnimation(id, updateID, frameCount); err != nil {
+		log.Printf("Failed to complete bump animation %d: %v", id, err)
+	}
+}
+
+// generateBumpAnimation animates the latest bump-tagged photo of each week and posts the
+// animation as a new private update, for the parents to share when they're happy with it.
+// It returns the update and how many frames the animation has.
+func generateBumpAnimation(ctx context.Context, animation *models.BumpAnimation) (int, int, error) {
+	items, err := getGalleryItems(animation.PregnancyID, models.MediaTagBump, false)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Items come by week, so the last one seen of each week is its latest
+	var weeks []int
+	latest := map[int]models.GalleryItem{}
+	for _, item := range items {
+		if item.Kind != models.MediaKindPhoto || item.WeekNumber == nil || !images.IsProcessable(item.Filename) {
+			continue
+		}
+		week := *item.WeekNumber
+		if _, ok := latest[week]; !ok {
+			weeks = append(weeks, week)
+		}
+		latest[week] = item
+	}
+	if len(weeks) < 2 {
+		return 0, 0, fmt.Errorf("bump-tagged photos from at least two weeks are needed")
+	}
+
+	dir, err := os.MkdirTemp("", "bump-animation-")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	// The large rendition is plenty for a frame and much quicker to decode than the original
+	store := updateMediaStore(models.MediaKindPhoto)
+	var frames []images.AnimationFrame
+	for _, week := range weeks {
+		item := latest[week]
+		photoDir, photoPath, err := fetchToTemp(ctx, store, updateMediaKey(animation.PregnancyID, item.FilenameForSize(models.RenditionLarge)))
+		if err != nil {
+			log.Printf("Skipping bump photo %d in animation %d: %v", item.ID, animation.ID, err)
+			continue
+		}
+		defer os.RemoveAll(photoDir)
+
+		week := week
+		frames = append(frames, images.AnimationFrame{Path: photoPath, Week: &week})
+	}
+
+	gifPath := filepath.Join(dir, "bump-progression.gif")
+	file, err := os.Create(gifPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	frameCount, err := images.Animate(file, frames, animation.FPS)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return 0, 0, err
+	}
+
+	updateID, err := createBumpAnimationUpdate(animation.PregnancyID, weeks[0], weeks[len(weeks)-1], frameCount)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := saveMedia(ctx, file, "bump-progression.gif", animation.PregnancyID, updateID, 0); err != nil {
+		if _, delErr := db.GetDB().Exec(`DELETE FROM pregnancy_updates WHERE id = ?`, updateID); delErr != nil {
+			log.Printf("Failed to remove update %d of failed bump animation: %v", updateID, delErr)
+		}
+		return 0, 0, err
+	}
+	processUpdateMedia(updateID)
+
+	return updateID, frameCount, nil
+}
+
+// Database functions
+
+// createBumpAnimationUpdate creates the private update a bump animation is posted in
+func createBumpAnimationUpdate(pregnancyID, firstWeek, lastWeek, frameCount int) (int, error) {
+	var conceptionDate *time.Time
+	if err := db.GetDB().QueryRow(`SELECT conception_date FROM pregnancies WHERE id = ?`, pregnancyID).Scan(&conceptionDate); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	title := fmt.Sprintf("Bump progression: weeks %d to %d", firstWeek, lastWeek)
+	content := fmt.Sprintf("%d weeks of bump photos, one after another.", frameCount)
+
+	result, err := db.GetDB().Exec(`
+		INSERT INTO pregnancy_updates (pregnancy_id, week_number, title, content, update_type, is_shared, update_date)
+		VALUES (?, ?, ?, ?, ?, FALSE, ?)`,
+		pregnancyID, weekNumberForDate(conceptionDate, now), title, content, "general", &now)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// claimBumpAnimation marks an animation as processing, unless it's already being or been
+// processed, and returns it
+func claimBumpAnimation(id int) (*models.BumpAnimation, bool, error) {
+	result, err := db.GetDB().Exec(`
+		UPDATE bump_animations
+		SET status = ?, started_at = ?
+		WHERE id = ? AND (status = ? OR (status = ? AND started_at < ?))`,
+		models.BumpAnimationProcessing, time.Now(), id,
+		models.BumpAnimationPending, models.BumpAnimationProcessing, time.Now().Add(-models.BumpAnimationTimeout))
+	if err != nil {
+		return nil, false, err
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return nil, false, nil
+	}
+
+	var animation models.BumpAnimation
+	err = db.GetDB().QueryRow(`SELECT id, pregnancy_id, fps FROM bump_animations WHERE id = ?`, id).
+		Scan(&animation.ID, &animation.PregnancyID, &animation.FPS)
+	if err != nil {
+		return nil, false, err
+	}
+	return &animation, true, nil
+}
+
+func completeBumpAnimation(id, updateID, frameCount int) error {
+	_, err := db.GetDB().Exec(`
+		UPDATE bump_animations SET status = ?, update_id = ?, frame_count = ?, completed_at = ? WHERE id = ?`,
+		models.BumpAnimationDone, updateID, frameCount, time.Now(), id)
+	return err
+}
+
+func failBumpAnimation(id int, reason string) error {
+	_, err := db.GetDB().Exec(`
+		UPDATE bump_animations SET status = ?, error = ?, completed_at = ? WHERE id = ?`,
+		models.BumpAnimationFailed, reason, time.Now(), id)
+	return err
+}
+
+// getBumpAnimation fetches one of a pregnancy's bump animations, or sql.ErrNoRows
+func getBumpAnimation(id, pregnancyID int) (*models.BumpAnimation, error) {
+	var animation models.BumpAnimation
+	err := db.GetDB().QueryRow(`
+		SELECT id, pregnancy_id, fps, status, frame_count, update_id, error, started_at, completed_at, created_at
+		FROM bump_animations
+		WHERE id = ? AND pregnancy_id = ?`, id, pregnancyID).Scan(
+		&animation.ID, &animation.PregnancyID, &animation.FPS, &animation.Status, &animation.FrameCount,
+		&animation.UpdateID, &animation.Error, &animation.StartedAt, &animation.CompletedAt, &animation.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &animation, nil
+}
+
+// getBumpAnimations fetches a pregnancy's bump animations, newest first
+func getBumpAnimations(pregnancyID int) ([]models.BumpAnimation, error) {
+	rows, err := db.GetDB().Query(`
+		SELECT id, pregnancy_id, fps, status, frame_count, update_id, error, started_at, completed_at, created_at
+		FROM bump_animations
+		WHERE pregnancy_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT 20`, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	animations := []models.BumpAnimation{}
+	for rows.Next() {
+		var animation models.BumpAnimation
+		err := rows.Scan(&animation.ID, &animation.PregnancyID, &animation.FPS, &animation.Status, &animation.FrameCount,
+			&animation.UpdateID, &animation.Error, &animation.StartedAt, &animation.CompletedAt, &animation.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		animations = append(animations, animation)
+	}
+	return animations, rows.Err()
+}
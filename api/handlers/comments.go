@@ -0,0 +1,619 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-go/api/db"
+	"simple-go/api/middleware"
+	"simple-go/api/models"
+	"simple-go/api/services/email"
+)
+
+// UpdateFeedback is what the village has said about an update: its reactions and comments
+type UpdateFeedback struct {
+	Reactions    []models.ReactionCount `json:"reactions"`
+	CommentCount int                    `json:"comment_count"`
+	Comments     []models.UpdateComment `json:"comments"`
+}
+
+// CreateCommentRequest posts a comment, or a reply to the comment that starts a thread
+type CreateCommentRequest struct {
+	Body     string `json:"body"`
+	ParentID *int   `json:"parent_id"`
+}
+
+// ReactionRequest adds a reaction to an update
+type ReactionRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+// HideCommentRequest hides a comment and its replies from the village, or shows them again
+type HideCommentRequest struct {
+	Hidden bool `json:"hidden"`
+}
+
+// villagerUpdate is a shared update being viewed from the public timeline, and who by
+type villagerUpdate struct {
+	*timelineViewer
+	updateID int
+}
+
+// GetPublicUpdateCommentsHandler returns the reactions and visible comments of a shared update
+// to a village member: /api/timeline/{shareID}/updates/{id}/comments?member=
+func GetPublicUpdateCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	viewer, ok := resolveVillagerUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	writeUpdateFeedback(w, viewer.updateID, viewer.memberID, false, http.StatusOK)
+}
+
+// CreatePublicUpdateCommentHandler posts a village member's comment or reply on a shared update
+// and lets the parents know
+func CreatePublicUpdateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	viewer, ok := resolveVillagerUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	body, parentID, ok := decodeCommentRequest(w, r, viewer.updateID, false)
+	if !ok {
+		return
+	}
+
+	memberID := viewer.memberID
+	if _, err := createUpdateComment(viewer.updateID, parentID, &memberID, nil, viewer.name, body); err != nil {
+		log.Printf("Failed to save comment on update %d: %v", viewer.updateID, err)
+		http.Error(w, "Failed to save comment", http.StatusInternalServerError)
+		return
+	}
+
+	go notifyParentsOfFeedback(viewer.pregnancy, viewer.updateID, models.EmailTypeComment, viewer.name, body, "")
+
+	writeUpdateFeedback(w, viewer.updateID, viewer.memberID, false, http.StatusCreated)
+}
+
+// AddUpdateReactionHandler adds a village member's reaction to a shared update. The parents are
+// told about the member's first reaction to an update, not every emoji after it.
+func AddUpdateReactionHandler(w http.ResponseWriter, r *http.Request) {
+	viewer, ok := resolveVillagerUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	var req ReactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !models.IsReactionEmoji(req.Emoji) {
+		http.Error(w, "Unsupported reaction", http.StatusBadRequest)
+		return
+	}
+
+	first, err := addUpdateReaction(viewer.updateID, viewer.memberID, req.Emoji)
+	if err != nil {
+		log.Printf("Failed to save reaction on update %d: %v", viewer.updateID, err)
+		http.Error(w, "Failed to save reaction", http.StatusInternalServerError)
+		return
+	}
+	if first {
+		go notifyParentsOfFeedback(viewer.pregnancy, viewer.updateID, models.EmailTypeReaction, viewer.name, "", req.Emoji)
+	}
+
+	writeUpdateFeedback(w, viewer.updateID, viewer.memberID, false, http.StatusOK)
+}
+
+// RemoveUpdateReactionHandler takes back one of a village member's reactions: DELETE
+// /api/timeline/{shareID}/updates/{id}/reactions?member=&emoji=
+func RemoveUpdateReactionHandler(w http.ResponseWriter, r *http.Request) {
+	viewer, ok := resolveVillagerUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	emoji := r.URL.Query().Get("emoji")
+	if !models.IsReactionEmoji(emoji) {
+		http.Error(w, "Unsupported reaction", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.GetDB().Exec(`
+		DELETE FROM update_reactions WHERE update_id = ? AND village_member_id = ? AND emoji = ?`,
+		viewer.updateID, viewer.memberID, emoji); err != nil {
+		log.Printf("Failed to remove reaction on update %d: %v", viewer.updateID, err)
+		http.Error(w, "Failed to remove reaction", http.StatusInternalServerError)
+		return
+	}
+
+	writeUpdateFeedback(w, viewer.updateID, viewer.memberID, false, http.StatusOK)
+}
+
+// GetUpdateCommentsHandler returns the reactions and every comment of one of the parents'
+// updates, including hidden ones
+func GetUpdateCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	updateID, _, err := updateCommentIDsFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid update ID", http.StatusBadRequest)
+		return
+	}
+
+	if !requireOwnedUpdate(w, updateID, claims.UserID) {
+		return
+	}
+
+	writeUpdateFeedback(w, updateID, 0, true, http.StatusOK)
+}
+
+// CreateUpdateCommentHandler posts a parent's comment or reply on one of their updates
+func CreateUpdateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	updateID, _, err := updateCommentIDsFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid update ID", http.StatusBadRequest)
+		return
+	}
+
+	if !requireOwnedUpdate(w, updateID, claims.UserID) {
+		return
+	}
+
+	body, parentID, ok := decodeCommentRequest(w, r, updateID, true)
+	if !ok {
+		return
+	}
+
+	var name string
+	if err := db.GetDB().QueryRow(`SELECT name FROM users WHERE id = ?`, claims.UserID).Scan(&name); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	userID := claims.UserID
+	if _, err := createUpdateComment(updateID, parentID, nil, &userID, name, body); err != nil {
+		log.Printf("Failed to save comment on update %d: %v", updateID, err)
+		http.Error(w, "Failed to save comment", http.StatusInternalServerError)
+		return
+	}
+
+	writeUpdateFeedback(w, updateID, 0, true, http.StatusCreated)
+}
+
+// HideUpdateCommentHandler hides a comment on one of the parents' updates from the village,
+// along with its replies, or shows it again
+func HideUpdateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	updateID, commentID, err := updateCommentIDsFromPath(r.URL.Path)
+	if err != nil || commentID == 0 {
+		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		return
+	}
+
+	var req HideCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !requireOwnedUpdate(w, updateID, claims.UserID) {
+		return
+	}
+
+	var hiddenAt *time.Time
+	if req.Hidden {
+		now := time.Now()
+		hiddenAt = &now
+	}
+
+	result, err := db.GetDB().Exec(`UPDATE update_comments SET hidden_at = ? WHERE id = ? AND update_id = ?`, hiddenAt, commentID, updateID)
+	if err != nil {
+		log.Printf("Failed to hide comment %d: %v", commentID, err)
+		http.Error(w, "Failed to hide comment", http.StatusInternalServerError)
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+
+	writeUpdateFeedback(w, updateID, 0, true, http.StatusOK)
+}
+
+// DeleteUpdateCommentHandler deletes a comment on one of the parents' updates, along with its
+// replies
+func DeleteUpdateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	updateID, commentID, err := updateCommentIDsFromPath(r.URL.Path)
+	if err != nil || commentID == 0 {
+		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		return
+	}
+
+	if !requireOwnedUpdate(w, updateID, claims.UserID) {
+		return
+	}
+
+	deleted, err := deleteUpdateComment(commentID, updateID)
+	if err != nil {
+		log.Printf("Failed to delete comment %d: %v", commentID, err)
+		http.Error(w, "Failed to delete comment", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+
+	writeUpdateFeedback(w, updateID, 0, true, http.StatusOK)
+}
+
+// resolveVillagerUpdate checks the viewer of /api/timeline/{shareID}/updates/{id}/... is a
+// village member of the timeline and that the update is shared on it, writing the error if not
+func resolveVillagerUpdate(w http.ResponseWriter, r *http.Request) (*villagerUpdate, bool) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/timeline/"), "/"), "/")
+	if len(parts) != 4 || parts[0] == "" || parts[1] != "updates" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return nil, false
+	}
+	updateID, err := strconv.Atoi(parts[2])
+	if err != nil {
+		http.Error(w, "Invalid update ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	timeline, ok := resolveTimelineViewer(w, r, parts[0])
+	if !ok {
+		return nil, false
+	}
+
+	var isShared bool
+	err = db.GetDB().QueryRow(`
+		SELECT is_shared FROM pregnancy_updates WHERE id = ? AND pregnancy_id = ? AND deleted_at IS NULL`,
+		updateID, timeline.pregnancy.ID).Scan(&isShared)
+	if err == sql.ErrNoRows || (err == nil && !isShared) {
+		http.Error(w, "Update not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return &villagerUpdate{timelineViewer: timeline, updateID: updateID}, true
+}
+
+// requireOwnedUpdate checks an update is one of the user's, writing the error if not
+func requireOwnedUpdate(w http.ResponseWriter, updateID, userID int) bool {
+	_, err := getOwnedUpdatePregnancyID(updateID, userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Update not found or access denied", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// decodeCommentRequest reads and checks a new comment, writing the error if it isn't valid.
+// A reply must be to a comment on the same update that starts a thread, and a villager can't
+// reply to a hidden one.
+func decodeCommentRequest(w http.ResponseWriter, r *http.Request, updateID int, byParent bool) (string, *int, bool) {
+	var req CreateCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return "", nil, false
+	}
+
+	body := strings.TrimSpace(req.Body)
+	if body == "" {
+		http.Error(w, "Comment is required", http.StatusBadRequest)
+		return "", nil, false
+	}
+	if len([]rune(body)) > models.MaxCommentLength {
+		http.Error(w, fmt.Sprintf("Comments can be at most %d characters", models.MaxCommentLength), http.StatusBadRequest)
+		return "", nil, false
+	}
+
+	if req.ParentID != nil {
+		var parentOfParent *int
+		var hiddenAt *time.Time
+		err := db.GetDB().QueryRow(`
+			SELECT parent_id, hidden_at FROM update_comments WHERE id = ? AND update_id = ?`,
+			*req.ParentID, updateID).Scan(&parentOfParent, &hiddenAt)
+		if err == sql.ErrNoRows || (err == nil && hiddenAt != nil && !byParent) {
+			http.Error(w, "Comment to reply to not found", http.StatusNotFound)
+			return "", nil, false
+		}
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return "", nil, false
+		}
+		// Threads are one level deep, so a reply to a reply joins its thread
+		if parentOfParent != nil {
+			req.ParentID = parentOfParent
+		}
+	}
+
+	return body, req.ParentID, true
+}
+
+// updateCommentIDsFromPath reads the update ID, and the comment ID if there is one, from
+// /api/updates/{id}/comments[/{commentID}]
+func updateCommentIDsFromPath(urlPath string) (int, int, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(urlPath, "/api/updates/"), "/"), "/")
+	if len(parts) < 2 || parts[1] != "comments" {
+		return 0, 0, fmt.Errorf("not an update comments path: %s", urlPath)
+	}
+
+	updateID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(parts) < 3 {
+		return updateID, 0, nil
+	}
+
+	commentID, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return 0, 0, err
+	}
+	return updateID, commentID, nil
+}
+
+// writeUpdateFeedback responds with an update's reactions and comments after a change to
+// them, marking the viewer's own when memberID is set
+func writeUpdateFeedback(w http.ResponseWriter, updateID, memberID int, includeHidden bool, status int) {
+	feedback, err := getUpdateFeedback(updateID, memberID, includeHidden)
+	if err != nil {
+		log.Printf("Failed to get feedback for update %d: %v", updateID, err)
+		http.Error(w, "Failed to fetch comments", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(feedback)
+}
+
+// notifyParentsOfFeedback emails the parents about a comment or reaction on one of their updates
+func notifyParentsOfFeedback(pregnancy *models.Pregnancy, updateID int, emailType, authorName, body, reaction string) {
+	var title string
+	if err := db.GetDB().QueryRow(`SELECT title FROM pregnancy_updates WHERE id = ?`, updateID).Scan(&title); err != nil {
+		log.Printf("Failed to get update %d for %s notification: %v", updateID, emailType, err)
+		return
+	}
+
+	emailService, err := email.NewEmailService()
+	if err != nil {
+		log.Printf("Error creating email service: %v", err)
+		return
+	}
+	if err := emailService.SendFeedbackNotification(context.Background(), pregnancy, title, emailType, authorName, body, reaction); err != nil {
+		log.Printf("Error sending %s notification for update %d: %v", emailType, updateID, err)
+	}
+}
+
+// Database functions
+
+// getUpdateFeedback fetches an update's reaction counts and threaded comments. Hidden comments
+// and their replies are left out unless includeHidden is set.
+func getUpdateFeedback(updateID, memberID int, includeHidden bool) (*UpdateFeedback, error) {
+	reactions, err := getUpdateReactionCounts(updateID, memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := getUpdateComments(updateID, memberID, includeHidden)
+	if err != nil {
+		return nil, err
+	}
+
+	feedback := &UpdateFeedback{Reactions: reactions, Comments: comments}
+	for _, comment := range comments {
+		feedback.CommentCount += 1 + len(comment.Replies)
+	}
+	return feedback, nil
+}
+
+// getUpdateFeedbackCounts fetches an update's reaction counts and how many comments it has,
+// for listing it on a timeline
+func getUpdateFeedbackCounts(updateID, memberID int, includeHidden bool) ([]models.ReactionCount, int, error) {
+	reactions, err := getUpdateReactionCounts(updateID, memberID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := countUpdateComments(updateID, includeHidden)
+	if err != nil {
+		return nil, 0, err
+	}
+	return reactions, count, nil
+}
+
+// getUpdateReactionCounts counts an update's reactions by emoji, in the order they're offered.
+// The ones memberID left are marked as the viewer's.
+func getUpdateReactionCounts(updateID, memberID int) ([]models.ReactionCount, error) {
+	rows, err := db.GetDB().Query(`
+		SELECT emoji, COUNT(*), SUM(CASE WHEN village_member_id = ? THEN 1 ELSE 0 END)
+		FROM update_reactions
+		WHERE update_id = ?
+		GROUP BY emoji`, memberID, updateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]models.ReactionCount{}
+	for rows.Next() {
+		var count models.ReactionCount
+		var mine int
+		if err := rows.Scan(&count.Emoji, &count.Count, &mine); err != nil {
+			return nil, err
+		}
+		count.Reacted = mine > 0
+		counts[count.Emoji] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	reactions := []models.ReactionCount{}
+	for _, emoji := range models.ReactionEmojis {
+		if count, ok := counts[emoji]; ok {
+			reactions = append(reactions, count)
+		}
+	}
+	return reactions, nil
+}
+
+// countUpdateComments counts the comments on an update, leaving out hidden ones and their
+// replies unless includeHidden is set
+func countUpdateComments(updateID int, includeHidden bool) (int, error) {
+	var count int
+	err := db.GetDB().QueryRow(`
+		SELECT COUNT(*)
+		FROM update_comments c
+		LEFT JOIN update_comments thread ON thread.id = c.parent_id
+		WHERE c.update_id = ? AND (? OR (c.hidden_at IS NULL AND thread.hidden_at IS NULL))`,
+		updateID, includeHidden).Scan(&count)
+	return count, err
+}
+
+// getUpdateComments fetches an update's comments, oldest first, with each thread's replies
+// under the comment that starts it
+func getUpdateComments(updateID, memberID int, includeHidden bool) ([]models.UpdateComment, error) {
+	rows, err := db.GetDB().Query(`
+		SELECT id, update_id, parent_id, village_member_id, user_id, author_name, body, hidden_at, created_at
+		FROM update_comments
+		WHERE update_id = ?
+		ORDER BY created_at, id`, updateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []models.UpdateComment
+	for rows.Next() {
+		var comment models.UpdateComment
+		err := rows.Scan(&comment.ID, &comment.UpdateID, &comment.ParentID, &comment.VillageMemberID, &comment.UserID,
+			&comment.AuthorName, &comment.Body, &comment.HiddenAt, &comment.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if comment.HiddenAt != nil && !includeHidden {
+			continue
+		}
+		comment.ByParent = comment.UserID != nil
+		comment.IsMine = memberID != 0 && comment.VillageMemberID != nil && *comment.VillageMemberID == memberID
+		all = append(all, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Replies to a thread that's been left out are left out with it
+	comments := []models.UpdateComment{}
+	threads := map[int]int{}
+	for _, comment := range all {
+		if comment.ParentID == nil {
+			threads[comment.ID] = len(comments)
+			comments = append(comments, comment)
+		}
+	}
+	for _, comment := range all {
+		if comment.ParentID == nil {
+			continue
+		}
+		if i, ok := threads[*comment.ParentID]; ok {
+			comments[i].Replies = append(comments[i].Replies, comment)
+		}
+	}
+	return comments, nil
+}
+
+func createUpdateComment(updateID int, parentID, memberID, userID *int, authorName, body string) (int, error) {
+	result, err := db.GetDB().Exec(`
+		INSERT INTO update_comments (update_id, parent_id, village_member_id, user_id, author_name, body)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		updateID, parentID, memberID, userID, authorName, body)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// addUpdateReaction records a member's reaction, reporting whether it's their first reaction
+// to the update. Reacting with the same emoji twice changes nothing.
+func addUpdateReaction(updateID, memberID int, emoji string) (bool, error) {
+	var existing int
+	err := db.GetDB().QueryRow(`
+		SELECT COUNT(*) FROM update_reactions WHERE update_id = ? AND village_member_id = ?`,
+		updateID, memberID).Scan(&existing)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = db.GetDB().Exec(`
+		INSERT OR IGNORE INTO update_reactions (update_id, village_member_id, emoji) VALUES (?, ?, ?)`,
+		updateID, memberID, emoji)
+	if err != nil {
+		return false, err
+	}
+	return existing == 0, nil
+}
+
+// deleteUpdateComment deletes a comment on an update and its replies, reporting whether the
+// comment was found
+func deleteUpdateComment(commentID, updateID int) (bool, error) {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM update_comments WHERE parent_id = ? AND update_id = ?`, commentID, updateID); err != nil {
+		return false, err
+	}
+	result, err := tx.Exec(`DELETE FROM update_comments WHERE id = ? AND update_id = ?`, commentID, updateID)
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return false, nil
+	}
+	return true, tx.Commit()
+}
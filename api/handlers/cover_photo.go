@@ -3,15 +3,17 @@ package handlers
 import (
 	"fmt"
 	"io"
+	"log"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"simple-go/api/config"
 	"simple-go/api/db"
 	"simple-go/api/middleware"
+	"simple-go/api/services/storage"
 )
 
 // UploadCoverPhotoHandler handles uploading a cover photo for a pregnancy
@@ -57,16 +59,17 @@ func UploadCoverPhotoHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create covers directory if it doesn't exist
-	coversDir := filepath.Join(config.AppConfig.ImagesDirectory, "covers")
-	if err := os.MkdirAll(coversDir, 0755); err != nil {
-		http.Error(w, "Failed to create covers directory", http.StatusInternalServerError)
+	// The photo is sanitized on disk before it is stored
+	dir, err := os.MkdirTemp("", "cover-")
+	if err != nil {
+		http.Error(w, "Failed to create file", http.StatusInternalServerError)
 		return
 	}
+	defer os.RemoveAll(dir)
 
 	// Generate unique filename
 	filename := fmt.Sprintf("pregnancy_%d_cover_%d%s", pregnancyID, time.Now().Unix(), ext)
-	fullPath := filepath.Join(coversDir, filename)
+	fullPath := filepath.Join(dir, filename)
 
 	// Create the file
 	dst, err := os.Create(fullPath)
@@ -83,19 +86,35 @@ func UploadCoverPhotoHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Strip location and device metadata before the photo can be served
+	if _, err := sanitizeUploadedPhoto(dst, fullPath); err != nil {
+		log.Printf("Failed to sanitize cover photo for pregnancy %d: %v", pregnancyID, err)
+		http.Error(w, "Invalid image file", http.StatusBadRequest)
+		return
+	}
+
+	key := coverPhotoKey(filename)
+	if err := storeFile(r.Context(), storage.Images(), key, fullPath, mime.TypeByExtension(ext)); err != nil {
+		log.Printf("Failed to store cover photo for pregnancy %d: %v", pregnancyID, err)
+		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+		return
+	}
+
 	// Update pregnancy with new cover photo filename
 	_, err = db.GetDB().Exec(`
 		UPDATE pregnancies 
-		SET cover_photo_filename = ?, updated_at = ?
+		SET cover_photo_filename = ?, cover_photo_renditions = NULL, updated_at = ?
 		WHERE id = ?`,
 		filename, time.Now(), pregnancyID)
 	if err != nil {
 		// Clean up the file if database update fails
-		os.Remove(fullPath)
+		storage.Images().Delete(r.Context(), key)
 		http.Error(w, "Failed to update pregnancy", http.StatusInternalServerError)
 		return
 	}
 
+	go processCoverPhoto(pregnancyID, filename)
+
 	// Return success response
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(fmt.Sprintf(`{"success": true, "filename": "%s"}`, filename)))
@@ -125,15 +144,15 @@ func DeleteCoverPhotoHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Remove file if it exists
 	if currentFilename != nil && *currentFilename != "" {
-		coversDir := filepath.Join(config.AppConfig.ImagesDirectory, "covers")
-		fullPath := filepath.Join(coversDir, *currentFilename)
-		os.Remove(fullPath) // Ignore errors if file doesn't exist
+		key := coverPhotoKey(*currentFilename)
+		storage.Images().Delete(r.Context(), key) // Ignore errors if file doesn't exist
+		removeRenditions(r.Context(), storage.Images(), key)
 	}
 
 	// Update pregnancy to remove cover photo
 	_, err = db.GetDB().Exec(`
 		UPDATE pregnancies 
-		SET cover_photo_filename = NULL, updated_at = ?
+		SET cover_photo_filename = NULL, cover_photo_renditions = NULL, updated_at = ?
 		WHERE id = ?`,
 		time.Now(), pregnancyID)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"simple-go/api/db"
 	"simple-go/api/middleware"
@@ -116,7 +117,7 @@ func GetEmailNotificationsHandler(w http.ResponseWriter, r *http.Request) {
 		SELECT 
 			en.id, en.pregnancy_id, en.village_member_id, en.update_id, en.milestone_id,
 			en.email_type, en.subject, en.sent_at, en.delivery_status, en.ses_message_id,
-			en.created_at, vm.name as recipient_name, vm.email as recipient_email
+			en.opened_at, en.clicked_at, en.created_at, vm.name as recipient_name, vm.email as recipient_email
 		FROM email_notifications en
 		JOIN village_members vm ON en.village_member_id = vm.id
 		WHERE en.pregnancy_id = ?
@@ -147,6 +148,8 @@ func GetEmailNotificationsHandler(w http.ResponseWriter, r *http.Request) {
 			&notification.SentAt,
 			&notification.DeliveryStatus,
 			&notification.SESMessageID,
+			&notification.OpenedAt,
+			&notification.ClickedAt,
 			&notification.CreatedAt,
 			&recipientName,
 			&recipientEmail,
@@ -169,6 +172,8 @@ func GetEmailNotificationsHandler(w http.ResponseWriter, r *http.Request) {
 			"is_successful":    notification.IsSuccessful(),
 			"is_failed":        notification.IsFailed(),
 			"ses_message_id":   notification.SESMessageID,
+			"opened_at":        notification.OpenedAt,
+			"clicked_at":       notification.ClickedAt,
 			"created_at":       notification.CreatedAt,
 		}
 		
@@ -318,7 +323,7 @@ func SendUpdateNotificationHandler(w http.ResponseWriter, r *http.Request) {
 			p.partner_name, p.partner_email, p.share_id, p.is_active, p.created_at
 		FROM pregnancy_updates pu
 		JOIN pregnancies p ON pu.pregnancy_id = p.id
-		WHERE pu.id = ? AND p.user_id = ?
+		WHERE pu.id = ? AND p.user_id = ? AND pu.deleted_at IS NULL
 	`
 	
 	err := db.GetDB().QueryRow(query, req.UpdateID, claims.UserID).Scan(
@@ -371,4 +376,77 @@ func SendUpdateNotificationHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
-}
\ No newline at end of file
+}
+// SendDueDigests sends daily and weekly digests to members whose digest interval has elapsed
+func SendDueDigests() error {
+	rows, err := db.GetDB().Query(`
+		SELECT vm.id
+		FROM village_members vm
+		JOIN pregnancies p ON p.id = vm.pregnancy_id
+		WHERE vm.is_subscribed = TRUE AND vm.is_told = TRUE AND vm.delivery_frequency IN (?, ?)
+		AND vm.email != '' AND vm.email IS NOT NULL AND p.is_active = TRUE
+	`, models.DeliveryDailyDigest, models.DeliveryWeeklyDigest)
+	if err != nil {
+		return err
+	}
+
+	var memberIDs []int
+	for rows.Next() {
+		var memberID int
+		if err := rows.Scan(&memberID); err != nil {
+			rows.Close()
+			return err
+		}
+		memberIDs = append(memberIDs, memberID)
+	}
+	rows.Close()
+
+	if len(memberIDs) == 0 {
+		return nil
+	}
+
+	emailService, err := email.NewEmailService()
+	if err != nil {
+		return fmt.Errorf("failed to initialize email service: %w", err)
+	}
+
+	// Times are compared with timestamps SQLite stored in UTC
+	now := time.Now().UTC()
+	for _, memberID := range memberIDs {
+		member, err := GetVillageMemberByID(memberID)
+		if err != nil {
+			log.Printf("Failed to get village member %d for digest: %v", memberID, err)
+			continue
+		}
+
+		// The first digest covers everything since the member joined
+		since := member.CreatedAt
+		if member.LastDigestSentAt != nil {
+			since = *member.LastDigestSentAt
+		}
+		if now.Sub(since) < member.DigestInterval() {
+			continue
+		}
+
+		pregnancy, err := GetPregnancyByID(member.PregnancyID)
+		if err != nil {
+			log.Printf("Failed to get pregnancy %d for digest: %v", member.PregnancyID, err)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		_, err = emailService.SendDigest(ctx, member, pregnancy, since)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to send digest to member %d: %v", member.ID, err)
+			continue
+		}
+
+		// Advance even when there was nothing to send so the next digest covers the next period
+		if _, err := db.GetDB().Exec(`UPDATE village_members SET last_digest_sent_at = ? WHERE id = ?`, now, member.ID); err != nil {
+			log.Printf("Failed to record digest for member %d: %v", member.ID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"simple-go/api/db"
+	"simple-go/api/middleware"
+	"simple-go/api/models"
+	emailservice "simple-go/api/services/email"
+)
+
+// transparentGIF is the 1x1 image served for email open tracking
+var transparentGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// EngagementResponse is the parents' view of who follows along
+type EngagementResponse struct {
+	Members []*models.MemberEngagement `json:"members"`
+	Updates []*models.UpdateReach      `json:"updates"`
+}
+
+// GetEngagementHandler returns per-member activity and per-update reach for the user's pregnancy
+// (/api/village-members/engagement). Members are sorted with the most recently active first.
+func GetEngagementHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get user's pregnancy (either as owner or partner)
+	pregnancy, err := GetActivePregnancyForUser(claims.UserID)
+	if err != nil {
+		log.Printf("Database error getting pregnancy: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if pregnancy == nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	members, err := GetMemberEngagement(pregnancy.ID)
+	if err != nil {
+		log.Printf("Database error getting member engagement: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	updates, err := GetUpdateReach(pregnancy.ID)
+	if err != nil {
+		log.Printf("Database error getting update reach: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if members == nil {
+		members = []*models.MemberEngagement{}
+	}
+	if updates == nil {
+		updates = []*models.UpdateReach{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(EngagementResponse{
+		Members: members,
+		Updates: updates,
+	})
+}
+
+// EmailOpenHandler serves the open-tracking pixel embedded in villager emails
+// (/api/email/open/{trackingID}). It always returns the image so mail clients never show a broken one.
+func EmailOpenHandler(w http.ResponseWriter, r *http.Request) {
+	trackingID := strings.TrimPrefix(r.URL.Path, "/api/email/open/")
+	if err := recordEmailEngagement(trackingID, models.EngagementEmailOpen); err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to record email open: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Cache-Control", "no-store, max-age=0")
+	w.Write(transparentGIF)
+}
+
+// EmailClickHandler records a click on a link in a villager email and redirects to the link
+// (/api/email/click/{trackingID}?url=...&sig=...)
+func EmailClickHandler(w http.ResponseWriter, r *http.Request) {
+	trackingID := strings.TrimPrefix(r.URL.Path, "/api/email/click/")
+	target := r.URL.Query().Get("url")
+
+	if target == "" || !emailservice.ValidTrackedLink(trackingID, target, r.URL.Query().Get("sig")) {
+		http.Error(w, "Invalid link", http.StatusBadRequest)
+		return
+	}
+
+	if err := recordEmailEngagement(trackingID, models.EngagementEmailClick); err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to record email click: %v", err)
+	}
+
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// recordEmailEngagement marks a sent email as opened or clicked. A click also counts as an open,
+// and either one counts as seeing the update the email was about.
+func recordEmailEngagement(trackingID, eventType string) error {
+	if trackingID == "" || strings.Contains(trackingID, "/") {
+		return sql.ErrNoRows
+	}
+
+	var notificationID, pregnancyID, memberID int
+	var updateID *int
+	err := db.GetDB().QueryRow(`
+		SELECT id, pregnancy_id, village_member_id, update_id
+		FROM email_notifications
+		WHERE tracking_id = ?
+	`, trackingID).Scan(&notificationID, &pregnancyID, &memberID, &updateID)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE email_notifications SET opened_at = COALESCE(opened_at, CURRENT_TIMESTAMP) WHERE id = ?`
+	if eventType == models.EngagementEmailClick {
+		query = `
+			UPDATE email_notifications
+			SET opened_at = COALESCE(opened_at, CURRENT_TIMESTAMP), clicked_at = COALESCE(clicked_at, CURRENT_TIMESTAMP)
+			WHERE id = ?
+		`
+	}
+	if _, err := db.GetDB().Exec(query, notificationID); err != nil {
+		return fmt.Errorf("failed to update email notification: %w", err)
+	}
+
+	if err := RecordEngagementEvent(pregnancyID, memberID, eventType, &notificationID); err != nil {
+		return err
+	}
+
+	if updateID != nil {
+		return RecordUpdateView(*updateID, memberID, models.UpdateViewSourceEmail)
+	}
+	return nil
+}
+
+// recordTimelineVisit records a village member loading the public timeline and seeing its updates.
+// Only the first page counts as a visit; later pages just mark more updates as seen.
+func recordTimelineVisit(pregnancyID int, email string, items []PublicTimelineItem, offset int) error {
+	// Timeline access matches emails case-insensitively, so the lookup here does too
+	var memberID int
+	err := db.GetDB().QueryRow(`
+		SELECT id FROM village_members WHERE pregnancy_id = ? AND LOWER(email) = LOWER(?) LIMIT 1
+	`, pregnancyID, email).Scan(&memberID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// The parents viewing their own timeline
+			return nil
+		}
+		return err
+	}
+
+	if offset == 0 {
+		if err := RecordEngagementEvent(pregnancyID, memberID, models.EngagementTimelineVisit, nil); err != nil {
+			return err
+		}
+	}
+
+	for _, item := range items {
+		if err := RecordUpdateView(item.ID, memberID, models.UpdateViewSourceTimeline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseSQLiteTimestamp parses timestamps returned from aggregate queries, which SQLite hands
+// back as plain text
+func parseSQLiteTimestamp(value string) (time.Time, error) {
+	formats := []string{
+		time.RFC3339Nano,
+		"2006-01-02 15:04:05.999999999-07:00",
+		"2006-01-02 15:04:05",
+	}
+	for _, format := range formats {
+		if parsed, err := time.Parse(format, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", value)
+}
+
+// Database functions
+
+func RecordEngagementEvent(pregnancyID, memberID int, eventType string, notificationID *int) error {
+	_, err := db.GetDB().Exec(`
+		INSERT INTO engagement_events (pregnancy_id, village_member_id, event_type, email_notification_id)
+		VALUES (?, ?, ?, ?)
+	`, pregnancyID, memberID, eventType, notificationID)
+	if err != nil {
+		return fmt.Errorf("failed to record engagement event: %w", err)
+	}
+	return nil
+}
+
+// RecordUpdateView notes that a member has seen an update; only the first view is kept
+func RecordUpdateView(updateID, memberID int, source string) error {
+	_, err := db.GetDB().Exec(`
+		INSERT OR IGNORE INTO update_views (update_id, village_member_id, source)
+		VALUES (?, ?, ?)
+	`, updateID, memberID, source)
+	if err != nil {
+		return fmt.Errorf("failed to record update view: %w", err)
+	}
+	return nil
+}
+
+// GetMemberEngagement returns activity for every member of a village, most recently active first
+func GetMemberEngagement(pregnancyID int) ([]*models.MemberEngagement, error) {
+	query := `
+		SELECT vm.id, vm.name, vm.email, vm.relationship,
+			(SELECT MAX(ee.created_at) FROM engagement_events ee WHERE ee.village_member_id = vm.id) AS last_seen_at,
+			(SELECT COUNT(*) FROM engagement_events ee WHERE ee.village_member_id = vm.id AND ee.event_type = 'timeline_visit'),
+			(SELECT COUNT(*) FROM email_notifications en WHERE en.village_member_id = vm.id AND en.delivery_status != 'failed'),
+			(SELECT COUNT(*) FROM email_notifications en WHERE en.village_member_id = vm.id AND en.opened_at IS NOT NULL),
+			(SELECT COUNT(*) FROM email_notifications en WHERE en.village_member_id = vm.id AND en.clicked_at IS NOT NULL),
+			(SELECT COUNT(*) FROM update_views uv WHERE uv.village_member_id = vm.id)
+		FROM village_members vm
+		WHERE vm.pregnancy_id = ?
+		ORDER BY last_seen_at IS NULL, last_seen_at DESC, vm.name ASC
+	`
+
+	rows, err := db.GetDB().Query(query, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*models.MemberEngagement
+	for rows.Next() {
+		member := &models.MemberEngagement{}
+		var lastSeenAt sql.NullString
+		err := rows.Scan(
+			&member.MemberID,
+			&member.Name,
+			&member.Email,
+			&member.Relationship,
+			&lastSeenAt,
+			&member.TimelineVisits,
+			&member.EmailsSent,
+			&member.EmailOpens,
+			&member.EmailClicks,
+			&member.UpdatesViewed,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if lastSeenAt.Valid {
+			parsed, err := parseSQLiteTimestamp(lastSeenAt.String)
+			if err != nil {
+				return nil, err
+			}
+			member.LastSeenAt = &parsed
+		}
+
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+// GetUpdateReach returns, for each shared update, how many members were emailed it and how many saw it
+func GetUpdateReach(pregnancyID int) ([]*models.UpdateReach, error) {
+	query := `
+		SELECT pu.id, pu.title, pu.week_number, pu.created_at,
+			(SELECT COUNT(DISTINCT en.village_member_id) FROM email_notifications en WHERE en.update_id = pu.id AND en.delivery_status != 'failed'),
+			(SELECT COUNT(*) FROM update_views uv WHERE uv.update_id = pu.id)
+		FROM pregnancy_updates pu
+		WHERE pu.pregnancy_id = ? AND pu.is_shared = TRUE AND pu.deleted_at IS NULL
+		ORDER BY pu.created_at DESC, pu.id DESC
+	`
+
+	rows, err := db.GetDB().Query(query, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var updates []*models.UpdateReach
+	for rows.Next() {
+		update := &models.UpdateReach{}
+		err := rows.Scan(
+			&update.UpdateID,
+			&update.Title,
+			&update.WeekNumber,
+			&update.SharedAt,
+			&update.Emailed,
+			&update.Reach,
+		)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, update)
+	}
+
+	return updates, nil
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"simple-go/api/db"
 	"simple-go/api/middleware"
@@ -56,7 +57,7 @@ func (s *EventService) GetTimelineEvents(pregnancyID int, limit, offset int) ([]
 	query := `
 		SELECT id, pregnancy_id, event_type, event_title, event_description, event_data, week_number, created_at, created_by
 		FROM pregnancy_events 
-		WHERE pregnancy_id = ?
+		WHERE pregnancy_id = ? AND retracted_at IS NULL
 		ORDER BY created_at DESC, id DESC
 		LIMIT ? OFFSET ?
 	`
@@ -240,11 +241,13 @@ func CreateMilestoneReachedEvent(pregnancyID int, milestoneTitle string, weekNum
 	)
 }
 
-// CreateUpdateSharedEvent creates an event when a pregnancy update is shared
-func CreateUpdateSharedEvent(pregnancyID int, userID int, updateTitle, updateDescription string, weekNumber *int) error {
+// CreateUpdateSharedEvent creates an event when a pregnancy update is shared. The update ID is kept
+// in the event data so the event can be retracted if the update is deleted.
+func CreateUpdateSharedEvent(pregnancyID int, userID int, updateID int, updateTitle, updateDescription string, weekNumber *int) error {
 	eventService := NewEventService()
 	
 	eventData := map[string]interface{}{
+		"update_id":    updateID,
 		"update_title": updateTitle,
 	}
 
@@ -258,3 +261,78 @@ func CreateUpdateSharedEvent(pregnancyID int, userID int, updateTitle, updateDes
 		eventData,
 	)
 }
+
+// CreateLeaderPromotedEvent creates an event when a villager is made or removed as a village leader
+func CreateLeaderPromotedEvent(pregnancyID int, villagerName string, isLeader bool, userID int, weekNumber *int) error {
+	eventService := NewEventService()
+
+	eventData := map[string]interface{}{
+		"villager_name": villagerName,
+		"is_leader":     isLeader,
+	}
+
+	title := fmt.Sprintf("%s is now a village leader", villagerName)
+	description := fmt.Sprintf("%s can now help manage your village", villagerName)
+	if !isLeader {
+		title = fmt.Sprintf("%s is no longer a village leader", villagerName)
+		description = fmt.Sprintf("%s can no longer manage your village", villagerName)
+	}
+
+	return eventService.CreateEvent(
+		pregnancyID,
+		models.EventLeaderPromoted,
+		title,
+		description,
+		weekNumber,
+		&userID,
+		eventData,
+	)
+}
+
+// CreateLeaderActionEvent records an action a village leader took on the family's behalf
+func CreateLeaderActionEvent(pregnancyID int, leaderUserID int, leaderName, action, description string, weekNumber *int, details map[string]interface{}) error {
+	eventService := NewEventService()
+
+	eventData := map[string]interface{}{
+		"leader_name": leaderName,
+		"action":      action,
+	}
+	for key, value := range details {
+		eventData[key] = value
+	}
+
+	return eventService.CreateEvent(
+		pregnancyID,
+		models.EventLeaderAction,
+		fmt.Sprintf("%s %s", leaderName, description),
+		fmt.Sprintf("Village leader %s %s", leaderName, description),
+		weekNumber,
+		&leaderUserID,
+		eventData,
+	)
+}
+
+// CreateTellWaveCompletedEvent creates an event when the parents confirm a wave of their announcement plan
+func CreateTellWaveCompletedEvent(pregnancyID int, waveName string, villagerNames []string, userID int, weekNumber *int) error {
+	eventService := NewEventService()
+
+	eventData := map[string]interface{}{
+		"wave_name":      waveName,
+		"villager_names": villagerNames,
+	}
+
+	description := fmt.Sprintf("%d people now know about your pregnancy", len(villagerNames))
+	if len(villagerNames) > 0 {
+		description = fmt.Sprintf("%s now know about your pregnancy", strings.Join(villagerNames, ", "))
+	}
+
+	return eventService.CreateEvent(
+		pregnancyID,
+		models.EventTellWaveCompleted,
+		fmt.Sprintf("Told %s", waveName),
+		description,
+		weekNumber,
+		&userID,
+		eventData,
+	)
+}
@@ -0,0 +1,451 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"simple-go/api/db"
+	"simple-go/api/middleware"
+	"simple-go/api/models"
+	"simple-go/api/services/media"
+)
+
+// UpdateMediaTagsRequest sets every tag of a media item; an empty list clears them
+type UpdateMediaTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// UpdateMediaTagsHandler sets the tags of one of an update's photos or videos
+func UpdateMediaTagsHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	updateID, mediaID, err := updateMediaIDsFromPath(r.URL.Path)
+	if err != nil || mediaID == 0 {
+		http.Error(w, "Invalid media ID", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateMediaTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := normalizeMediaTags(req.Tags)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pregnancyID, err := getOwnedUpdatePregnancyID(updateID, claims.UserID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Update not found or access denied", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	item, err := getUpdateMediaItem(mediaID, updateID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if item == nil {
+		http.Error(w, "Media not found", http.StatusNotFound)
+		return
+	}
+
+	if err := setMediaTags(mediaID, tags); err != nil {
+		log.Printf("Failed to tag media %d: %v", mediaID, err)
+		http.Error(w, "Failed to save tags", http.StatusInternalServerError)
+		return
+	}
+
+	touchUpdate(updateID)
+	writeUpdateMedia(w, updateID, pregnancyID, http.StatusOK)
+}
+
+// GetGalleriesHandler lists the galleries of the parent's active pregnancy: every built-in
+// tag, and each custom tag in use, with how many photos and videos have it
+func GetGalleriesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pregnancyID, err := getActivePregnancyID(claims.UserID)
+	if err != nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	galleries, err := getGalleries(pregnancyID, false)
+	if err != nil {
+		log.Printf("Failed to get galleries: %v", err)
+		http.Error(w, "Failed to retrieve galleries", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"galleries": galleries,
+	})
+}
+
+// GetGalleryHandler lists the photos and videos of the parent's active pregnancy with a tag,
+// from every update whether shared or not, by week
+func GetGalleryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tag, ok := galleryTagFromPath(r.URL.Path)
+	if !ok {
+		http.Error(w, "Invalid tag", http.StatusBadRequest)
+		return
+	}
+
+	pregnancyID, err := getActivePregnancyID(claims.UserID)
+	if err != nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	items, err := getGalleryItems(pregnancyID, tag, false)
+	if err != nil {
+		log.Printf("Failed to get gallery %q: %v", tag, err)
+		http.Error(w, "Failed to retrieve gallery", http.StatusInternalServerError)
+		return
+	}
+	for i := range items {
+		signUpdateMedia(&items[i].UpdateMedia, pregnancyID, media.ScopePrivate)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tag":      tag,
+		"built_in": models.IsBuiltInMediaTag(tag),
+		"items":    items,
+	})
+}
+
+// PublicGalleryHandler serves the galleries of a shared timeline to a village member:
+// /api/timeline/{shareID}/galleries lists them and /api/timeline/{shareID}/galleries/{tag}
+// lists the media with a tag. Only media of shared updates is included.
+func PublicGalleryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/timeline/"), "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] != "galleries" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	shareID := parts[0]
+
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "Email parameter required", http.StatusBadRequest)
+		return
+	}
+
+	pregnancy, err := GetPregnancyByShareID(shareID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Pregnancy not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	hasAccess, err := verifyEmailAccess(pregnancy, email)
+	if err != nil {
+		log.Printf("Error verifying email access: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !hasAccess {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if len(parts) == 2 {
+		galleries, err := getGalleries(pregnancy.ID, true)
+		if err != nil {
+			log.Printf("Failed to get shared galleries: %v", err)
+			http.Error(w, "Failed to retrieve galleries", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"galleries": galleries,
+		})
+		return
+	}
+
+	tag, ok := normalizeGalleryTag(parts[2])
+	if !ok {
+		http.Error(w, "Invalid tag", http.StatusBadRequest)
+		return
+	}
+
+	items, err := getGalleryItems(pregnancy.ID, tag, true)
+	if err != nil {
+		log.Printf("Failed to get shared gallery %q: %v", tag, err)
+		http.Error(w, "Failed to retrieve gallery", http.StatusInternalServerError)
+		return
+	}
+	for i := range items {
+		signUpdateMedia(&items[i].UpdateMedia, pregnancy.ID, media.ScopeShared)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tag":      tag,
+		"built_in": models.IsBuiltInMediaTag(tag),
+		"items":    items,
+	})
+}
+
+// galleryTagFromPath reads the tag from /api/galleries/{tag}
+func galleryTagFromPath(urlPath string) (string, bool) {
+	return normalizeGalleryTag(strings.Trim(strings.TrimPrefix(urlPath, "/api/galleries/"), "/"))
+}
+
+// normalizeGalleryTag normalizes a tag taken from a URL path segment
+func normalizeGalleryTag(segment string) (string, bool) {
+	tag, err := url.PathUnescape(segment)
+	if err != nil || strings.Contains(tag, "/") {
+		return "", false
+	}
+	return models.NormalizeMediaTag(tag)
+}
+
+// normalizeMediaTags normalizes a list of tags, dropping duplicates
+func normalizeMediaTags(tags []string) ([]string, error) {
+	var normalized []string
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		n, ok := models.NormalizeMediaTag(tag)
+		if !ok {
+			return nil, fmt.Errorf("Invalid tag %q: tags are letters, numbers and hyphens, at most %d characters", tag, models.MaxMediaTagLength)
+		}
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		normalized = append(normalized, n)
+	}
+	if len(normalized) > models.MaxTagsPerMedia {
+		return nil, fmt.Errorf("A photo or video can have at most %d tags", models.MaxTagsPerMedia)
+	}
+	return normalized, nil
+}
+
+// Database functions
+
+// getActivePregnancyID returns the user's active pregnancy
+func getActivePregnancyID(userID int) (int, error) {
+	var pregnancyID int
+	err := db.GetDB().QueryRow(`
+		SELECT id FROM pregnancies
+		WHERE user_id = ? AND is_active = TRUE
+		ORDER BY created_at DESC LIMIT 1`,
+		userID).Scan(&pregnancyID)
+	return pregnancyID, err
+}
+
+// loadMediaTags fills in the tags of each media item
+func loadMediaTags(items []models.UpdateMedia) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(items))
+	args := make([]interface{}, len(items))
+	byID := map[int]*models.UpdateMedia{}
+	for i := range items {
+		placeholders[i] = "?"
+		args[i] = items[i].ID
+		items[i].Tags = []string{}
+		byID[items[i].ID] = &items[i]
+	}
+
+	rows, err := db.GetDB().Query(`
+		SELECT media_id, tag FROM media_tags
+		WHERE media_id IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY tag`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mediaID int
+		var tag string
+		if err := rows.Scan(&mediaID, &tag); err != nil {
+			return err
+		}
+		if item := byID[mediaID]; item != nil {
+			item.Tags = append(item.Tags, tag)
+		}
+	}
+	return rows.Err()
+}
+
+// setMediaTags replaces the tags of a media item
+func setMediaTags(mediaID int, tags []string) error {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM media_tags WHERE media_id = ?`, mediaID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec(`INSERT INTO media_tags (media_id, tag) VALUES (?, ?)`, mediaID, tag); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// getGalleries counts the media with each tag in a pregnancy's updates that aren't in the
+// recycle bin, or only its shared updates when sharedOnly is set. Built-in tags come first,
+// even when nothing has them yet, then custom tags by name.
+func getGalleries(pregnancyID int, sharedOnly bool) ([]models.Gallery, error) {
+	query := `
+		SELECT mt.tag, COUNT(*)
+		FROM media_tags mt
+		JOIN update_media um ON um.id = mt.media_id
+		JOIN pregnancy_updates pu ON pu.id = um.update_id
+		WHERE pu.pregnancy_id = ? AND pu.deleted_at IS NULL`
+	if sharedOnly {
+		query += ` AND pu.is_shared = TRUE`
+	}
+	query += ` GROUP BY mt.tag`
+
+	rows, err := db.GetDB().Query(query, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var tag string
+		var count int
+		if err := rows.Scan(&tag, &count); err != nil {
+			return nil, err
+		}
+		counts[tag] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var galleries []models.Gallery
+	for _, tag := range models.BuiltInMediaTags {
+		galleries = append(galleries, models.Gallery{Tag: tag, BuiltIn: true, Count: counts[tag]})
+	}
+	var custom []models.Gallery
+	for tag, count := range counts {
+		if !models.IsBuiltInMediaTag(tag) {
+			custom = append(custom, models.Gallery{Tag: tag, Count: count})
+		}
+	}
+	sort.Slice(custom, func(i, j int) bool { return custom[i].Tag < custom[j].Tag })
+	return append(galleries, custom...), nil
+}
+
+// getGalleryItems fetches the media with a tag in a pregnancy's updates that aren't in the
+// recycle bin, or only its shared updates when sharedOnly is set, ordered by week. Media of
+// updates without a week comes last.
+func getGalleryItems(pregnancyID int, tag string, sharedOnly bool) ([]models.GalleryItem, error) {
+	query := `
+		SELECT um.id, um.update_id, um.kind, um.mime_type, um.filename, um.original_filename, um.file_size,
+		       um.checksum, um.width, um.height, um.duration_seconds, um.caption, um.sort_order, um.renditions,
+		       um.captured_at, um.created_at, pu.title, pu.week_number, pu.update_date, pu.created_at, pu.is_shared
+		FROM media_tags mt
+		JOIN update_media um ON um.id = mt.media_id
+		JOIN pregnancy_updates pu ON pu.id = um.update_id
+		WHERE mt.tag = ? AND pu.pregnancy_id = ? AND pu.deleted_at IS NULL`
+	if sharedOnly {
+		query += ` AND pu.is_shared = TRUE`
+	}
+	query += `
+		ORDER BY pu.week_number IS NULL, pu.week_number, COALESCE(pu.update_date, pu.created_at), pu.id, um.sort_order`
+
+	rows, err := db.GetDB().Query(query, tag, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.GalleryItem{}
+	for rows.Next() {
+		var item models.GalleryItem
+		var updateDate sql.NullTime
+		var isShared bool
+		err := rows.Scan(&item.ID, &item.UpdateID, &item.Kind, &item.MIMEType, &item.Filename, &item.OriginalFilename,
+			&item.FileSize, &item.Checksum, &item.Width, &item.Height, &item.DurationSeconds, &item.Caption,
+			&item.SortOrder, &item.Renditions, &item.CapturedAt, &item.CreatedAt,
+			&item.UpdateTitle, &item.WeekNumber, &updateDate, &item.UpdateDate, &isShared)
+		if err != nil {
+			return nil, err
+		}
+		if updateDate.Valid {
+			item.UpdateDate = updateDate.Time
+		}
+		if !sharedOnly {
+			item.IsShared = &isShared
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	tagged := make([]models.UpdateMedia, len(items))
+	for i := range items {
+		tagged[i] = items[i].UpdateMedia
+	}
+	if err := loadMediaTags(tagged); err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Tags = tagged[i].Tags
+	}
+	return items, nil
+}
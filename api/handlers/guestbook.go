@@ -0,0 +1,536 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-go/api/config"
+	"simple-go/api/db"
+	"simple-go/api/middleware"
+	"simple-go/api/models"
+	"simple-go/api/services/media"
+	"simple-go/api/services/storage"
+)
+
+// CreateGuestbookMessageRequest is a village member's message for the baby, sent in the data
+// field of a multipart form with an optional photo
+type CreateGuestbookMessageRequest struct {
+	Body       string `json:"body"`
+	OnTimeline bool   `json:"on_timeline"`
+}
+
+// UpdateGuestbookMessageRequest reveals a message on the timeline, or hides it again
+type UpdateGuestbookMessageRequest struct {
+	OnTimeline bool `json:"on_timeline"`
+}
+
+// GetPublicGuestbookHandler returns the guestbook messages shown on a shared timeline to a
+// village member, along with their own hidden ones: /api/timeline/{shareID}/guestbook?member=
+func GetPublicGuestbookHandler(w http.ResponseWriter, r *http.Request) {
+	viewer, ok := resolveGuestbookViewer(w, r)
+	if !ok {
+		return
+	}
+
+	messages, err := getGuestbookMessages(viewer.pregnancy.ID, viewer.memberID, false)
+	if err != nil {
+		log.Printf("Failed to get guestbook for pregnancy %d: %v", viewer.pregnancy.ID, err)
+		http.Error(w, "Failed to retrieve guestbook", http.StatusInternalServerError)
+		return
+	}
+	for i := range messages {
+		signVillagerGuestbookPhoto(&messages[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"messages": messages,
+	})
+}
+
+// CreateGuestbookMessageHandler saves a village member's message for the baby, with the
+// photo in the photo form field if there is one
+func CreateGuestbookMessageHandler(w http.ResponseWriter, r *http.Request) {
+	viewer, ok := resolveGuestbookViewer(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, models.MaxGuestbookPhotoSize+(1<<20))
+	if err := r.ParseMultipartForm(models.MaxGuestbookPhotoSize); err != nil {
+		http.Error(w, "Failed to parse form data", http.StatusBadRequest)
+		return
+	}
+
+	var req CreateGuestbookMessageRequest
+	if err := json.Unmarshal([]byte(r.FormValue("data")), &req); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" {
+		http.Error(w, "Message is required", http.StatusBadRequest)
+		return
+	}
+	if len([]rune(req.Body)) > models.MaxGuestbookMessageLength {
+		http.Error(w, fmt.Sprintf("Messages can be at most %d characters", models.MaxGuestbookMessageLength), http.StatusBadRequest)
+		return
+	}
+
+	message := &models.GuestbookMessage{
+		PregnancyID:     viewer.pregnancy.ID,
+		VillageMemberID: &viewer.memberID,
+		AuthorName:      viewer.name,
+		Body:            req.Body,
+		OnTimeline:      req.OnTimeline,
+	}
+
+	file, fileHeader, err := r.FormFile("photo")
+	if err == nil {
+		defer file.Close()
+
+		filename, mimeType, err := storeGuestbookPhoto(r.Context(), file, viewer.pregnancy.ID)
+		if err == media.ErrUnsupportedType {
+			http.Error(w, "Photos must be JPEG, PNG, GIF or WebP", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to store guestbook photo %s for pregnancy %d: %v", fileHeader.Filename, viewer.pregnancy.ID, err)
+			http.Error(w, "Failed to save photo", http.StatusInternalServerError)
+			return
+		}
+		message.PhotoFilename = &filename
+		message.PhotoMIMEType = &mimeType
+	} else if err != http.ErrMissingFile {
+		http.Error(w, "Invalid photo", http.StatusBadRequest)
+		return
+	}
+
+	if err := createGuestbookMessage(message); err != nil {
+		if message.PhotoFilename != nil {
+			storage.Images().Delete(r.Context(), guestbookPhotoKey(message.PregnancyID, *message.PhotoFilename))
+		}
+		log.Printf("Failed to save guestbook message for pregnancy %d: %v", viewer.pregnancy.ID, err)
+		http.Error(w, "Failed to save message", http.StatusInternalServerError)
+		return
+	}
+
+	message.IsMine = true
+	signVillagerGuestbookPhoto(message)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(message)
+}
+
+// GetGuestbookHandler returns every message in the active pregnancy's guestbook, hidden ones
+// included
+func GetGuestbookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pregnancyID, err := getActivePregnancyID(claims.UserID)
+	if err != nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	messages, err := getGuestbookMessages(pregnancyID, 0, true)
+	if err != nil {
+		log.Printf("Failed to get guestbook for pregnancy %d: %v", pregnancyID, err)
+		http.Error(w, "Failed to retrieve guestbook", http.StatusInternalServerError)
+		return
+	}
+	for i := range messages {
+		signGuestbookPhoto(&messages[i], media.ScopePrivate, media.URLLifetime)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"messages": messages,
+	})
+}
+
+// UpdateGuestbookMessageHandler reveals a guestbook message on the timeline, or hides it again
+func UpdateGuestbookMessageHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	messageID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/guestbook/"))
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateGuestbookMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	pregnancyID, err := getActivePregnancyID(claims.UserID)
+	if err != nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	message, err := getGuestbookMessage(messageID, pregnancyID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Revealing is recorded so the parents can tell which messages were kept for later
+	if req.OnTimeline && !message.OnTimeline {
+		now := time.Now()
+		message.RevealedAt = &now
+	} else if !req.OnTimeline {
+		message.RevealedAt = nil
+	}
+	message.OnTimeline = req.OnTimeline
+
+	_, err = db.GetDB().Exec(`UPDATE guestbook_messages SET on_timeline = ?, revealed_at = ? WHERE id = ?`,
+		message.OnTimeline, message.RevealedAt, message.ID)
+	if err != nil {
+		log.Printf("Failed to update guestbook message %d: %v", message.ID, err)
+		http.Error(w, "Failed to update message", http.StatusInternalServerError)
+		return
+	}
+
+	signGuestbookPhoto(message, media.ScopePrivate, media.URLLifetime)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(message)
+}
+
+// DeleteGuestbookMessageHandler deletes a guestbook message and its photo
+func DeleteGuestbookMessageHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	messageID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/guestbook/"))
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	pregnancyID, err := getActivePregnancyID(claims.UserID)
+	if err != nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	message, err := getGuestbookMessage(messageID, pregnancyID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := db.GetDB().Exec(`DELETE FROM guestbook_messages WHERE id = ?`, message.ID); err != nil {
+		log.Printf("Failed to delete guestbook message %d: %v", message.ID, err)
+		http.Error(w, "Failed to delete message", http.StatusInternalServerError)
+		return
+	}
+
+	if message.PhotoFilename != nil {
+		if err := storage.Images().Delete(r.Context(), guestbookPhotoKey(pregnancyID, *message.PhotoFilename)); err != nil {
+			log.Printf("Failed to delete photo of guestbook message %d: %v", message.ID, err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// guestbookExportTemplate lays the guestbook out for printing, one message to a block that
+// isn't split across pages
+var guestbookExportTemplate = template.Must(template.New("guestbook").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+	body { font-family: Georgia, serif; color: #333; max-width: 720px; margin: 40px auto; padding: 0 24px; }
+	h1 { text-align: center; font-weight: normal; margin-bottom: 48px; }
+	.message { page-break-inside: avoid; break-inside: avoid; border-bottom: 1px solid #ddd; padding: 24px 0; }
+	.body { font-size: 18px; line-height: 1.6; white-space: pre-line; }
+	.author { margin-top: 12px; font-style: italic; }
+	.date { color: #888; font-size: 14px; }
+	img { display: block; max-width: 100%; max-height: 480px; margin: 0 auto 16px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Messages}}
+<div class="message">
+	{{if .PhotoURL}}<img src="{{.PhotoURL}}" alt="Photo from {{.AuthorName}}">{{end}}
+	<div class="body">{{.Body}}</div>
+	<div class="author">With love, {{.AuthorName}}</div>
+	<div class="date">{{.CreatedAt.Format "January 2, 2006"}}</div>
+</div>
+{{else}}
+<p>No one has signed the guestbook yet.</p>
+{{end}}
+</body>
+</html>
+`))
+
+// ExportGuestbookHandler exports every message in the guestbook, hidden ones included, for a
+// printed keepsake: a printable page by default, or JSON with format=json. Photo URLs are
+// absolute and last as long as emailed ones, since an export is kept and printed well after
+// it's made.
+func ExportGuestbookHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pregnancy, err := GetActivePregnancyForUser(claims.UserID)
+	if err != nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	messages, err := getGuestbookMessages(pregnancy.ID, 0, true)
+	if err != nil {
+		log.Printf("Failed to get guestbook for pregnancy %d: %v", pregnancy.ID, err)
+		http.Error(w, "Failed to retrieve guestbook", http.StatusInternalServerError)
+		return
+	}
+	for i := range messages {
+		signGuestbookPhoto(&messages[i], media.ScopePrivate, media.EmailURLLifetime)
+		if messages[i].PhotoURL != "" {
+			messages[i].PhotoURL = config.AppConfig.BaseURL + messages[i].PhotoURL
+		}
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"messages": messages,
+		})
+		return
+	}
+
+	title := "Messages for Baby"
+	if pregnancy.BabyName != nil && *pregnancy.BabyName != "" {
+		title = "Messages for " + *pregnancy.BabyName
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = guestbookExportTemplate.Execute(w, map[string]interface{}{
+		"Title":    title,
+		"Messages": messages,
+	})
+	if err != nil {
+		log.Printf("Failed to render guestbook export for pregnancy %d: %v", pregnancy.ID, err)
+	}
+}
+
+// ServeGuestbookPhoto serves the photo of a guestbook message to a request with a signed URL.
+// Shared URLs only work while the message is shown on the timeline, so hiding a message stops
+// its photo being served to the village.
+func ServeGuestbookPhoto(w http.ResponseWriter, r *http.Request, photoPath string) {
+	scope, err := media.VerifyURL(r.URL.Path, r.URL.Query())
+	if err != nil {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// Guestbook photos live at guestbook/{pregnancyID}/{filename}
+	parts := strings.Split(photoPath, "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	pregnancyID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var onTimeline bool
+	err = db.GetDB().QueryRow(`
+		SELECT on_timeline FROM guestbook_messages WHERE pregnancy_id = ? AND photo_filename = ?
+	`, pregnancyID, parts[1]).Scan(&onTimeline)
+	if err == sql.ErrNoRows || (err == nil && scope == media.ScopeShared && !onTimeline) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to check access to guestbook photo %s: %v", photoPath, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	serveStoredFile(w, r, storage.Images(), guestbookPhotoKey(pregnancyID, parts[1]), scope == media.ScopeShared, time.Until(media.ExpiresAt(r.URL.Query())))
+}
+
+// resolveGuestbookViewer checks the viewer of /api/timeline/{shareID}/guestbook can see the
+// timeline, writing the error if not
+func resolveGuestbookViewer(w http.ResponseWriter, r *http.Request) (*timelineViewer, bool) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/timeline/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "guestbook" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return nil, false
+	}
+	return resolveTimelineViewer(w, r, parts[0])
+}
+
+// guestbookPhotoKey returns the storage key of a guestbook photo
+func guestbookPhotoKey(pregnancyID int, filename string) string {
+	return fmt.Sprintf("guestbook/%d/%s", pregnancyID, filename)
+}
+
+// signGuestbookPhoto fills in the signed URL of a message's photo, if it has one
+func signGuestbookPhoto(message *models.GuestbookMessage, scope string, lifetime time.Duration) {
+	if message.PhotoFilename == nil {
+		return
+	}
+	message.PhotoURL = media.SignURL("/images/"+guestbookPhotoKey(message.PregnancyID, *message.PhotoFilename), scope, lifetime)
+}
+
+// signVillagerGuestbookPhoto signs the photo URL of a message listed for a village member. Only
+// the member who left a hidden message is shown it, so its photo gets a private URL that is
+// only cached by their browser and doesn't let anyone else see it.
+func signVillagerGuestbookPhoto(message *models.GuestbookMessage) {
+	scope := media.ScopeShared
+	if !message.OnTimeline {
+		scope = media.ScopePrivate
+	}
+	signGuestbookPhoto(message, scope, media.URLLifetime)
+}
+
+// storeGuestbookPhoto checks, sanitizes and stores a photo for a guestbook message, returning
+// its filename and MIME type
+func storeGuestbookPhoto(ctx context.Context, file io.ReadSeeker, pregnancyID int) (string, string, error) {
+	mimeType, kind, err := media.DetectFileType(file)
+	if err != nil {
+		return "", "", err
+	}
+	if kind != models.MediaKindPhoto {
+		return "", "", media.ErrUnsupportedType
+	}
+
+	dir, err := os.MkdirTemp("", "guestbook-")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.RemoveAll(dir)
+
+	filename := fmt.Sprintf("%d%s", time.Now().UnixNano(), media.Extension(mimeType))
+	localPath := filepath.Join(dir, filename)
+
+	dst, err := os.Create(localPath)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := io.Copy(dst, file); err != nil {
+		dst.Close()
+		return "", "", err
+	}
+
+	// Strip location and device metadata before the photo can be served
+	if _, err := sanitizeUploadedPhoto(dst, localPath); err != nil {
+		return "", "", fmt.Errorf("failed to sanitize: %w", err)
+	}
+
+	if err := storeFile(ctx, storage.Images(), guestbookPhotoKey(pregnancyID, filename), localPath, mimeType); err != nil {
+		return "", "", err
+	}
+	return filename, mimeType, nil
+}
+
+// Database functions
+
+func createGuestbookMessage(message *models.GuestbookMessage) error {
+	result, err := db.GetDB().Exec(`
+		INSERT INTO guestbook_messages (pregnancy_id, village_member_id, author_name, body, photo_filename, photo_mime_type, on_timeline, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		message.PregnancyID, message.VillageMemberID, message.AuthorName, message.Body,
+		message.PhotoFilename, message.PhotoMIMEType, message.OnTimeline, time.Now())
+	if err != nil {
+		return err
+	}
+
+	id, _ := result.LastInsertId()
+	return db.GetDB().QueryRow(`SELECT id, created_at FROM guestbook_messages WHERE id = ?`, id).Scan(&message.ID, &message.CreatedAt)
+}
+
+func getGuestbookMessage(messageID, pregnancyID int) (*models.GuestbookMessage, error) {
+	var message models.GuestbookMessage
+	err := db.GetDB().QueryRow(`
+		SELECT id, pregnancy_id, village_member_id, author_name, body, photo_filename, photo_mime_type,
+		       on_timeline, revealed_at, created_at
+		FROM guestbook_messages
+		WHERE id = ? AND pregnancy_id = ?`, messageID, pregnancyID).Scan(
+		&message.ID, &message.PregnancyID, &message.VillageMemberID, &message.AuthorName, &message.Body,
+		&message.PhotoFilename, &message.PhotoMIMEType, &message.OnTimeline, &message.RevealedAt, &message.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// getGuestbookMessages fetches a guestbook's messages, oldest first. Unless includeHidden is
+// set, only those shown on the timeline are returned, along with memberID's own hidden ones
+// when memberID is set.
+func getGuestbookMessages(pregnancyID, memberID int, includeHidden bool) ([]models.GuestbookMessage, error) {
+	rows, err := db.GetDB().Query(`
+		SELECT id, pregnancy_id, village_member_id, author_name, body, photo_filename, photo_mime_type,
+		       on_timeline, revealed_at, created_at
+		FROM guestbook_messages
+		WHERE pregnancy_id = ? AND (? OR on_timeline OR (? != 0 AND village_member_id = ?))
+		ORDER BY created_at, id`, pregnancyID, includeHidden, memberID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.GuestbookMessage{}
+	for rows.Next() {
+		var message models.GuestbookMessage
+		err := rows.Scan(&message.ID, &message.PregnancyID, &message.VillageMemberID, &message.AuthorName, &message.Body,
+			&message.PhotoFilename, &message.PhotoMIMEType, &message.OnTimeline, &message.RevealedAt, &message.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		message.IsMine = memberID != 0 && message.VillageMemberID != nil && *message.VillageMemberID == memberID
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"simple-go/api/db"
+	"simple-go/api/middleware"
+	"simple-go/api/models"
+)
+
+// GetHouseholdsHandler returns the village roster grouped by household
+func GetHouseholdsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get user's pregnancy (either as owner or partner)
+	pregnancy, err := GetActivePregnancyForUser(claims.UserID)
+	if err != nil {
+		log.Printf("Database error getting pregnancy: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if pregnancy == nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	households, err := GetHouseholdsByPregnancyID(pregnancy.ID)
+	if err != nil {
+		log.Printf("Database error getting households: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Ensure we return an empty array instead of null when no households exist
+	if households == nil {
+		households = []*models.Household{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(households)
+}
+
+// GetVillageStatsHandler returns village summary statistics counted per household
+func GetVillageStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get user's pregnancy (either as owner or partner)
+	pregnancy, err := GetActivePregnancyForUser(claims.UserID)
+	if err != nil {
+		log.Printf("Database error getting pregnancy: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if pregnancy == nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	stats, err := GetVillageStats(pregnancy.ID)
+	if err != nil {
+		log.Printf("Database error getting village stats: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// Database functions
+
+func CreateHousehold(tx *sql.Tx, pregnancyID int, name, relationship string) (*models.Household, error) {
+	query := `
+		INSERT INTO households (pregnancy_id, name, relationship)
+		VALUES (?, ?, ?)
+		RETURNING id, pregnancy_id, name, relationship, created_at, updated_at
+	`
+
+	var household models.Household
+	err := tx.QueryRow(query, pregnancyID, name, relationship).Scan(
+		&household.ID,
+		&household.PregnancyID,
+		&household.Name,
+		&household.Relationship,
+		&household.CreatedAt,
+		&household.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &household, nil
+}
+
+// GetHouseholdsByPregnancyID returns all households for a pregnancy with their member addresses
+func GetHouseholdsByPregnancyID(pregnancyID int) ([]*models.Household, error) {
+	query := `
+		SELECT id, pregnancy_id, name, relationship, created_at, updated_at
+		FROM households
+		WHERE pregnancy_id = ?
+		ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := db.GetDB().Query(query, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var households []*models.Household
+	byID := make(map[int]*models.Household)
+	for rows.Next() {
+		var household models.Household
+		err := rows.Scan(
+			&household.ID,
+			&household.PregnancyID,
+			&household.Name,
+			&household.Relationship,
+			&household.CreatedAt,
+			&household.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		household.Members = []models.VillageMember{}
+		households = append(households, &household)
+		byID[household.ID] = &household
+	}
+
+	members, err := GetVillageMembersByPregnancyID(pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range members {
+		if member.HouseholdID == nil {
+			continue
+		}
+		if household, ok := byID[*member.HouseholdID]; ok {
+			household.Members = append(household.Members, *member)
+		}
+	}
+
+	return households, nil
+}
+
+// GetVillageStats counts households rather than individual addresses so a
+// couple sharing one household is counted once
+func GetVillageStats(pregnancyID int) (*models.VillageStats, error) {
+	query := `
+		SELECT
+			COUNT(*) as total_members,
+			COALESCE(SUM(is_told), 0) as told_members,
+			COALESCE(SUM(is_subscribed), 0) as subscribed_members,
+			COALESCE(SUM(addresses), 0) as total_addresses
+		FROM (
+			SELECT
+				COALESCE('h' || household_id, 'm' || id) as household_key,
+				MAX(is_told) as is_told,
+				MAX(is_subscribed) as is_subscribed,
+				COUNT(*) as addresses
+			FROM village_members
+			WHERE pregnancy_id = ?
+			GROUP BY household_key
+		)
+	`
+
+	var stats models.VillageStats
+	err := db.GetDB().QueryRow(query, pregnancyID).Scan(
+		&stats.TotalMembers,
+		&stats.ToldMembers,
+		&stats.SubscribedMembers,
+		&stats.TotalAddresses,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.PendingMembers = stats.TotalMembers - stats.ToldMembers
+
+	return &stats, nil
+}
+
+// DeleteHouseholdIfEmpty removes a household that no longer has any addresses
+func DeleteHouseholdIfEmpty(householdID int) error {
+	query := `
+		DELETE FROM households
+		WHERE id = ? AND NOT EXISTS (SELECT 1 FROM village_members WHERE household_id = ?)
+	`
+	_, err := db.GetDB().Exec(query, householdID, householdID)
+	return err
+}
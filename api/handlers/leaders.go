@@ -0,0 +1,596 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-go/api/db"
+	"simple-go/api/middleware"
+	"simple-go/api/models"
+	emailservice "simple-go/api/services/email"
+)
+
+// SetVillageLeaderRequest represents a request to promote or demote a village leader
+type SetVillageLeaderRequest struct {
+	IsLeader bool `json:"is_leader"`
+}
+
+// ClaimLeaderRequest redeems the claim link emailed to a new village leader
+type ClaimLeaderRequest struct {
+	Token string `json:"token"`
+}
+
+// LedVillage describes a village the current user helps manage as a leader
+type LedVillage struct {
+	PregnancyID int    `json:"pregnancy_id"`
+	MemberID    int    `json:"member_id"`
+	ParentNames string `json:"parent_names"`
+	BabyName    string `json:"baby_name"`
+	DueDate     string `json:"due_date"`
+	CurrentWeek int    `json:"current_week"`
+}
+
+// SetVillageLeaderHandler lets the parents promote or demote a village member as a leader
+func SetVillageLeaderHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Extract member ID from URL path (/api/village-members/{id}/leader)
+	path := strings.TrimPrefix(r.URL.Path, "/api/village-members/")
+	memberID, err := strconv.Atoi(strings.TrimSuffix(path, "/leader"))
+	if err != nil {
+		http.Error(w, "Invalid member ID", http.StatusBadRequest)
+		return
+	}
+
+	var req SetVillageLeaderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Get user's pregnancy (either as owner or partner)
+	pregnancy, err := GetActivePregnancyForUser(claims.UserID)
+	if err != nil {
+		log.Printf("Database error getting pregnancy: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if pregnancy == nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	// Verify the member belongs to this user's pregnancy
+	member, err := GetVillageMemberByID(memberID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Village member not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Database error getting village member: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if member.PregnancyID != pregnancy.ID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	updatedMember, claimToken, err := SetVillageMemberLeader(memberID, req.IsLeader)
+	if err != nil {
+		log.Printf("Failed to update village leader: %v", err)
+		http.Error(w, "Failed to update village member", http.StatusInternalServerError)
+		return
+	}
+
+	// The role only reaches an account once the member redeems the link sent to their email,
+	// so promoting someone who hasn't claimed it yet sends a fresh link
+	if claimToken != "" {
+		go sendLeaderInvite(pregnancy, updatedMember, claimToken)
+	}
+
+	if member.IsLeader != req.IsLeader {
+		weekNumber := pregnancy.GetCurrentWeek()
+		if err := CreateLeaderPromotedEvent(pregnancy.ID, member.Name, req.IsLeader, claims.UserID, &weekNumber); err != nil {
+			log.Printf("Failed to create leader promoted event: %v", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updatedMember)
+}
+
+// ClaimLeaderHandler gives the signed-in account the leader role of the village member whose
+// emailed claim link it was opened from (POST /api/leader/claim)
+func ClaimLeaderHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req ClaimLeaderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Token = strings.TrimSpace(req.Token)
+	if req.Token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+
+	memberID, err := ClaimVillageLeader(req.Token, claims.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "This leader link is invalid or has already been used", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to claim village leader: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	member, err := GetVillageMemberByID(memberID)
+	if err != nil {
+		log.Printf("Database error getting village member: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	villages, err := GetLedVillagesForUser(claims.UserID)
+	if err != nil {
+		log.Printf("Database error getting led villages: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	for _, village := range villages {
+		if village.PregnancyID == member.PregnancyID {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(village)
+			return
+		}
+	}
+
+	http.Error(w, "This village is no longer active", http.StatusNotFound)
+}
+
+// GetLedVillagesHandler returns the villages the current user is a leader of
+func GetLedVillagesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	villages, err := GetLedVillagesForUser(claims.UserID)
+	if err != nil {
+		log.Printf("Database error getting led villages: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if villages == nil {
+		villages = []LedVillage{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(villages)
+}
+
+// LeaderVillageMembersHandler lets a leader see the roster or add a household to the village
+func LeaderVillageMembersHandler(w http.ResponseWriter, r *http.Request) {
+	claims, leader, pregnancy, ok := requireVillageLeader(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		households, err := GetHouseholdsByPregnancyID(pregnancy.ID)
+		if err != nil {
+			log.Printf("Database error getting households: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		if households == nil {
+			households = []*models.Household{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(households)
+
+	case http.MethodPost:
+		var req CreateVillageMembersBulkRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// Trim whitespace from name and relationship
+		req.Name = strings.TrimSpace(req.Name)
+		req.Relationship = strings.TrimSpace(req.Relationship)
+
+		// Validate required fields
+		if req.Name == "" || len(req.Emails) == 0 || req.Relationship == "" {
+			http.Error(w, "Name, emails, and relationship are required", http.StatusBadRequest)
+			return
+		}
+
+		for i, email := range req.Emails {
+			req.Emails[i] = strings.TrimSpace(email)
+			if req.Emails[i] == "" {
+				http.Error(w, "Empty email not allowed", http.StatusBadRequest)
+				return
+			}
+
+			existingMember, err := GetVillageMemberByEmail(pregnancy.ID, req.Emails[i])
+			if err != nil && err != sql.ErrNoRows {
+				log.Printf("Database error checking existing member: %v", err)
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			if existingMember != nil {
+				http.Error(w, fmt.Sprintf("Email %s is already in this village", req.Emails[i]), http.StatusConflict)
+				return
+			}
+		}
+
+		household, err := CreateHouseholdWithEvent(pregnancy.ID, req.Name, req.Emails, req.Relationship, req.IsTold, false)
+		if err != nil {
+			log.Printf("Failed to create village household: %v", err)
+			http.Error(w, "Failed to create village member", http.StatusInternalServerError)
+			return
+		}
+
+		weekNumber := pregnancy.GetCurrentWeek()
+		if err := CreateLeaderActionEvent(pregnancy.ID, claims.UserID, leader.Name, "member_added",
+			fmt.Sprintf("added %s to the village", req.Name), &weekNumber,
+			map[string]interface{}{"household_id": household.ID, "villager_name": req.Name}); err != nil {
+			log.Printf("Failed to create leader action event: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(household)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// LeaderAccessRequestsHandler returns pending access requests for a village the user leads
+func LeaderAccessRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, _, pregnancy, ok := requireVillageLeader(w, r)
+	if !ok {
+		return
+	}
+
+	requests, err := GetPendingAccessRequests(pregnancy.ID)
+	if err != nil {
+		log.Printf("Error getting access requests: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if requests == nil {
+		requests = []AccessRequestRecord{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requests)
+}
+
+// LeaderManageAccessRequestHandler lets a leader approve or deny an access request
+func LeaderManageAccessRequestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, leader, pregnancy, ok := requireVillageLeader(w, r)
+	if !ok {
+		return
+	}
+
+	// Path is /api/leader/villages/{pregnancyID}/access-requests/{requestID}/{action}
+	path := strings.TrimPrefix(r.URL.Path, "/api/leader/villages/")
+	parts := strings.Split(path, "/")
+	if len(parts) < 4 {
+		http.Error(w, "Invalid URL path", http.StatusBadRequest)
+		return
+	}
+
+	requestID, err := strconv.Atoi(parts[2])
+	if err != nil {
+		http.Error(w, "Invalid request ID", http.StatusBadRequest)
+		return
+	}
+
+	action := parts[3] // should be "approve" or "deny"
+	if action != "approve" && action != "deny" {
+		http.Error(w, "Action must be 'approve' or 'deny'", http.StatusBadRequest)
+		return
+	}
+
+	// The body is optional and only carries a personal note for the requester
+	var body ManageAccessRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req, _, err := GetPendingAccessRequestByID(requestID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Access request not found or already processed", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error getting access request: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if req.PregnancyID != pregnancy.ID {
+		http.Error(w, "Access denied: This request doesn't belong to this village", http.StatusForbidden)
+		return
+	}
+
+	if err := ProcessAccessRequest(req, action, strings.TrimSpace(body.Note)); err != nil {
+		if errors.Is(err, ErrAccessRequestProcessed) {
+			http.Error(w, "Access request already processed", http.StatusConflict)
+			return
+		}
+		log.Printf("Error processing access request: %v", err)
+		http.Error(w, "Failed to add village member", http.StatusInternalServerError)
+		return
+	}
+
+	description := fmt.Sprintf("approved %s's request to join the village", req.Name)
+	if action == "deny" {
+		description = fmt.Sprintf("denied %s's request to join the village", req.Name)
+	}
+
+	weekNumber := pregnancy.GetCurrentWeek()
+	if err := CreateLeaderActionEvent(pregnancy.ID, claims.UserID, leader.Name, "access_request_"+action+"d",
+		description, &weekNumber,
+		map[string]interface{}{"requester_name": req.Name, "requester_email": req.Email}); err != nil {
+		log.Printf("Failed to create leader action event: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"action":  action,
+		"message": action + "d successfully",
+	})
+}
+
+// requireVillageLeader resolves the pregnancy ID from a /api/leader/villages/{id}/... path and
+// verifies the authenticated user is a leader of that village, writing an error response if not
+func requireVillageLeader(w http.ResponseWriter, r *http.Request) (*middleware.Claims, *models.VillageMember, *models.Pregnancy, bool) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, nil, nil, false
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/leader/villages/")
+	pregnancyID, err := strconv.Atoi(strings.Split(path, "/")[0])
+	if err != nil {
+		http.Error(w, "Invalid pregnancy ID", http.StatusBadRequest)
+		return nil, nil, nil, false
+	}
+
+	leader, err := GetLeaderMembership(claims.UserID, pregnancyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "You are not a leader of this village", http.StatusForbidden)
+			return nil, nil, nil, false
+		}
+		log.Printf("Database error checking village leader: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil, nil, nil, false
+	}
+
+	pregnancy, err := GetPregnancyByID(pregnancyID)
+	if err != nil {
+		log.Printf("Database error getting pregnancy: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil, nil, nil, false
+	}
+
+	return claims, leader, pregnancy, true
+}
+
+// sendLeaderInvite emails a new leader their claim link
+func sendLeaderInvite(pregnancy *models.Pregnancy, member *models.VillageMember, claimToken string) {
+	emailService, err := emailservice.NewEmailService()
+	if err != nil {
+		log.Printf("Failed to initialize email service for leader invite: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if err := emailService.SendLeaderInvite(ctx, pregnancy, member, claimToken); err != nil {
+		log.Printf("Failed to send leader invite to member %d: %v", member.ID, err)
+	}
+}
+
+// Database functions
+
+// SetVillageMemberLeader promotes or demotes a village member. Promoting a member whose role
+// hasn't been claimed yet issues a new claim token, returned so it can be emailed to them;
+// demoting takes the role away from the account that claimed it.
+func SetVillageMemberLeader(memberID int, isLeader bool) (*models.VillageMember, string, error) {
+	var claimToken string
+	if isLeader {
+		token, err := generateSecretToken()
+		if err != nil {
+			return nil, "", err
+		}
+
+		result, err := db.GetDB().Exec(`
+			UPDATE village_members
+			SET is_leader = TRUE, leader_claim_token = ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND leader_user_id IS NULL
+		`, token, memberID)
+		if err != nil {
+			return nil, "", err
+		}
+		if rows, err := result.RowsAffected(); err != nil {
+			return nil, "", err
+		} else if rows > 0 {
+			claimToken = token
+		}
+	} else {
+		_, err := db.GetDB().Exec(`
+			UPDATE village_members
+			SET is_leader = FALSE, leader_user_id = NULL, leader_claim_token = NULL, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?
+		`, memberID)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	member, err := GetVillageMemberByID(memberID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return member, claimToken, nil
+}
+
+// ClaimVillageLeader redeems a leader claim token for an account and returns the village
+// member it was issued to. Each token works once.
+func ClaimVillageLeader(token string, userID int) (int, error) {
+	var memberID int
+	err := db.GetDB().QueryRow(`
+		UPDATE village_members
+		SET leader_user_id = ?, leader_claim_token = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE leader_claim_token = ? AND is_leader = TRUE
+		RETURNING id
+	`, userID, token).Scan(&memberID)
+	return memberID, err
+}
+
+// GetLeaderMembership returns the leader's village member record for a pregnancy, if the
+// user's account claimed the role
+func GetLeaderMembership(userID, pregnancyID int) (*models.VillageMember, error) {
+	var memberID int
+	err := db.GetDB().QueryRow(`
+		SELECT vm.id
+		FROM village_members vm
+		JOIN pregnancies p ON p.id = vm.pregnancy_id
+		WHERE vm.leader_user_id = ? AND vm.pregnancy_id = ? AND vm.is_leader = TRUE AND p.is_active = TRUE
+		LIMIT 1
+	`, userID, pregnancyID).Scan(&memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetVillageMemberByID(memberID)
+}
+
+// GetLedVillagesForUser returns every active village where the user is a leader
+func GetLedVillagesForUser(userID int) ([]LedVillage, error) {
+	rows, err := db.GetDB().Query(`
+		SELECT vm.id, p.id
+		FROM village_members vm
+		JOIN pregnancies p ON p.id = vm.pregnancy_id
+		WHERE vm.leader_user_id = ? AND vm.is_leader = TRUE AND p.is_active = TRUE
+		ORDER BY p.due_date ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	type membership struct {
+		memberID    int
+		pregnancyID int
+	}
+	var memberships []membership
+	for rows.Next() {
+		var m membership
+		if err := rows.Scan(&m.memberID, &m.pregnancyID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		memberships = append(memberships, m)
+	}
+	rows.Close()
+
+	var villages []LedVillage
+	for _, m := range memberships {
+		pregnancy, err := GetPregnancyByID(m.pregnancyID)
+		if err != nil {
+			return nil, err
+		}
+
+		owner, err := GetUserByID(pregnancy.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		parentNames := owner.Name
+		if pregnancy.PartnerName != nil && *pregnancy.PartnerName != "" {
+			parentNames = fmt.Sprintf("%s & %s", owner.Name, *pregnancy.PartnerName)
+		}
+
+		babyName := "Baby"
+		if pregnancy.BabyName != nil && *pregnancy.BabyName != "" {
+			babyName = *pregnancy.BabyName
+		}
+
+		villages = append(villages, LedVillage{
+			PregnancyID: pregnancy.ID,
+			MemberID:    m.memberID,
+			ParentNames: parentNames,
+			BabyName:    babyName,
+			DueDate:     pregnancy.DueDate.Format("2006-01-02"),
+			CurrentWeek: pregnancy.GetCurrentWeek(),
+		})
+	}
+
+	return villages, nil
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"simple-go/api/db"
+	"simple-go/api/models"
+	"simple-go/api/services/images"
+	"simple-go/api/services/storage"
+)
+
+// storedURLLifetime is how long a backend's direct download URL is handed out for when media
+// is served by redirecting to it
+const storedURLLifetime = 12 * time.Hour
+
+// publicMediaMaxAge is how long public media can be cached
+const publicMediaMaxAge = 365 * 24 * time.Hour
+
+// updateMediaStore returns the store media of a kind is kept in
+func updateMediaStore(kind string) storage.Storage {
+	if kind == models.MediaKindVideo {
+		return storage.Videos()
+	}
+	return storage.Images()
+}
+
+// updateMediaKey returns the storage key of an update's photo or video, or of one of its renditions
+func updateMediaKey(pregnancyID int, filename string) string {
+	return fmt.Sprintf("%d/%s", pregnancyID, filename)
+}
+
+// coverPhotoKey returns the storage key of a cover photo, or of one of its renditions
+func coverPhotoKey(filename string) string {
+	return "covers/" + filename
+}
+
+// storeFile uploads a local file to a store
+func storeFile(ctx context.Context, store storage.Storage, key, localPath, contentType string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return store.Put(ctx, key, file, info.Size(), contentType)
+}
+
+// fetchToTemp downloads a stored file into a new temporary directory, for the image and video
+// code that works on files on disk. The caller must remove the directory.
+func fetchToTemp(ctx context.Context, store storage.Storage, key string) (string, string, error) {
+	dir, err := os.MkdirTemp("", "media-")
+	if err != nil {
+		return "", "", err
+	}
+
+	body, _, err := store.Get(ctx, key)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", "", err
+	}
+	defer body.Close()
+
+	localPath := filepath.Join(dir, path.Base(key))
+	dst, err := os.Create(localPath)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", "", err
+	}
+	if _, err := io.Copy(dst, body); err != nil {
+		dst.Close()
+		os.RemoveAll(dir)
+		return "", "", err
+	}
+	if err := dst.Close(); err != nil {
+		os.RemoveAll(dir)
+		return "", "", err
+	}
+	return dir, localPath, nil
+}
+
+// storeRenditions uploads the renditions generated in dir next to key
+func storeRenditions(ctx context.Context, store storage.Storage, key, dir string, renditions models.PhotoRenditions) error {
+	for _, rendition := range renditions {
+		renditionKey := path.Join(path.Dir(key), rendition.Filename)
+		if err := storeFile(ctx, store, renditionKey, filepath.Join(dir, rendition.Filename), "image/jpeg"); err != nil {
+			removeRenditions(ctx, store, key)
+			return err
+		}
+	}
+	return nil
+}
+
+// removeRenditions deletes every rendition of the photo stored under key
+func removeRenditions(ctx context.Context, store storage.Storage, key string) error {
+	for _, size := range images.Sizes {
+		if err := store.Delete(ctx, images.RenditionFilename(key, size.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serveStoredFile serves a stored file, redirecting to the backend when it can hand out
+// download URLs itself. Public files may be cached by anyone, private ones only by the
+// viewer's browser; either way for no longer than maxAge, or the life of the backend's URL.
+func serveStoredFile(w http.ResponseWriter, r *http.Request, store storage.Storage, key string, public bool, maxAge time.Duration) {
+	directURL, err := store.URL(r.Context(), key, storedURLLifetime)
+	if err == storage.ErrInvalidKey {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get URL for %s: %v", key, err)
+		http.Error(w, "Failed to load file", http.StatusInternalServerError)
+		return
+	}
+
+	visibility := "private"
+	if public {
+		visibility = "public"
+	}
+	if directURL != "" {
+		if maxAge > storedURLLifetime {
+			maxAge = storedURLLifetime
+		}
+		w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", visibility, int(maxAge.Seconds())))
+		http.Redirect(w, r, directURL, http.StatusFound)
+		return
+	}
+
+	// Stored files never change, so public ones can be cached for good
+	if public {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int(maxAge.Seconds())))
+	} else {
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
+	}
+
+	body, info, err := store.Get(r.Context(), key)
+	if err == storage.ErrNotFound || err == storage.ErrInvalidKey {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to read %s: %v", key, err)
+		http.Error(w, "Failed to load file", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	if w.Header().Get("Content-Type") == "" && info.ContentType != "" {
+		w.Header().Set("Content-Type", info.ContentType)
+	}
+
+	// Seekable files support range requests, which video players rely on
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, path.Base(key), info.ModTime, seeker)
+		return
+	}
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size))
+	io.Copy(w, body)
+}
+
+// ServeCoverPhoto serves a cover photo or one of its renditions. Cover photos are public so
+// they can be used in link previews.
+func ServeCoverPhoto(w http.ResponseWriter, r *http.Request, filename string) {
+	serveStoredFile(w, r, storage.Images(), coverPhotoKey(filename), true, publicMediaMaxAge)
+}
+
+// MigrateStoredMedia copies every photo, video, cover photo, guestbook photo and rendition from
+// the configured storage backend to another one, skipping files already there at the same size.
+// It is run from the command line with -migrate-storage before switching STORAGE_BACKEND over.
+func MigrateStoredMedia(backend string) error {
+	targetImages, targetVideos, err := storage.Open(backend)
+	if err != nil {
+		return err
+	}
+
+	type storedFile struct {
+		kind string
+		key  string
+	}
+	var files []storedFile
+
+	rows, err := db.GetDB().Query(`
+		SELECT pu.pregnancy_id, um.kind, um.filename, um.renditions
+		FROM update_media um
+		JOIN pregnancy_updates pu ON pu.id = um.update_id
+		ORDER BY um.id
+	`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var pregnancyID int
+		var kind, filename string
+		var renditions models.PhotoRenditions
+		if err := rows.Scan(&pregnancyID, &kind, &filename, &renditions); err != nil {
+			rows.Close()
+			return err
+		}
+		files = append(files, storedFile{kind, updateMediaKey(pregnancyID, filename)})
+		for _, rendition := range renditions {
+			files = append(files, storedFile{kind, updateMediaKey(pregnancyID, rendition.Filename)})
+		}
+	}
+	rows.Close()
+
+	rows, err = db.GetDB().Query(`
+		SELECT cover_photo_filename, cover_photo_renditions FROM pregnancies WHERE cover_photo_filename IS NOT NULL
+	`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var filename string
+		var renditions models.PhotoRenditions
+		if err := rows.Scan(&filename, &renditions); err != nil {
+			rows.Close()
+			return err
+		}
+		files = append(files, storedFile{models.MediaKindPhoto, coverPhotoKey(filename)})
+		for _, rendition := range renditions {
+			files = append(files, storedFile{models.MediaKindPhoto, coverPhotoKey(rendition.Filename)})
+		}
+	}
+	rows.Close()
+
+	rows, err = db.GetDB().Query(`
+		SELECT pregnancy_id, photo_filename FROM guestbook_messages WHERE photo_filename IS NOT NULL
+	`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var pregnancyID int
+		var filename string
+		if err := rows.Scan(&pregnancyID, &filename); err != nil {
+			rows.Close()
+			return err
+		}
+		files = append(files, storedFile{models.MediaKindPhoto, guestbookPhotoKey(pregnancyID, filename)})
+	}
+	rows.Close()
+
+	ctx := context.Background()
+	var copied, skipped, failed int
+	for _, file := range files {
+		source, target := storage.Images(), targetImages
+		if file.kind == models.MediaKindVideo {
+			source, target = storage.Videos(), targetVideos
+		}
+
+		sourceInfo, err := source.Stat(ctx, file.key)
+		if err != nil {
+			log.Printf("Failed to read %s: %v", file.key, err)
+			failed++
+			continue
+		}
+		if targetInfo, err := target.Stat(ctx, file.key); err == nil && targetInfo.Size == sourceInfo.Size {
+			skipped++
+			continue
+		}
+
+		if err := storage.Copy(ctx, source, target, file.key); err != nil {
+			log.Printf("Failed to copy %s: %v", file.key, err)
+			failed++
+			continue
+		}
+		copied++
+	}
+
+	log.Printf("Copied %d stored files to %s storage, skipped %d already there, %d failed", copied, backend, skipped, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d files could not be copied", failed)
+	}
+	return nil
+}
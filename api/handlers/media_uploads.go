@@ -0,0 +1,538 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"simple-go/api/config"
+	"simple-go/api/db"
+	"simple-go/api/middleware"
+	"simple-go/api/models"
+	"simple-go/api/services/media"
+)
+
+// maxMediaUploadSize is the largest photo or video that can be uploaded in chunks
+const maxMediaUploadSize = 2 << 30 // 2 GB
+
+// maxUploadChunkSize is the largest chunk accepted in one request
+const maxUploadChunkSize = 32 << 20 // 32 MB
+
+// uploadSniffLength is how much of an upload has to arrive before its type can be checked
+const uploadSniffLength = 512
+
+// uploadLocks stops two chunks being written to the same upload at once
+var uploadLocks sync.Map
+
+// CreateMediaUploadRequest starts a resumable upload
+type CreateMediaUploadRequest struct {
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"` // optional hex SHA-256 of the whole file, checked once it has arrived
+}
+
+// CreateMediaUploadHandler starts a resumable upload of a photo or video. The file is then sent
+// in chunks with AppendMediaUploadHandler, and attached to an update by listing the upload's ID
+// in upload_ids when the update is created or edited.
+func CreateMediaUploadHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateMediaUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Filename = filepath.Base(strings.TrimSpace(req.Filename))
+	if req.Filename == "" || req.Filename == "." || req.Filename == "/" {
+		http.Error(w, "Filename is required", http.StatusBadRequest)
+		return
+	}
+	if req.Size <= 0 {
+		http.Error(w, "Size is required", http.StatusBadRequest)
+		return
+	}
+	if req.Size > maxMediaUploadSize {
+		http.Error(w, fmt.Sprintf("Uploads can be at most %d MB", maxMediaUploadSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var checksum *string
+	if req.Checksum != "" {
+		req.Checksum = strings.ToLower(req.Checksum)
+		if decoded, err := hex.DecodeString(req.Checksum); err != nil || len(decoded) != sha256.Size {
+			http.Error(w, "Checksum must be a hex SHA-256 digest", http.StatusBadRequest)
+			return
+		}
+		checksum = &req.Checksum
+	}
+
+	upload, err := createMediaUpload(claims.UserID, req.Filename, req.Size, checksum)
+	if err != nil {
+		log.Printf("Failed to create upload for user %d: %v", claims.UserID, err)
+		http.Error(w, "Failed to create upload", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", "/api/uploads/"+upload.ID)
+	writeMediaUpload(w, upload, http.StatusCreated)
+}
+
+// GetMediaUploadHandler reports how much of an upload has arrived, so an interrupted upload can
+// carry on from there. It also answers HEAD requests.
+func GetMediaUploadHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	uploadID := mediaUploadIDFromPath(r.URL.Path)
+	if !isMediaUploadID(uploadID) {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	}
+
+	upload, err := getMediaUpload(uploadID, claims.UserID)
+	if err != nil {
+		log.Printf("Failed to get upload: %v", err)
+		http.Error(w, "Failed to get upload", http.StatusInternalServerError)
+		return
+	}
+	if upload == nil {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeMediaUpload(w, upload, http.StatusOK)
+}
+
+// AppendMediaUploadHandler writes a chunk of an upload. The Upload-Offset header must match
+// how much has already arrived. An Upload-Checksum header of the form "sha256 <base64 digest>"
+// has the chunk checked and thrown away if it doesn't match; without one, whatever arrives
+// before a dropped connection is kept.
+func AppendMediaUploadHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	uploadID := mediaUploadIDFromPath(r.URL.Path)
+	if !isMediaUploadID(uploadID) {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	}
+	lock, _ := uploadLocks.LoadOrStore(uploadID, &sync.Mutex{})
+	if !lock.(*sync.Mutex).TryLock() {
+		http.Error(w, "Another chunk of this upload is still being written", http.StatusConflict)
+		return
+	}
+	defer lock.(*sync.Mutex).Unlock()
+
+	upload, err := getMediaUpload(uploadID, claims.UserID)
+	if err != nil {
+		log.Printf("Failed to get upload: %v", err)
+		http.Error(w, "Failed to get upload", http.StatusInternalServerError)
+		return
+	}
+	if upload == nil {
+		uploadLocks.Delete(uploadID)
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	}
+	if upload.IsComplete() {
+		http.Error(w, "Upload is already complete", http.StatusConflict)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		http.Error(w, "Upload-Offset header is required", http.StatusBadRequest)
+		return
+	}
+	if offset != upload.Offset {
+		// The client has lost track of what arrived; it should resume from the current offset
+		w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		http.Error(w, fmt.Sprintf("Upload is at offset %d", upload.Offset), http.StatusConflict)
+		return
+	}
+
+	var chunkHash hash.Hash
+	var expectedChunkSum []byte
+	if header := r.Header.Get("Upload-Checksum"); header != "" {
+		algorithm, digest, _ := strings.Cut(header, " ")
+		expectedChunkSum, err = base64.StdEncoding.DecodeString(digest)
+		if !strings.EqualFold(algorithm, "sha256") || err != nil || len(expectedChunkSum) != sha256.Size {
+			http.Error(w, "Upload-Checksum must be \"sha256 <base64 digest>\"", http.StatusBadRequest)
+			return
+		}
+		chunkHash = sha256.New()
+	}
+
+	path := mediaUploadPath(upload.ID)
+	file, err := os.OpenFile(path, os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("Failed to open upload %s: %v", upload.ID, err)
+		http.Error(w, "Failed to write upload", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	// Anything past the offset is left from a chunk that was thrown away
+	if err := file.Truncate(offset); err != nil {
+		log.Printf("Failed to truncate upload %s: %v", upload.ID, err)
+		http.Error(w, "Failed to write upload", http.StatusInternalServerError)
+		return
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		log.Printf("Failed to seek upload %s: %v", upload.ID, err)
+		http.Error(w, "Failed to write upload", http.StatusInternalServerError)
+		return
+	}
+
+	remaining := upload.Size - offset
+	body := http.MaxBytesReader(w, r.Body, maxUploadChunkSize)
+	var dst io.Writer = file
+	if chunkHash != nil {
+		dst = io.MultiWriter(file, chunkHash)
+	}
+	written, copyErr := io.Copy(dst, io.LimitReader(body, remaining+1))
+
+	if written > remaining {
+		file.Truncate(offset)
+		http.Error(w, "Chunk runs past the end of the upload", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(copyErr, &tooLarge) {
+		file.Truncate(offset)
+		http.Error(w, fmt.Sprintf("Chunks can be at most %d MB", maxUploadChunkSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if chunkHash != nil && (copyErr != nil || !bytes.Equal(chunkHash.Sum(nil), expectedChunkSum)) {
+		file.Truncate(offset)
+		http.Error(w, "Chunk checksum mismatch", http.StatusBadRequest)
+		return
+	}
+	if err := file.Sync(); err != nil {
+		log.Printf("Failed to sync upload %s: %v", upload.ID, err)
+		file.Truncate(offset)
+		http.Error(w, "Failed to write upload", http.StatusInternalServerError)
+		return
+	}
+
+	upload.Offset = offset + written
+	if err := saveMediaUploadOffset(upload); err != nil {
+		log.Printf("Failed to record offset of upload %s: %v", upload.ID, err)
+		http.Error(w, "Failed to write upload", http.StatusInternalServerError)
+		return
+	}
+	if copyErr != nil {
+		// The connection dropped; the client resumes from the recorded offset
+		log.Printf("Upload %s interrupted at offset %d: %v", upload.ID, upload.Offset, copyErr)
+		return
+	}
+
+	// Turn away files that can't be attached before the rest of them is sent
+	if offset < uploadSniffLength && (upload.Offset >= uploadSniffLength || upload.Offset == upload.Size) {
+		if err := checkMediaUploadType(path); err != nil {
+			deleteMediaUpload(upload.ID)
+			http.Error(w, "Unsupported file type. Photos must be JPG, PNG, GIF or WebP and videos MP4 or MOV", http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	if upload.Offset == upload.Size {
+		if err := completeMediaUpload(upload); err == errMediaUploadChecksum {
+			deleteMediaUpload(upload.ID)
+			http.Error(w, "Upload checksum mismatch; start the upload again", http.StatusUnprocessableEntity)
+			return
+		} else if err != nil {
+			log.Printf("Failed to complete upload %s: %v", upload.ID, err)
+			http.Error(w, "Failed to complete upload", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeMediaUpload(w, upload, http.StatusOK)
+}
+
+// DeleteMediaUploadHandler abandons an upload
+func DeleteMediaUploadHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	uploadID := mediaUploadIDFromPath(r.URL.Path)
+	if !isMediaUploadID(uploadID) {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	}
+
+	upload, err := getMediaUpload(uploadID, claims.UserID)
+	if err != nil {
+		log.Printf("Failed to get upload: %v", err)
+		http.Error(w, "Failed to delete upload", http.StatusInternalServerError)
+		return
+	}
+	if upload == nil {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	}
+
+	if err := deleteMediaUpload(upload.ID); err != nil {
+		log.Printf("Failed to delete upload %s: %v", upload.ID, err)
+		http.Error(w, "Failed to delete upload", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ExpireMediaUploads removes uploads that have had no chunks for models.MediaUploadLifetime,
+// and finished uploads that were never attached to an update
+func ExpireMediaUploads() error {
+	rows, err := db.GetDB().Query(`SELECT id FROM media_uploads WHERE expires_at <= ?`, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	var uploadIDs []string
+	for rows.Next() {
+		var uploadID string
+		if err := rows.Scan(&uploadID); err != nil {
+			rows.Close()
+			return err
+		}
+		uploadIDs = append(uploadIDs, uploadID)
+	}
+	rows.Close()
+
+	for _, uploadID := range uploadIDs {
+		if err := deleteMediaUpload(uploadID); err != nil {
+			log.Printf("Failed to remove expired upload %s: %v", uploadID, err)
+			continue
+		}
+		log.Printf("Removed expired upload %s", uploadID)
+	}
+
+	return nil
+}
+
+// completedMediaUploads fetches the user's finished uploads to attach to an update, in the
+// order given. It fails, naming the upload, if any of them isn't the user's or isn't finished.
+func completedMediaUploads(uploadIDs []string, userID int) ([]models.MediaUpload, error) {
+	var uploads []models.MediaUpload
+	for _, uploadID := range uploadIDs {
+		if !isMediaUploadID(uploadID) {
+			return nil, fmt.Errorf("Upload %s not found", uploadID)
+		}
+		upload, err := getMediaUpload(uploadID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if upload == nil {
+			return nil, fmt.Errorf("Upload %s not found", uploadID)
+		}
+		if !upload.IsComplete() {
+			return nil, fmt.Errorf("Upload %s is not complete", uploadID)
+		}
+		uploads = append(uploads, *upload)
+	}
+	return uploads, nil
+}
+
+// attachMediaUpload stores a finished upload as media of an update, then removes the upload
+func attachMediaUpload(ctx context.Context, upload models.MediaUpload, pregnancyID, updateID, sortOrder int) (*models.UpdateMedia, error) {
+	file, err := os.Open(mediaUploadPath(upload.ID))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	item, err := saveMedia(ctx, file, upload.Filename, pregnancyID, updateID, sortOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := deleteMediaUpload(upload.ID); err != nil {
+		log.Printf("Failed to remove attached upload %s: %v", upload.ID, err)
+	}
+	return item, nil
+}
+
+// mediaUploadIDFromPath returns the upload ID at the end of /api/uploads/{id}
+func mediaUploadIDFromPath(urlPath string) string {
+	return strings.Trim(strings.TrimPrefix(urlPath, "/api/uploads/"), "/")
+}
+
+// isMediaUploadID reports whether id looks like an ID from generateMediaUploadID
+func isMediaUploadID(id string) bool {
+	decoded, err := hex.DecodeString(id)
+	return err == nil && len(decoded) == 16
+}
+
+// mediaUploadPath returns where the chunks of an upload are written. Uploads are kept on this
+// server's disk until they are attached, whichever storage backend media ends up in.
+func mediaUploadPath(uploadID string) string {
+	return filepath.Join(config.AppConfig.UploadsDirectory, uploadID)
+}
+
+// checkMediaUploadType checks the start of an upload is a photo or video that can be attached
+func checkMediaUploadType(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, _, err = media.DetectFileType(file)
+	return err
+}
+
+func writeMediaUpload(w http.ResponseWriter, upload *models.MediaUpload, status int) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Size, 10))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(upload)
+}
+
+// Database functions
+
+// errMediaUploadChecksum is returned when a finished upload doesn't match its checksum
+var errMediaUploadChecksum = errors.New("upload checksum mismatch")
+
+// generateMediaUploadID creates an unguessable ID for an upload
+func generateMediaUploadID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+func createMediaUpload(userID int, filename string, size int64, checksum *string) (*models.MediaUpload, error) {
+	uploadID, err := generateMediaUploadID()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(config.AppConfig.UploadsDirectory, 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(mediaUploadPath(uploadID))
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+
+	now := time.Now().UTC()
+	upload := &models.MediaUpload{
+		ID:        uploadID,
+		UserID:    userID,
+		Filename:  filename,
+		Size:      size,
+		Checksum:  checksum,
+		ExpiresAt: now.Add(models.MediaUploadLifetime),
+		CreatedAt: now,
+	}
+
+	_, err = db.GetDB().Exec(`
+		INSERT INTO media_uploads (id, user_id, filename, size, checksum, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		upload.ID, upload.UserID, upload.Filename, upload.Size, upload.Checksum, upload.ExpiresAt, upload.CreatedAt)
+	if err != nil {
+		os.Remove(mediaUploadPath(uploadID))
+		return nil, err
+	}
+	return upload, nil
+}
+
+// getMediaUpload fetches one of the user's uploads, or nil if there is no such upload
+func getMediaUpload(uploadID string, userID int) (*models.MediaUpload, error) {
+	var upload models.MediaUpload
+	err := db.GetDB().QueryRow(`
+		SELECT id, user_id, filename, size, received_size, checksum, completed_at, expires_at, created_at
+		FROM media_uploads
+		WHERE id = ? AND user_id = ? AND expires_at > ?`,
+		uploadID, userID, time.Now().UTC()).Scan(
+		&upload.ID, &upload.UserID, &upload.Filename, &upload.Size, &upload.Offset, &upload.Checksum,
+		&upload.CompletedAt, &upload.ExpiresAt, &upload.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// saveMediaUploadOffset records how much of an upload has arrived, extending its expiry
+func saveMediaUploadOffset(upload *models.MediaUpload) error {
+	upload.ExpiresAt = time.Now().UTC().Add(models.MediaUploadLifetime)
+	_, err := db.GetDB().Exec(`UPDATE media_uploads SET received_size = ?, expires_at = ? WHERE id = ?`,
+		upload.Offset, upload.ExpiresAt, upload.ID)
+	return err
+}
+
+// completeMediaUpload checks a fully arrived upload against its checksum and marks it complete
+func completeMediaUpload(upload *models.MediaUpload) error {
+	if upload.Checksum != nil {
+		file, err := os.Open(mediaUploadPath(upload.ID))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		fileHash := sha256.New()
+		if _, err := io.Copy(fileHash, file); err != nil {
+			return err
+		}
+		if hex.EncodeToString(fileHash.Sum(nil)) != *upload.Checksum {
+			return errMediaUploadChecksum
+		}
+	}
+
+	now := time.Now().UTC()
+	upload.CompletedAt = &now
+	upload.ExpiresAt = now.Add(models.MediaUploadLifetime)
+	_, err := db.GetDB().Exec(`UPDATE media_uploads SET completed_at = ?, expires_at = ? WHERE id = ?`,
+		upload.CompletedAt, upload.ExpiresAt, upload.ID)
+	return err
+}
+
+// deleteMediaUpload removes an upload and what has arrived of it
+func deleteMediaUpload(uploadID string) error {
+	if err := os.Remove(mediaUploadPath(uploadID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	uploadLocks.Delete(uploadID)
+	_, err := db.GetDB().Exec(`DELETE FROM media_uploads WHERE id = ?`, uploadID)
+	return err
+}
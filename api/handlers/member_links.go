@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"simple-go/api/db"
+	"simple-go/api/middleware"
+	"simple-go/api/models"
+	"simple-go/api/services/email"
+)
+
+// ResetMemberLinkHandler turns off a village member's own timeline links, for instance after
+// one was forwarded, and emails them a new one (POST /api/village-members/{id}/reset-link)
+func ResetMemberLinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/village-members/")
+	memberID, err := strconv.Atoi(strings.TrimSuffix(path, "/reset-link"))
+	if err != nil {
+		http.Error(w, "Invalid member ID", http.StatusBadRequest)
+		return
+	}
+
+	pregnancy, err := GetActivePregnancyForUser(claims.UserID)
+	if err != nil {
+		log.Printf("Database error getting pregnancy: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if pregnancy == nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	member, err := GetVillageMemberByID(memberID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Village member not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Database error getting village member: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if member.PregnancyID != pregnancy.ID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if err := ResetVillageMemberAccess(memberID); err != nil {
+		log.Printf("Failed to reset timeline link for member %d: %v", memberID, err)
+		http.Error(w, "Failed to reset link", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Timeline link reset for village member %d", memberID)
+
+	// Members who haven't been told yet get their link once the parents mark them as told
+	emailed := member.IsTold && member.Email != ""
+	if emailed {
+		go sendTimelineLink(pregnancy, memberID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"emailed": emailed,
+	})
+}
+
+// Database functions
+
+// GetVillageMemberByAccessToken looks up the village member whose own timeline link carries token
+func GetVillageMemberByAccessToken(token string) (*models.VillageMember, error) {
+	memberID, ok := email.MemberAccessID(token)
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	var nonce sql.NullString
+	err := db.GetDB().QueryRow(`SELECT access_nonce FROM village_members WHERE id = ?`, memberID).Scan(&nonce)
+	if err != nil {
+		return nil, err
+	}
+	if !email.ValidMemberAccess(memberID, nonce.String, token) {
+		return nil, sql.ErrNoRows
+	}
+
+	return GetVillageMemberByID(memberID)
+}
+
+// ResetVillageMemberAccess replaces a member's access nonce, so the timeline links sent to them
+// so far stop working
+func ResetVillageMemberAccess(memberID int) error {
+	nonce, err := generateSecretToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.GetDB().Exec(`UPDATE village_members SET access_nonce = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, nonce, memberID)
+	return err
+}
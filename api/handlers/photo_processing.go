@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"simple-go/api/db"
+	"simple-go/api/models"
+	"simple-go/api/services/images"
+	"simple-go/api/services/media"
+	"simple-go/api/services/storage"
+)
+
+// photoProcessingMu runs one photo at a time; decoding a phone photo takes a lot of memory, and
+// it also stops an upload and the background sweep from processing the same photo twice
+var photoProcessingMu sync.Mutex
+
+// sanitizeUploadedPhoto finishes writing an upload and strips its metadata, returning the size
+// of the sanitized file. A photo that can't be sanitized is deleted rather than served as is.
+func sanitizeUploadedPhoto(dst *os.File, path string) (int64, error) {
+	if err := dst.Close(); err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+
+	result, err := images.Sanitize(path)
+	if err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+	return result.Size, nil
+}
+
+// processUpdateMedia generates renditions for an update's photos that don't have them yet.
+// It is run in the background after an upload so the request doesn't wait on it.
+func processUpdateMedia(updateID int) {
+	rows, err := db.GetDB().Query(`
+		SELECT id FROM update_media WHERE update_id = ? AND processed_at IS NULL ORDER BY sort_order
+	`, updateID)
+	if err != nil {
+		log.Printf("Failed to get media to process for update %d: %v", updateID, err)
+		return
+	}
+
+	var mediaIDs []int
+	for rows.Next() {
+		var mediaID int
+		if err := rows.Scan(&mediaID); err != nil {
+			continue
+		}
+		mediaIDs = append(mediaIDs, mediaID)
+	}
+	rows.Close()
+
+	for _, mediaID := range mediaIDs {
+		if err := processMedia(mediaID); err != nil {
+			log.Printf("Failed to process media %d: %v", mediaID, err)
+		}
+	}
+}
+
+// processMedia generates and records the renditions of a single photo. Videos, and photos
+// that can't be processed, are still marked processed so they aren't retried. Media recorded
+// before it was probed on upload is probed first.
+func processMedia(mediaID int) error {
+	photoProcessingMu.Lock()
+	defer photoProcessingMu.Unlock()
+
+	var pregnancyID int
+	var kind, filename string
+	var processedAt, checksum *string
+	err := db.GetDB().QueryRow(`
+		SELECT pu.pregnancy_id, um.kind, um.filename, um.processed_at, um.checksum
+		FROM update_media um
+		JOIN pregnancy_updates pu ON pu.id = um.update_id
+		WHERE um.id = ?
+	`, mediaID).Scan(&pregnancyID, &kind, &filename, &processedAt, &checksum)
+	if err == sql.ErrNoRows || (processedAt != nil && checksum != nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Probing and resizing work on a local copy of the stored file
+	ctx := context.Background()
+	store := updateMediaStore(kind)
+	key := updateMediaKey(pregnancyID, filename)
+	dir, path, err := fetchToTemp(ctx, store, key)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", key, err)
+	}
+	defer os.RemoveAll(dir)
+
+	if checksum == nil {
+		if err := probeStoredMedia(mediaID, kind, path); err != nil {
+			return err
+		}
+	}
+	if processedAt != nil {
+		return nil
+	}
+
+	var width, height *int
+	var renditions models.PhotoRenditions
+	if kind == models.MediaKindPhoto && images.IsProcessable(filename) {
+		result, err := images.Process(dir, filename)
+		if err != nil {
+			log.Printf("Could not generate renditions for photo %d: %v", mediaID, err)
+		} else if err := storeRenditions(ctx, store, key, dir, result.Renditions); err != nil {
+			return fmt.Errorf("failed to store renditions of %s: %w", key, err)
+		} else {
+			width, height, renditions = &result.Width, &result.Height, result.Renditions
+		}
+	}
+
+	_, err = db.GetDB().Exec(`
+		UPDATE update_media
+		SET width = COALESCE(?, width), height = COALESCE(?, height), renditions = ?, processed_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, width, height, renditions, mediaID)
+	return err
+}
+
+// probeStoredMedia records the details of media stored before uploads were probed, which were
+// only typed by their extension
+func probeStoredMedia(mediaID int, kind, path string) error {
+	info, err := media.Probe(path)
+	if err != nil {
+		return fmt.Errorf("failed to probe %s: %w", filepath.Base(path), err)
+	}
+
+	mimeType := info.MIMEType
+	if info.Kind != kind {
+		log.Printf("Media %d is stored as a %s but its contents are a %s", mediaID, kind, info.Kind)
+		mimeType = ""
+	}
+
+	_, err = db.GetDB().Exec(`
+		UPDATE update_media
+		SET mime_type = COALESCE(NULLIF(?, ''), mime_type), file_size = ?, checksum = ?, width = ?, height = ?,
+		    duration_seconds = ?, captured_at = COALESCE(captured_at, ?)
+		WHERE id = ?
+	`, mimeType, info.Size, info.Checksum, info.Width, info.Height, info.Duration, info.CapturedAt, mediaID)
+	return err
+}
+
+// processCoverPhoto generates renditions for a newly uploaded cover photo. They are only
+// recorded if the photo is still the pregnancy's cover by the time they are ready.
+func processCoverPhoto(pregnancyID int, filename string) {
+	photoProcessingMu.Lock()
+	defer photoProcessingMu.Unlock()
+
+	ctx := context.Background()
+	key := coverPhotoKey(filename)
+	dir, _, err := fetchToTemp(ctx, storage.Images(), key)
+	if err != nil {
+		log.Printf("Could not fetch cover photo %s: %v", filename, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	result, err := images.Process(dir, filename)
+	if err != nil {
+		log.Printf("Could not generate renditions for cover photo %s: %v", filename, err)
+		return
+	}
+	if err := storeRenditions(ctx, storage.Images(), key, dir, result.Renditions); err != nil {
+		log.Printf("Could not store renditions for cover photo %s: %v", filename, err)
+		return
+	}
+
+	res, err := db.GetDB().Exec(`
+		UPDATE pregnancies SET cover_photo_renditions = ?
+		WHERE id = ? AND cover_photo_filename = ?
+	`, result.Renditions, pregnancyID, filename)
+	if err != nil {
+		log.Printf("Failed to save cover photo renditions for pregnancy %d: %v", pregnancyID, err)
+		return
+	}
+
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		removeRenditions(ctx, storage.Images(), key)
+	}
+}
+
+// ProcessPendingMedia generates renditions for any photos still waiting for them, such as
+// ones uploaded just before a restart or before renditions existed, and probes media that was
+// stored before uploads were probed
+func ProcessPendingMedia() error {
+	rows, err := db.GetDB().Query(`
+		SELECT um.id
+		FROM update_media um
+		JOIN pregnancy_updates pu ON pu.id = um.update_id
+		WHERE (um.processed_at IS NULL OR um.checksum IS NULL) AND pu.deleted_at IS NULL
+		ORDER BY um.id
+	`)
+	if err != nil {
+		return err
+	}
+
+	var mediaIDs []int
+	for rows.Next() {
+		var mediaID int
+		if err := rows.Scan(&mediaID); err != nil {
+			rows.Close()
+			return err
+		}
+		mediaIDs = append(mediaIDs, mediaID)
+	}
+	rows.Close()
+
+	for _, mediaID := range mediaIDs {
+		if err := processMedia(mediaID); err != nil {
+			log.Printf("Failed to process media %d: %v", mediaID, err)
+		}
+	}
+
+	return nil
+}
+
+// SanitizeStoredImages strips metadata from every photo and video already stored, for media
+// uploaded before uploads were sanitized. Photos whose orientation had to be applied get their
+// renditions regenerated. It is run once from the command line with -sanitize-images.
+func SanitizeStoredImages() error {
+	if err := sanitizeStoredVideos(); err != nil {
+		return err
+	}
+
+	type storedPhoto struct {
+		key      string
+		filename string
+		isCover  bool
+	}
+	var photos []storedPhoto
+
+	rows, err := db.GetDB().Query(`
+		SELECT pu.pregnancy_id, um.filename
+		FROM update_media um
+		JOIN pregnancy_updates pu ON pu.id = um.update_id
+		WHERE um.kind = 'photo'
+		ORDER BY um.id
+	`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var pregnancyID int
+		var filename string
+		if err := rows.Scan(&pregnancyID, &filename); err != nil {
+			rows.Close()
+			return err
+		}
+		photos = append(photos, storedPhoto{updateMediaKey(pregnancyID, filename), filename, false})
+	}
+	rows.Close()
+
+	rows, err = db.GetDB().Query(`SELECT cover_photo_filename FROM pregnancies WHERE cover_photo_filename IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var filename string
+		if err := rows.Scan(&filename); err != nil {
+			rows.Close()
+			return err
+		}
+		photos = append(photos, storedPhoto{coverPhotoKey(filename), filename, true})
+	}
+	rows.Close()
+
+	ctx := context.Background()
+	store := storage.Images()
+	var sanitized, rotated int
+	for _, photo := range photos {
+		result, err := sanitizeStoredPhoto(ctx, store, photo.key)
+		if err != nil {
+			log.Printf("Failed to sanitize %s: %v", photo.key, err)
+			continue
+		}
+		if !result.Changed {
+			continue
+		}
+		sanitized++
+
+		if photo.isCover {
+			if result.Rotated {
+				rotated++
+				db.GetDB().Exec(`UPDATE pregnancies SET cover_photo_renditions = NULL WHERE cover_photo_filename = ?`, photo.filename)
+				removeRenditions(ctx, store, photo.key)
+			}
+			continue
+		}
+
+		// The checksum is cleared so the photo is probed again
+		query := `UPDATE update_media SET file_size = ?, checksum = NULL WHERE filename = ? AND kind = 'photo'`
+		if result.Rotated {
+			rotated++
+			query = `UPDATE update_media SET file_size = ?, checksum = NULL, width = NULL, height = NULL, renditions = NULL, processed_at = NULL WHERE filename = ? AND kind = 'photo'`
+			removeRenditions(ctx, store, photo.key)
+		}
+		if _, err := db.GetDB().Exec(query, result.Size, photo.filename); err != nil {
+			log.Printf("Failed to update photo record for %s: %v", photo.filename, err)
+		}
+	}
+
+	log.Printf("Sanitized %d stored photos, %d of which were rotated to apply their orientation", sanitized, rotated)
+
+	// Regenerate renditions for the rotated photos
+	rows, err = db.GetDB().Query(`
+		SELECT id, cover_photo_filename FROM pregnancies
+		WHERE cover_photo_filename IS NOT NULL AND cover_photo_renditions IS NULL
+	`)
+	if err != nil {
+		return err
+	}
+	covers := map[int]string{}
+	for rows.Next() {
+		var pregnancyID int
+		var filename string
+		if err := rows.Scan(&pregnancyID, &filename); err != nil {
+			rows.Close()
+			return err
+		}
+		covers[pregnancyID] = filename
+	}
+	rows.Close()
+
+	for pregnancyID, filename := range covers {
+		processCoverPhoto(pregnancyID, filename)
+	}
+	return ProcessPendingMedia()
+}
+
+// sanitizeStoredVideos strips location and device metadata from every stored update video
+func sanitizeStoredVideos() error {
+	rows, err := db.GetDB().Query(`
+		SELECT pu.pregnancy_id, um.filename
+		FROM update_media um
+		JOIN pregnancy_updates pu ON pu.id = um.update_id
+		WHERE um.kind = 'video'
+		ORDER BY um.id
+	`)
+	if err != nil {
+		return err
+	}
+	var filenames, keys []string
+	for rows.Next() {
+		var pregnancyID int
+		var filename string
+		if err := rows.Scan(&pregnancyID, &filename); err != nil {
+			rows.Close()
+			return err
+		}
+		filenames = append(filenames, filename)
+		keys = append(keys, updateMediaKey(pregnancyID, filename))
+	}
+	rows.Close()
+
+	ctx := context.Background()
+	store := storage.Videos()
+	var sanitized int
+	for i, key := range keys {
+		changed, err := sanitizeStoredVideo(ctx, store, key)
+		if err != nil {
+			log.Printf("Failed to sanitize %s: %v", key, err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+		sanitized++
+
+		// The checksum is cleared so the video is probed again
+		if _, err := db.GetDB().Exec(`UPDATE update_media SET checksum = NULL WHERE filename = ? AND kind = 'video'`, filenames[i]); err != nil {
+			log.Printf("Failed to update video record for %s: %v", filenames[i], err)
+		}
+	}
+
+	log.Printf("Sanitized %d stored videos", sanitized)
+	return nil
+}
+
+// sanitizeStoredVideo strips metadata from a stored video, storing it again if anything changed
+func sanitizeStoredVideo(ctx context.Context, store storage.Storage, key string) (bool, error) {
+	dir, path, err := fetchToTemp(ctx, store, key)
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(dir)
+
+	changed, err := media.SanitizeVideo(path)
+	if err != nil || !changed {
+		return false, err
+	}
+
+	info, err := store.Stat(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return true, storeFile(ctx, store, key, path, info.ContentType)
+}
+
+// sanitizeStoredPhoto strips metadata from a stored photo, storing it again if anything changed
+func sanitizeStoredPhoto(ctx context.Context, store storage.Storage, key string) (*images.SanitizeResult, error) {
+	dir, path, err := fetchToTemp(ctx, store, key)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	result, err := images.Sanitize(path)
+	if err != nil {
+		return nil, err
+	}
+	if result.Changed {
+		info, err := store.Stat(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if err := storeFile(ctx, store, key, path, info.ContentType); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"simple-go/api/db"
+	"simple-go/api/models"
+)
+
+// PreferencesResponse is what a villager sees on their token-authenticated preference page
+type PreferencesResponse struct {
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	ParentNames  string `json:"parent_names"`
+	BabyName     string `json:"baby_name"`
+	IsSubscribed bool   `json:"is_subscribed"`
+	// DeliveryFrequency is immediate, daily, weekly or none
+	DeliveryFrequency string `json:"delivery_frequency"`
+}
+
+// UpdatePreferencesRequest represents a villager changing their email preferences
+type UpdatePreferencesRequest struct {
+	IsSubscribed      *bool   `json:"is_subscribed"`
+	DeliveryFrequency *string `json:"delivery_frequency"`
+}
+
+// PreferencesHandler lets a villager view or change their email preferences using the
+// unsubscribe token from their emails (/api/preferences/{token})
+func PreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/api/preferences/")
+	member, err := GetVillageMemberByUnsubscribeToken(token)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Invalid or expired link", http.StatusNotFound)
+			return
+		}
+		log.Printf("Database error getting village member by token: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		// Nothing to change, just return the current preferences
+
+	case "PUT":
+		var req UpdatePreferencesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if req.DeliveryFrequency != nil {
+			if !models.IsValidDeliveryFrequency(*req.DeliveryFrequency) {
+				http.Error(w, "Delivery frequency must be immediate, daily, weekly or none", http.StatusBadRequest)
+				return
+			}
+			if err := SetVillageMemberDeliveryFrequency(member.ID, *req.DeliveryFrequency); err != nil {
+				log.Printf("Failed to update delivery frequency for member %d: %v", member.ID, err)
+				http.Error(w, "Failed to update preferences", http.StatusInternalServerError)
+				return
+			}
+			member.DeliveryFrequency = *req.DeliveryFrequency
+		}
+
+		if req.IsSubscribed != nil {
+			if err := SetVillageMemberSubscribed(member.ID, *req.IsSubscribed); err != nil {
+				log.Printf("Failed to update subscription for member %d: %v", member.ID, err)
+				http.Error(w, "Failed to update preferences", http.StatusInternalServerError)
+				return
+			}
+			member.IsSubscribed = *req.IsSubscribed
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response, err := buildPreferencesResponse(member)
+	if err != nil {
+		log.Printf("Database error building preferences: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// OneClickUnsubscribeHandler handles RFC 8058 one-click unsubscribe requests sent by mail
+// clients to the List-Unsubscribe URL (/api/unsubscribe/{token}). Only POST unsubscribes;
+// a GET (e.g. a link scanner or a client without one-click support) is sent to the
+// preference page instead.
+func OneClickUnsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/api/unsubscribe/")
+
+	switch r.Method {
+	case http.MethodGet:
+		http.Redirect(w, r, "/preferences/"+token, http.StatusSeeOther)
+
+	case http.MethodPost:
+		member, err := GetVillageMemberByUnsubscribeToken(token)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Invalid or expired link", http.StatusNotFound)
+				return
+			}
+			log.Printf("Database error getting village member by token: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := SetVillageMemberSubscribed(member.ID, false); err != nil {
+			log.Printf("Failed to unsubscribe member %d: %v", member.ID, err)
+			http.Error(w, "Failed to unsubscribe", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Village member %d unsubscribed via one-click link", member.ID)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":       true,
+			"is_subscribed": false,
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func buildPreferencesResponse(member *models.VillageMember) (*PreferencesResponse, error) {
+	pregnancy, err := GetPregnancyByID(member.PregnancyID)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := GetUserByID(pregnancy.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	parentNames := owner.Name
+	if pregnancy.PartnerName != nil && *pregnancy.PartnerName != "" {
+		parentNames = owner.Name + " & " + *pregnancy.PartnerName
+	}
+
+	babyName := "Baby"
+	if pregnancy.BabyName != nil && *pregnancy.BabyName != "" {
+		babyName = *pregnancy.BabyName
+	}
+
+	return &PreferencesResponse{
+		Name:              member.Name,
+		Email:             member.Email,
+		ParentNames:       parentNames,
+		BabyName:          babyName,
+		IsSubscribed:      member.IsSubscribed,
+		DeliveryFrequency: member.DeliveryFrequency,
+	}, nil
+}
+
+// generateSecretToken creates the random secrets used in emailed links, such as a villager's
+// preference links and the approve/deny links for access requests
+func generateSecretToken() (string, error) {
+	// Generate 16 random bytes (will create 32 character hex string)
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// Database functions
+
+func GetVillageMemberByUnsubscribeToken(token string) (*models.VillageMember, error) {
+	if token == "" || strings.Contains(token, "/") {
+		return nil, sql.ErrNoRows
+	}
+
+	var memberID int
+	err := db.GetDB().QueryRow(`SELECT id FROM village_members WHERE unsubscribe_token = ?`, token).Scan(&memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetVillageMemberByID(memberID)
+}
+
+func SetVillageMemberSubscribed(memberID int, isSubscribed bool) error {
+	query := `UPDATE village_members SET is_subscribed = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	_, err := db.GetDB().Exec(query, isSubscribed, memberID)
+	return err
+}
+
+// SetVillageMemberDeliveryFrequency changes how often a member gets update emails. Switching onto
+// a digest starts the digest period from now so earlier updates aren't repeated.
+func SetVillageMemberDeliveryFrequency(memberID int, frequency string) error {
+	query := `
+		UPDATE village_members
+		SET last_digest_sent_at = CASE WHEN delivery_frequency != ? AND ? IN ('daily', 'weekly') THEN CURRENT_TIMESTAMP ELSE last_digest_sent_at END,
+			delivery_frequency = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+	_, err := db.GetDB().Exec(query, frequency, frequency, frequency, memberID)
+	return err
+}
@@ -342,27 +342,18 @@ func JoinVillageFromInviteHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Create village members for each email
-	var members []*models.VillageMember
-	for i, email := range req.Emails {
-		// For multiple emails, append number to name (e.g., "John & Jane" becomes "John & Jane (1)", "John & Jane (2)")
-		memberName := req.Name
-		if len(req.Emails) > 1 {
-			memberName = fmt.Sprintf("%s (%d)", req.Name, i+1)
-		}
-
-		member, err := CreateVillageMemberWithEvent(pregnancyID, memberName, email, req.Relationship, req.IsTold, true)
-		if err != nil {
-			log.Printf("Failed to create village member: %v", err)
-			http.Error(w, "Failed to create village member", http.StatusInternalServerError)
-			return
-		}
-		members = append(members, member)
+	// Create one household with a village member for each email
+	household, err := CreateHouseholdWithEvent(pregnancyID, req.Name, req.Emails, req.Relationship, req.IsTold, true)
+	if err != nil {
+		log.Printf("Failed to create village household: %v", err)
+		http.Error(w, "Failed to create village member", http.StatusInternalServerError)
+		return
 	}
 
 	response := map[string]interface{}{
-		"members": members,
-		"success": true,
+		"household": household,
+		"members":   household.Members,
+		"success":   true,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -481,7 +472,7 @@ func UpdatePregnancy(pregnancyID int, dueDate time.Time, partnerName, partnerEma
 
 func GetActivePregnancyByUserID(userID int) (*models.Pregnancy, error) {
 	query := `
-		SELECT id, user_id, partner_name, partner_email, due_date, conception_date, current_week, baby_name, is_active, share_id, cover_photo_filename, created_at, updated_at
+		SELECT id, user_id, partner_name, partner_email, due_date, conception_date, current_week, baby_name, is_active, share_id, cover_photo_filename, cover_photo_renditions, created_at, updated_at
 		FROM pregnancies 
 		WHERE user_id = ? AND is_active = TRUE
 		ORDER BY created_at DESC
@@ -501,6 +492,7 @@ func GetActivePregnancyByUserID(userID int) (*models.Pregnancy, error) {
 		&pregnancy.IsActive,
 		&pregnancy.ShareID,
 		&pregnancy.CoverPhotoFilename,
+		&pregnancy.CoverPhotoRenditions,
 		&pregnancy.CreatedAt,
 		&pregnancy.UpdatedAt,
 	)
@@ -540,7 +532,7 @@ func GetActivePregnancyForUser(userID int) (*models.Pregnancy, error) {
 
 func GetActivePregnancyByPartnerEmail(email string) (*models.Pregnancy, error) {
 	query := `
-		SELECT id, user_id, partner_name, partner_email, due_date, conception_date, current_week, baby_name, is_active, share_id, cover_photo_filename, created_at, updated_at
+		SELECT id, user_id, partner_name, partner_email, due_date, conception_date, current_week, baby_name, is_active, share_id, cover_photo_filename, cover_photo_renditions, created_at, updated_at
 		FROM pregnancies 
 		WHERE partner_email = ? AND is_active = TRUE
 		ORDER BY created_at DESC
@@ -560,6 +552,7 @@ func GetActivePregnancyByPartnerEmail(email string) (*models.Pregnancy, error) {
 		&pregnancy.IsActive,
 		&pregnancy.ShareID,
 		&pregnancy.CoverPhotoFilename,
+		&pregnancy.CoverPhotoRenditions,
 		&pregnancy.CreatedAt,
 		&pregnancy.UpdatedAt,
 	)
@@ -612,7 +605,7 @@ func intPtr(i int) *int {
 
 func GetPregnancyByShareID(shareID string) (*models.Pregnancy, error) {
 	query := `
-		SELECT id, user_id, partner_name, partner_email, due_date, conception_date, current_week, baby_name, is_active, share_id, cover_photo_filename, created_at, updated_at
+		SELECT id, user_id, partner_name, partner_email, due_date, conception_date, current_week, baby_name, is_active, share_id, cover_photo_filename, cover_photo_renditions, created_at, updated_at
 		FROM pregnancies 
 		WHERE share_id = ? AND is_active = TRUE
 		LIMIT 1
@@ -631,6 +624,7 @@ func GetPregnancyByShareID(shareID string) (*models.Pregnancy, error) {
 		&pregnancy.IsActive,
 		&pregnancy.ShareID,
 		&pregnancy.CoverPhotoFilename,
+		&pregnancy.CoverPhotoRenditions,
 		&pregnancy.CreatedAt,
 		&pregnancy.UpdatedAt,
 	)
@@ -644,7 +638,7 @@ func GetPregnancyByShareID(shareID string) (*models.Pregnancy, error) {
 
 func GetPregnancyByID(pregnancyID int) (*models.Pregnancy, error) {
 	query := `
-		SELECT id, user_id, partner_name, partner_email, due_date, conception_date, current_week, baby_name, is_active, share_id, cover_photo_filename, created_at, updated_at
+		SELECT id, user_id, partner_name, partner_email, due_date, conception_date, current_week, baby_name, is_active, share_id, cover_photo_filename, cover_photo_renditions, created_at, updated_at
 		FROM pregnancies 
 		WHERE id = ?
 		LIMIT 1
@@ -663,6 +657,7 @@ func GetPregnancyByID(pregnancyID int) (*models.Pregnancy, error) {
 		&pregnancy.IsActive,
 		&pregnancy.ShareID,
 		&pregnancy.CoverPhotoFilename,
+		&pregnancy.CoverPhotoRenditions,
 		&pregnancy.CreatedAt,
 		&pregnancy.UpdatedAt,
 	)
@@ -4,14 +4,18 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"simple-go/api/config"
 	"simple-go/api/db"
 	"simple-go/api/models"
+	"simple-go/api/services/markdown"
+	"simple-go/api/services/media"
 	"simple-go/api/services/email"
 )
 
@@ -20,11 +24,15 @@ type PublicTimelineItem struct {
 	ID          int                  `json:"id"`
 	Title       string               `json:"title"`
 	Description *string              `json:"description"`
+	DescriptionHTML *string          `json:"description_html,omitempty"` // Description rendered from Markdown
 	WeekNumber  *int                 `json:"week_number"`
 	UpdateDate  string               `json:"update_date"`
 	CreatedBy   string               `json:"created_by"`
-	Photos      []models.UpdatePhoto `json:"photos,omitempty"`
+	Media       []models.UpdateMedia `json:"media,omitempty"`
 	PregnancyID int                  `json:"pregnancy_id"`
+	Reactions    []models.ReactionCount `json:"reactions"`
+	CommentCount int                    `json:"comment_count"`
+	EditedAt     *time.Time             `json:"edited_at,omitempty"` // When a version the village saw was last edited
 }
 
 // VerifyAccessRequest represents a request to verify email access
@@ -43,6 +51,7 @@ type AccessRequest struct {
 	Name         string `json:"name"`
 	Relationship string `json:"relationship"`
 	Message      string `json:"message"`
+	Referrer     string `json:"referrer"` // name or email of the village member who referred them
 }
 
 // PublicTimelineHandler returns shared updates for a pregnancy via share ID
@@ -115,6 +124,27 @@ func PublicTimelineHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Count reactions and comments, marking the ones the viewer left when they came through
+	// their own link
+	var memberID int
+	member, err := GetVillageMemberByAccessToken(r.URL.Query().Get("member"))
+	if err == nil && member.PregnancyID == pregnancy.ID {
+		memberID = member.ID
+	} else if err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to look up village member: %v", err)
+	}
+	for i := range items {
+		items[i].Reactions, items[i].CommentCount, err = getUpdateFeedbackCounts(items[i].ID, memberID, false)
+		if err != nil {
+			log.Printf("Failed to count feedback on update %d: %v", items[i].ID, err)
+		}
+	}
+
+	// Record the visit for the parents' engagement view
+	if err := recordTimelineVisit(pregnancy.ID, email, items, offset); err != nil {
+		log.Printf("Failed to record timeline visit: %v", err)
+	}
+
 	// Get pregnancy info for context
 	var userName string
 	err = db.GetDB().QueryRow(`SELECT name FROM users WHERE id = ?`, pregnancy.UserID).Scan(&userName)
@@ -239,6 +269,82 @@ func VerifyTimelineAccessHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// SendTimelineLinkHandler emails a village member their own link to the timeline, which they need
+// to react, comment and search. Members who haven't been told yet aren't sent one, and it answers
+// the same whether or not the email is in the village.
+func SendTimelineLinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/timeline/")
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] != "send-link" {
+		http.Error(w, "Invalid URL path", http.StatusBadRequest)
+		return
+	}
+
+	var req VerifyAccessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Email == "" {
+		http.Error(w, "Email is required", http.StatusBadRequest)
+		return
+	}
+
+	pregnancy, err := GetPregnancyByShareID(parts[0])
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Pregnancy not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	var memberID int
+	err = db.GetDB().QueryRow(`
+		SELECT id FROM village_members
+		WHERE pregnancy_id = ? AND LOWER(email) = LOWER(?) AND is_told = TRUE
+		LIMIT 1
+	`, pregnancy.ID, req.Email).Scan(&memberID)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error looking up village member for timeline link: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if err == nil {
+		go sendTimelineLink(pregnancy, memberID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// sendTimelineLink emails a village member their own timeline link
+func sendTimelineLink(pregnancy *models.Pregnancy, memberID int) {
+	member, err := GetVillageMemberByID(memberID)
+	if err != nil {
+		log.Printf("Failed to get village member %d for timeline link: %v", memberID, err)
+		return
+	}
+
+	emailService, err := email.NewEmailService()
+	if err != nil {
+		log.Printf("Error creating email service: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	if err := emailService.SendTimelineLink(ctx, pregnancy, member); err != nil {
+		log.Printf("Failed to send timeline link to member %d: %v", memberID, err)
+	}
+}
+
 // RequestTimelineAccessHandler handles access requests from non-village members
 func RequestTimelineAccessHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -321,11 +427,22 @@ func RequestTimelineAccessHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Store the access request in the database
-	_, err = db.GetDB().Exec(`
-		INSERT INTO access_requests (pregnancy_id, email, name, relationship, message, status, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, 'pending', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-	`, pregnancy.ID, req.Email, req.Name, req.Relationship, req.Message)
+	// Secret for the signed approve/deny links in the owner's notification email
+	decisionNonce, err := generateSecretToken()
+	if err != nil {
+		log.Printf("Error generating access request nonce: %v", err)
+		http.Error(w, "Failed to store access request", http.StatusInternalServerError)
+		return
+	}
+
+	// Store the access request in the database. Requests nobody acts on expire after a while.
+	var requestID int
+	err = db.GetDB().QueryRow(`
+		INSERT INTO access_requests (pregnancy_id, email, name, relationship, message, referrer, status, expires_at, decision_nonce, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), 'pending', datetime('now', ?), ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id
+	`, pregnancy.ID, req.Email, req.Name, req.Relationship, req.Message, strings.TrimSpace(req.Referrer),
+		fmt.Sprintf("+%d days", config.AppConfig.AccessRequestExpiryDays), decisionNonce).Scan(&requestID)
 
 	if err != nil {
 		log.Printf("Error storing access request: %v", err)
@@ -336,27 +453,162 @@ func RequestTimelineAccessHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Access request stored for pregnancy %d: %s (%s) wants to join as %s", 
 		pregnancy.ID, req.Name, req.Email, req.Relationship)
 
-	// Send email notification to pregnancy owner
-	emailService, err := email.NewEmailService()
+	record, _, err := GetPendingAccessRequestByID(requestID)
 	if err != nil {
-		log.Printf("Error creating email service: %v", err)
-	} else {
-		// Send notification in the background to avoid blocking the response
-		go func() {
-			ctx := context.Background()
-			err := emailService.SendAccessRequestNotification(ctx, pregnancy.ID, req.Name, req.Email, req.Relationship, req.Message)
-			if err != nil {
-				log.Printf("Error sending access request notification: %v", err)
-			} else {
-				log.Printf("Access request notification sent for pregnancy %d", pregnancy.ID)
-			}
-		}()
+		log.Printf("Error getting access request %d: %v", requestID, err)
+		http.Error(w, "Failed to store access request", http.StatusInternalServerError)
+		return
+	}
+
+	// If one of the parents' rules covers this request it's let in once the requester confirms
+	// their email address, or the member they named confirms they referred them
+	rule, err := FindMatchingAccessRule(pregnancy.ID, req.Email, req.Relationship, req.Referrer)
+	if err != nil {
+		log.Printf("Error checking access request rules: %v", err)
+	} else if rule != nil {
+		confirmWith, err := sendAccessRequestConfirmation(pregnancy, record, rule, decisionNonce)
+		if err != nil {
+			log.Printf("Error asking for access request %d to be confirmed: %v", requestID, err)
+		} else {
+			log.Printf("Access request %d matched %s rule %d, waiting for the %s", requestID, rule.RuleType, rule.ID, confirmWith)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "confirm_with": confirmWith})
+			return
+		}
 	}
 
+	notifyOwnerOfAccessRequest(record, decisionNonce)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
+// sendAccessRequestConfirmation emails the link that lets a rule-matched request through, to the
+// requester or to the referrer the rule trusts, and says which ("requester" or "referrer")
+func sendAccessRequestConfirmation(pregnancy *models.Pregnancy, req *AccessRequestRecord, rule *models.AccessRequestRule, nonce string) (string, error) {
+	action := accessRuleConfirmation(rule)
+	toName, toEmail, confirmWith := req.Name, req.Email, "requester"
+	if action == accessConfirmVouch {
+		memberID, err := strconv.Atoi(rule.Value)
+		if err != nil {
+			return "", err
+		}
+		referrer, err := GetVillageMemberByID(memberID)
+		if err != nil {
+			return "", err
+		}
+		if referrer.Email == "" {
+			return "", fmt.Errorf("referrer %d has no email address", referrer.ID)
+		}
+		toName, toEmail, confirmWith = referrer.Name, referrer.Email, "referrer"
+	}
+
+	emailService, err := email.NewEmailService()
+	if err != nil {
+		return "", err
+	}
+
+	confirmURL := AccessRequestConfirmURL(req.ID, action, nonce)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		err := emailService.SendAccessRequestConfirmation(ctx, pregnancy, toName, toEmail, req.Name, req.Email, req.Relationship, confirmURL, action == accessConfirmVouch)
+		if err != nil {
+			// Nobody can confirm it now, so leave the decision to the parents
+			log.Printf("Error sending access request confirmation: %v", err)
+			notifyOwnerOfAccessRequest(req, nonce)
+		}
+	}()
+
+	return confirmWith, nil
+}
+
+// notifyOwnerOfAccessRequest emails the parents the signed links to approve or deny a request.
+// They're only emailed once per request, however many times it's handed to them.
+func notifyOwnerOfAccessRequest(req *AccessRequestRecord, nonce string) {
+	result, err := db.GetDB().Exec(`
+		UPDATE access_requests SET owner_notified_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND owner_notified_at IS NULL
+	`, req.ID)
+	if err != nil {
+		log.Printf("Error marking access request %d as notified: %v", req.ID, err)
+		return
+	}
+	if notified, err := result.RowsAffected(); err != nil || notified == 0 {
+		return
+	}
+
+	emailService, err := email.NewEmailService()
+	if err != nil {
+		log.Printf("Error creating email service: %v", err)
+		clearAccessRequestNotified(req.ID)
+		return
+	}
+
+	// Send notification in the background to avoid blocking the response
+	go func() {
+		ctx := context.Background()
+		approveURL, denyURL := AccessRequestDecisionURLs(req.ID, nonce)
+		err := emailService.SendAccessRequestNotification(ctx, req.PregnancyID, req.Name, req.Email, req.Relationship, req.Message, approveURL, denyURL)
+		if err != nil {
+			log.Printf("Error sending access request notification: %v", err)
+			clearAccessRequestNotified(req.ID)
+		} else {
+			log.Printf("Access request notification sent for pregnancy %d", req.PregnancyID)
+		}
+	}()
+}
+
+// clearAccessRequestNotified lets the parents be notified of a request again after the email
+// to them failed
+func clearAccessRequestNotified(requestID int) {
+	if _, err := db.GetDB().Exec(`UPDATE access_requests SET owner_notified_at = NULL WHERE id = ?`, requestID); err != nil {
+		log.Printf("Error clearing notified state of access request %d: %v", requestID, err)
+	}
+}
+
+// timelineViewer is a village member viewing a shared timeline through their own link
+type timelineViewer struct {
+	pregnancy *models.Pregnancy
+	memberID  int
+	name      string
+}
+
+// resolveTimelineViewer checks the request carries the access token from a village member's own link
+// to the timeline shared as shareID, writing the error if it doesn't. An email address alone
+// isn't enough, since anyone can type in someone else's.
+func resolveTimelineViewer(w http.ResponseWriter, r *http.Request, shareID string) (*timelineViewer, bool) {
+	token := r.URL.Query().Get("member")
+	if token == "" {
+		http.Error(w, "Member link required", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	pregnancy, err := GetPregnancyByShareID(shareID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Pregnancy not found", http.StatusNotFound)
+			return nil, false
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	member, err := GetVillageMemberByAccessToken(token)
+	if err == sql.ErrNoRows || (err == nil && member.PregnancyID != pregnancy.ID) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error looking up village member link: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return &timelineViewer{pregnancy: pregnancy, memberID: member.ID, name: member.Name}, true
+}
+
 // verifyEmailAccess checks if an email has access to view the timeline
 func verifyEmailAccess(pregnancy *models.Pregnancy, email string) (bool, error) {
 	// Check if email is the pregnancy owner
@@ -407,7 +659,7 @@ func getPublicTimelineItems(pregnancyID, limit, offset int) ([]PublicTimelineIte
 	FROM pregnancy_updates pu
 	JOIN pregnancies p ON p.id = pu.pregnancy_id
 	JOIN users u ON u.id = p.user_id
-	WHERE pu.pregnancy_id = ? AND pu.is_shared = TRUE
+	WHERE pu.pregnancy_id = ? AND pu.is_shared = TRUE AND pu.deleted_at IS NULL
 	ORDER BY update_date DESC, pu.id DESC
 	LIMIT ? OFFSET ?`
 
@@ -467,9 +719,14 @@ func getPublicTimelineItems(pregnancyID, limit, offset int) ([]PublicTimelineIte
 		item.CreatedBy = createdBy
 		item.PregnancyID = pregnancyID
 
+		if item.Description != nil {
+			descriptionHTML := markdown.ToHTML(*item.Description)
+			item.DescriptionHTML = &descriptionHTML
+		}
+
 		// Get photos/videos for this update
-		photos, _ := getUpdatePhotos(item.ID)
-		item.Photos = photos
+		item.Media, _ = getSignedUpdateMedia(item.ID, item.PregnancyID, media.ScopeShared)
+		item.EditedAt, _ = getUpdateEditedAt(item.ID, true)
 
 		items = append(items, item)
 	}
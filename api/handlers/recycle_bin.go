@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-go/api/db"
+	"simple-go/api/middleware"
+	"simple-go/api/models"
+)
+
+// DeletedUpdate is an update in the recycle bin
+type DeletedUpdate struct {
+	models.PregnancyUpdate
+	PurgeAt *time.Time `json:"purge_at"`
+}
+
+// DeleteUpdateHandler moves an update to the recycle bin (DELETE /api/updates/{id}). The update
+// disappears from every timeline and its update_posted event is retracted until it is restored.
+func DeleteUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	updateID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/updates/"))
+	if err != nil {
+		http.Error(w, "Invalid update ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get user's pregnancy (either as owner or partner)
+	pregnancy, err := GetActivePregnancyForUser(claims.UserID)
+	if err != nil {
+		log.Printf("Database error getting pregnancy: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if pregnancy == nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	deleted, err := SoftDeleteUpdate(updateID, pregnancy.ID)
+	if err != nil {
+		log.Printf("Failed to delete update %d: %v", updateID, err)
+		http.Error(w, "Failed to delete update", http.StatusInternalServerError)
+		return
+	}
+
+	if !deleted {
+		http.Error(w, "Update not found or access denied", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"purge_at": time.Now().AddDate(0, 0, models.UpdateRecycleBinDays),
+	})
+}
+
+// RestoreUpdateHandler brings an update back out of the recycle bin (POST /api/updates/{id}/restore)
+func RestoreUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/updates/")
+	updateID, err := strconv.Atoi(strings.TrimSuffix(path, "/restore"))
+	if err != nil {
+		http.Error(w, "Invalid update ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get user's pregnancy (either as owner or partner)
+	pregnancy, err := GetActivePregnancyForUser(claims.UserID)
+	if err != nil {
+		log.Printf("Database error getting pregnancy: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if pregnancy == nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	restored, err := RestoreUpdate(updateID, pregnancy.ID)
+	if err != nil {
+		log.Printf("Failed to restore update %d: %v", updateID, err)
+		http.Error(w, "Failed to restore update", http.StatusInternalServerError)
+		return
+	}
+
+	if !restored {
+		http.Error(w, "Update not found in the recycle bin", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// GetDeletedUpdatesHandler lists the updates in the recycle bin of the user's pregnancy (GET /api/updates/deleted)
+func GetDeletedUpdatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get user's pregnancy (either as owner or partner)
+	pregnancy, err := GetActivePregnancyForUser(claims.UserID)
+	if err != nil {
+		log.Printf("Database error getting pregnancy: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if pregnancy == nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	updates, err := GetDeletedUpdates(pregnancy.ID)
+	if err != nil {
+		log.Printf("Failed to get deleted updates: %v", err)
+		http.Error(w, "Failed to fetch deleted updates", http.StatusInternalServerError)
+		return
+	}
+
+	if updates == nil {
+		updates = []DeletedUpdate{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updates)
+}
+
+// PurgeDeletedUpdates permanently removes updates that have been in the recycle bin longer than
+// models.UpdateRecycleBinDays, along with their photo and video files and retracted events
+func PurgeDeletedUpdates() error {
+	rows, err := db.GetDB().Query(`
+		SELECT id, pregnancy_id FROM pregnancy_updates
+		WHERE deleted_at IS NOT NULL AND deleted_at <= datetime('now', ?)
+	`, fmt.Sprintf("-%d days", models.UpdateRecycleBinDays))
+	if err != nil {
+		return err
+	}
+
+	type purgeTarget struct {
+		updateID    int
+		pregnancyID int
+	}
+	var targets []purgeTarget
+	for rows.Next() {
+		var target purgeTarget
+		if err := rows.Scan(&target.updateID, &target.pregnancyID); err != nil {
+			rows.Close()
+			return err
+		}
+		targets = append(targets, target)
+	}
+	rows.Close()
+
+	for _, target := range targets {
+		if err := purgeUpdate(target.updateID, target.pregnancyID); err != nil {
+			log.Printf("Failed to purge update %d: %v", target.updateID, err)
+			continue
+		}
+		log.Printf("Purged deleted update %d from pregnancy %d", target.updateID, target.pregnancyID)
+	}
+
+	return nil
+}
+
+// purgeUpdate deletes an update's rows and then its media files, including photo renditions.
+// The files go last so a failed delete never leaves rows pointing at missing files; a file that
+// can't be removed afterwards is only logged.
+func purgeUpdate(updateID, pregnancyID int) error {
+	items, err := getUpdateMedia(updateID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		DELETE FROM media_tags
+		WHERE media_id IN (SELECT id FROM update_media WHERE update_id = ?)
+	`, updateID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM update_media WHERE update_id = ?`, updateID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM update_reactions WHERE update_id = ?`, updateID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM update_comments WHERE update_id = ?`, updateID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM update_revisions WHERE update_id = ?`, updateID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM pregnancy_events
+		WHERE event_type = ? AND json_extract(event_data, '$.update_id') = ?
+	`, models.EventUpdatePosted, updateID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM pregnancy_updates WHERE id = ?`, updateID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := removeStoredMedia(context.Background(), pregnancyID, item); err != nil {
+			log.Printf("Failed to remove media %d of purged update %d: %v", item.ID, updateID, err)
+		}
+	}
+
+	return nil
+}
+
+// Database functions
+
+// SoftDeleteUpdate moves one of a pregnancy's updates to the recycle bin and retracts its event
+func SoftDeleteUpdate(updateID, pregnancyID int) (bool, error) {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE pregnancy_updates
+		SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND pregnancy_id = ? AND deleted_at IS NULL
+	`, updateID, pregnancyID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		return false, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE pregnancy_events
+		SET retracted_at = CURRENT_TIMESTAMP
+		WHERE event_type = ? AND json_extract(event_data, '$.update_id') = ? AND retracted_at IS NULL
+	`, models.EventUpdatePosted, updateID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// RestoreUpdate takes one of a pregnancy's updates out of the recycle bin and reinstates its event
+func RestoreUpdate(updateID, pregnancyID int) (bool, error) {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE pregnancy_updates
+		SET deleted_at = NULL
+		WHERE id = ? AND pregnancy_id = ? AND deleted_at IS NOT NULL
+	`, updateID, pregnancyID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		return false, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE pregnancy_events
+		SET retracted_at = NULL
+		WHERE event_type = ? AND json_extract(event_data, '$.update_id') = ?
+	`, models.EventUpdatePosted, updateID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// GetDeletedUpdates returns the updates in a pregnancy's recycle bin, most recently deleted first
+func GetDeletedUpdates(pregnancyID int) ([]DeletedUpdate, error) {
+	rows, err := db.GetDB().Query(`
+		SELECT pu.id, pu.pregnancy_id, pu.week_number, pu.title, pu.content, pu.update_type,
+		       pu.appointment_type, pu.is_shared, pu.shared_at, pu.update_date, pu.created_at, pu.updated_at, pu.deleted_at
+		FROM pregnancy_updates pu
+		WHERE pu.pregnancy_id = ? AND pu.deleted_at IS NOT NULL
+		ORDER BY pu.deleted_at DESC, pu.id DESC
+	`, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var updates []DeletedUpdate
+	for rows.Next() {
+		var update DeletedUpdate
+		err := rows.Scan(&update.ID, &update.PregnancyID, &update.WeekNumber, &update.Title,
+			&update.Content, &update.UpdateType, &update.AppointmentType, &update.IsShared,
+			&update.SharedAt, &update.UpdateDate, &update.CreatedAt, &update.UpdatedAt, &update.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		update.PurgeAt = update.PregnancyUpdate.PurgeAt()
+		updates = append(updates, update)
+	}
+
+	return updates, nil
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"simple-go/api/db"
+	"simple-go/api/models"
+	"simple-go/api/services/email"
+)
+
+// parseUpdateSchedule validates the optional publish_at and publish_week fields of an update
+// request. Either one schedules the update to be shared automatically; both may be set, in
+// which case whichever comes first wins.
+func parseUpdateSchedule(req *CreateUpdateRequest) (*time.Time, *int, error) {
+	var publishAt *time.Time
+	if req.PublishAt != nil && *req.PublishAt != "" {
+		parsed, err := time.Parse(time.RFC3339, *req.PublishAt)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid publish time format")
+		}
+		if !parsed.After(time.Now()) {
+			return nil, nil, fmt.Errorf("Publish time must be in the future")
+		}
+		parsed = parsed.UTC()
+		publishAt = &parsed
+	}
+
+	publishWeek := req.PublishWeek
+	if publishWeek != nil && (*publishWeek < models.MinPublishWeek || *publishWeek > models.MaxPublishWeek) {
+		return nil, nil, fmt.Errorf("Publish week must be between %d and %d", models.MinPublishWeek, models.MaxPublishWeek)
+	}
+
+	return publishAt, publishWeek, nil
+}
+
+// PublishScheduledUpdates shares every update whose publish time has passed or whose publish
+// week has begun. Each one is published like a manual share: the update_posted event is
+// created and the village is emailed.
+func PublishScheduledUpdates() error {
+	rows, err := db.GetDB().Query(`
+		SELECT pu.id, pu.pregnancy_id, pu.publish_at, pu.publish_week
+		FROM pregnancy_updates pu
+		JOIN pregnancies p ON p.id = pu.pregnancy_id
+		WHERE pu.is_shared = FALSE AND pu.deleted_at IS NULL AND p.is_active = TRUE
+		  AND (pu.publish_at IS NOT NULL OR pu.publish_week IS NOT NULL)
+	`)
+	if err != nil {
+		return err
+	}
+
+	type scheduledUpdate struct {
+		updateID    int
+		pregnancyID int
+		publishAt   *time.Time
+		publishWeek *int
+	}
+	var scheduled []scheduledUpdate
+	for rows.Next() {
+		var update scheduledUpdate
+		if err := rows.Scan(&update.updateID, &update.pregnancyID, &update.publishAt, &update.publishWeek); err != nil {
+			rows.Close()
+			return err
+		}
+		scheduled = append(scheduled, update)
+	}
+	rows.Close()
+
+	now := time.Now()
+	for _, update := range scheduled {
+		pregnancy, err := GetPregnancyByID(update.pregnancyID)
+		if err != nil || pregnancy == nil {
+			log.Printf("Failed to get pregnancy %d for scheduled update %d: %v", update.pregnancyID, update.updateID, err)
+			continue
+		}
+
+		currentWeek := pregnancy.GetCurrentWeek()
+		due := (update.publishAt != nil && !update.publishAt.After(now)) ||
+			(update.publishWeek != nil && currentWeek >= *update.publishWeek)
+		if !due {
+			continue
+		}
+
+		if err := publishScheduledUpdate(update.updateID, pregnancy); err != nil {
+			log.Printf("Failed to publish scheduled update %d: %v", update.updateID, err)
+		}
+	}
+
+	return nil
+}
+
+// publishScheduledUpdate shares a single scheduled update. The update keeps the date and week
+// the parents wrote it for; only its shared_at records when it went out.
+func publishScheduledUpdate(updateID int, pregnancy *models.Pregnancy) error {
+	// Only the run that flips is_shared goes on to notify, so an update is never announced twice
+	result, err := db.GetDB().Exec(`
+		UPDATE pregnancy_updates
+		SET is_shared = TRUE, shared_at = ?, publish_at = NULL, publish_week = NULL
+		WHERE id = ? AND is_shared = FALSE AND deleted_at IS NULL
+	`, time.Now().UTC(), updateID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		return err
+	}
+	log.Printf("Published scheduled update %d for pregnancy %d", updateID, pregnancy.ID)
+
+	var update models.PregnancyUpdate
+	err = db.GetDB().QueryRow(`
+		SELECT id, pregnancy_id, week_number, title, content, update_type, appointment_type, is_shared, shared_at, update_date, created_at, updated_at
+		FROM pregnancy_updates WHERE id = ?`, updateID).Scan(
+		&update.ID, &update.PregnancyID, &update.WeekNumber, &update.Title, &update.Content,
+		&update.UpdateType, &update.AppointmentType, &update.IsShared, &update.SharedAt, &update.UpdateDate,
+		&update.CreatedAt, &update.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to fetch published update: %w", err)
+	}
+
+	var userName string
+	db.GetDB().QueryRow(`SELECT name FROM users WHERE id = ?`, pregnancy.UserID).Scan(&userName)
+
+	eventTitle := fmt.Sprintf("%s shared an update", userName)
+	eventDescription := update.Title
+	if update.Content != nil && *update.Content != "" {
+		eventDescription = *update.Content
+	}
+
+	if err := CreateUpdateSharedEvent(pregnancy.ID, pregnancy.UserID, update.ID, eventTitle, eventDescription, update.WeekNumber); err != nil {
+		log.Printf("Failed to create event for scheduled update %d: %v", update.ID, err)
+	}
+
+	emailService, err := email.NewEmailService()
+	if err != nil {
+		return fmt.Errorf("failed to initialize email service: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if err := emailService.SendUpdateNotification(ctx, &update, pregnancy); err != nil {
+		return fmt.Errorf("failed to send update notification: %w", err)
+	}
+	return nil
+}
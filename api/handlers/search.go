@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"simple-go/api/db"
+	"simple-go/api/middleware"
+	"simple-go/api/models"
+)
+
+// Marks around matching words in highlighted text, swapped for <mark> once the text is escaped
+const (
+	searchMatchStart = "\x02"
+	searchMatchEnd   = "\x03"
+)
+
+// searchQuery is a parsed search: the FTS5 expression to match and the filters on it
+type searchQuery struct {
+	match    string
+	kinds    []string
+	weekFrom *int
+	weekTo   *int
+	limit    int
+	offset   int
+}
+
+// SearchHandler searches the parents' timeline: updates, events, captions and comments
+// (GET /api/search?q=&type=&week_from=&week_to=)
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pregnancy, err := GetActivePregnancyForUser(claims.UserID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if pregnancy == nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	query, err := parseSearchQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeSearchResults(w, pregnancy.ID, query, false)
+}
+
+// PublicSearchHandler searches what the village can see: updates shared with them, their
+// captions and the comments the parents haven't hidden
+// (GET /api/timeline/{shareID}/search?member=&q=&type=&week_from=&week_to=)
+func PublicSearchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/timeline/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "search" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	viewer, ok := resolveTimelineViewer(w, r, parts[0])
+	if !ok {
+		return
+	}
+
+	query, err := parseSearchQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeSearchResults(w, viewer.pregnancy.ID, query, true)
+}
+
+func writeSearchResults(w http.ResponseWriter, pregnancyID int, query *searchQuery, sharedOnly bool) {
+	results, err := searchTimeline(pregnancyID, query, sharedOnly)
+	if err != nil {
+		log.Printf("Failed to search pregnancy %d: %v", pregnancyID, err)
+		http.Error(w, "Failed to search", http.StatusInternalServerError)
+		return
+	}
+
+	if results == nil {
+		results = []models.SearchResult{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"results": results,
+		"limit":   query.limit,
+		"offset":  query.offset,
+	})
+}
+
+// parseSearchQuery reads the search words and filters from the query string. Every word has to
+// match, as a word or the start of one.
+func parseSearchQuery(r *http.Request) (*searchQuery, error) {
+	params := r.URL.Query()
+
+	text := strings.TrimSpace(params.Get("q"))
+	if len([]rune(text)) > models.MaxSearchQueryLength {
+		return nil, fmt.Errorf("Searches can be at most %d characters", models.MaxSearchQueryLength)
+	}
+
+	// Words are quoted so nothing typed is read as FTS5 syntax
+	words := strings.FieldsFunc(text, func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsNumber(c)
+	})
+	if len(words) == 0 {
+		return nil, fmt.Errorf("Search words are required")
+	}
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = `"` + word + `"*`
+	}
+	query := &searchQuery{match: strings.Join(terms, " "), limit: 20}
+
+	if kinds := params.Get("type"); kinds != "" {
+		for _, kind := range strings.Split(kinds, ",") {
+			kind = strings.TrimSpace(kind)
+			if !isSearchKind(kind) {
+				return nil, fmt.Errorf("Invalid type %q: must be one of %s", kind, strings.Join(models.SearchKinds, ", "))
+			}
+			query.kinds = append(query.kinds, kind)
+		}
+	}
+
+	for _, name := range []string{"week_from", "week_to"} {
+		value := params.Get(name)
+		if value == "" {
+			continue
+		}
+		week, err := strconv.Atoi(value)
+		if err != nil || week < 0 {
+			return nil, fmt.Errorf("%s must be a week number", name)
+		}
+		if name == "week_from" {
+			query.weekFrom = &week
+		} else {
+			query.weekTo = &week
+		}
+	}
+	if query.weekFrom != nil && query.weekTo != nil && *query.weekFrom > *query.weekTo {
+		return nil, fmt.Errorf("week_from can't be after week_to")
+	}
+
+	if limitStr := params.Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 100 {
+			query.limit = parsedLimit
+		}
+	}
+	if offsetStr := params.Get("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+			query.offset = parsedOffset
+		}
+	}
+
+	return query, nil
+}
+
+func isSearchKind(kind string) bool {
+	for _, searchKind := range models.SearchKinds {
+		if kind == searchKind {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightHTML escapes text from the search index and marks the words that matched
+func highlightHTML(text string) string {
+	escaped := html.EscapeString(text)
+	escaped = strings.ReplaceAll(escaped, searchMatchStart, "<mark>")
+	return strings.ReplaceAll(escaped, searchMatchEnd, "</mark>")
+}
+
+// Database functions
+
+// searchTimeline finds the updates, events, captions and comments of a pregnancy that match a
+// search, best match first. With sharedOnly, only what the village can see is searched: shared
+// updates, their captions and comments that aren't hidden, and no events.
+func searchTimeline(pregnancyID int, query *searchQuery, sharedOnly bool) ([]models.SearchResult, error) {
+	conditions := []string{"COALESCE(pu.pregnancy_id, pe.pregnancy_id) = ?"}
+	args := []interface{}{
+		searchMatchStart, searchMatchEnd, searchMatchStart, searchMatchEnd, query.match, pregnancyID,
+	}
+
+	if sharedOnly {
+		conditions = append(conditions, `h.kind != 'event' AND pu.is_shared = TRUE
+			AND (h.kind != 'comment' OR (uc.hidden_at IS NULL AND thread.hidden_at IS NULL))`)
+	}
+	if len(query.kinds) > 0 {
+		conditions = append(conditions, "h.kind IN (?"+strings.Repeat(", ?", len(query.kinds)-1)+")")
+		for _, kind := range query.kinds {
+			args = append(args, kind)
+		}
+	}
+	if query.weekFrom != nil {
+		conditions = append(conditions, "COALESCE(pu.week_number, pe.week_number) >= ?")
+		args = append(args, *query.weekFrom)
+	}
+	if query.weekTo != nil {
+		conditions = append(conditions, "COALESCE(pu.week_number, pe.week_number) <= ?")
+		args = append(args, *query.weekTo)
+	}
+	args = append(args, query.limit, query.offset)
+
+	rows, err := db.GetDB().Query(`
+		WITH hits AS (
+			SELECT kind, item_id,
+			       highlight(search_index, 2, ?, ?) AS title,
+			       snippet(search_index, 3, ?, ?, '…', 24) AS snippet,
+			       rank
+			FROM search_index
+			WHERE search_index MATCH ?
+		)
+		SELECT h.kind, h.item_id, pu.id, pu.title, h.title, h.snippet, pu.is_shared,
+		       COALESCE(pu.week_number, pe.week_number),
+		       COALESCE(pu.update_date, pu.created_at, pe.created_at)
+		FROM hits h
+		LEFT JOIN pregnancy_events pe ON h.kind = 'event' AND pe.id = h.item_id AND pe.retracted_at IS NULL
+		LEFT JOIN update_media um ON h.kind = 'caption' AND um.id = h.item_id
+		LEFT JOIN update_comments uc ON h.kind = 'comment' AND uc.id = h.item_id
+		LEFT JOIN update_comments thread ON thread.id = uc.parent_id
+		LEFT JOIN pregnancy_updates pu ON pu.deleted_at IS NULL AND pu.id = CASE h.kind
+			WHEN 'update' THEN h.item_id
+			WHEN 'caption' THEN um.update_id
+			WHEN 'comment' THEN uc.update_id
+		END
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY h.rank, h.item_id DESC
+		LIMIT ? OFFSET ?
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.SearchResult
+	for rows.Next() {
+		var result models.SearchResult
+		var updateTitle, title *string
+		var snippet string
+		var isShared *bool
+		err := rows.Scan(&result.Type, &result.ID, &result.UpdateID, &updateTitle, &title, &snippet,
+			&isShared, &result.WeekNumber, &result.Date)
+		if err != nil {
+			return nil, err
+		}
+
+		// Captions and comments are shown under the title of their update
+		if title != nil && *title != "" {
+			result.TitleHTML = highlightHTML(*title)
+			result.Title = strings.NewReplacer(searchMatchStart, "", searchMatchEnd, "").Replace(*title)
+		} else if updateTitle != nil {
+			result.Title = *updateTitle
+			result.TitleHTML = html.EscapeString(*updateTitle)
+		}
+		result.SnippetHTML = highlightHTML(snippet)
+
+		if !sharedOnly {
+			result.IsShared = isShared
+		}
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	week := func(n int) *int { return &n }
+
+	tests := []struct {
+		name     string
+		query    string
+		expected *searchQuery
+		err      string
+	}{
+		{
+			name:     "single word",
+			query:    "q=heartbeat",
+			expected: &searchQuery{match: `"heartbeat"*`, limit: 20},
+		},
+		{
+			name:     "every word is quoted",
+			query:    "q=first+kick",
+			expected: &searchQuery{match: `"first"* "kick"*`, limit: 20},
+		},
+		{
+			name:     "FTS5 syntax is dropped",
+			query:    `q=` + strings.NewReplacer(" ", "+", `"`, "%22", "*", "%2A").Replace(`kick" OR title:* NEAR(a b)`),
+			expected: &searchQuery{match: `"kick"* "OR"* "title"* "NEAR"* "a"* "b"*`, limit: 20},
+		},
+		{
+			name:     "letters beyond ASCII",
+			query:    "q=b%C3%A9b%C3%A9+12",
+			expected: &searchQuery{match: `"bébé"* "12"*`, limit: 20},
+		},
+		{
+			name:     "filters",
+			query:    "q=scan&type=update,+caption&week_from=10&week_to=20&limit=50&offset=40",
+			expected: &searchQuery{match: `"scan"*`, kinds: []string{"update", "caption"}, weekFrom: week(10), weekTo: week(20), limit: 50, offset: 40},
+		},
+		{
+			name:     "out of range limit and offset are ignored",
+			query:    "q=scan&limit=500&offset=-1",
+			expected: &searchQuery{match: `"scan"*`, limit: 20},
+		},
+		{
+			name:  "no words",
+			query: "q=%22*()",
+			err:   "Search words are required",
+		},
+		{
+			name:  "missing query",
+			query: "type=update",
+			err:   "Search words are required",
+		},
+		{
+			name:  "too long",
+			query: "q=" + strings.Repeat("a", 201),
+			err:   "Searches can be at most 200 characters",
+		},
+		{
+			name:  "unknown type",
+			query: "q=scan&type=update,photo",
+			err:   `Invalid type "photo"`,
+		},
+		{
+			name:  "invalid week",
+			query: "q=scan&week_from=ten",
+			err:   "week_from must be a week number",
+		},
+		{
+			name:  "negative week",
+			query: "q=scan&week_to=-2",
+			err:   "week_to must be a week number",
+		},
+		{
+			name:  "weeks the wrong way round",
+			query: "q=scan&week_from=20&week_to=10",
+			err:   "week_from can't be after week_to",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseSearchQuery(httptest.NewRequest("GET", "/api/search?"+tt.query, nil))
+			if tt.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Fatalf("Expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,562 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-go/api/db"
+	"simple-go/api/middleware"
+	"simple-go/api/models"
+	emailservice "simple-go/api/services/email"
+)
+
+// TellWaveRequest represents a request to create or update a wave of the announcement plan
+type TellWaveRequest struct {
+	Name       string   `json:"name"`
+	TargetWeek *int     `json:"target_week"`
+	TargetDate *string  `json:"target_date"` // YYYY-MM-DD
+	MemberIDs  []int    `json:"member_ids"`
+	Circles    []string `json:"circles"` // relationship types, e.g. "grandparent"
+}
+
+// TellPlanHandler lists the announcement plan or adds a new wave to it
+func TellPlanHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get user's pregnancy (either as owner or partner)
+	pregnancy, err := GetActivePregnancyForUser(claims.UserID)
+	if err != nil {
+		log.Printf("Database error getting pregnancy: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if pregnancy == nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		waves, err := GetTellWavesByPregnancyID(pregnancy)
+		if err != nil {
+			log.Printf("Database error getting tell plan: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		if waves == nil {
+			waves = []*models.TellWave{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(waves)
+
+	case http.MethodPost:
+		req, targetDate, ok := decodeTellWaveRequest(w, r, pregnancy.ID)
+		if !ok {
+			return
+		}
+
+		waveID, err := CreateTellWave(pregnancy.ID, req, targetDate)
+		if err != nil {
+			log.Printf("Failed to create tell wave: %v", err)
+			http.Error(w, "Failed to create wave", http.StatusInternalServerError)
+			return
+		}
+
+		wave, err := GetTellWaveByID(waveID, pregnancy)
+		if err != nil {
+			log.Printf("Database error getting tell wave: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(wave)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// TellWaveHandler updates, deletes or confirms a single wave of the announcement plan
+func TellWaveHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Extract wave ID from URL path (/api/tell-plan/{id} or /api/tell-plan/{id}/confirm)
+	path := strings.TrimPrefix(r.URL.Path, "/api/tell-plan/")
+	parts := strings.Split(path, "/")
+	waveID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, "Invalid wave ID", http.StatusBadRequest)
+		return
+	}
+	isConfirm := len(parts) > 1 && parts[1] == "confirm"
+
+	// Get user's pregnancy (either as owner or partner)
+	pregnancy, err := GetActivePregnancyForUser(claims.UserID)
+	if err != nil {
+		log.Printf("Database error getting pregnancy: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if pregnancy == nil {
+		http.Error(w, "No active pregnancy found", http.StatusNotFound)
+		return
+	}
+
+	// Verify the wave belongs to this user's pregnancy
+	wave, err := GetTellWaveByID(waveID, pregnancy)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Wave not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Database error getting tell wave: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	switch {
+	case isConfirm && r.Method == http.MethodPost:
+		if wave.CompletedAt != nil {
+			http.Error(w, "Wave has already been confirmed", http.StatusConflict)
+			return
+		}
+
+		told, err := ConfirmTellWave(wave, pregnancy, claims.UserID)
+		if err != nil {
+			log.Printf("Failed to confirm tell wave: %v", err)
+			http.Error(w, "Failed to confirm wave", http.StatusInternalServerError)
+			return
+		}
+
+		wave, err = GetTellWaveByID(waveID, pregnancy)
+		if err != nil {
+			log.Printf("Database error getting tell wave: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"wave":       wave,
+			"told_count": told,
+		})
+
+	case !isConfirm && r.Method == "PUT":
+		req, targetDate, ok := decodeTellWaveRequest(w, r, pregnancy.ID)
+		if !ok {
+			return
+		}
+
+		if err := UpdateTellWave(waveID, req, targetDate); err != nil {
+			log.Printf("Failed to update tell wave: %v", err)
+			http.Error(w, "Failed to update wave", http.StatusInternalServerError)
+			return
+		}
+
+		wave, err = GetTellWaveByID(waveID, pregnancy)
+		if err != nil {
+			log.Printf("Database error getting tell wave: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(wave)
+
+	case !isConfirm && r.Method == http.MethodDelete:
+		if _, err := db.GetDB().Exec(`DELETE FROM tell_waves WHERE id = ?`, waveID); err != nil {
+			log.Printf("Failed to delete tell wave: %v", err)
+			http.Error(w, "Failed to delete wave", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// decodeTellWaveRequest reads and validates a wave request body, writing an error response if invalid
+func decodeTellWaveRequest(w http.ResponseWriter, r *http.Request, pregnancyID int) (*TellWaveRequest, *time.Time, bool) {
+	var req TellWaveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	var targetDate *time.Time
+	if req.TargetDate != nil && *req.TargetDate != "" {
+		parsed, err := time.Parse("2006-01-02", *req.TargetDate)
+		if err != nil {
+			http.Error(w, "Invalid target date format, use YYYY-MM-DD", http.StatusBadRequest)
+			return nil, nil, false
+		}
+		targetDate = &parsed
+	}
+
+	if req.TargetWeek == nil && targetDate == nil {
+		http.Error(w, "A target week or target date is required", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	if req.TargetWeek != nil && (*req.TargetWeek < 1 || *req.TargetWeek > 42) {
+		http.Error(w, "Target week must be between 1 and 42", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	for _, memberID := range req.MemberIDs {
+		member, err := GetVillageMemberByID(memberID)
+		if err != nil || member.PregnancyID != pregnancyID {
+			http.Error(w, fmt.Sprintf("Village member %d not found", memberID), http.StatusBadRequest)
+			return nil, nil, false
+		}
+	}
+
+	for i, circle := range req.Circles {
+		req.Circles[i] = strings.ToLower(strings.TrimSpace(circle))
+	}
+
+	return &req, targetDate, true
+}
+
+// ConfirmTellWave marks every pending member of the wave as told, sends their welcome
+// emails and records the wave as completed. It returns the number of members told.
+func ConfirmTellWave(wave *models.TellWave, pregnancy *models.Pregnancy, userID int) (int, error) {
+	pending := wave.PendingMembers()
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	for _, member := range pending {
+		_, err := tx.Exec(`
+			UPDATE village_members
+			SET is_told = TRUE, told_date = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?
+		`, member.ID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE tell_waves SET completed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, wave.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	// One event for the whole wave, naming each household once
+	var names []string
+	seen := make(map[string]bool)
+	for _, member := range pending {
+		if !seen[member.Name] {
+			seen[member.Name] = true
+			names = append(names, member.Name)
+		}
+	}
+
+	weekNumber := pregnancy.GetCurrentWeek()
+	if err := CreateTellWaveCompletedEvent(pregnancy.ID, wave.Name, names, userID, &weekNumber); err != nil {
+		log.Printf("Failed to create tell wave completed event: %v", err)
+	}
+
+	if len(pending) > 0 {
+		sendWelcomeEmails(pregnancy, pending)
+	}
+
+	return len(pending), nil
+}
+
+// SendDueTellWaveReminders emails the parents about waves that have reached their target
+// week or date. Each wave is reminded about once.
+func SendDueTellWaveReminders() error {
+	rows, err := db.GetDB().Query(`
+		SELECT tw.id, tw.pregnancy_id
+		FROM tell_waves tw
+		JOIN pregnancies p ON p.id = tw.pregnancy_id
+		WHERE tw.completed_at IS NULL AND tw.reminder_sent_at IS NULL AND p.is_active = TRUE
+	`)
+	if err != nil {
+		return err
+	}
+
+	type waveRef struct {
+		waveID      int
+		pregnancyID int
+	}
+	var refs []waveRef
+	for rows.Next() {
+		var ref waveRef
+		if err := rows.Scan(&ref.waveID, &ref.pregnancyID); err != nil {
+			rows.Close()
+			return err
+		}
+		refs = append(refs, ref)
+	}
+	rows.Close()
+
+	if len(refs) == 0 {
+		return nil
+	}
+
+	emailService, err := emailservice.NewEmailService()
+	if err != nil {
+		return fmt.Errorf("failed to initialize email service: %w", err)
+	}
+
+	now := time.Now()
+	for _, ref := range refs {
+		pregnancy, err := GetPregnancyByID(ref.pregnancyID)
+		if err != nil {
+			log.Printf("Failed to get pregnancy %d for tell wave reminder: %v", ref.pregnancyID, err)
+			continue
+		}
+
+		wave, err := GetTellWaveByID(ref.waveID, pregnancy)
+		if err != nil {
+			log.Printf("Failed to get tell wave %d: %v", ref.waveID, err)
+			continue
+		}
+
+		if !wave.IsDueAt(pregnancy.GetCurrentWeek(), now) || len(wave.PendingMembers()) == 0 {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		err = emailService.SendTellWaveReminder(ctx, wave, pregnancy)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to send tell wave reminder for wave %d: %v", wave.ID, err)
+			continue
+		}
+
+		if _, err := db.GetDB().Exec(`UPDATE tell_waves SET reminder_sent_at = CURRENT_TIMESTAMP WHERE id = ?`, wave.ID); err != nil {
+			log.Printf("Failed to mark tell wave %d as reminded: %v", wave.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// Database functions
+
+func CreateTellWave(pregnancyID int, req *TellWaveRequest, targetDate *time.Time) (int, error) {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var waveID int
+	err = tx.QueryRow(`
+		INSERT INTO tell_waves (pregnancy_id, name, target_week, target_date)
+		VALUES (?, ?, ?, ?)
+		RETURNING id
+	`, pregnancyID, req.Name, req.TargetWeek, targetDate).Scan(&waveID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := replaceTellWaveTargets(tx, waveID, req); err != nil {
+		return 0, err
+	}
+
+	return waveID, tx.Commit()
+}
+
+// UpdateTellWave replaces a wave's details and targets. Changing the wave resets its
+// reminder so the parents are reminded again at the new time.
+func UpdateTellWave(waveID int, req *TellWaveRequest, targetDate *time.Time) error {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE tell_waves
+		SET name = ?, target_week = ?, target_date = ?, reminder_sent_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, req.Name, req.TargetWeek, targetDate, waveID)
+	if err != nil {
+		return err
+	}
+
+	if err := replaceTellWaveTargets(tx, waveID, req); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func replaceTellWaveTargets(tx *sql.Tx, waveID int, req *TellWaveRequest) error {
+	if _, err := tx.Exec(`DELETE FROM tell_wave_members WHERE wave_id = ?`, waveID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM tell_wave_circles WHERE wave_id = ?`, waveID); err != nil {
+		return err
+	}
+
+	for _, memberID := range req.MemberIDs {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO tell_wave_members (wave_id, village_member_id) VALUES (?, ?)`, waveID, memberID); err != nil {
+			return err
+		}
+	}
+
+	for _, circle := range req.Circles {
+		if circle == "" {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO tell_wave_circles (wave_id, relationship) VALUES (?, ?)`, waveID, circle); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetTellWavesByPregnancyID returns the announcement plan in target order with members resolved
+func GetTellWavesByPregnancyID(pregnancy *models.Pregnancy) ([]*models.TellWave, error) {
+	rows, err := db.GetDB().Query(`
+		SELECT id FROM tell_waves
+		WHERE pregnancy_id = ?
+		ORDER BY COALESCE(target_week, 99) ASC, target_date ASC, id ASC
+	`, pregnancy.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var waveIDs []int
+	for rows.Next() {
+		var waveID int
+		if err := rows.Scan(&waveID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		waveIDs = append(waveIDs, waveID)
+	}
+	rows.Close()
+
+	var waves []*models.TellWave
+	for _, waveID := range waveIDs {
+		wave, err := GetTellWaveByID(waveID, pregnancy)
+		if err != nil {
+			return nil, err
+		}
+		waves = append(waves, wave)
+	}
+
+	return waves, nil
+}
+
+// GetTellWaveByID loads a wave of the pregnancy's plan and resolves its members from
+// direct assignments and relationship circles
+func GetTellWaveByID(waveID int, pregnancy *models.Pregnancy) (*models.TellWave, error) {
+	var wave models.TellWave
+	err := db.GetDB().QueryRow(`
+		SELECT id, pregnancy_id, name, target_week, target_date, reminder_sent_at, completed_at, created_at, updated_at
+		FROM tell_waves
+		WHERE id = ? AND pregnancy_id = ?
+	`, waveID, pregnancy.ID).Scan(
+		&wave.ID,
+		&wave.PregnancyID,
+		&wave.Name,
+		&wave.TargetWeek,
+		&wave.TargetDate,
+		&wave.ReminderSentAt,
+		&wave.CompletedAt,
+		&wave.CreatedAt,
+		&wave.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	wave.MemberIDs = []int{}
+	memberRows, err := db.GetDB().Query(`SELECT village_member_id FROM tell_wave_members WHERE wave_id = ?`, waveID)
+	if err != nil {
+		return nil, err
+	}
+	for memberRows.Next() {
+		var memberID int
+		if err := memberRows.Scan(&memberID); err != nil {
+			memberRows.Close()
+			return nil, err
+		}
+		wave.MemberIDs = append(wave.MemberIDs, memberID)
+	}
+	memberRows.Close()
+
+	wave.Circles = []string{}
+	circleRows, err := db.GetDB().Query(`SELECT relationship FROM tell_wave_circles WHERE wave_id = ? ORDER BY relationship`, waveID)
+	if err != nil {
+		return nil, err
+	}
+	for circleRows.Next() {
+		var circle string
+		if err := circleRows.Scan(&circle); err != nil {
+			circleRows.Close()
+			return nil, err
+		}
+		wave.Circles = append(wave.Circles, circle)
+	}
+	circleRows.Close()
+
+	members, err := GetVillageMembersByPregnancyID(pregnancy.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	direct := make(map[int]bool)
+	for _, memberID := range wave.MemberIDs {
+		direct[memberID] = true
+	}
+	circles := make(map[string]bool)
+	for _, circle := range wave.Circles {
+		circles[circle] = true
+	}
+
+	wave.Members = []models.VillageMember{}
+	for _, member := range members {
+		if direct[member.ID] || circles[strings.ToLower(member.Relationship)] {
+			wave.Members = append(wave.Members, *member)
+		}
+	}
+
+	wave.IsDue = wave.IsDueAt(pregnancy.GetCurrentWeek(), time.Now())
+
+	return &wave, nil
+}
@@ -5,10 +5,13 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"simple-go/api/db"
 	"simple-go/api/middleware"
 	"simple-go/api/models"
+	"simple-go/api/services/markdown"
+	"simple-go/api/services/media"
 )
 
 // TimelineItem represents a combined timeline item (event or update)
@@ -17,14 +20,18 @@ type TimelineItem struct {
 	Type        string                   `json:"type"` // "event" or "update"
 	Title       string                   `json:"title"`
 	Description *string                  `json:"description"`
+	DescriptionHTML *string              `json:"description_html,omitempty"` // Description rendered from Markdown
 	WeekNumber  *int                     `json:"week_number"`
 	CreatedAt   string                   `json:"created_at"`
 	EventType   *string                  `json:"event_type,omitempty"`
 	UpdateType  *string                  `json:"update_type,omitempty"`
-	Photos      []models.UpdatePhoto     `json:"photos,omitempty"`
+	Media       []models.UpdateMedia     `json:"media,omitempty"`
 	IsShared    *bool                    `json:"is_shared,omitempty"`
 	PregnancyID int                      `json:"pregnancy_id"`
 	CreatedBy   *string                  `json:"created_by,omitempty"` // User name who created this item
+	Reactions    []models.ReactionCount `json:"reactions,omitempty"`
+	CommentCount *int                   `json:"comment_count,omitempty"`
+	EditedAt     *time.Time             `json:"edited_at,omitempty"` // When an update was last edited
 }
 
 // GetCombinedTimelineHandler returns a combined timeline of events and updates
@@ -100,7 +107,7 @@ func getCombinedTimelineItems(pregnancyID, limit, offset int) ([]TimelineItem, e
 		u.name as created_by
 	FROM pregnancy_events pe
 	LEFT JOIN users u ON pe.created_by = u.id
-	WHERE pe.pregnancy_id = ? AND pe.event_type != 'update_posted'
+	WHERE pe.pregnancy_id = ? AND pe.event_type != 'update_posted' AND pe.retracted_at IS NULL
 	
 	UNION ALL
 	
@@ -119,7 +126,7 @@ func getCombinedTimelineItems(pregnancyID, limit, offset int) ([]TimelineItem, e
 	FROM pregnancy_updates pu
 	JOIN pregnancies p ON p.id = pu.pregnancy_id
 	JOIN users u ON u.id = p.user_id
-	WHERE pu.pregnancy_id = ?
+	WHERE pu.pregnancy_id = ? AND pu.deleted_at IS NULL
 	
 	ORDER BY sort_date DESC, item_id DESC
 	LIMIT ? OFFSET ?`
@@ -164,10 +171,22 @@ func getCombinedTimelineItems(pregnancyID, limit, offset int) ([]TimelineItem, e
 		item.PregnancyID = pregnancyID
 		item.CreatedBy = createdBy
 
-		// If this is an update, get its photos
+		if item.Description != nil {
+			descriptionHTML := markdown.ToHTML(*item.Description)
+			item.DescriptionHTML = &descriptionHTML
+		}
+
+		// If this is an update, get its photos and videos
 		if item.Type == "update" {
-			photos, _ := getUpdatePhotos(item.ID)
-			item.Photos = photos
+			item.Media, _ = getSignedUpdateMedia(item.ID, pregnancyID, media.ScopePrivate)
+
+			reactions, commentCount, err := getUpdateFeedbackCounts(item.ID, 0, true)
+			if err == nil {
+				item.Reactions = reactions
+				item.CommentCount = &commentCount
+			}
+
+			item.EditedAt, _ = getUpdateEditedAt(item.ID, false)
 		}
 
 		items = append(items, item)
@@ -175,29 +194,3 @@ func getCombinedTimelineItems(pregnancyID, limit, offset int) ([]TimelineItem, e
 
 	return items, nil
 }
-
-// getUpdatePhotos fetches photos for a specific update
-func getUpdatePhotos(updateID int) ([]models.UpdatePhoto, error) {
-	rows, err := db.GetDB().Query(`
-		SELECT id, update_id, filename, original_filename, file_size, caption, sort_order, created_at
-		FROM update_photos 
-		WHERE update_id = ? 
-		ORDER BY sort_order`, updateID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var photos []models.UpdatePhoto
-	for rows.Next() {
-		var photo models.UpdatePhoto
-		err := rows.Scan(&photo.ID, &photo.UpdateID, &photo.Filename, &photo.OriginalFilename,
-			&photo.FileSize, &photo.Caption, &photo.SortOrder, &photo.CreatedAt)
-		if err != nil {
-			continue
-		}
-		photos = append(photos, photo)
-	}
-
-	return photos, nil
-}
\ No newline at end of file
@@ -5,20 +5,17 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
-	"simple-go/api/config"
 	"simple-go/api/db"
 	"simple-go/api/middleware"
 	"simple-go/api/models"
 	"simple-go/api/services/email"
+	"simple-go/api/services/media"
 )
 
 // CreateUpdateRequest represents the request to create a new pregnancy update
@@ -29,6 +26,9 @@ type CreateUpdateRequest struct {
 	AppointmentType *string `json:"appointment_type"`
 	IsShared        bool    `json:"is_shared"`
 	Date            *string `json:"date"` // ISO string for update date, defaults to current UTC time
+	PublishAt       *string `json:"publish_at"`   // ISO string; shares the update automatically at this time
+	PublishWeek     *int    `json:"publish_week"` // shares the update automatically when this week begins
+	UploadIDs       []string `json:"upload_ids"`  // finished resumable uploads to attach
 }
 
 // CreateUpdateHandler handles creating a new pregnancy update
@@ -75,6 +75,27 @@ func CreateUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Title is required", http.StatusBadRequest)
 		return
 	}
+	if req.Content != nil && len([]rune(*req.Content)) > models.MaxUpdateContentLength {
+		http.Error(w, fmt.Sprintf("Content can be at most %d characters", models.MaxUpdateContentLength), http.StatusBadRequest)
+		return
+	}
+
+	// Scheduled updates stay private until the publisher shares them
+	publishAt, publishWeek, err := parseUpdateSchedule(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if publishAt != nil || publishWeek != nil {
+		req.IsShared = false
+	}
+
+	// Resumable uploads must have finished before they can be attached
+	finishedUploads, err := completedMediaUploads(req.UploadIDs, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Parse update date or use current UTC time
 	var updateDate time.Time
@@ -90,21 +111,12 @@ func CreateUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Calculate week number based on conception date
-	var weekNumber *int
-	if conceptionDate != nil {
-		// Calculate gestational weeks from LMP (conception + 14 days)
-		daysSinceConception := int(updateDate.Sub(*conceptionDate).Hours() / 24)
-		// Add 14 days to account for LMP offset (gestational age calculation)
-		calculatedWeek := (daysSinceConception+14)/7 + 1
-		if calculatedWeek > 0 {
-			weekNumber = &calculatedWeek
-		}
-	}
+	weekNumber := weekNumberForDate(conceptionDate, updateDate)
 
 	// Insert the update
 	result, err := db.GetDB().Exec(`
-		INSERT INTO pregnancy_updates (pregnancy_id, week_number, title, content, update_type, appointment_type, is_shared, shared_at, update_date)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		INSERT INTO pregnancy_updates (pregnancy_id, week_number, title, content, update_type, appointment_type, is_shared, shared_at, update_date, publish_at, publish_week)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		pregnancyID, weekNumber, req.Title, req.Content, req.UpdateType, req.AppointmentType, req.IsShared,
 		func() *time.Time {
 			if req.IsShared {
@@ -112,7 +124,7 @@ func CreateUpdateHandler(w http.ResponseWriter, r *http.Request) {
 				return &now
 			}
 			return nil
-		}(), &updateDate)
+		}(), &updateDate, publishAt, publishWeek)
 
 	if err != nil {
 		http.Error(w, "Failed to create update", http.StatusInternalServerError)
@@ -125,106 +137,40 @@ func CreateUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Handle photo uploads
-	files := r.MultipartForm.File["photos"]
-	photoDir := filepath.Join(config.AppConfig.ImagesDirectory, fmt.Sprintf("%d", pregnancyID))
-
-	// Create photo directory if it doesn't exist
-	if len(files) > 0 {
-		if err := os.MkdirAll(photoDir, 0755); err != nil {
-			http.Error(w, "Failed to create photo directory", http.StatusInternalServerError)
-			return
-		}
-	}
-
-	// Process each uploaded photo
-	for i, fileHeader := range files {
-		file, err := fileHeader.Open()
-		if err != nil {
-			continue
-		}
-		defer file.Close()
-
-		// Generate unique filename
-		ext := filepath.Ext(fileHeader.Filename)
-		filename := fmt.Sprintf("%d_%d_%d%s", updateID, time.Now().Unix(), i, ext)
-		fullPath := filepath.Join(photoDir, filename)
+	// Earliest capture time of the uploaded photos and videos
+	var earliestCapture *time.Time
 
-		// Create the file
-		dst, err := os.Create(fullPath)
+	// Handle photo and video uploads, then finished resumable uploads
+	uploads := uploadedMediaFiles(r)
+	var saved []*models.UpdateMedia
+	for i, fileHeader := range uploads {
+		item, err := saveUploadedMedia(r.Context(), fileHeader, pregnancyID, int(updateID), i)
 		if err != nil {
+			// Log error but continue processing other uploads
+			log.Printf("Failed to save upload %s: %v", fileHeader.Filename, err)
 			continue
 		}
-		defer dst.Close()
-
-		// Copy the uploaded file
-		written, err := io.Copy(dst, file)
+		saved = append(saved, item)
+	}
+	for i, upload := range finishedUploads {
+		item, err := attachMediaUpload(r.Context(), upload, pregnancyID, int(updateID), len(uploads)+i)
 		if err != nil {
+			log.Printf("Failed to attach upload %s: %v", upload.ID, err)
 			continue
 		}
-
-		// Insert photo record
-		_, err = db.GetDB().Exec(`
-			INSERT INTO update_photos (update_id, filename, original_filename, file_size, sort_order)
-			VALUES (?, ?, ?, ?, ?)`,
-			updateID, filename, fileHeader.Filename, written, i)
-		if err != nil {
-			// Log error but continue processing other photos
-			fmt.Printf("Failed to insert photo record: %v\n", err)
-		}
+		saved = append(saved, item)
 	}
 
-	// Handle video uploads
-	videoFiles := r.MultipartForm.File["videos"]
-	videoDir := filepath.Join(config.AppConfig.VideosDirectory, fmt.Sprintf("%d", pregnancyID))
-
-	// Create video directory if it doesn't exist
-	if len(videoFiles) > 0 {
-		if err := os.MkdirAll(videoDir, 0755); err != nil {
-			http.Error(w, "Failed to create video directory", http.StatusInternalServerError)
-			return
+	for _, item := range saved {
+		if item.CapturedAt != nil && (earliestCapture == nil || item.CapturedAt.Before(*earliestCapture)) {
+			earliestCapture = item.CapturedAt
 		}
 	}
 
-	// Process each uploaded video
-	for i, fileHeader := range videoFiles {
-		file, err := fileHeader.Open()
-		if err != nil {
-			continue
-		}
-		defer file.Close()
-
-		// Validate video file type
-		ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
-		if ext != ".mp4" && ext != ".mov" {
-			continue // Skip unsupported video formats
-		}
-
-		// Generate unique filename
-		filename := fmt.Sprintf("%d_%d_%d%s", updateID, time.Now().Unix(), i, ext)
-		fullPath := filepath.Join(videoDir, filename)
-
-		// Create the file
-		dst, err := os.Create(fullPath)
-		if err != nil {
-			continue
-		}
-		defer dst.Close()
-
-		// Copy the uploaded file
-		written, err := io.Copy(dst, file)
-		if err != nil {
-			continue
-		}
-
-		// Insert video record (we'll reuse the update_photos table for now, adding a video table later if needed)
-		_, err = db.GetDB().Exec(`
-			INSERT INTO update_photos (update_id, filename, original_filename, file_size, sort_order)
-			VALUES (?, ?, ?, ?, ?)`,
-			updateID, filename, fileHeader.Filename, written, len(files)+i)
-		if err != nil {
-			// Log error but continue processing other videos
-			fmt.Printf("Failed to insert video record: %v\n", err)
+	// Without an explicit date, date the update to when its photos or videos were taken
+	if (req.Date == nil || *req.Date == "") && earliestCapture != nil {
+		if capturedWeek, ok := dateUpdateFromCapture(int(updateID), conceptionDate, *earliestCapture); ok {
+			weekNumber = capturedWeek
 		}
 	}
 
@@ -240,40 +186,34 @@ func CreateUpdateHandler(w http.ResponseWriter, r *http.Request) {
 				eventDescription = *req.Content
 			}
 
-			CreateUpdateSharedEvent(pregnancyID, userID, eventTitle, eventDescription, weekNumber)
+			CreateUpdateSharedEvent(pregnancyID, userID, int(updateID), eventTitle, eventDescription, weekNumber)
 		}
 	}
 
 	// Return the created update
 	var update models.PregnancyUpdate
 	err = db.GetDB().QueryRow(`
-		SELECT id, pregnancy_id, week_number, title, content, update_type, appointment_type, is_shared, shared_at, update_date, created_at, updated_at
+		SELECT id, pregnancy_id, week_number, title, content, update_type, appointment_type, is_shared, shared_at, update_date, created_at, updated_at,
+		       publish_at, publish_week
 		FROM pregnancy_updates WHERE id = ?`, updateID).Scan(
 		&update.ID, &update.PregnancyID, &update.WeekNumber, &update.Title, &update.Content,
 		&update.UpdateType, &update.AppointmentType, &update.IsShared, &update.SharedAt, &update.UpdateDate,
-		&update.CreatedAt, &update.UpdatedAt)
+		&update.CreatedAt, &update.UpdatedAt, &update.PublishAt, &update.PublishWeek)
 
 	if err != nil {
 		http.Error(w, "Failed to fetch created update", http.StatusInternalServerError)
 		return
 	}
 
-	// Get photos for the update
-	rows, _ := db.GetDB().Query(`
-		SELECT id, update_id, filename, original_filename, file_size, caption, sort_order, created_at
-		FROM update_photos WHERE update_id = ? ORDER BY sort_order`, updateID)
-	defer rows.Close()
-
-	for rows.Next() {
-		var photo models.UpdatePhoto
-		rows.Scan(&photo.ID, &photo.UpdateID, &photo.Filename, &photo.OriginalFilename,
-			&photo.FileSize, &photo.Caption, &photo.SortOrder, &photo.CreatedAt)
-		update.Photos = append(update.Photos, photo)
-	}
+	// Get photos and videos for the update
+	update.Media, _ = getSignedUpdateMedia(int(updateID), pregnancyID, media.ScopePrivate)
 
 	// Send email notification if update is shared
 	if update.IsShared {
 		go func() {
+			// Renditions are generated first so the email can use a resized photo
+			processUpdateMedia(int(updateID))
+
 			// Send email notifications in background to avoid blocking the response
 			emailService, err := email.NewEmailService()
 			if err != nil {
@@ -307,12 +247,30 @@ func CreateUpdateHandler(w http.ResponseWriter, r *http.Request) {
 				log.Printf("Update notification sent for pregnancy %d", pregnancyID)
 			}
 		}()
+	} else if len(saved) > 0 {
+		go processUpdateMedia(int(updateID))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(update)
 }
 
+// weekNumberForDate calculates the pregnancy week a date falls in from the conception date
+func weekNumberForDate(conceptionDate *time.Time, date time.Time) *int {
+	if conceptionDate == nil {
+		return nil
+	}
+
+	// Calculate gestational weeks from LMP (conception + 14 days)
+	daysSinceConception := int(date.Sub(*conceptionDate).Hours() / 24)
+	// Add 14 days to account for LMP offset (gestational age calculation)
+	calculatedWeek := (daysSinceConception+14)/7 + 1
+	if calculatedWeek > 0 {
+		return &calculatedWeek
+	}
+	return nil
+}
+
 // GetUpdatesHandler returns pregnancy updates for the current user
 func GetUpdatesHandler(w http.ResponseWriter, r *http.Request) {
 	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
@@ -366,9 +324,10 @@ func GetUpdatesHandler(w http.ResponseWriter, r *http.Request) {
 	// Build query based on access
 	query := `
 		SELECT id, pregnancy_id, week_number, title, content, update_type, 
-		       appointment_type, is_shared, shared_at, update_date, created_at, updated_at
+		       appointment_type, is_shared, shared_at, update_date, created_at, updated_at,
+		       publish_at, publish_week
 		FROM pregnancy_updates 
-		WHERE pregnancy_id = ?`
+		WHERE pregnancy_id = ? AND deleted_at IS NULL`
 	
 	// If not owner or viewing as villager, only show shared updates
 	if !isOwner || viewAsVillager {
@@ -389,23 +348,19 @@ func GetUpdatesHandler(w http.ResponseWriter, r *http.Request) {
 		var update models.PregnancyUpdate
 		err := rows.Scan(&update.ID, &update.PregnancyID, &update.WeekNumber, &update.Title,
 			&update.Content, &update.UpdateType, &update.AppointmentType, &update.IsShared,
-			&update.SharedAt, &update.UpdateDate, &update.CreatedAt, &update.UpdatedAt)
+			&update.SharedAt, &update.UpdateDate, &update.CreatedAt, &update.UpdatedAt,
+			&update.PublishAt, &update.PublishWeek)
 		if err != nil {
 			continue
 		}
 
-		// Get photos for each update
-		photoRows, _ := db.GetDB().Query(`
-			SELECT id, update_id, filename, original_filename, file_size, caption, sort_order, created_at
-			FROM update_photos WHERE update_id = ? ORDER BY sort_order`, update.ID)
-		
-		for photoRows.Next() {
-			var photo models.UpdatePhoto
-			photoRows.Scan(&photo.ID, &photo.UpdateID, &photo.Filename, &photo.OriginalFilename,
-				&photo.FileSize, &photo.Caption, &photo.SortOrder, &photo.CreatedAt)
-			update.Photos = append(update.Photos, photo)
+		// Get photos and videos for each update, signed only for shared updates when viewing
+		// as the village
+		scope := media.ScopePrivate
+		if !isOwner || viewAsVillager {
+			scope = media.ScopeShared
 		}
-		photoRows.Close()
+		update.Media, _ = getSignedUpdateMedia(update.ID, update.PregnancyID, scope)
 
 		updates = append(updates, update)
 	}
@@ -452,7 +407,7 @@ func UpdateShareStatusHandler(w http.ResponseWriter, r *http.Request) {
 		SELECT p.id, pu.is_shared
 		FROM pregnancy_updates pu
 		JOIN pregnancies p ON p.id = pu.pregnancy_id
-		WHERE pu.id = ? AND p.user_id = ?`,
+		WHERE pu.id = ? AND p.user_id = ? AND pu.deleted_at IS NULL`,
 		updateID, userID).Scan(&pregnancyID, &currentlyShared)
 	
 	if err == sql.ErrNoRows {
@@ -469,9 +424,10 @@ func UpdateShareStatusHandler(w http.ResponseWriter, r *http.Request) {
 		return nil
 	}()
 
+	// Sharing or unsharing by hand replaces any schedule the update had
 	_, err = db.GetDB().Exec(`
 		UPDATE pregnancy_updates 
-		SET is_shared = ?, shared_at = ?
+		SET is_shared = ?, shared_at = ?, publish_at = NULL, publish_week = NULL
 		WHERE id = ?`,
 		req.IsShared, sharedAt, updateID)
 
@@ -501,7 +457,7 @@ func UpdateShareStatusHandler(w http.ResponseWriter, r *http.Request) {
 			SELECT week_number FROM pregnancy_updates WHERE id = ?`,
 			updateID).Scan(&weekNumber)
 
-		CreateUpdateSharedEvent(pregnancyID, userID, eventTitle, eventDescription, weekNumber)
+		CreateUpdateSharedEvent(pregnancyID, userID, updateID, eventTitle, eventDescription, weekNumber)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -555,6 +511,27 @@ func UpdateUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Title is required", http.StatusBadRequest)
 		return
 	}
+	if req.Content != nil && len([]rune(*req.Content)) > models.MaxUpdateContentLength {
+		http.Error(w, fmt.Sprintf("Content can be at most %d characters", models.MaxUpdateContentLength), http.StatusBadRequest)
+		return
+	}
+
+	// Scheduled updates stay private until the publisher shares them
+	publishAt, publishWeek, err := parseUpdateSchedule(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if publishAt != nil || publishWeek != nil {
+		req.IsShared = false
+	}
+
+	// Resumable uploads must have finished before they can be attached
+	finishedUploads, err := completedMediaUploads(req.UploadIDs, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Verify ownership and get conception date
 	var pregnancyID int
@@ -563,7 +540,7 @@ func UpdateUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		SELECT p.id, p.conception_date
 		FROM pregnancy_updates pu
 		JOIN pregnancies p ON p.id = pu.pregnancy_id
-		WHERE pu.id = ? AND p.user_id = ?`,
+		WHERE pu.id = ? AND p.user_id = ? AND pu.deleted_at IS NULL`,
 		updateID, userID).Scan(&pregnancyID, &conceptionDate)
 	
 	if err == sql.ErrNoRows {
@@ -589,22 +566,26 @@ func UpdateUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Calculate week number based on conception date
-	var weekNumber *int
-	if conceptionDate != nil {
-		// Calculate gestational weeks from LMP (conception + 14 days)
-		daysSinceConception := int(updateDate.Sub(*conceptionDate).Hours() / 24)
-		// Add 14 days to account for LMP offset (gestational age calculation)
-		calculatedWeek := (daysSinceConception+14)/7 + 1
-		if calculatedWeek > 0 {
-			weekNumber = &calculatedWeek
-		}
+	weekNumber := weekNumberForDate(conceptionDate, updateDate)
+
+	// Keep the version being replaced, then update the existing update
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
 	}
+	defer tx.Rollback()
 
-	// Update the existing update
-	_, err = db.GetDB().Exec(`
+	if err := saveUpdateRevision(tx, updateID, userID, req.Title, req.Content, &req.IsShared); err != nil {
+		log.Printf("Failed to save revision of update %d: %v", updateID, err)
+		http.Error(w, "Failed to update", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = tx.Exec(`
 		UPDATE pregnancy_updates 
 		SET week_number = ?, title = ?, content = ?, update_type = ?, appointment_type = ?, 
-		    is_shared = ?, shared_at = ?, update_date = ?, updated_at = ?
+		    is_shared = ?, shared_at = ?, update_date = ?, updated_at = ?, publish_at = ?, publish_week = ?
 		WHERE id = ?`,
 		weekNumber, req.Title, req.Content, req.UpdateType, req.AppointmentType, req.IsShared,
 		func() *time.Time {
@@ -613,148 +594,94 @@ func UpdateUpdateHandler(w http.ResponseWriter, r *http.Request) {
 				return &now
 			}
 			return nil
-		}(), &updateDate, time.Now(), updateID)
+		}(), &updateDate, time.Now(), publishAt, publishWeek, updateID)
 
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		http.Error(w, "Failed to update", http.StatusInternalServerError)
 		return
 	}
 
-	// Handle new photo uploads (append to existing photos)
-	files := r.MultipartForm.File["photos"]
-	if len(files) > 0 {
-		photoDir := filepath.Join(config.AppConfig.ImagesDirectory, fmt.Sprintf("%d", pregnancyID))
-		
-		// Create photo directory if it doesn't exist
-		if err := os.MkdirAll(photoDir, 0755); err != nil {
-			http.Error(w, "Failed to create photo directory", http.StatusInternalServerError)
-			return
-		}
-
-		// Get current max sort order
+	// Handle new photo and video uploads and finished resumable uploads, appended after the
+	// existing media
+	uploads := uploadedMediaFiles(r)
+	if len(uploads) > 0 || len(finishedUploads) > 0 {
 		var maxSortOrder int
-		db.GetDB().QueryRow(`SELECT COALESCE(MAX(sort_order), -1) FROM update_photos WHERE update_id = ?`, updateID).Scan(&maxSortOrder)
+		db.GetDB().QueryRow(`SELECT COALESCE(MAX(sort_order), -1) FROM update_media WHERE update_id = ?`, updateID).Scan(&maxSortOrder)
 
-		// Process each uploaded photo
-		for i, fileHeader := range files {
-			file, err := fileHeader.Open()
-			if err != nil {
-				continue
+		for i, fileHeader := range uploads {
+			if _, err := saveUploadedMedia(r.Context(), fileHeader, pregnancyID, updateID, maxSortOrder+i+1); err != nil {
+				log.Printf("Failed to save upload %s: %v", fileHeader.Filename, err)
 			}
-			defer file.Close()
-
-			// Generate unique filename
-			ext := filepath.Ext(fileHeader.Filename)
-			filename := fmt.Sprintf("%d_%d_%d%s", updateID, time.Now().Unix(), i, ext)
-			fullPath := filepath.Join(photoDir, filename)
-
-			// Create the file
-			dst, err := os.Create(fullPath)
-			if err != nil {
-				continue
-			}
-			defer dst.Close()
-
-			// Copy the uploaded file
-			written, err := io.Copy(dst, file)
-			if err != nil {
-				continue
-			}
-
-			// Insert photo record
-			_, err = db.GetDB().Exec(`
-				INSERT INTO update_photos (update_id, filename, original_filename, file_size, sort_order)
-				VALUES (?, ?, ?, ?, ?)`,
-				updateID, filename, fileHeader.Filename, written, maxSortOrder+i+1)
-			if err != nil {
-				fmt.Printf("Failed to insert photo record: %v\n", err)
+		}
+		for i, upload := range finishedUploads {
+			if _, err := attachMediaUpload(r.Context(), upload, pregnancyID, updateID, maxSortOrder+len(uploads)+i+1); err != nil {
+				log.Printf("Failed to attach upload %s: %v", upload.ID, err)
 			}
 		}
+
+		go processUpdateMedia(updateID)
 	}
 
-	// Handle new video uploads (append to existing videos)
-	videoFiles := r.MultipartForm.File["videos"]
-	if len(videoFiles) > 0 {
-		videoDir := filepath.Join(config.AppConfig.VideosDirectory, fmt.Sprintf("%d", pregnancyID))
-		
-		// Create video directory if it doesn't exist
-		if err := os.MkdirAll(videoDir, 0755); err != nil {
-			http.Error(w, "Failed to create video directory", http.StatusInternalServerError)
-			return
+	// Without an explicit date, date the update to when its photos or videos were taken, the
+	// same as when it was created, now including any just attached
+	if req.Date == nil || *req.Date == "" {
+		var earliestCapture *time.Time
+		err := db.GetDB().QueryRow(`
+			SELECT captured_at FROM update_media
+			WHERE update_id = ? AND captured_at IS NOT NULL
+			ORDER BY captured_at LIMIT 1`, updateID).Scan(&earliestCapture)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("Failed to get capture time of update %d: %v", updateID, err)
+		} else if earliestCapture != nil {
+			dateUpdateFromCapture(updateID, conceptionDate, *earliestCapture)
 		}
+	}
 
-		// Get current max sort order
-		var maxSortOrder int
-		db.GetDB().QueryRow(`SELECT COALESCE(MAX(sort_order), -1) FROM update_photos WHERE update_id = ?`, updateID).Scan(&maxSortOrder)
-
-		// Process each uploaded video
-		for i, fileHeader := range videoFiles {
-			file, err := fileHeader.Open()
-			if err != nil {
-				continue
-			}
-			defer file.Close()
-
-			// Validate video file type
-			ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
-			if ext != ".mp4" && ext != ".mov" {
-				continue // Skip unsupported video formats
-			}
-
-			// Generate unique filename
-			filename := fmt.Sprintf("%d_%d_%d%s", updateID, time.Now().Unix(), i, ext)
-			fullPath := filepath.Join(videoDir, filename)
-
-			// Create the file
-			dst, err := os.Create(fullPath)
-			if err != nil {
-				continue
-			}
-			defer dst.Close()
+	// Return the updated update
+	writeEditedUpdate(w, updateID, pregnancyID)
+}
 
-			// Copy the uploaded file
-			written, err := io.Copy(dst, file)
-			if err != nil {
-				continue
-			}
+// dateUpdateFromCapture dates an update to when its earliest photo or video was taken, returning
+// the week it then falls in. Photos from before the pregnancy began don't say anything about
+// when the update happened, so those leave it as it was.
+func dateUpdateFromCapture(updateID int, conceptionDate *time.Time, captured time.Time) (*int, bool) {
+	capturedWeek := weekNumberForDate(conceptionDate, captured)
+	if conceptionDate != nil && capturedWeek == nil {
+		return nil, false
+	}
 
-			// Insert video record
-			_, err = db.GetDB().Exec(`
-				INSERT INTO update_photos (update_id, filename, original_filename, file_size, sort_order)
-				VALUES (?, ?, ?, ?, ?)`,
-				updateID, filename, fileHeader.Filename, written, maxSortOrder+len(files)+i+1)
-			if err != nil {
-				fmt.Printf("Failed to insert video record: %v\n", err)
-			}
-		}
+	updateDate := captured.UTC()
+	_, err := db.GetDB().Exec(`UPDATE pregnancy_updates SET update_date = ?, week_number = ? WHERE id = ?`,
+		&updateDate, capturedWeek, updateID)
+	if err != nil {
+		log.Printf("Failed to date update %d from photo capture time: %v", updateID, err)
+		return nil, false
 	}
+	return capturedWeek, true
+}
 
-	// Return the updated update
+// writeEditedUpdate responds with an update after the parents have changed it, with its photos
+// and videos signed for them
+func writeEditedUpdate(w http.ResponseWriter, updateID, pregnancyID int) {
 	var update models.PregnancyUpdate
-	err = db.GetDB().QueryRow(`
-		SELECT id, pregnancy_id, week_number, title, content, update_type, appointment_type, is_shared, shared_at, update_date, created_at, updated_at
+	err := db.GetDB().QueryRow(`
+		SELECT id, pregnancy_id, week_number, title, content, update_type, appointment_type, is_shared, shared_at, update_date, created_at, updated_at,
+		       publish_at, publish_week
 		FROM pregnancy_updates WHERE id = ?`, updateID).Scan(
 		&update.ID, &update.PregnancyID, &update.WeekNumber, &update.Title, &update.Content,
 		&update.UpdateType, &update.AppointmentType, &update.IsShared, &update.SharedAt, &update.UpdateDate,
-		&update.CreatedAt, &update.UpdatedAt)
+		&update.CreatedAt, &update.UpdatedAt, &update.PublishAt, &update.PublishWeek)
 
 	if err != nil {
 		http.Error(w, "Failed to fetch updated update", http.StatusInternalServerError)
 		return
 	}
 
-	// Get photos for the update
-	rows, _ := db.GetDB().Query(`
-		SELECT id, update_id, filename, original_filename, file_size, caption, sort_order, created_at
-		FROM update_photos WHERE update_id = ? ORDER BY sort_order`, updateID)
-	defer rows.Close()
-
-	for rows.Next() {
-		var photo models.UpdatePhoto
-		rows.Scan(&photo.ID, &photo.UpdateID, &photo.Filename, &photo.OriginalFilename,
-			&photo.FileSize, &photo.Caption, &photo.SortOrder, &photo.CreatedAt)
-		update.Photos = append(update.Photos, photo)
-	}
+	// Get photos and videos for the update
+	update.Media, _ = getSignedUpdateMedia(updateID, pregnancyID, media.ScopePrivate)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(update)
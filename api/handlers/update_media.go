@@ -0,0 +1,772 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-go/api/db"
+	"simple-go/api/middleware"
+	"simple-go/api/models"
+	"simple-go/api/services/media"
+	"simple-go/api/services/storage"
+)
+
+// uploadedMediaFiles returns the photos and videos uploaded with an update, photos first
+func uploadedMediaFiles(r *http.Request) []*multipart.FileHeader {
+	var uploads []*multipart.FileHeader
+	uploads = append(uploads, r.MultipartForm.File["photos"]...)
+	uploads = append(uploads, r.MultipartForm.File["videos"]...)
+	return uploads
+}
+
+// saveUploadedMedia stores an uploaded photo or video for an update and records it. Its type is
+// judged from its contents rather than its name or the form field it came in, and photos have
+// their metadata stripped before they are probed, so the recorded size and checksum are of the
+// file that is served.
+func saveUploadedMedia(ctx context.Context, fileHeader *multipart.FileHeader, pregnancyID, updateID, sortOrder int) (*models.UpdateMedia, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return saveMedia(ctx, file, fileHeader.Filename, pregnancyID, updateID, sortOrder)
+}
+
+// saveMedia stores a photo or video read from file for an update and records it, as
+// saveUploadedMedia does for form uploads
+func saveMedia(ctx context.Context, file io.ReadSeeker, originalFilename string, pregnancyID, updateID, sortOrder int) (*models.UpdateMedia, error) {
+	item, err := storeMedia(ctx, file, originalFilename, pregnancyID, updateID, sortOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := db.GetDB().Exec(`
+		INSERT INTO update_media (update_id, kind, mime_type, filename, original_filename, file_size, checksum,
+		                          width, height, duration_seconds, sort_order, captured_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		item.UpdateID, item.Kind, item.MIMEType, item.Filename, item.OriginalFilename, item.FileSize, item.Checksum,
+		item.Width, item.Height, item.DurationSeconds, item.SortOrder, item.CapturedAt)
+	if err != nil {
+		updateMediaStore(item.Kind).Delete(ctx, updateMediaKey(pregnancyID, item.Filename))
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	item.ID = int(id)
+	return item, nil
+}
+
+// storeMedia checks, sanitizes, probes and stores a photo or video read from file, returning
+// the details to record for it
+func storeMedia(ctx context.Context, file io.ReadSeeker, originalFilename string, pregnancyID, updateID, sortOrder int) (*models.UpdateMedia, error) {
+	mimeType, kind, err := media.DetectFileType(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// Photos are sanitized and probed on disk before they are stored
+	dir, err := os.MkdirTemp("", "upload-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	// Generate unique filename. Media is cached as if it never changes, so a name is never reused,
+	// even by a replacement uploaded the same second.
+	store := updateMediaStore(kind)
+	timestamp := time.Now().Unix()
+	filename := fmt.Sprintf("%d_%d_%d%s", updateID, timestamp, sortOrder, media.Extension(mimeType))
+	for {
+		if _, err := store.Stat(ctx, updateMediaKey(pregnancyID, filename)); err == storage.ErrNotFound {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		timestamp++
+		filename = fmt.Sprintf("%d_%d_%d%s", updateID, timestamp, sortOrder, media.Extension(mimeType))
+	}
+	localPath := filepath.Join(dir, filename)
+
+	dst, err := os.Create(localPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(dst, file); err != nil {
+		dst.Close()
+		return nil, err
+	}
+
+	// Strip location and device metadata before the photo or video can be served
+	if kind == models.MediaKindPhoto {
+		if _, err := sanitizeUploadedPhoto(dst, localPath); err != nil {
+			return nil, fmt.Errorf("failed to sanitize: %w", err)
+		}
+	} else if err := dst.Close(); err != nil {
+		return nil, err
+	} else if _, err := media.SanitizeVideo(localPath); err != nil {
+		return nil, fmt.Errorf("failed to sanitize: %w", err)
+	}
+
+	info, err := media.Probe(localPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := storeFile(ctx, store, updateMediaKey(pregnancyID, filename), localPath, info.MIMEType); err != nil {
+		return nil, err
+	}
+
+	item := &models.UpdateMedia{
+		UpdateID:         updateID,
+		Kind:             info.Kind,
+		MIMEType:         info.MIMEType,
+		Filename:         filename,
+		OriginalFilename: originalFilename,
+		Checksum:         &info.Checksum,
+		Width:            info.Width,
+		Height:           info.Height,
+		DurationSeconds:  info.Duration,
+		SortOrder:        sortOrder,
+		CapturedAt:       info.CapturedAt,
+	}
+	fileSize := int(info.Size)
+	item.FileSize = &fileSize
+	return item, nil
+}
+
+// getSignedUpdateMedia fetches the photos and videos of an update with URLs signed for scope.
+// The caller must already have checked the viewer can see the update.
+func getSignedUpdateMedia(updateID, pregnancyID int, scope string) ([]models.UpdateMedia, error) {
+	items, err := getUpdateMedia(updateID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		signUpdateMedia(&items[i], pregnancyID, scope)
+	}
+	return items, nil
+}
+
+// signUpdateMedia sets the URLs of a photo or video and its renditions, signed for scope
+func signUpdateMedia(item *models.UpdateMedia, pregnancyID int, scope string) {
+	item.URL = media.SignURL(updateMediaURL(pregnancyID, item.Kind, item.Filename), scope, media.URLLifetime)
+	if len(item.Renditions) > 0 {
+		item.RenditionURLs = map[string]string{}
+		for _, rendition := range item.Renditions {
+			item.RenditionURLs[rendition.Size] = media.SignURL(updateMediaURL(pregnancyID, item.Kind, rendition.Filename), scope, media.URLLifetime)
+		}
+	}
+}
+
+// updateMediaURL returns the unsigned URL path an update's photo or video is served from
+func updateMediaURL(pregnancyID int, kind, filename string) string {
+	if kind == models.MediaKindVideo {
+		return fmt.Sprintf("/videos/%d/%s", pregnancyID, filename)
+	}
+	return fmt.Sprintf("/images/%d/%s", pregnancyID, filename)
+}
+
+// ServeUpdateMedia serves a photo or video of an update, stored under mediaPath in the store for
+// kind, to a request with a signed URL. The update must still be visible to whoever the URL
+// was signed for. Media of shared updates is cached publicly and media of private updates only
+// by the viewer's browser, either way only until its URL expires, so unsharing an update
+// isn't undone by a cache.
+func ServeUpdateMedia(w http.ResponseWriter, r *http.Request, kind, mediaPath string) {
+	scope, err := media.VerifyURL(r.URL.Path, r.URL.Query())
+	if err != nil {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// Update media lives at {pregnancyID}/{updateID}_..., renditions included
+	parts := strings.Split(mediaPath, "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	pregnancyID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	updateID, err := strconv.Atoi(strings.SplitN(parts[1], "_", 2)[0])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var isShared, isDeleted bool
+	err = db.GetDB().QueryRow(`
+		SELECT is_shared, deleted_at IS NOT NULL FROM pregnancy_updates WHERE id = ? AND pregnancy_id = ?
+	`, updateID, pregnancyID).Scan(&isShared, &isDeleted)
+	if err == sql.ErrNoRows || isDeleted || (scope == media.ScopeShared && !isShared) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to check access to %s: %v", mediaPath, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	maxAge := time.Until(media.ExpiresAt(r.URL.Query()))
+	if maxAge > publicMediaMaxAge {
+		maxAge = publicMediaMaxAge
+	}
+
+	if kind == models.MediaKindVideo {
+		switch strings.ToLower(path.Ext(mediaPath)) {
+		case ".mp4":
+			w.Header().Set("Content-Type", "video/mp4")
+		case ".mov":
+			w.Header().Set("Content-Type", "video/quicktime")
+		}
+	}
+
+	serveStoredFile(w, r, updateMediaStore(kind), mediaPath, isShared, maxAge)
+}
+
+// maxMediaCaptionLength is the longest caption a photo or video can have
+const maxMediaCaptionLength = 500
+
+// UpdateMediaCaptionRequest sets or, when empty, clears a media item's caption
+type UpdateMediaCaptionRequest struct {
+	Caption *string `json:"caption"`
+}
+
+// ReorderUpdateMediaRequest lists every media item of an update in its new order
+type ReorderUpdateMediaRequest struct {
+	MediaIDs []int `json:"media_ids"`
+}
+
+// AddUpdateMediaHandler adds photos and videos to an existing update, including one that has
+// already been shared. They are sent like the uploads of a new update: photos and videos
+// form fields, and finished resumable uploads listed in upload_ids in the data field.
+func AddUpdateMediaHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID := claims.UserID
+
+	updateID, _, err := updateMediaIDsFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid update ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse multipart form data for file uploads
+	err = r.ParseMultipartForm(100 << 20) // 100 MB max for videos
+	if err != nil {
+		http.Error(w, "Failed to parse form data", http.StatusBadRequest)
+		return
+	}
+
+	var req CreateUpdateRequest
+	if jsonData := r.FormValue("data"); jsonData != "" {
+		if err := json.Unmarshal([]byte(jsonData), &req); err != nil {
+			http.Error(w, "Invalid request data", http.StatusBadRequest)
+			return
+		}
+	}
+
+	pregnancyID, err := getOwnedUpdatePregnancyID(updateID, userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Update not found or access denied", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Resumable uploads must have finished before they can be attached
+	finishedUploads, err := completedMediaUploads(req.UploadIDs, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	uploads := uploadedMediaFiles(r)
+	if len(uploads) == 0 && len(finishedUploads) == 0 {
+		http.Error(w, "No photos or videos provided", http.StatusBadRequest)
+		return
+	}
+
+	// New media goes after the existing media
+	var maxSortOrder int
+	db.GetDB().QueryRow(`SELECT COALESCE(MAX(sort_order), -1) FROM update_media WHERE update_id = ?`, updateID).Scan(&maxSortOrder)
+
+	added := 0
+	for i, fileHeader := range uploads {
+		if _, err := saveUploadedMedia(r.Context(), fileHeader, pregnancyID, updateID, maxSortOrder+i+1); err != nil {
+			log.Printf("Failed to save upload %s: %v", fileHeader.Filename, err)
+			continue
+		}
+		added++
+	}
+	for i, upload := range finishedUploads {
+		if _, err := attachMediaUpload(r.Context(), upload, pregnancyID, updateID, maxSortOrder+len(uploads)+i+1); err != nil {
+			log.Printf("Failed to attach upload %s: %v", upload.ID, err)
+			continue
+		}
+		added++
+	}
+	if added == 0 {
+		http.Error(w, "None of the files could be added. Photos must be JPG, PNG, GIF or WebP and videos MP4 or MOV", http.StatusBadRequest)
+		return
+	}
+
+	touchUpdate(updateID)
+	go processUpdateMedia(updateID)
+
+	writeUpdateMedia(w, updateID, pregnancyID, http.StatusCreated)
+}
+
+// UpdateMediaCaptionHandler sets the caption of one of an update's photos or videos
+func UpdateMediaCaptionHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	updateID, mediaID, err := updateMediaIDsFromPath(r.URL.Path)
+	if err != nil || mediaID == 0 {
+		http.Error(w, "Invalid media ID", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateMediaCaptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var caption *string
+	if req.Caption != nil {
+		trimmed := strings.TrimSpace(*req.Caption)
+		if len([]rune(trimmed)) > maxMediaCaptionLength {
+			http.Error(w, fmt.Sprintf("Captions can be at most %d characters", maxMediaCaptionLength), http.StatusBadRequest)
+			return
+		}
+		if trimmed != "" {
+			caption = &trimmed
+		}
+	}
+
+	pregnancyID, err := getOwnedUpdatePregnancyID(updateID, claims.UserID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Update not found or access denied", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := db.GetDB().Exec(`UPDATE update_media SET caption = ? WHERE id = ? AND update_id = ?`, caption, mediaID, updateID)
+	if err != nil {
+		log.Printf("Failed to caption media %d: %v", mediaID, err)
+		http.Error(w, "Failed to save caption", http.StatusInternalServerError)
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		http.Error(w, "Media not found", http.StatusNotFound)
+		return
+	}
+
+	touchUpdate(updateID)
+	writeUpdateMedia(w, updateID, pregnancyID, http.StatusOK)
+}
+
+// ReorderUpdateMediaHandler puts an update's photos and videos in a new order. Every one of
+// them must be listed, once.
+func ReorderUpdateMediaHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	updateID, _, err := updateMediaIDsFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid update ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ReorderUpdateMediaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	pregnancyID, err := getOwnedUpdatePregnancyID(updateID, claims.UserID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Update not found or access denied", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	items, err := getUpdateMedia(updateID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	listed := map[int]bool{}
+	for _, mediaID := range req.MediaIDs {
+		listed[mediaID] = true
+	}
+	complete := len(req.MediaIDs) == len(items) && len(listed) == len(items)
+	for _, item := range items {
+		complete = complete && listed[item.ID]
+	}
+	if !complete {
+		http.Error(w, "media_ids must list each of the update's photos and videos once", http.StatusBadRequest)
+		return
+	}
+
+	if err := reorderUpdateMedia(updateID, req.MediaIDs); err != nil {
+		log.Printf("Failed to reorder media of update %d: %v", updateID, err)
+		http.Error(w, "Failed to reorder media", http.StatusInternalServerError)
+		return
+	}
+
+	touchUpdate(updateID)
+	writeUpdateMedia(w, updateID, pregnancyID, http.StatusOK)
+}
+
+// ReplaceUpdateMediaHandler swaps the file of one of an update's photos or videos, keeping its
+// caption and place. The new file is sent as a file form field, or as a finished resumable
+// upload named by upload_id in the data field.
+func ReplaceUpdateMediaHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID := claims.UserID
+
+	updateID, mediaID, err := updateMediaIDsFromPath(r.URL.Path)
+	if err != nil || mediaID == 0 {
+		http.Error(w, "Invalid media ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse multipart form data for file uploads
+	err = r.ParseMultipartForm(100 << 20) // 100 MB max for videos
+	if err != nil {
+		http.Error(w, "Failed to parse form data", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		UploadID string `json:"upload_id"`
+	}
+	if jsonData := r.FormValue("data"); jsonData != "" {
+		if err := json.Unmarshal([]byte(jsonData), &req); err != nil {
+			http.Error(w, "Invalid request data", http.StatusBadRequest)
+			return
+		}
+	}
+
+	pregnancyID, err := getOwnedUpdatePregnancyID(updateID, userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Update not found or access denied", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	existing, err := getUpdateMediaItem(mediaID, updateID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if existing == nil {
+		http.Error(w, "Media not found", http.StatusNotFound)
+		return
+	}
+
+	var file io.ReadSeekCloser
+	var originalFilename string
+	var upload *models.MediaUpload
+	if req.UploadID != "" {
+		finishedUploads, err := completedMediaUploads([]string{req.UploadID}, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		upload = &finishedUploads[0]
+		file, err = os.Open(mediaUploadPath(upload.ID))
+		if err != nil {
+			log.Printf("Failed to open upload %s: %v", upload.ID, err)
+			http.Error(w, "Failed to replace media", http.StatusInternalServerError)
+			return
+		}
+		originalFilename = upload.Filename
+	} else {
+		formFile, fileHeader, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "No file provided", http.StatusBadRequest)
+			return
+		}
+		file, originalFilename = formFile, fileHeader.Filename
+	}
+	defer file.Close()
+
+	stored, err := storeMedia(r.Context(), file, originalFilename, pregnancyID, updateID, existing.SortOrder)
+	if err == media.ErrUnsupportedType {
+		http.Error(w, "Unsupported file type. Photos must be JPG, PNG, GIF or WebP and videos MP4 or MOV", http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to store replacement for media %d: %v", mediaID, err)
+		http.Error(w, "Failed to replace media", http.StatusBadRequest)
+		return
+	}
+
+	if err := replaceUpdateMedia(mediaID, stored); err != nil {
+		log.Printf("Failed to replace media %d: %v", mediaID, err)
+		updateMediaStore(stored.Kind).Delete(r.Context(), updateMediaKey(pregnancyID, stored.Filename))
+		http.Error(w, "Failed to replace media", http.StatusInternalServerError)
+		return
+	}
+
+	if err := removeStoredMedia(r.Context(), pregnancyID, *existing); err != nil {
+		log.Printf("Failed to remove replaced file of media %d: %v", mediaID, err)
+	}
+	if upload != nil {
+		if err := deleteMediaUpload(upload.ID); err != nil {
+			log.Printf("Failed to remove attached upload %s: %v", upload.ID, err)
+		}
+	}
+
+	touchUpdate(updateID)
+	go processUpdateMedia(updateID)
+
+	writeUpdateMedia(w, updateID, pregnancyID, http.StatusOK)
+}
+
+// DeleteUpdateMediaHandler removes one photo or video from an update, along with its files
+func DeleteUpdateMediaHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	updateID, mediaID, err := updateMediaIDsFromPath(r.URL.Path)
+	if err != nil || mediaID == 0 {
+		http.Error(w, "Invalid media ID", http.StatusBadRequest)
+		return
+	}
+
+	pregnancyID, err := getOwnedUpdatePregnancyID(updateID, claims.UserID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Update not found or access denied", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	item, err := getUpdateMediaItem(mediaID, updateID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if item == nil {
+		http.Error(w, "Media not found", http.StatusNotFound)
+		return
+	}
+
+	if _, err := db.GetDB().Exec(`DELETE FROM media_tags WHERE media_id = ?`, mediaID); err != nil {
+		log.Printf("Failed to delete tags of media %d: %v", mediaID, err)
+		http.Error(w, "Failed to delete media", http.StatusInternalServerError)
+		return
+	}
+	if _, err := db.GetDB().Exec(`DELETE FROM update_media WHERE id = ?`, mediaID); err != nil {
+		log.Printf("Failed to delete media %d: %v", mediaID, err)
+		http.Error(w, "Failed to delete media", http.StatusInternalServerError)
+		return
+	}
+	if err := removeStoredMedia(r.Context(), pregnancyID, *item); err != nil {
+		log.Printf("Failed to remove files of deleted media %d: %v", mediaID, err)
+	}
+
+	touchUpdate(updateID)
+	writeUpdateMedia(w, updateID, pregnancyID, http.StatusOK)
+}
+
+// updateMediaIDsFromPath reads the update ID, and the media ID if there is one, from
+// /api/updates/{id}/media[/{mediaID}[/...]]
+func updateMediaIDsFromPath(urlPath string) (int, int, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(urlPath, "/api/updates/"), "/"), "/")
+	if len(parts) < 2 || parts[1] != "media" {
+		return 0, 0, fmt.Errorf("not an update media path: %s", urlPath)
+	}
+
+	updateID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(parts) < 3 || parts[2] == "order" {
+		return updateID, 0, nil
+	}
+
+	mediaID, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return 0, 0, err
+	}
+	return updateID, mediaID, nil
+}
+
+// writeUpdateMedia responds with an update's photos and videos after a change to them
+func writeUpdateMedia(w http.ResponseWriter, updateID, pregnancyID, status int) {
+	items, err := getSignedUpdateMedia(updateID, pregnancyID, media.ScopePrivate)
+	if err != nil {
+		http.Error(w, "Failed to fetch media", http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = []models.UpdateMedia{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"media": items,
+	})
+}
+
+// removeStoredMedia deletes the stored file of a photo or video and its renditions
+func removeStoredMedia(ctx context.Context, pregnancyID int, item models.UpdateMedia) error {
+	store := updateMediaStore(item.Kind)
+	key := updateMediaKey(pregnancyID, item.Filename)
+	if err := store.Delete(ctx, key); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", key, err)
+	}
+	if err := removeRenditions(ctx, store, key); err != nil {
+		return fmt.Errorf("failed to remove renditions of %s: %w", key, err)
+	}
+	return nil
+}
+
+// getUpdateMedia fetches the photos and videos of an update, in display order
+func getUpdateMedia(updateID int) ([]models.UpdateMedia, error) {
+	rows, err := db.GetDB().Query(`
+		SELECT id, update_id, kind, mime_type, filename, original_filename, file_size, checksum, width, height,
+		       duration_seconds, caption, sort_order, renditions, captured_at, created_at
+		FROM update_media
+		WHERE update_id = ?
+		ORDER BY sort_order`, updateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.UpdateMedia
+	for rows.Next() {
+		var item models.UpdateMedia
+		err := rows.Scan(&item.ID, &item.UpdateID, &item.Kind, &item.MIMEType, &item.Filename, &item.OriginalFilename,
+			&item.FileSize, &item.Checksum, &item.Width, &item.Height, &item.DurationSeconds, &item.Caption,
+			&item.SortOrder, &item.Renditions, &item.CapturedAt, &item.CreatedAt)
+		if err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+
+	if err := loadMediaTags(items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Database functions
+
+// getOwnedUpdatePregnancyID returns the pregnancy of one of the user's updates that isn't in the
+// recycle bin, or sql.ErrNoRows
+func getOwnedUpdatePregnancyID(updateID, userID int) (int, error) {
+	var pregnancyID int
+	err := db.GetDB().QueryRow(`
+		SELECT p.id
+		FROM pregnancy_updates pu
+		JOIN pregnancies p ON p.id = pu.pregnancy_id
+		WHERE pu.id = ? AND p.user_id = ? AND pu.deleted_at IS NULL`,
+		updateID, userID).Scan(&pregnancyID)
+	return pregnancyID, err
+}
+
+// getUpdateMediaItem fetches one photo or video of an update, or nil if it has no such media
+func getUpdateMediaItem(mediaID, updateID int) (*models.UpdateMedia, error) {
+	items, err := getUpdateMedia(updateID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		if items[i].ID == mediaID {
+			return &items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// touchUpdate records that an update's media changed
+func touchUpdate(updateID int) {
+	if _, err := db.GetDB().Exec(`UPDATE pregnancy_updates SET updated_at = ? WHERE id = ?`, time.Now(), updateID); err != nil {
+		log.Printf("Failed to touch update %d: %v", updateID, err)
+	}
+}
+
+// reorderUpdateMedia gives an update's media the sort order of their place in mediaIDs
+func reorderUpdateMedia(updateID int, mediaIDs []int) error {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, mediaID := range mediaIDs {
+		if _, err := tx.Exec(`UPDATE update_media SET sort_order = ? WHERE id = ? AND update_id = ?`, i, mediaID, updateID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// replaceUpdateMedia records a new stored file for a photo or video. Its renditions are
+// generated again by the media job.
+func replaceUpdateMedia(mediaID int, stored *models.UpdateMedia) error {
+	_, err := db.GetDB().Exec(`
+		UPDATE update_media
+		SET kind = ?, mime_type = ?, filename = ?, original_filename = ?, file_size = ?, checksum = ?,
+		    width = ?, height = ?, duration_seconds = ?, captured_at = ?, renditions = NULL, processed_at = NULL
+		WHERE id = ?`,
+		stored.Kind, stored.MIMEType, stored.Filename, stored.OriginalFilename, stored.FileSize, stored.Checksum,
+		stored.Width, stored.Height, stored.DurationSeconds, stored.CapturedAt, mediaID)
+	return err
+}
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-go/api/db"
+	"simple-go/api/middleware"
+	"simple-go/api/models"
+)
+
+// maxDiffCells bounds the work of comparing two versions line by line. Longer changes are
+// shown as the old lines removed and the new ones added.
+const maxDiffCells = 1000000
+
+// RevisionDiff compares a revision of an update with the version that replaced it, which is
+// either the next revision or, for the latest one, the update as it is now
+type RevisionDiff struct {
+	RevisionID     int               `json:"revision_id"`
+	NextRevisionID *int              `json:"next_revision_id"`
+	OldTitle       string            `json:"old_title"`
+	NewTitle       string            `json:"new_title"`
+	TitleChanged   bool              `json:"title_changed"`
+	WasShared      bool              `json:"was_shared"`
+	IsShared       bool              `json:"is_shared"`
+	Lines          []models.DiffLine `json:"lines"`
+}
+
+// GetUpdateRevisionsHandler lists the earlier versions of one of the parents' updates, most
+// recent first (GET /api/updates/{id}/revisions)
+func GetUpdateRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	updateID, _, err := updateRevisionIDsFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid update ID", http.StatusBadRequest)
+		return
+	}
+
+	if !requireOwnedUpdate(w, updateID, claims.UserID) {
+		return
+	}
+
+	revisions, err := getUpdateRevisions(updateID)
+	if err != nil {
+		log.Printf("Failed to get revisions of update %d: %v", updateID, err)
+		http.Error(w, "Failed to fetch revisions", http.StatusInternalServerError)
+		return
+	}
+
+	if revisions == nil {
+		revisions = []models.UpdateRevision{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// GetUpdateRevisionDiffHandler compares a revision with the version that replaced it
+// (GET /api/updates/{id}/revisions/{revisionID}/diff)
+func GetUpdateRevisionDiffHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	updateID, revisionID, err := updateRevisionIDsFromPath(r.URL.Path)
+	if err != nil || revisionID == 0 {
+		http.Error(w, "Invalid revision ID", http.StatusBadRequest)
+		return
+	}
+
+	if !requireOwnedUpdate(w, updateID, claims.UserID) {
+		return
+	}
+
+	revision, err := getUpdateRevision(revisionID, updateID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Revision not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get revision %d: %v", revisionID, err)
+		http.Error(w, "Failed to fetch revision", http.StatusInternalServerError)
+		return
+	}
+
+	next, err := getNextUpdateVersion(revision)
+	if err != nil {
+		log.Printf("Failed to get the version after revision %d: %v", revisionID, err)
+		http.Error(w, "Failed to fetch revision", http.StatusInternalServerError)
+		return
+	}
+
+	diff := RevisionDiff{
+		RevisionID:   revision.ID,
+		OldTitle:     revision.Title,
+		NewTitle:     next.Title,
+		TitleChanged: revision.Title != next.Title,
+		WasShared:    revision.IsShared,
+		IsShared:     next.IsShared,
+		Lines:        diffLines(contentLines(revision.Content), contentLines(next.Content)),
+	}
+	if next.ID != 0 {
+		diff.NextRevisionID = &next.ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+// RestoreUpdateRevisionHandler puts a revision's title and content back on the update
+// (POST /api/updates/{id}/revisions/{revisionID}/restore). Restoring is an edit, so the version
+// it replaces is kept as a revision in turn. Whether the update is shared doesn't change.
+func RestoreUpdateRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*middleware.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	updateID, revisionID, err := updateRevisionIDsFromPath(r.URL.Path)
+	if err != nil || revisionID == 0 {
+		http.Error(w, "Invalid revision ID", http.StatusBadRequest)
+		return
+	}
+
+	pregnancyID, err := getOwnedUpdatePregnancyID(updateID, claims.UserID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Update not found or access denied", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	revision, err := getUpdateRevision(revisionID, updateID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Revision not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get revision %d: %v", revisionID, err)
+		http.Error(w, "Failed to fetch revision", http.StatusInternalServerError)
+		return
+	}
+
+	if err := restoreUpdateRevision(revision, claims.UserID); err != nil {
+		log.Printf("Failed to restore revision %d of update %d: %v", revisionID, updateID, err)
+		http.Error(w, "Failed to restore revision", http.StatusInternalServerError)
+		return
+	}
+
+	writeEditedUpdate(w, updateID, pregnancyID)
+}
+
+// updateRevisionIDsFromPath reads the update ID and, if there is one, the revision ID from
+// /api/updates/{id}/revisions[/{revisionID}[/...]]
+func updateRevisionIDsFromPath(urlPath string) (int, int, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(urlPath, "/api/updates/"), "/"), "/")
+	if len(parts) < 2 || parts[1] != "revisions" {
+		return 0, 0, fmt.Errorf("not an update revisions path: %s", urlPath)
+	}
+
+	updateID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(parts) < 3 {
+		return updateID, 0, nil
+	}
+
+	revisionID, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return 0, 0, err
+	}
+	return updateID, revisionID, nil
+}
+
+// contentLines splits an update's content into lines for comparing
+func contentLines(content *string) []string {
+	if content == nil || *content == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(*content, "\r\n", "\n"), "\n")
+}
+
+// diffLines compares two versions line by line, keeping the longest run of lines they share
+func diffLines(before, after []string) []models.DiffLine {
+	lines := []models.DiffLine{}
+
+	// Lines at the start and end that didn't change need no comparing
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		lines = append(lines, models.DiffLine{Op: models.DiffEqual, Text: before[prefix]})
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix && before[len(before)-1-suffix] == after[len(after)-1-suffix] {
+		suffix++
+	}
+	a, b := before[prefix:len(before)-suffix], after[prefix:len(after)-suffix]
+
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			lines = append(lines, models.DiffLine{Op: models.DiffDelete, Text: line})
+		}
+		for _, line := range b {
+			lines = append(lines, models.DiffLine{Op: models.DiffInsert, Text: line})
+		}
+	} else {
+		// common[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+		common := make([][]int, len(a)+1)
+		for i := range common {
+			common[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					common[i][j] = common[i+1][j+1] + 1
+				} else if common[i+1][j] >= common[i][j+1] {
+					common[i][j] = common[i+1][j]
+				} else {
+					common[i][j] = common[i][j+1]
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < len(a) || j < len(b) {
+			switch {
+			case i < len(a) && j < len(b) && a[i] == b[j]:
+				lines = append(lines, models.DiffLine{Op: models.DiffEqual, Text: a[i]})
+				i++
+				j++
+			case j == len(b) || (i < len(a) && common[i+1][j] >= common[i][j+1]):
+				lines = append(lines, models.DiffLine{Op: models.DiffDelete, Text: a[i]})
+				i++
+			default:
+				lines = append(lines, models.DiffLine{Op: models.DiffInsert, Text: b[j]})
+				j++
+			}
+		}
+	}
+
+	for _, line := range before[len(before)-suffix:] {
+		lines = append(lines, models.DiffLine{Op: models.DiffEqual, Text: line})
+	}
+	return lines
+}
+
+// Database functions
+
+// saveUpdateRevision keeps an update's current version as a revision, before it is edited by
+// the user to title, content and, unless it is nil, isShared. Nothing is kept when none of those
+// change, such as when only the date or type of the update is edited.
+func saveUpdateRevision(tx *sql.Tx, updateID, userID int, title string, content *string, isShared *bool) error {
+	_, err := tx.Exec(`
+		INSERT INTO update_revisions (update_id, title, content, is_shared, edited_by, edited_at)
+		SELECT id, title, content, is_shared, ?, ?
+		FROM pregnancy_updates
+		WHERE id = ? AND (title != ? OR COALESCE(content, '') != COALESCE(?, '') OR is_shared != COALESCE(?, is_shared))
+	`, userID, time.Now().UTC(), updateID, title, content, isShared)
+	return err
+}
+
+// getUpdateRevisions returns the revisions of an update, most recent first
+func getUpdateRevisions(updateID int) ([]models.UpdateRevision, error) {
+	rows, err := db.GetDB().Query(`
+		SELECT ur.id, ur.update_id, ur.title, ur.content, ur.is_shared, ur.edited_by,
+		       COALESCE(u.name, ''), ur.edited_at
+		FROM update_revisions ur
+		LEFT JOIN users u ON u.id = ur.edited_by
+		WHERE ur.update_id = ?
+		ORDER BY ur.id DESC
+	`, updateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []models.UpdateRevision
+	for rows.Next() {
+		var revision models.UpdateRevision
+		err := rows.Scan(&revision.ID, &revision.UpdateID, &revision.Title, &revision.Content,
+			&revision.IsShared, &revision.EditedByID, &revision.EditedBy, &revision.EditedAt)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+// getUpdateRevision fetches one revision of an update, or sql.ErrNoRows if it has no such revision
+func getUpdateRevision(revisionID, updateID int) (*models.UpdateRevision, error) {
+	var revision models.UpdateRevision
+	err := db.GetDB().QueryRow(`
+		SELECT ur.id, ur.update_id, ur.title, ur.content, ur.is_shared, ur.edited_by,
+		       COALESCE(u.name, ''), ur.edited_at
+		FROM update_revisions ur
+		LEFT JOIN users u ON u.id = ur.edited_by
+		WHERE ur.id = ? AND ur.update_id = ?
+	`, revisionID, updateID).Scan(&revision.ID, &revision.UpdateID, &revision.Title, &revision.Content,
+		&revision.IsShared, &revision.EditedByID, &revision.EditedBy, &revision.EditedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// getNextUpdateVersion returns the version that replaced a revision: the next revision, or the
+// update as it is now, with an ID of 0, if the revision is the latest
+func getNextUpdateVersion(revision *models.UpdateRevision) (*models.UpdateRevision, error) {
+	var next models.UpdateRevision
+	err := db.GetDB().QueryRow(`
+		SELECT id, title, content, is_shared
+		FROM update_revisions
+		WHERE update_id = ? AND id > ?
+		ORDER BY id
+		LIMIT 1
+	`, revision.UpdateID, revision.ID).Scan(&next.ID, &next.Title, &next.Content, &next.IsShared)
+	if err == sql.ErrNoRows {
+		err = db.GetDB().QueryRow(`
+			SELECT title, content, is_shared FROM pregnancy_updates WHERE id = ?
+		`, revision.UpdateID).Scan(&next.Title, &next.Content, &next.IsShared)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// restoreUpdateRevision puts a revision's title and content back on its update, keeping the
+// version it replaces
+func restoreUpdateRevision(revision *models.UpdateRevision, userID int) error {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := saveUpdateRevision(tx, revision.UpdateID, userID, revision.Title, revision.Content, nil); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE pregnancy_updates SET title = ?, content = ?, updated_at = ? WHERE id = ?
+	`, revision.Title, revision.Content, time.Now().UTC(), revision.UpdateID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// getUpdateEditedAt returns when an update was last edited, or nil if it never has been. With
+// sharedOnly, only edits to a version the village could see count.
+func getUpdateEditedAt(updateID int, sharedOnly bool) (*time.Time, error) {
+	var editedAt time.Time
+	err := db.GetDB().QueryRow(`
+		SELECT edited_at FROM update_revisions
+		WHERE update_id = ? AND (is_shared = TRUE OR ? = FALSE)
+		ORDER BY id DESC
+		LIMIT 1
+	`, updateID, sharedOnly).Scan(&editedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &editedAt, nil
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -32,7 +33,8 @@ type CreateVillageMembersBulkRequest struct {
 }
 
 type CreateVillageMembersBulkResponse struct {
-	Members []*models.VillageMember `json:"members"`
+	Household *models.Household        `json:"household"`
+	Members   []*models.VillageMember `json:"members"`
 }
 
 // CreateVillageMemberHandler handles creating a new village member
@@ -104,7 +106,7 @@ func CreateVillageMemberHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(member)
 }
 
-// CreateVillageMembersBulkHandler handles creating multiple village members from comma-separated emails
+// CreateVillageMembersBulkHandler handles creating a household with multiple email addresses
 func CreateVillageMembersBulkHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -170,26 +172,22 @@ func CreateVillageMembersBulkHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Create village members for each email
-	var members []*models.VillageMember
-	for i, email := range req.Emails {
-		// For multiple emails, append number to name (e.g., "John & Jane" becomes "John & Jane (1)", "John & Jane (2)")
-		memberName := req.Name
-		if len(req.Emails) > 1 {
-			memberName = fmt.Sprintf("%s (%d)", req.Name, i+1)
-		}
+	// Create one household with a village member for each email
+	household, err := CreateHouseholdWithEvent(pregnancy.ID, req.Name, req.Emails, req.Relationship, req.IsTold, false)
+	if err != nil {
+		log.Printf("Failed to create village household: %v", err)
+		http.Error(w, "Failed to create village member", http.StatusInternalServerError)
+		return
+	}
 
-		member, err := CreateVillageMemberWithEvent(pregnancy.ID, memberName, email, req.Relationship, req.IsTold, false)
-		if err != nil {
-			log.Printf("Failed to create village member: %v", err)
-			http.Error(w, "Failed to create village member", http.StatusInternalServerError)
-			return
-		}
-		members = append(members, member)
+	var members []*models.VillageMember
+	for i := range household.Members {
+		members = append(members, &household.Members[i])
 	}
 
 	response := CreateVillageMembersBulkResponse{
-		Members: members,
+		Household: household,
+		Members:   members,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -229,6 +227,24 @@ func GetVillageMembersHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Optionally sort by recent activity (timeline visits, email opens and clicks)
+	if r.URL.Query().Get("sort") == "activity" {
+		engagement, err := GetMemberEngagement(pregnancy.ID)
+		if err != nil {
+			log.Printf("Database error getting member engagement: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		rank := make(map[int]int, len(engagement))
+		for i, member := range engagement {
+			rank[member.MemberID] = i
+		}
+		sort.SliceStable(members, func(i, j int) bool {
+			return rank[members[i].ID] < rank[members[j].ID]
+		})
+	}
+
 	// Ensure we return an empty array instead of null when no members exist
 	if members == nil {
 		members = []*models.VillageMember{}
@@ -373,17 +389,27 @@ func UpdateVillageMemberHandler(w http.ResponseWriter, r *http.Request) {
 
 // Database functions
 
-func CreateVillageMember(pregnancyID int, name, email, relationship string, isTold bool) (*models.VillageMember, error) {
+func CreateVillageMember(tx *sql.Tx, pregnancyID int, householdID *int, name, email, relationship string, isTold bool) (*models.VillageMember, error) {
+	unsubscribeToken, err := generateSecretToken()
+	if err != nil {
+		return nil, err
+	}
+	accessNonce, err := generateSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		INSERT INTO village_members (pregnancy_id, name, email, relationship, is_told)
-		VALUES (?, ?, ?, ?, ?)
-		RETURNING id, pregnancy_id, name, email, relationship, is_told, told_date, is_subscribed, unsubscribe_token, created_at, updated_at
+		INSERT INTO village_members (pregnancy_id, household_id, name, email, relationship, is_told, unsubscribe_token, access_nonce)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id, pregnancy_id, household_id, name, email, relationship, is_told, told_date, is_subscribed, unsubscribe_token, is_leader, delivery_frequency, last_digest_sent_at, created_at, updated_at
 	`
 
 	var member models.VillageMember
-	err := db.GetDB().QueryRow(query, pregnancyID, name, email, relationship, isTold).Scan(
+	err = tx.QueryRow(query, pregnancyID, householdID, name, email, relationship, isTold, unsubscribeToken, accessNonce).Scan(
 		&member.ID,
 		&member.PregnancyID,
+		&member.HouseholdID,
 		&member.Name,
 		&member.Email,
 		&member.Relationship,
@@ -391,6 +417,9 @@ func CreateVillageMember(pregnancyID int, name, email, relationship string, isTo
 		&member.ToldDate,
 		&member.IsSubscribed,
 		&member.UnsubscribeToken,
+		&member.IsLeader,
+		&member.DeliveryFrequency,
+		&member.LastDigestSentAt,
 		&member.CreatedAt,
 		&member.UpdatedAt,
 	)
@@ -402,19 +431,66 @@ func CreateVillageMember(pregnancyID int, name, email, relationship string, isTo
 	return &member, nil
 }
 
-// CreateVillageMemberWithEvent creates a village member and corresponding event
+// CreateVillageMemberWithEvent creates a single-address household and corresponding event
 func CreateVillageMemberWithEvent(pregnancyID int, name, email, relationship string, isTold bool, isFromInvite bool) (*models.VillageMember, error) {
-	// Create the village member first
-	member, err := CreateVillageMember(pregnancyID, name, email, relationship, isTold)
+	household, err := CreateHouseholdWithEvent(pregnancyID, name, []string{email}, relationship, isTold, isFromInvite)
+	if err != nil {
+		return nil, err
+	}
+
+	return &household.Members[0], nil
+}
+
+// CreateHouseholdWithEvent creates a household with one village member per email address
+// and a single corresponding event for the whole household
+func CreateHouseholdWithEvent(pregnancyID int, name string, emails []string, relationship string, isTold bool, isFromInvite bool) (*models.Household, error) {
+	// Create the household and its members first, together, so a failure leaves neither behind
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	household, err := createHouseholdMembers(tx, pregnancyID, name, emails, relationship, isTold)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	recordHouseholdJoined(pregnancyID, household, name, relationship, isTold, isFromInvite)
+	return household, nil
+}
+
+// createHouseholdMembers creates a household with one village member per email address
+// within the given transaction
+func createHouseholdMembers(tx *sql.Tx, pregnancyID int, name string, emails []string, relationship string, isTold bool) (*models.Household, error) {
+	household, err := CreateHousehold(tx, pregnancyID, name, relationship)
 	if err != nil {
 		return nil, err
 	}
 
+	for _, email := range emails {
+		member, err := CreateVillageMember(tx, pregnancyID, &household.ID, name, email, relationship, isTold)
+		if err != nil {
+			return nil, err
+		}
+		household.Members = append(household.Members, *member)
+	}
+
+	return household, nil
+}
+
+// recordHouseholdJoined creates the event for a newly created household and sends any
+// welcome emails once its members are saved
+func recordHouseholdJoined(pregnancyID int, household *models.Household, name, relationship string, isTold bool, isFromInvite bool) {
 	// Get current week for the pregnancy
 	pregnancy, err := GetPregnancyByID(pregnancyID)
 	if err != nil {
 		log.Printf("Could not get pregnancy for event creation: %v", err)
-		return member, nil // Don't fail member creation if event fails
+		return // Don't fail member creation if event fails
 	}
 
 	weekNumber := pregnancy.GetCurrentWeek()
@@ -431,34 +507,43 @@ func CreateVillageMemberWithEvent(pregnancyID int, name, email, relationship str
 		}
 	}
 
-	// Send welcome email if member has email and is being added (not from invite, as invite flow handles its own emails)
-	if !isFromInvite && email != "" {
-		go func() {
-			// Send welcome email in background to avoid blocking the response
-			emailService, err := emailservice.NewEmailService()
-			if err != nil {
-				log.Printf("Failed to initialize email service for welcome email: %v", err)
-				return
+	// Send welcome email to each address when being added (not from invite, as invite flow handles its own emails).
+	// Members who haven't been told yet get theirs once the parents mark them as told.
+	if !isFromInvite && isTold {
+		sendWelcomeEmails(pregnancy, household.Members)
+	}
+}
+
+// sendWelcomeEmails sends the welcome email to each member in the background to avoid blocking the response
+func sendWelcomeEmails(pregnancy *models.Pregnancy, members []models.VillageMember) {
+	go func() {
+		emailService, err := emailservice.NewEmailService()
+		if err != nil {
+			log.Printf("Failed to initialize email service for welcome email: %v", err)
+			return
+		}
+
+		for i := range members {
+			member := &members[i]
+			if member.Email == "" {
+				continue
 			}
 
 			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-			defer cancel()
-
 			err = emailService.SendWelcomeEmail(ctx, member, pregnancy)
+			cancel()
 			if err != nil {
 				log.Printf("Failed to send welcome email to %s: %v", member.Email, err)
 			} else {
-				log.Printf("Welcome email sent to %s for pregnancy %d", member.Email, pregnancyID)
+				log.Printf("Welcome email sent to %s for pregnancy %d", member.Email, pregnancy.ID)
 			}
-		}()
-	}
-
-	return member, nil
+		}
+	}()
 }
 
 func GetVillageMembersByPregnancyID(pregnancyID int) ([]*models.VillageMember, error) {
 	query := `
-		SELECT id, pregnancy_id, name, email, relationship, is_told, told_date, is_subscribed, unsubscribe_token, created_at, updated_at
+		SELECT id, pregnancy_id, household_id, name, email, relationship, is_told, told_date, is_subscribed, unsubscribe_token, is_leader, delivery_frequency, last_digest_sent_at, created_at, updated_at
 		FROM village_members 
 		WHERE pregnancy_id = ?
 		ORDER BY created_at ASC
@@ -476,6 +561,7 @@ func GetVillageMembersByPregnancyID(pregnancyID int) ([]*models.VillageMember, e
 		err := rows.Scan(
 			&member.ID,
 			&member.PregnancyID,
+			&member.HouseholdID,
 			&member.Name,
 			&member.Email,
 			&member.Relationship,
@@ -483,6 +569,9 @@ func GetVillageMembersByPregnancyID(pregnancyID int) ([]*models.VillageMember, e
 			&member.ToldDate,
 			&member.IsSubscribed,
 			&member.UnsubscribeToken,
+			&member.IsLeader,
+			&member.DeliveryFrequency,
+			&member.LastDigestSentAt,
 			&member.CreatedAt,
 			&member.UpdatedAt,
 		)
@@ -497,7 +586,7 @@ func GetVillageMembersByPregnancyID(pregnancyID int) ([]*models.VillageMember, e
 
 func GetVillageMemberByEmail(pregnancyID int, email string) (*models.VillageMember, error) {
 	query := `
-		SELECT id, pregnancy_id, name, email, relationship, is_told, told_date, is_subscribed, unsubscribe_token, created_at, updated_at
+		SELECT id, pregnancy_id, household_id, name, email, relationship, is_told, told_date, is_subscribed, unsubscribe_token, is_leader, delivery_frequency, last_digest_sent_at, created_at, updated_at
 		FROM village_members 
 		WHERE pregnancy_id = ? AND email = ?
 		LIMIT 1
@@ -507,6 +596,7 @@ func GetVillageMemberByEmail(pregnancyID int, email string) (*models.VillageMemb
 	err := db.GetDB().QueryRow(query, pregnancyID, email).Scan(
 		&member.ID,
 		&member.PregnancyID,
+		&member.HouseholdID,
 		&member.Name,
 		&member.Email,
 		&member.Relationship,
@@ -514,6 +604,9 @@ func GetVillageMemberByEmail(pregnancyID int, email string) (*models.VillageMemb
 		&member.ToldDate,
 		&member.IsSubscribed,
 		&member.UnsubscribeToken,
+		&member.IsLeader,
+		&member.DeliveryFrequency,
+		&member.LastDigestSentAt,
 		&member.CreatedAt,
 		&member.UpdatedAt,
 	)
@@ -527,7 +620,7 @@ func GetVillageMemberByEmail(pregnancyID int, email string) (*models.VillageMemb
 
 func GetVillageMemberByID(memberID int) (*models.VillageMember, error) {
 	query := `
-		SELECT id, pregnancy_id, name, email, relationship, is_told, told_date, is_subscribed, unsubscribe_token, created_at, updated_at
+		SELECT id, pregnancy_id, household_id, name, email, relationship, is_told, told_date, is_subscribed, unsubscribe_token, is_leader, delivery_frequency, last_digest_sent_at, created_at, updated_at
 		FROM village_members 
 		WHERE id = ?
 		LIMIT 1
@@ -537,6 +630,7 @@ func GetVillageMemberByID(memberID int) (*models.VillageMember, error) {
 	err := db.GetDB().QueryRow(query, memberID).Scan(
 		&member.ID,
 		&member.PregnancyID,
+		&member.HouseholdID,
 		&member.Name,
 		&member.Email,
 		&member.Relationship,
@@ -544,6 +638,9 @@ func GetVillageMemberByID(memberID int) (*models.VillageMember, error) {
 		&member.ToldDate,
 		&member.IsSubscribed,
 		&member.UnsubscribeToken,
+		&member.IsLeader,
+		&member.DeliveryFrequency,
+		&member.LastDigestSentAt,
 		&member.CreatedAt,
 		&member.UpdatedAt,
 	)
@@ -560,13 +657,14 @@ func UpdateVillageMemberStatus(memberID int, isTold bool) (*models.VillageMember
 		UPDATE village_members 
 		SET is_told = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
-		RETURNING id, pregnancy_id, name, email, relationship, is_told, told_date, is_subscribed, unsubscribe_token, created_at, updated_at
+		RETURNING id, pregnancy_id, household_id, name, email, relationship, is_told, told_date, is_subscribed, unsubscribe_token, is_leader, delivery_frequency, last_digest_sent_at, created_at, updated_at
 	`
 
 	var member models.VillageMember
 	err := db.GetDB().QueryRow(query, isTold, memberID).Scan(
 		&member.ID,
 		&member.PregnancyID,
+		&member.HouseholdID,
 		&member.Name,
 		&member.Email,
 		&member.Relationship,
@@ -574,6 +672,9 @@ func UpdateVillageMemberStatus(memberID int, isTold bool) (*models.VillageMember
 		&member.ToldDate,
 		&member.IsSubscribed,
 		&member.UnsubscribeToken,
+		&member.IsLeader,
+		&member.DeliveryFrequency,
+		&member.LastDigestSentAt,
 		&member.CreatedAt,
 		&member.UpdatedAt,
 	)
@@ -612,13 +713,33 @@ func UpdateVillageMemberStatusWithEvent(memberID int, isTold bool, userID int) (
 		if err := CreateVillagerToldEvent(member.PregnancyID, member.Name, userID, &weekNumber); err != nil {
 			log.Printf("Failed to create villager told event: %v", err)
 		}
+
+		sendWelcomeEmails(pregnancy, []models.VillageMember{*member})
 	}
 
 	return member, nil
 }
 
 func DeleteVillageMember(memberID int) error {
+	member, err := GetVillageMemberByID(memberID)
+	if err != nil {
+		return err
+	}
+
 	query := `DELETE FROM village_members WHERE id = ?`
-	_, err := db.GetDB().Exec(query, memberID)
-	return err
+	if _, err := db.GetDB().Exec(query, memberID); err != nil {
+		return err
+	}
+
+	// Their comments stay under the name they left them with, but reactions go with them
+	if _, err := db.GetDB().Exec(`DELETE FROM update_reactions WHERE village_member_id = ?`, memberID); err != nil {
+		return err
+	}
+
+	// Remove the household once its last address is gone
+	if member.HouseholdID != nil {
+		return DeleteHouseholdIfEmpty(*member.HouseholdID)
+	}
+
+	return nil
 }
\ No newline at end of file
@@ -1,24 +1,32 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"html"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
+	"path"
 	"strings"
+	"time"
 
 	"simple-go/api/config"
 	"simple-go/api/db"
 	"simple-go/api/handlers"
 	"simple-go/api/middleware"
+	"simple-go/api/models"
 	"simple-go/api/routes"
+	"simple-go/api/services/storage"
 	
 	"github.com/joho/godotenv"
 )
 
 func main() {
+	sanitizeImages := flag.Bool("sanitize-images", false, "strip location and device metadata from stored photos and videos, then exit")
+	migrateStorage := flag.String("migrate-storage", "", "copy stored media to another storage backend (local or s3), then exit")
+	flag.Parse()
+
 	// Load environment variables from .env file
 	// This will silently fail if .env doesn't exist, which is fine
 	_ = godotenv.Load()
@@ -32,9 +40,33 @@ func main() {
 	}
 	defer db.CloseDB()
 
+	// Open the storage backend media is kept in
+	if err := storage.Init(); err != nil {
+		log.Fatal("Failed to initialize storage:", err)
+	}
+
+	// One-off copy of stored media before switching storage backends
+	if *migrateStorage != "" {
+		if err := handlers.MigrateStoredMedia(*migrateStorage); err != nil {
+			log.Fatal("Failed to migrate stored media:", err)
+		}
+		return
+	}
+
+	// One-off maintenance for photos and videos uploaded before metadata was stripped
+	if *sanitizeImages {
+		if err := handlers.SanitizeStoredImages(); err != nil {
+			log.Fatal("Failed to sanitize stored images:", err)
+		}
+		return
+	}
+
 	// Setup routes with middleware
 	setupRoutes()
 
+	// Start periodic jobs such as announcement plan reminders
+	startBackgroundJobs()
+
 	port := ":" + config.AppConfig.ServerPort
 	fmt.Printf("Server starting on port %s\n", port)
 	fmt.Println("Public routes: /health, /login, /register, /api/login, /api/register")
@@ -47,6 +79,31 @@ func main() {
 	}
 }
 
+// startBackgroundJobs runs periodic jobs in the background for the lifetime of the server
+func startBackgroundJobs() {
+	go runPeriodically("tell plan reminders", time.Hour, handlers.SendDueTellWaveReminders)
+	go runPeriodically("email digests", time.Hour, handlers.SendDueDigests)
+	go runPeriodically("access request expiry", time.Hour, handlers.ExpireAccessRequests)
+	go runPeriodically("recycle bin purge", time.Hour, handlers.PurgeDeletedUpdates)
+	go runPeriodically("scheduled update publishing", time.Minute, handlers.PublishScheduledUpdates)
+	go runPeriodically("media processing", time.Hour, handlers.ProcessPendingMedia)
+	go runPeriodically("abandoned upload expiry", time.Hour, handlers.ExpireMediaUploads)
+	go runPeriodically("bump animations", time.Minute, handlers.ProcessPendingBumpAnimations)
+}
+
+// runPeriodically runs job immediately and then on every tick of interval, logging failures
+func runPeriodically(name string, interval time.Duration, job func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job(); err != nil {
+			log.Printf("Background job %q failed: %v", name, err)
+		}
+		<-ticker.C
+	}
+}
+
 func setupRoutes() {
 	// Public routes (no middleware)
 	http.HandleFunc("/health", routes.HealthHandler)
@@ -64,11 +121,26 @@ func setupRoutes() {
 	http.HandleFunc("/api/pregnancy/invite-hash", middleware.AuthMiddleware(handlers.GetInviteHashHandler))
 	http.HandleFunc("/api/pregnancy/invite/", handlers.GetPregnancyFromInviteHandler)
 	http.HandleFunc("/api/pregnancy/join/", handlers.JoinVillageFromInviteHandler)
+	http.HandleFunc("/api/preferences/", handlers.PreferencesHandler)
+	http.HandleFunc("/api/unsubscribe/", handlers.OneClickUnsubscribeHandler)
+	http.HandleFunc("/preferences/", routes.PreferencesPageHandler)
+	http.HandleFunc("/api/access-requests/", handlers.AccessRequestLinkHandler)
+	http.HandleFunc("/access-request/", routes.AccessRequestPageHandler)
 	http.HandleFunc("/api/village-members", middleware.AuthMiddleware(villageHandler))
 	http.HandleFunc("/api/village-members/bulk", middleware.AuthMiddleware(handlers.CreateVillageMembersBulkHandler))
+	http.HandleFunc("/api/village-members/households", middleware.AuthMiddleware(handlers.GetHouseholdsHandler))
+	http.HandleFunc("/api/village-members/stats", middleware.AuthMiddleware(handlers.GetVillageStatsHandler))
+	http.HandleFunc("/api/village-members/engagement", middleware.AuthMiddleware(handlers.GetEngagementHandler))
 	http.HandleFunc("/api/village-members/access-requests", middleware.AuthMiddleware(handlers.GetAccessRequestsHandler))
 	http.HandleFunc("/api/village-members/access-requests/", middleware.AuthMiddleware(handlers.ManageAccessRequestHandler))
+	http.HandleFunc("/api/village-members/access-rules", middleware.AuthMiddleware(handlers.AccessRulesHandler))
+	http.HandleFunc("/api/village-members/access-rules/", middleware.AuthMiddleware(handlers.DeleteAccessRuleHandler))
 	http.HandleFunc("/api/village-members/", middleware.AuthMiddleware(villageMemberHandler))
+	http.HandleFunc("/api/leader/claim", middleware.AuthMiddleware(handlers.ClaimLeaderHandler))
+	http.HandleFunc("/api/leader/villages", middleware.AuthMiddleware(handlers.GetLedVillagesHandler))
+	http.HandleFunc("/api/leader/villages/", middleware.AuthMiddleware(leaderVillageHandler))
+	http.HandleFunc("/api/tell-plan", middleware.AuthMiddleware(handlers.TellPlanHandler))
+	http.HandleFunc("/api/tell-plan/", middleware.AuthMiddleware(handlers.TellWaveHandler))
 	
 	http.HandleFunc("/api/timeline", middleware.AuthMiddleware(handlers.GetCombinedTimelineHandler))
 	http.HandleFunc("/timeline/", handlers.PublicTimelineHandler)
@@ -79,6 +151,16 @@ func setupRoutes() {
 			handlers.VerifyTimelineAccessHandler(w, r)
 		} else if strings.Contains(path, "/request-access") {
 			handlers.RequestTimelineAccessHandler(w, r)
+		} else if strings.HasSuffix(path, "/send-link") {
+			handlers.SendTimelineLinkHandler(w, r)
+		} else if strings.Contains(path, "/galleries") {
+			handlers.PublicGalleryHandler(w, r)
+		} else if strings.Contains(path, "/updates/") {
+			publicUpdateFeedbackHandler(w, r)
+		} else if strings.HasSuffix(path, "/guestbook") {
+			publicGuestbookHandler(w, r)
+		} else if strings.HasSuffix(path, "/search") {
+			handlers.PublicSearchHandler(w, r)
 		} else {
 			http.Error(w, "Not found", http.StatusNotFound)
 		}
@@ -87,12 +169,23 @@ func setupRoutes() {
 	http.HandleFunc("/api/updates", middleware.AuthMiddleware(updateHandler))
 	http.HandleFunc("/api/updates/", middleware.AuthMiddleware(updateDetailHandler))
 	http.HandleFunc("/api/cover-photo", middleware.AuthMiddleware(coverPhotoHandler))
+	http.HandleFunc("/api/galleries", middleware.AuthMiddleware(handlers.GetGalleriesHandler))
+	http.HandleFunc("/api/galleries/", middleware.AuthMiddleware(handlers.GetGalleryHandler))
+	http.HandleFunc("/api/bump-animations", middleware.AuthMiddleware(bumpAnimationHandler))
+	http.HandleFunc("/api/bump-animations/", middleware.AuthMiddleware(handlers.GetBumpAnimationHandler))
+	http.HandleFunc("/api/search", middleware.AuthMiddleware(handlers.SearchHandler))
+	http.HandleFunc("/api/guestbook", middleware.AuthMiddleware(handlers.GetGuestbookHandler))
+	http.HandleFunc("/api/guestbook/", middleware.AuthMiddleware(guestbookDetailHandler))
+	http.HandleFunc("/api/uploads", middleware.AuthMiddleware(uploadHandler))
+	http.HandleFunc("/api/uploads/", middleware.AuthMiddleware(uploadDetailHandler))
 	// Email notification routes
 	http.HandleFunc("/api/email/test", middleware.AuthMiddleware(handlers.SendTestEmailHandler))
 	http.HandleFunc("/api/email/notifications", middleware.AuthMiddleware(handlers.GetEmailNotificationsHandler))
 	http.HandleFunc("/api/email/statistics", middleware.AuthMiddleware(handlers.GetEmailStatisticsHandler))
 	http.HandleFunc("/api/email/config-test", middleware.AuthMiddleware(handlers.TestEmailConfigurationHandler))
 	http.HandleFunc("/api/email/send-update", middleware.AuthMiddleware(handlers.SendUpdateNotificationHandler))
+	http.HandleFunc("/api/email/open/", handlers.EmailOpenHandler)
+	http.HandleFunc("/api/email/click/", handlers.EmailClickHandler)
 	http.HandleFunc("/images/", imageHandler)
 	http.HandleFunc("/videos/", videoHandler)
 	http.HandleFunc("/app", routes.AppPageHandler)
@@ -151,6 +244,15 @@ func villageHandler(w http.ResponseWriter, r *http.Request) {
 
 // villageMemberHandler routes individual village member requests (for update and delete)
 func villageMemberHandler(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/leader") {
+		handlers.SetVillageLeaderHandler(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/reset-link") {
+		handlers.ResetMemberLinkHandler(w, r)
+		return
+	}
+
 	switch r.Method {
 	case "PUT":
 		handlers.UpdateVillageMemberHandler(w, r)
@@ -161,7 +263,22 @@ func villageMemberHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// imageHandler serves images from the configured images directory
+// leaderVillageHandler routes requests from village leaders managing a village on the parents' behalf
+func leaderVillageHandler(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/members"):
+		handlers.LeaderVillageMembersHandler(w, r)
+	case strings.HasSuffix(r.URL.Path, "/access-requests"):
+		handlers.LeaderAccessRequestsHandler(w, r)
+	case strings.Contains(r.URL.Path, "/access-requests/"):
+		handlers.LeaderManageAccessRequestHandler(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// imageHandler serves images from the image store. Cover photos are public;
+// update photos need a signed URL.
 func imageHandler(w http.ResponseWriter, r *http.Request) {
 	// Get the image path from URL
 	imagePath := strings.TrimPrefix(r.URL.Path, "/images/")
@@ -170,28 +287,25 @@ func imageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Construct full file path
-	fullPath := filepath.Join(config.AppConfig.ImagesDirectory, imagePath)
-
-	// Security check - ensure path doesn't escape images directory
-	cleanPath := filepath.Clean(fullPath)
-	absImagesDir, _ := filepath.Abs(config.AppConfig.ImagesDirectory)
-	absCleanPath, _ := filepath.Abs(cleanPath)
-	
-	if !strings.HasPrefix(absCleanPath, absImagesDir) {
+	// Security check - ensure path doesn't escape the image store
+	if !isStorageKey(imagePath) {
 		http.Error(w, "Invalid image path", http.StatusBadRequest)
 		return
 	}
 
-	// Set cache headers for images (cache for 1 year since they don't change)
-	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
-	w.Header().Set("Expires", "Thu, 31 Dec 2037 23:55:55 GMT")
+	if strings.HasPrefix(imagePath, "covers/") {
+		handlers.ServeCoverPhoto(w, r, strings.TrimPrefix(imagePath, "covers/"))
+		return
+	}
+	if strings.HasPrefix(imagePath, "guestbook/") {
+		handlers.ServeGuestbookPhoto(w, r, strings.TrimPrefix(imagePath, "guestbook/"))
+		return
+	}
 
-	// Serve the file
-	http.ServeFile(w, r, fullPath)
+	handlers.ServeUpdateMedia(w, r, models.MediaKindPhoto, imagePath)
 }
 
-// videoHandler serves videos from the configured videos directory
+// videoHandler serves videos from the video store, which all need a signed URL
 func videoHandler(w http.ResponseWriter, r *http.Request) {
 	// Get the video path from URL
 	videoPath := strings.TrimPrefix(r.URL.Path, "/videos/")
@@ -200,31 +314,19 @@ func videoHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Construct full file path
-	fullPath := filepath.Join(config.AppConfig.VideosDirectory, videoPath)
-
-	// Security check - ensure path doesn't escape videos directory
-	cleanPath := filepath.Clean(fullPath)
-	if !strings.HasPrefix(cleanPath, config.AppConfig.VideosDirectory) {
+	// Security check - ensure path doesn't escape the video store
+	if !isStorageKey(videoPath) {
 		http.Error(w, "Invalid video path", http.StatusBadRequest)
 		return
 	}
 
-	// Set cache headers for videos (cache for 1 year since they don't change)
-	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
-	w.Header().Set("Expires", "Thu, 31 Dec 2037 23:55:55 GMT")
-
-	// Set appropriate content type for videos
-	ext := strings.ToLower(filepath.Ext(cleanPath))
-	switch ext {
-	case ".mp4":
-		w.Header().Set("Content-Type", "video/mp4")
-	case ".mov":
-		w.Header().Set("Content-Type", "video/quicktime")
-	}
+	handlers.ServeUpdateMedia(w, r, models.MediaKindVideo, videoPath)
+}
 
-	// Serve the file
-	http.ServeFile(w, r, fullPath)
+// isStorageKey reports whether a path from a URL is a plain storage key, with no "." or ".."
+// segments that could reach outside the store
+func isStorageKey(p string) bool {
+	return path.Clean("/"+p) == "/"+p
 }
 
 // timelinePageHandler serves the public timeline page for a pregnancy
@@ -268,7 +370,7 @@ func timelinePageHandler(w http.ResponseWriter, r *http.Request) {
 	// Generate cover photo URL
 	coverPhotoURL := ""
 	if pregnancy.CoverPhotoFilename != nil && *pregnancy.CoverPhotoFilename != "" {
-		coverPhotoURL = fmt.Sprintf("/images/covers/%s", *pregnancy.CoverPhotoFilename)
+		coverPhotoURL = fmt.Sprintf("/images/covers/%s", pregnancy.CoverPhotoForSize(models.RenditionLarge))
 	}
 	
 	// Read the HTML template
@@ -317,11 +419,146 @@ func updateHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// updateDetailHandler routes individual update requests (for update and delete)
+// updateDetailHandler routes individual update requests (for update and delete) and the recycle bin
 func updateDetailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/updates/deleted" {
+		handlers.GetDeletedUpdatesHandler(w, r)
+		return
+	}
+	if strings.Contains(r.URL.Path, "/revisions") {
+		updateRevisionsHandler(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/restore") {
+		handlers.RestoreUpdateHandler(w, r)
+		return
+	}
+	if strings.Contains(r.URL.Path, "/media") {
+		updateMediaHandler(w, r)
+		return
+	}
+	if strings.Contains(r.URL.Path, "/comments") {
+		updateCommentsHandler(w, r)
+		return
+	}
+
 	switch r.Method {
 	case "PUT":
 		handlers.UpdateUpdateHandler(w, r)
+	case http.MethodDelete:
+		handlers.DeleteUpdateHandler(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// updateMediaHandler routes requests for the photos and videos of an update:
+// /api/updates/{id}/media, .../media/order, .../media/{mediaID}, .../media/{mediaID}/replace
+// and .../media/{mediaID}/tags
+func updateMediaHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/updates/"), "/"), "/")
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		handlers.AddUpdateMediaHandler(w, r)
+	case len(parts) == 3 && parts[2] == "order" && r.Method == "PUT":
+		handlers.ReorderUpdateMediaHandler(w, r)
+	case len(parts) == 3 && parts[2] != "order" && r.Method == "PUT":
+		handlers.UpdateMediaCaptionHandler(w, r)
+	case len(parts) == 3 && parts[2] != "order" && r.Method == http.MethodDelete:
+		handlers.DeleteUpdateMediaHandler(w, r)
+	case len(parts) == 4 && parts[3] == "replace" && r.Method == http.MethodPost:
+		handlers.ReplaceUpdateMediaHandler(w, r)
+	case len(parts) == 4 && parts[3] == "tags" && r.Method == "PUT":
+		handlers.UpdateMediaTagsHandler(w, r)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// updateCommentsHandler routes the parents' requests for the comments on an update:
+// /api/updates/{id}/comments and .../comments/{commentID}
+func updateCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/updates/"), "/"), "/")
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		handlers.GetUpdateCommentsHandler(w, r)
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		handlers.CreateUpdateCommentHandler(w, r)
+	case len(parts) == 3 && r.Method == "PUT":
+		handlers.HideUpdateCommentHandler(w, r)
+	case len(parts) == 3 && r.Method == http.MethodDelete:
+		handlers.DeleteUpdateCommentHandler(w, r)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// updateRevisionsHandler routes requests for the earlier versions of an update:
+// /api/updates/{id}/revisions, .../revisions/{revisionID}/diff and .../revisions/{revisionID}/restore
+func updateRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/updates/"), "/"), "/")
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		handlers.GetUpdateRevisionsHandler(w, r)
+	case len(parts) == 4 && parts[3] == "diff" && r.Method == http.MethodGet:
+		handlers.GetUpdateRevisionDiffHandler(w, r)
+	case len(parts) == 4 && parts[3] == "restore" && r.Method == http.MethodPost:
+		handlers.RestoreUpdateRevisionHandler(w, r)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// publicUpdateFeedbackHandler routes villagers' reactions and comments on a shared update:
+// /api/timeline/{shareID}/updates/{id}/comments and .../reactions
+func publicUpdateFeedbackHandler(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/comments") && r.Method == http.MethodGet:
+		handlers.GetPublicUpdateCommentsHandler(w, r)
+	case strings.HasSuffix(r.URL.Path, "/comments") && r.Method == http.MethodPost:
+		handlers.CreatePublicUpdateCommentHandler(w, r)
+	case strings.HasSuffix(r.URL.Path, "/reactions") && r.Method == http.MethodPost:
+		handlers.AddUpdateReactionHandler(w, r)
+	case strings.HasSuffix(r.URL.Path, "/reactions") && r.Method == http.MethodDelete:
+		handlers.RemoveUpdateReactionHandler(w, r)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// publicGuestbookHandler routes villagers' requests for the guestbook:
+// /api/timeline/{shareID}/guestbook
+func publicGuestbookHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetPublicGuestbookHandler(w, r)
+	case http.MethodPost:
+		handlers.CreateGuestbookMessageHandler(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// guestbookDetailHandler routes the parents' requests for a guestbook message, and the
+// guestbook export
+func guestbookDetailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/guestbook/export" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handlers.ExportGuestbookHandler(w, r)
+		return
+	}
+
+	switch r.Method {
+	case "PUT":
+		handlers.UpdateGuestbookMessageHandler(w, r)
+	case http.MethodDelete:
+		handlers.DeleteGuestbookMessageHandler(w, r)
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
@@ -339,6 +576,42 @@ func coverPhotoHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// bumpAnimationHandler routes bump animation requests
+func bumpAnimationHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetBumpAnimationsHandler(w, r)
+	case http.MethodPost:
+		handlers.CreateBumpAnimationHandler(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// uploadHandler routes requests to start a resumable upload
+func uploadHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		handlers.CreateMediaUploadHandler(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// uploadDetailHandler routes requests for a resumable upload in progress
+func uploadDetailHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		handlers.GetMediaUploadHandler(w, r)
+	case http.MethodPatch:
+		handlers.AppendMediaUploadHandler(w, r)
+	case http.MethodDelete:
+		handlers.DeleteMediaUploadHandler(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 // timelineAPIHandler routes timeline API requests for email verification and access requests
 func timelineAPIHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract the path to determine which handler to use
@@ -5,8 +5,9 @@ import "net/http"
 func CORSMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Upload-Offset, Upload-Checksum")
+		w.Header().Set("Access-Control-Expose-Headers", "Location, Upload-Offset, Upload-Length")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessRequestRule approves matching timeline access requests without waiting for the parents,
+// once the requester confirms their email address or their referrer confirms they referred them
+type AccessRequestRule struct {
+	ID          int       `json:"id" db:"id"`
+	PregnancyID int       `json:"pregnancy_id" db:"pregnancy_id"`
+	RuleType    string    `json:"rule_type" db:"rule_type"`
+	Value       string    `json:"value" db:"value"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// Access request rule types
+const (
+	AccessRuleEmailDomain  = "email_domain" // Value is a domain such as "example.com"
+	AccessRuleReferrer     = "referrer"     // Value is the ID of the village member vouching for requesters
+	AccessRuleRelationship = "relationship" // Value is a relationship type such as "grandparent"
+)
+
+// Access request outcomes reported to the requester
+const (
+	AccessOutcomeApproved = "approved"
+	AccessOutcomeDenied   = "denied"
+	AccessOutcomeExpired  = "expired"
+)
+
+// IsValidAccessRuleType checks whether a rule type is supported
+func IsValidAccessRuleType(ruleType string) bool {
+	switch ruleType {
+	case AccessRuleEmailDomain, AccessRuleReferrer, AccessRuleRelationship:
+		return true
+	default:
+		return false
+	}
+}
+
+// Matches reports whether a request from email with the given relationship, naming
+// referrer as the existing member who referred them (nil if none), satisfies the rule
+func (r *AccessRequestRule) Matches(email, relationship string, referrer *VillageMember) bool {
+	switch r.RuleType {
+	case AccessRuleEmailDomain:
+		at := strings.LastIndex(email, "@")
+		if at < 0 {
+			return false
+		}
+		domain := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(r.Value)), "@")
+		return strings.EqualFold(email[at+1:], domain)
+	case AccessRuleReferrer:
+		if referrer == nil {
+			return false
+		}
+		memberID, err := strconv.Atoi(r.Value)
+		return err == nil && referrer.ID == memberID
+	case AccessRuleRelationship:
+		return strings.EqualFold(strings.TrimSpace(relationship), strings.TrimSpace(r.Value))
+	default:
+		return false
+	}
+}
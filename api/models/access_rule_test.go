@@ -0,0 +1,42 @@
+package models
+
+import "testing"
+
+func TestAccessRequestRuleMatches(t *testing.T) {
+	referrer := &VillageMember{ID: 7}
+	otherMember := &VillageMember{ID: 8}
+
+	tests := []struct {
+		name         string
+		rule         AccessRequestRule
+		email        string
+		relationship string
+		referrer     *VillageMember
+		expected     bool
+	}{
+		{"email domain", AccessRequestRule{RuleType: AccessRuleEmailDomain, Value: "example.com"}, "aunt@example.com", "", nil, true},
+		{"email domain ignores case", AccessRequestRule{RuleType: AccessRuleEmailDomain, Value: "Example.COM"}, "aunt@EXAMPLE.com", "", nil, true},
+		{"email domain with leading @", AccessRequestRule{RuleType: AccessRuleEmailDomain, Value: " @example.com "}, "aunt@example.com", "", nil, true},
+		{"other email domain", AccessRequestRule{RuleType: AccessRuleEmailDomain, Value: "example.com"}, "aunt@example.org", "", nil, false},
+		{"subdomain", AccessRequestRule{RuleType: AccessRuleEmailDomain, Value: "example.com"}, "aunt@mail.example.com", "", nil, false},
+		{"domain as suffix of another", AccessRequestRule{RuleType: AccessRuleEmailDomain, Value: "example.com"}, "aunt@notexample.com", "", nil, false},
+		{"last @ counts", AccessRequestRule{RuleType: AccessRuleEmailDomain, Value: "example.com"}, "aunt@example.com@evil.com", "", nil, false},
+		{"email without @", AccessRequestRule{RuleType: AccessRuleEmailDomain, Value: "example.com"}, "example.com", "", nil, false},
+		{"referrer", AccessRequestRule{RuleType: AccessRuleReferrer, Value: "7"}, "friend@example.com", "", referrer, true},
+		{"other referrer", AccessRequestRule{RuleType: AccessRuleReferrer, Value: "7"}, "friend@example.com", "", otherMember, false},
+		{"no referrer", AccessRequestRule{RuleType: AccessRuleReferrer, Value: "7"}, "friend@example.com", "", nil, false},
+		{"invalid referrer value", AccessRequestRule{RuleType: AccessRuleReferrer, Value: "seven"}, "friend@example.com", "", referrer, false},
+		{"relationship", AccessRequestRule{RuleType: AccessRuleRelationship, Value: "grandparent"}, "nana@example.com", "grandparent", nil, true},
+		{"relationship ignores case and spaces", AccessRequestRule{RuleType: AccessRuleRelationship, Value: "Grandparent "}, "nana@example.com", " GRANDPARENT", nil, true},
+		{"other relationship", AccessRequestRule{RuleType: AccessRuleRelationship, Value: "grandparent"}, "nana@example.com", "friend", nil, false},
+		{"unknown rule type", AccessRequestRule{RuleType: "anyone", Value: ""}, "nana@example.com", "friend", referrer, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.rule.Matches(tt.email, tt.relationship, tt.referrer); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
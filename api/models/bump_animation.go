@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// BumpAnimation is a request to animate the bump-tagged photos of a pregnancy, week by week
+type BumpAnimation struct {
+	ID          int        `json:"id" db:"id"`
+	PregnancyID int        `json:"pregnancy_id" db:"pregnancy_id"`
+	FPS         int        `json:"fps" db:"fps"`
+	Status      string     `json:"status" db:"status"`
+	FrameCount  *int       `json:"frame_count" db:"frame_count"`
+	UpdateID    *int       `json:"update_id" db:"update_id"`
+	Error       *string    `json:"error,omitempty" db:"error"`
+	StartedAt   *time.Time `json:"started_at" db:"started_at"`
+	CompletedAt *time.Time `json:"completed_at" db:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Bump animation statuses
+const (
+	BumpAnimationPending    = "pending"
+	BumpAnimationProcessing = "processing"
+	BumpAnimationDone       = "done"
+	BumpAnimationFailed     = "failed"
+)
+
+// BumpAnimationTimeout is how long an animation can be processing before it's taken to have
+// been interrupted, by a restart for instance, and is picked up again
+const BumpAnimationTimeout = time.Hour
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// UpdateComment is a comment on a shared update, by a village member or one of the parents.
+// Replies are listed under the comment that starts their thread.
+type UpdateComment struct {
+	ID              int             `json:"id" db:"id"`
+	UpdateID        int             `json:"update_id" db:"update_id"`
+	ParentID        *int            `json:"parent_id" db:"parent_id"`
+	VillageMemberID *int            `json:"-" db:"village_member_id"`
+	UserID          *int            `json:"-" db:"user_id"`
+	AuthorName      string          `json:"author_name" db:"author_name"`
+	Body            string          `json:"body" db:"body"`
+	HiddenAt        *time.Time      `json:"hidden_at,omitempty" db:"hidden_at"`
+	CreatedAt       time.Time       `json:"created_at" db:"created_at"`
+	ByParent        bool            `json:"by_parent"`
+	IsMine          bool            `json:"is_mine,omitempty"`
+	Replies         []UpdateComment `json:"replies,omitempty"`
+}
+
+// MaxCommentLength is the longest comment that can be posted, in characters
+const MaxCommentLength = 2000
+
+// ReactionEmojis are the reactions village members can leave on an update, in display order
+var ReactionEmojis = []string{"❤️", "😍", "🎉", "😂", "😮", "🙏"}
+
+// IsReactionEmoji reports whether emoji is one of the reactions that can be left
+func IsReactionEmoji(emoji string) bool {
+	for _, reaction := range ReactionEmojis {
+		if emoji == reaction {
+			return true
+		}
+	}
+	return false
+}
+
+// ReactionCount is how many village members reacted to an update with an emoji, and whether
+// the viewer is one of them
+type ReactionCount struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	Reacted bool   `json:"reacted,omitempty"`
+}
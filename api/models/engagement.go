@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// Engagement event types recorded for village members
+const (
+	EngagementTimelineVisit = "timeline_visit"
+	EngagementEmailOpen     = "email_open"
+	EngagementEmailClick    = "email_click"
+)
+
+// Where a member first saw an update
+const (
+	UpdateViewSourceTimeline = "timeline"
+	UpdateViewSourceEmail    = "email"
+)
+
+// MemberEngagement summarizes how closely a village member follows along
+type MemberEngagement struct {
+	MemberID       int        `json:"member_id"`
+	Name           string     `json:"name"`
+	Email          string     `json:"email"`
+	Relationship   string     `json:"relationship"`
+	LastSeenAt     *time.Time `json:"last_seen_at"`
+	TimelineVisits int        `json:"timeline_visits"`
+	EmailsSent     int        `json:"emails_sent"`
+	EmailOpens     int        `json:"email_opens"`
+	EmailClicks    int        `json:"email_clicks"`
+	UpdatesViewed  int        `json:"updates_viewed"`
+}
+
+// UpdateReach counts how many village members have seen a shared update
+type UpdateReach struct {
+	UpdateID   int       `json:"update_id"`
+	Title      string    `json:"title"`
+	WeekNumber *int      `json:"week_number"`
+	SharedAt   time.Time `json:"shared_at"`
+	Emailed    int       `json:"emailed"`
+	Reach      int       `json:"reach"`
+}
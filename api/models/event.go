@@ -24,6 +24,9 @@ const (
 	EventAppointmentCompleted = "appointment_completed"  // Manual milestone completion
 	EventUpdatePosted         = "update_posted"          // User shares news/photos
 	EventWeekProgression      = "week_progression"       // Weekly automatic milestones
+	EventLeaderPromoted       = "leader_promoted"        // Villager made (or removed as) a village leader
+	EventLeaderAction         = "leader_action"          // A village leader managed the village
+	EventTellWaveCompleted    = "tell_wave_completed"    // A wave of the announcement plan was told
 )
 
 // GetEventDisplayInfo returns user-friendly display information for events
@@ -43,6 +46,12 @@ func (e *PregnancyEvent) GetEventDisplayInfo() (icon string, color string) {
 		return "📝", "text-indigo-600"
 	case EventWeekProgression:
 		return "📅", "text-gray-600"
+	case EventLeaderPromoted:
+		return "⭐", "text-amber-600"
+	case EventLeaderAction:
+		return "🛡️", "text-teal-600"
+	case EventTellWaveCompleted:
+		return "📣", "text-orange-600"
 	default:
 		return "📌", "text-gray-500"
 	}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Built-in media tags, each with a gallery of its own even before anything is tagged
+const (
+	MediaTagUltrasound = "ultrasound"
+	MediaTagBump       = "bump"
+	MediaTagNursery    = "nursery"
+	MediaTagShower     = "shower"
+)
+
+// BuiltInMediaTags lists the built-in tags in the order their galleries are shown
+var BuiltInMediaTags = []string{MediaTagUltrasound, MediaTagBump, MediaTagNursery, MediaTagShower}
+
+// Limits on media tags
+const (
+	MaxMediaTagLength = 40
+	MaxTagsPerMedia   = 10
+)
+
+var mediaTagPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// NormalizeMediaTag turns a tag as typed into the form it's stored in: lowercase, with spaces
+// and underscores as hyphens. It reports false if the result isn't a valid tag.
+func NormalizeMediaTag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	tag = strings.TrimPrefix(tag, "#")
+	tag = strings.Join(strings.FieldsFunc(tag, func(r rune) bool {
+		return r == ' ' || r == '_' || r == '-'
+	}), "-")
+	if tag == "" || len(tag) > MaxMediaTagLength || !mediaTagPattern.MatchString(tag) {
+		return "", false
+	}
+	return tag, true
+}
+
+// IsBuiltInMediaTag reports whether tag is one of the built-in tags
+func IsBuiltInMediaTag(tag string) bool {
+	for _, builtIn := range BuiltInMediaTags {
+		if tag == builtIn {
+			return true
+		}
+	}
+	return false
+}
+
+// Gallery summarizes the media with one tag
+type Gallery struct {
+	Tag     string `json:"tag"`
+	BuiltIn bool   `json:"built_in"`
+	Count   int    `json:"count"`
+}
+
+// GalleryItem is a tagged photo or video along with the update it belongs to
+type GalleryItem struct {
+	UpdateMedia
+	UpdateTitle string    `json:"update_title"`
+	WeekNumber  *int      `json:"week_number"`
+	UpdateDate  time.Time `json:"update_date"`
+	IsShared    *bool     `json:"is_shared,omitempty"`
+}
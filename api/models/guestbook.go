@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// GuestbookMessage is a note a village member left for the baby, with an optional photo. It is
+// shown on the timeline, or kept hidden until the parents reveal it.
+type GuestbookMessage struct {
+	ID              int        `json:"id" db:"id"`
+	PregnancyID     int        `json:"pregnancy_id" db:"pregnancy_id"`
+	VillageMemberID *int       `json:"-" db:"village_member_id"`
+	AuthorName      string     `json:"author_name" db:"author_name"`
+	Body            string     `json:"body" db:"body"`
+	PhotoFilename   *string    `json:"-" db:"photo_filename"`
+	PhotoMIMEType   *string    `json:"-" db:"photo_mime_type"`
+	PhotoURL        string     `json:"photo_url,omitempty"`
+	OnTimeline      bool       `json:"on_timeline" db:"on_timeline"`
+	RevealedAt      *time.Time `json:"revealed_at,omitempty" db:"revealed_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	IsMine          bool       `json:"is_mine,omitempty"`
+}
+
+// Limits on guestbook messages
+const (
+	MaxGuestbookMessageLength = 5000
+	MaxGuestbookPhotoSize     = 10 << 20
+)
@@ -5,17 +5,20 @@ import (
 )
 
 type EmailNotification struct {
-	ID               int       `json:"id" db:"id"`
-	PregnancyID      int       `json:"pregnancy_id" db:"pregnancy_id"`
-	VillageMemberID  int       `json:"village_member_id" db:"village_member_id"`
-	UpdateID         *int      `json:"update_id" db:"update_id"`
-	MilestoneID      *int      `json:"milestone_id" db:"milestone_id"`
-	EmailType        string    `json:"email_type" db:"email_type"`
-	Subject          string    `json:"subject" db:"subject"`
-	SentAt           time.Time `json:"sent_at" db:"sent_at"`
-	DeliveryStatus   string    `json:"delivery_status" db:"delivery_status"`
-	SESMessageID     *string   `json:"ses_message_id" db:"ses_message_id"`
-	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	ID               int        `json:"id" db:"id"`
+	PregnancyID      int        `json:"pregnancy_id" db:"pregnancy_id"`
+	VillageMemberID  int        `json:"village_member_id" db:"village_member_id"`
+	UpdateID         *int       `json:"update_id" db:"update_id"`
+	MilestoneID      *int       `json:"milestone_id" db:"milestone_id"`
+	EmailType        string     `json:"email_type" db:"email_type"`
+	Subject          string     `json:"subject" db:"subject"`
+	SentAt           time.Time  `json:"sent_at" db:"sent_at"`
+	DeliveryStatus   string     `json:"delivery_status" db:"delivery_status"`
+	SESMessageID     *string    `json:"ses_message_id" db:"ses_message_id"`
+	TrackingID       *string    `json:"-" db:"tracking_id"`
+	OpenedAt         *time.Time `json:"opened_at" db:"opened_at"`
+	ClickedAt        *time.Time `json:"clicked_at" db:"clicked_at"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
 }
 
 // Email types
@@ -25,6 +28,14 @@ const (
 	EmailTypeAnnouncement = "announcement"
 	EmailTypeWelcome      = "welcome"
 	EmailTypeReminder     = "reminder"
+	EmailTypeTellWaveReminder = "tell_wave_reminder"
+	EmailTypeDigest       = "digest"
+	EmailTypeAccessRequestOutcome = "access_request_outcome"
+	EmailTypeAccessRequestConfirmation = "access_request_confirmation"
+	EmailTypeComment      = "comment"
+	EmailTypeReaction     = "reaction"
+	EmailTypeLeaderInvite = "leader_invite"
+	EmailTypeTimelineLink = "timeline_link"
 )
 
 // Delivery statuses
@@ -49,6 +60,18 @@ func (en *EmailNotification) GetDisplayEmailType() string {
 		return "Welcome"
 	case EmailTypeReminder:
 		return "Reminder"
+	case EmailTypeDigest:
+		return "Digest"
+	case EmailTypeAccessRequestOutcome:
+		return "Access Request Outcome"
+	case EmailTypeAccessRequestConfirmation:
+		return "Access Request Confirmation"
+	case EmailTypeTellWaveReminder:
+		return "Announcement Plan Reminder"
+	case EmailTypeLeaderInvite:
+		return "Leader Invite"
+	case EmailTypeTimelineLink:
+		return "Timeline Link"
 	default:
 		return "Email"
 	}
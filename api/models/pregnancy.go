@@ -16,10 +16,23 @@ type Pregnancy struct {
 	IsActive           bool      `json:"is_active" db:"is_active"`
 	ShareID            string    `json:"share_id" db:"share_id"`
 	CoverPhotoFilename *string   `json:"cover_photo_filename" db:"cover_photo_filename"`
+	CoverPhotoRenditions PhotoRenditions `json:"cover_photo_renditions,omitempty" db:"cover_photo_renditions"`
 	CreatedAt          time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt          time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// CoverPhotoForSize returns the cover photo file to serve for a rendition size, falling back to
+// the original upload. It returns an empty string if there is no cover photo.
+func (p *Pregnancy) CoverPhotoForSize(size string) string {
+	if p.CoverPhotoFilename == nil {
+		return ""
+	}
+	if filename := p.CoverPhotoRenditions.Filename(size); filename != "" {
+		return filename
+	}
+	return *p.CoverPhotoFilename
+}
+
 // PregnancyWithUser includes user information
 type PregnancyWithUser struct {
 	Pregnancy
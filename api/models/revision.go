@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// UpdateRevision is a version of an update that an edit replaced: its title and content, and
+// whether it was shared, so what the village saw before the edit. EditedBy and EditedAt are
+// who made the edit and when.
+type UpdateRevision struct {
+	ID         int       `json:"id" db:"id"`
+	UpdateID   int       `json:"update_id" db:"update_id"`
+	Title      string    `json:"title" db:"title"`
+	Content    *string   `json:"content" db:"content"`
+	IsShared   bool      `json:"is_shared" db:"is_shared"`
+	EditedByID *int      `json:"-" db:"edited_by"`
+	EditedBy   string    `json:"edited_by"`
+	EditedAt   time.Time `json:"edited_at" db:"edited_at"`
+}
+
+// Kinds of line in a revision diff
+const (
+	DiffEqual  = "equal"
+	DiffDelete = "delete"
+	DiffInsert = "insert"
+)
+
+// DiffLine is a line of content in a revision diff: unchanged, only in the older version or
+// only in the newer one
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
@@ -0,0 +1,30 @@
+package models
+
+// Kinds of search result
+const (
+	SearchKindUpdate  = "update"
+	SearchKindEvent   = "event"
+	SearchKindCaption = "caption"
+	SearchKindComment = "comment"
+)
+
+// SearchKinds are the kinds of result a search can be filtered to
+var SearchKinds = []string{SearchKindUpdate, SearchKindEvent, SearchKindCaption, SearchKindComment}
+
+// SearchResult is an update, event, photo or video caption or comment that matched a search.
+// Captions and comments carry the title of their update. TitleHTML and SnippetHTML are escaped,
+// with the matching words wrapped in <mark>.
+type SearchResult struct {
+	Type        string `json:"type"`
+	ID          int    `json:"id"`
+	UpdateID    *int   `json:"update_id,omitempty"`
+	Title       string `json:"title"`
+	TitleHTML   string `json:"title_html"`
+	SnippetHTML string `json:"snippet_html"`
+	WeekNumber  *int   `json:"week_number"`
+	Date        string `json:"date"`
+	IsShared    *bool  `json:"is_shared,omitempty"`
+}
+
+// MaxSearchQueryLength is the longest search, in characters
+const MaxSearchQueryLength = 200
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+)
+
+// TellWave is one stage of the parents' "who to tell when" announcement plan
+type TellWave struct {
+	ID             int             `json:"id" db:"id"`
+	PregnancyID    int             `json:"pregnancy_id" db:"pregnancy_id"`
+	Name           string          `json:"name" db:"name"`
+	TargetWeek     *int            `json:"target_week" db:"target_week"`
+	TargetDate     *time.Time      `json:"target_date" db:"target_date"`
+	ReminderSentAt *time.Time      `json:"reminder_sent_at" db:"reminder_sent_at"`
+	CompletedAt    *time.Time      `json:"completed_at" db:"completed_at"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+	MemberIDs      []int           `json:"member_ids"`
+	Circles        []string        `json:"circles"`
+	Members        []VillageMember `json:"members"`
+	IsDue          bool            `json:"is_due"`
+}
+
+// IsDueAt reports whether the wave has reached its target week or date
+func (tw *TellWave) IsDueAt(currentWeek int, now time.Time) bool {
+	if tw.CompletedAt != nil {
+		return false
+	}
+
+	if tw.TargetWeek != nil && currentWeek >= *tw.TargetWeek {
+		return true
+	}
+
+	if tw.TargetDate != nil && !now.Before(*tw.TargetDate) {
+		return true
+	}
+
+	return false
+}
+
+// PendingMembers returns the resolved wave members who have not been told yet
+func (tw *TellWave) PendingMembers() []VillageMember {
+	var pending []VillageMember
+	for _, member := range tw.Members {
+		if !member.IsTold {
+			pending = append(pending, member)
+		}
+	}
+	return pending
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -18,18 +20,157 @@ type PregnancyUpdate struct {
 	UpdateDate      *time.Time `json:"update_date" db:"update_date"`
 	CreatedAt       time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
-	Photos          []UpdatePhoto `json:"photos,omitempty"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	PublishAt       *time.Time `json:"publish_at,omitempty" db:"publish_at"`
+	PublishWeek     *int       `json:"publish_week,omitempty" db:"publish_week"`
+	Media           []UpdateMedia `json:"media,omitempty"`
 }
 
-type UpdatePhoto struct {
+// MaxUpdateContentLength is the longest an update's content can be, in characters
+const MaxUpdateContentLength = 20000
+
+// UpdateRecycleBinDays is how long a deleted update can be restored before it is purged
+const UpdateRecycleBinDays = 30
+
+// Scheduled updates can be set to publish when any week of the pregnancy begins
+const (
+	MinPublishWeek = 1
+	MaxPublishWeek = 42
+)
+
+// PurgeAt returns when a deleted update will be permanently removed
+func (pu *PregnancyUpdate) PurgeAt() *time.Time {
+	if pu.DeletedAt == nil {
+		return nil
+	}
+	purgeAt := pu.DeletedAt.AddDate(0, 0, UpdateRecycleBinDays)
+	return &purgeAt
+}
+
+// Kinds of media an update can have
+const (
+	MediaKindPhoto = "photo"
+	MediaKindVideo = "video"
+)
+
+// UpdateMedia is a photo or video attached to an update
+type UpdateMedia struct {
 	ID               int       `json:"id" db:"id"`
 	UpdateID         int       `json:"update_id" db:"update_id"`
+	Kind             string    `json:"kind" db:"kind"`
+	MIMEType         string    `json:"mime_type" db:"mime_type"`
 	Filename         string    `json:"filename" db:"filename"`
 	OriginalFilename string    `json:"original_filename" db:"original_filename"`
 	FileSize         *int      `json:"file_size" db:"file_size"`
+	Checksum         *string   `json:"checksum,omitempty" db:"checksum"`
 	Caption          *string   `json:"caption" db:"caption"`
+	Tags             []string  `json:"tags"`
 	SortOrder        int       `json:"sort_order" db:"sort_order"`
+	Width            *int      `json:"width,omitempty" db:"width"`
+	Height           *int      `json:"height,omitempty" db:"height"`
+	DurationSeconds  *float64  `json:"duration_seconds,omitempty" db:"duration_seconds"`
+	Renditions       PhotoRenditions `json:"renditions,omitempty" db:"renditions"`
+	CapturedAt       *time.Time `json:"captured_at,omitempty" db:"captured_at"`
 	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	URL              string    `json:"url,omitempty"`
+	RenditionURLs    map[string]string `json:"rendition_urls,omitempty"`
+}
+
+// FilenameForSize returns the file to serve for a rendition size, or the original if the
+// photo has no rendition of that size
+func (um *UpdateMedia) FilenameForSize(size string) string {
+	if filename := um.Renditions.Filename(size); filename != "" {
+		return filename
+	}
+	return um.Filename
+}
+
+// MediaUploadLifetime is how long a resumable upload is kept after its last chunk arrives, or
+// after it completes, before it is given up on
+const MediaUploadLifetime = 24 * time.Hour
+
+// MediaUpload is a photo or video being uploaded in chunks, to be attached to an update once
+// it is complete
+type MediaUpload struct {
+	ID          string     `json:"id" db:"id"`
+	UserID      int        `json:"-" db:"user_id"`
+	Filename    string     `json:"filename" db:"filename"`
+	Size        int64      `json:"size" db:"size"`
+	Offset      int64      `json:"offset" db:"received_size"`
+	Checksum    *string    `json:"checksum,omitempty" db:"checksum"`
+	CompletedAt *time.Time `json:"completed_at" db:"completed_at"`
+	ExpiresAt   time.Time  `json:"expires_at" db:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// IsComplete reports whether every byte of the upload has arrived
+func (mu *MediaUpload) IsComplete() bool {
+	return mu.CompletedAt != nil
+}
+
+// PhotoRendition is a resized copy of an uploaded photo, stored next to the original
+type PhotoRendition struct {
+	Size     string `json:"size"`
+	Filename string `json:"filename"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+// Rendition sizes, smallest first
+const (
+	RenditionThumb  = "thumb"
+	RenditionMedium = "medium"
+	RenditionLarge  = "large"
+)
+
+// PhotoRenditions is stored as a JSON array alongside the photo or cover it belongs to
+type PhotoRenditions []PhotoRendition
+
+// Scan implements sql.Scanner
+func (pr *PhotoRenditions) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*pr = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), pr)
+	case []byte:
+		return json.Unmarshal(v, pr)
+	default:
+		return fmt.Errorf("cannot scan %T into PhotoRenditions", value)
+	}
+}
+
+// Value implements driver.Valuer
+func (pr PhotoRenditions) Value() (driver.Value, error) {
+	if len(pr) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(pr)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Filename returns the rendition to use for size, falling back to the next larger rendition.
+// It returns an empty string when the original should be used, e.g. a photo smaller than size.
+func (pr PhotoRenditions) Filename(size string) string {
+	wanted := false
+	for _, name := range []string{RenditionThumb, RenditionMedium, RenditionLarge} {
+		if name == size {
+			wanted = true
+		}
+		if !wanted {
+			continue
+		}
+		for _, rendition := range pr {
+			if rendition.Size == name {
+				return rendition.Filename
+			}
+		}
+	}
+	return ""
 }
 
 // Update types
@@ -118,10 +259,16 @@ func (pu *PregnancyUpdate) GetWeekDisplay() string {
 
 // HasPhotos checks if the update has any photos
 func (pu *PregnancyUpdate) HasPhotos() bool {
-	return len(pu.Photos) > 0
+	return pu.GetPhotoCount() > 0
 }
 
-// GetPhotoCount returns the number of photos in the update
+// GetPhotoCount returns the number of photos in the update, not counting videos
 func (pu *PregnancyUpdate) GetPhotoCount() int {
-	return len(pu.Photos)
+	count := 0
+	for _, media := range pu.Media {
+		if media.Kind == MediaKindPhoto {
+			count++
+		}
+	}
+	return count
 }
\ No newline at end of file
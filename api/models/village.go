@@ -7,23 +7,40 @@ import (
 type VillageMember struct {
 	ID                int       `json:"id" db:"id"`
 	PregnancyID       int       `json:"pregnancy_id" db:"pregnancy_id"`
+	HouseholdID       *int      `json:"household_id" db:"household_id"`
 	Name              string    `json:"name" db:"name"`
 	Email             string    `json:"email" db:"email"`
 	Relationship      string    `json:"relationship" db:"relationship"`
 	IsTold            bool      `json:"is_told" db:"is_told"`
 	ToldDate          *time.Time `json:"told_date" db:"told_date"`
 	IsSubscribed      bool      `json:"is_subscribed" db:"is_subscribed"`
-	UnsubscribeToken  *string   `json:"unsubscribe_token" db:"unsubscribe_token"`
+	UnsubscribeToken  *string   `json:"-" db:"unsubscribe_token"` // secret for the villager's own preference links
+	IsLeader          bool      `json:"is_leader" db:"is_leader"`
+	DeliveryFrequency string    `json:"delivery_frequency" db:"delivery_frequency"`
+	LastDigestSentAt  *time.Time `json:"last_digest_sent_at" db:"last_digest_sent_at"`
 	CreatedAt         time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
 }
 
-// VillageStats provides summary statistics about the village
+// Household groups one or more village member contact addresses under a single
+// displayed name and relationship
+type Household struct {
+	ID           int             `json:"id" db:"id"`
+	PregnancyID  int             `json:"pregnancy_id" db:"pregnancy_id"`
+	Name         string          `json:"name" db:"name"`
+	Relationship string          `json:"relationship" db:"relationship"`
+	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at" db:"updated_at"`
+	Members      []VillageMember `json:"members"`
+}
+
+// VillageStats provides summary statistics about the village, counted per household
 type VillageStats struct {
 	TotalMembers    int `json:"total_members"`
 	ToldMembers     int `json:"told_members"`
 	SubscribedMembers int `json:"subscribed_members"`
 	PendingMembers  int `json:"pending_members"`
+	TotalAddresses  int `json:"total_addresses"`
 }
 
 // RelationshipGroup groups village members by relationship type
@@ -33,6 +50,37 @@ type RelationshipGroup struct {
 	Count        int             `json:"count"`
 }
 
+// Delivery frequencies for update and milestone emails
+const (
+	DeliveryImmediate   = "immediate"
+	DeliveryDailyDigest = "daily"
+	DeliveryWeeklyDigest = "weekly"
+	DeliveryNone        = "none"
+)
+
+// IsValidDeliveryFrequency checks whether a delivery frequency is supported
+func IsValidDeliveryFrequency(frequency string) bool {
+	switch frequency {
+	case DeliveryImmediate, DeliveryDailyDigest, DeliveryWeeklyDigest, DeliveryNone:
+		return true
+	default:
+		return false
+	}
+}
+
+// DigestInterval returns how often a digest member should be emailed, or zero
+// for members who aren't on a digest
+func (vm *VillageMember) DigestInterval() time.Duration {
+	switch vm.DeliveryFrequency {
+	case DeliveryDailyDigest:
+		return 24 * time.Hour
+	case DeliveryWeeklyDigest:
+		return 7 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
 // Common relationship types
 const (
 	RelationshipMother     = "mother"
@@ -79,4 +127,23 @@ func (vm *VillageMember) GetDisplayRelationship() string {
 // CanReceiveUpdates checks if village member can receive email updates
 func (vm *VillageMember) CanReceiveUpdates() bool {
 	return vm.IsTold && vm.IsSubscribed
+}
+
+// GetEmails returns every contact address in the household
+func (h *Household) GetEmails() []string {
+	emails := make([]string, 0, len(h.Members))
+	for _, member := range h.Members {
+		emails = append(emails, member.Email)
+	}
+	return emails
+}
+
+// IsTold checks if anyone in the household has been told
+func (h *Household) IsTold() bool {
+	for _, member := range h.Members {
+		if member.IsTold {
+			return true
+		}
+	}
+	return false
 }
\ No newline at end of file
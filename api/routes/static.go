@@ -49,6 +49,14 @@ func ManagePregnancyPageHandler(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, "public/manage-pregnancy.html")
 }
 
+func PreferencesPageHandler(w http.ResponseWriter, r *http.Request) {
+	http.ServeFile(w, r, "public/preferences.html")
+}
+
+func AccessRequestPageHandler(w http.ResponseWriter, r *http.Request) {
+	http.ServeFile(w, r, "public/access-request.html")
+}
+
 func PublicTimelinePageHandler(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, "public/timeline.html")
 }
@@ -2,10 +2,15 @@ package email
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"html/template"
 	"log"
+	"net/url"
 	"simple-go/api/db"
 	"simple-go/api/models"
+	"simple-go/api/services/markdown"
+	"simple-go/api/services/media"
 	"strings"
 	"time"
 )
@@ -38,7 +43,8 @@ func (e *EmailService) SendUpdateNotification(ctx context.Context, update *model
 	// Generate first photo URL if available
 	firstPhotoURL := ""
 	if photoCount > 0 {
-		firstPhotoURL = fmt.Sprintf("%s/images/%d/%s", e.getBaseURL(), pregnancy.ID, photos[0].Filename)
+		photoPath := fmt.Sprintf("/images/%d/%s", pregnancy.ID, photos[0].FilenameForSize(models.RenditionMedium))
+		firstPhotoURL = e.getBaseURL() + media.SignURL(photoPath, media.ScopeShared, media.EmailURLLifetime)
 	}
 
 	// Prepare template data
@@ -51,7 +57,8 @@ func (e *EmailService) SendUpdateNotification(ctx context.Context, update *model
 		TimelineURL:     timelineURL,
 		Update:          update,
 		UpdateTitle:     update.Title,
-		UpdateContent:   getStringValue(update.Content),
+		UpdateContent:   markdown.ToText(getStringValue(update.Content)),
+		UpdateContentHTML: template.HTML(markdown.ToHTML(getStringValue(update.Content))),
 		UpdateWeek:      *update.WeekNumber,
 		UpdateDate:      update.UpdateDate.Format("January 2, 2006"),
 		UpdatePhotos:    make([]string, photoCount), // Just for count
@@ -63,6 +70,9 @@ func (e *EmailService) SendUpdateNotification(ctx context.Context, update *model
 		// Use only the first name for a more personal touch
 		firstName := strings.Fields(strings.TrimSpace(member.Name))[0]
 		templateData.RecipientName = firstName
+		preferencesURL, headers := e.getPreferenceLinks(&member)
+		templateData.PreferencesURL = preferencesURL
+		templateData.TimelineURL = e.getMemberTimelineURL(pregnancy, &member)
 		
 		// Generate email content for this recipient
 		htmlContent, textContent, err := e.UpdateNotificationTemplate(templateData)
@@ -83,6 +93,7 @@ func (e *EmailService) SendUpdateNotification(ctx context.Context, update *model
 			PregnancyID:     pregnancy.ID,
 			VillageMemberID: member.ID,
 			UpdateID:        &update.ID,
+			Headers:         headers,
 		}
 
 		err = e.SendEmail(ctx, emailReq)
@@ -97,6 +108,71 @@ func (e *EmailService) SendUpdateNotification(ctx context.Context, update *model
 	return nil
 }
 
+// SendDigest gathers the updates shared and milestones reached since the given time into a
+// single email for a daily or weekly digest member. It returns the number of items included;
+// nothing is sent when there is nothing new.
+func (e *EmailService) SendDigest(ctx context.Context, member *models.VillageMember, pregnancy *models.Pregnancy, since time.Time) (int, error) {
+	updates, err := e.getSharedUpdatesSince(pregnancy.ID, since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get updates for digest: %w", err)
+	}
+
+	milestones, err := e.getMilestoneEventsSince(pregnancy.ID, since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get milestones for digest: %w", err)
+	}
+
+	itemCount := len(updates) + len(milestones)
+	if itemCount == 0 {
+		return 0, nil
+	}
+
+	if !e.config.EmailEnabled {
+		log.Printf("Email disabled, skipping digest for member %d", member.ID)
+		return itemCount, nil
+	}
+
+	preferencesURL, headers := e.getPreferenceLinks(member)
+
+	templateData := &TemplateData{
+		SenderName:       e.config.SenderName,
+		RecipientName:    strings.Fields(strings.TrimSpace(member.Name))[0],
+		PregnancyID:      pregnancy.ID,
+		ParentNames:      e.getParentNames(pregnancy),
+		DueDate:          pregnancy.DueDate.Format("January 2, 2006"),
+		CurrentWeek:      pregnancy.GetCurrentWeek(),
+		TimelineURL:      e.getMemberTimelineURL(pregnancy, member),
+		PreferencesURL:   preferencesURL,
+		DigestFrequency:  member.DeliveryFrequency,
+		DigestUpdates:    updates,
+		DigestMilestones: milestones,
+	}
+
+	htmlContent, textContent, err := e.DigestTemplate(templateData)
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate digest template: %w", err)
+	}
+
+	emailReq := &EmailRequest{
+		ToEmail:         member.Email,
+		ToName:          member.Name,
+		Subject:         e.GenerateSubject(models.EmailTypeDigest, templateData),
+		HTMLContent:     htmlContent,
+		TextContent:     textContent,
+		EmailType:       models.EmailTypeDigest,
+		PregnancyID:     pregnancy.ID,
+		VillageMemberID: member.ID,
+		Headers:         headers,
+	}
+
+	if err := e.SendEmail(ctx, emailReq); err != nil {
+		return 0, fmt.Errorf("failed to send digest to %s: %w", member.Email, err)
+	}
+
+	log.Printf("Digest with %d items sent to %s for pregnancy %d", itemCount, member.Email, pregnancy.ID)
+	return itemCount, nil
+}
+
 // SendMilestoneNotification sends email notifications for milestone achievements
 func (e *EmailService) SendMilestoneNotification(ctx context.Context, milestone *models.PregnancyMilestone, pregnancy *models.Pregnancy) error {
 	if !e.config.EmailEnabled {
@@ -133,15 +209,20 @@ func (e *EmailService) SendMilestoneNotification(ctx context.Context, milestone
 		MilestoneType:    milestone.GetDisplayType(),
 	}
 
-	// Generate email content
-	htmlContent, textContent, err := e.MilestoneNotificationTemplate(templateData)
-	if err != nil {
-		return fmt.Errorf("failed to generate milestone email template: %w", err)
-	}
-
 	// Send emails to all village members
 	for _, member := range villageMembers {
 		templateData.RecipientName = member.Name
+		preferencesURL, headers := e.getPreferenceLinks(&member)
+		templateData.PreferencesURL = preferencesURL
+		templateData.TimelineURL = e.getMemberTimelineURL(pregnancy, &member)
+
+		// Generate email content for this recipient
+		htmlContent, textContent, err := e.MilestoneNotificationTemplate(templateData)
+		if err != nil {
+			log.Printf("Failed to generate milestone email template for %s: %v", member.Email, err)
+			continue
+		}
+
 		subject := e.GenerateSubject(models.EmailTypeMilestone, templateData)
 
 		emailReq := &EmailRequest{
@@ -154,6 +235,7 @@ func (e *EmailService) SendMilestoneNotification(ctx context.Context, milestone
 			PregnancyID:     pregnancy.ID,
 			VillageMemberID: member.ID,
 			MilestoneID:     &milestone.ID,
+			Headers:         headers,
 		}
 
 		err = e.SendEmail(ctx, emailReq)
@@ -170,6 +252,100 @@ func (e *EmailService) SendMilestoneNotification(ctx context.Context, milestone
 
 // SendWelcomeEmail sends a welcome email to new village members
 func (e *EmailService) SendWelcomeEmail(ctx context.Context, member *models.VillageMember, pregnancy *models.Pregnancy) error {
+	return e.sendWelcome(ctx, member, pregnancy, false, "")
+}
+
+// SendAccessRequestOutcome tells a requester whether their timeline access request was approved,
+// denied or expired, with an optional personal note from the parents. Approved requesters get the
+// welcome email marked as an approval; member is only needed for approvals.
+func (e *EmailService) SendAccessRequestOutcome(ctx context.Context, pregnancy *models.Pregnancy, member *models.VillageMember, requesterName, requesterEmail, outcome, note string) error {
+	if outcome == models.AccessOutcomeApproved {
+		return e.sendWelcome(ctx, member, pregnancy, true, note)
+	}
+
+	if !e.config.EmailEnabled {
+		log.Printf("Email disabled, skipping %s access request email to %s", outcome, requesterEmail)
+		return nil
+	}
+
+	templateData := &TemplateData{
+		SenderName:    e.config.SenderName,
+		RecipientName: strings.Fields(strings.TrimSpace(requesterName))[0],
+		PregnancyID:   pregnancy.ID,
+		ParentNames:   e.getParentNames(pregnancy),
+		AccessOutcome: outcome,
+		OwnerNote:     note,
+	}
+
+	htmlContent, textContent, err := e.AccessRequestDeclinedTemplate(templateData)
+	if err != nil {
+		return fmt.Errorf("failed to generate access request email template: %w", err)
+	}
+
+	emailReq := &EmailRequest{
+		ToEmail:     requesterEmail,
+		ToName:      requesterName,
+		Subject:     e.GenerateSubject(models.EmailTypeAccessRequestOutcome, templateData),
+		HTMLContent: htmlContent,
+		TextContent: textContent,
+		EmailType:   models.EmailTypeAccessRequestOutcome,
+		PregnancyID: pregnancy.ID,
+	}
+
+	if err := e.SendEmail(ctx, emailReq); err != nil {
+		return fmt.Errorf("failed to send access request email to %s: %w", requesterEmail, err)
+	}
+
+	log.Printf("Access request %s email sent to %s for pregnancy %d", outcome, requesterEmail, pregnancy.ID)
+	return nil
+}
+
+// SendAccessRequestConfirmation emails the link that lets an access request matched by one of the
+// parents' rules through: to the requester to confirm their email address, or, when vouching, to
+// the member they named as their referrer
+func (e *EmailService) SendAccessRequestConfirmation(ctx context.Context, pregnancy *models.Pregnancy, toName, toEmail, requesterName, requesterEmail, relationship, confirmURL string, vouching bool) error {
+	if !e.config.EmailEnabled {
+		log.Printf("Email disabled, skipping access request confirmation to %s", toEmail)
+		return nil
+	}
+
+	templateData := &TemplateData{
+		SenderName:            e.config.SenderName,
+		RecipientName:         strings.Fields(strings.TrimSpace(toName))[0],
+		PregnancyID:           pregnancy.ID,
+		ParentNames:           e.getParentNames(pregnancy),
+		RequestorName:         requesterName,
+		RequestorEmail:        requesterEmail,
+		RequestorRelationship: relationship,
+		ConfirmURL:            confirmURL,
+		Vouching:              vouching,
+	}
+
+	htmlContent, textContent, err := e.AccessRequestConfirmationTemplate(templateData)
+	if err != nil {
+		return fmt.Errorf("failed to generate access request confirmation template: %w", err)
+	}
+
+	emailReq := &EmailRequest{
+		ToEmail:     toEmail,
+		ToName:      toName,
+		Subject:     e.GenerateSubject(models.EmailTypeAccessRequestConfirmation, templateData),
+		HTMLContent: htmlContent,
+		TextContent: textContent,
+		EmailType:   models.EmailTypeAccessRequestConfirmation,
+		PregnancyID: pregnancy.ID,
+	}
+
+	if err := e.SendEmail(ctx, emailReq); err != nil {
+		return fmt.Errorf("failed to send access request confirmation to %s: %w", toEmail, err)
+	}
+
+	log.Printf("Access request confirmation sent to %s for pregnancy %d", toEmail, pregnancy.ID)
+	return nil
+}
+
+// sendWelcome sends the welcome email, worded as an approval when the member requested access
+func (e *EmailService) sendWelcome(ctx context.Context, member *models.VillageMember, pregnancy *models.Pregnancy, approved bool, note string) error {
 	if !e.config.EmailEnabled {
 		log.Printf("Email disabled, skipping welcome email for member %d", member.ID)
 		return nil
@@ -177,18 +353,20 @@ func (e *EmailService) SendWelcomeEmail(ctx context.Context, member *models.Vill
 
 	// Generate timeline URL
 	baseURL := e.getBaseURL()
-	timelineURL := fmt.Sprintf("%s/view/%s", baseURL, pregnancy.ShareID)
+	timelineURL := e.getMemberTimelineURL(pregnancy, member)
 	log.Printf("Using BASE_URL: %s for pregnancy %d", baseURL, pregnancy.ID)
 
 	// Generate cover photo URL
 	coverPhotoURL := ""
 	if pregnancy.CoverPhotoFilename != nil && *pregnancy.CoverPhotoFilename != "" {
-		coverPhotoURL = fmt.Sprintf("%s/images/covers/%s", baseURL, *pregnancy.CoverPhotoFilename)
+		coverPhotoURL = fmt.Sprintf("%s/images/covers/%s", baseURL, pregnancy.CoverPhotoForSize(models.RenditionMedium))
 		log.Printf("Generated cover photo URL: %s", coverPhotoURL)
 	} else {
 		log.Printf("No cover photo found for pregnancy %d - CoverPhotoFilename: %v", pregnancy.ID, pregnancy.CoverPhotoFilename)
 	}
 
+	preferencesURL, headers := e.getPreferenceLinks(member)
+
 	// Prepare template data
 	templateData := &TemplateData{
 		SenderName:        e.config.SenderName,
@@ -200,6 +378,9 @@ func (e *EmailService) SendWelcomeEmail(ctx context.Context, member *models.Vill
 		TimelineURL:       timelineURL,
 		CoverPhotoURL:     coverPhotoURL,
 		VillageMemberName: member.Name,
+		PreferencesURL:    preferencesURL,
+		AccessApproved:    approved,
+		OwnerNote:         note,
 	}
 
 	// Generate email content
@@ -208,7 +389,12 @@ func (e *EmailService) SendWelcomeEmail(ctx context.Context, member *models.Vill
 		return fmt.Errorf("failed to generate welcome email template: %w", err)
 	}
 
-	subject := e.GenerateSubject(models.EmailTypeWelcome, templateData)
+	emailType := models.EmailTypeWelcome
+	if approved {
+		emailType = models.EmailTypeAccessRequestOutcome
+		templateData.AccessOutcome = models.AccessOutcomeApproved
+	}
+	subject := e.GenerateSubject(emailType, templateData)
 
 	emailReq := &EmailRequest{
 		ToEmail:         member.Email,
@@ -216,9 +402,10 @@ func (e *EmailService) SendWelcomeEmail(ctx context.Context, member *models.Vill
 		Subject:         subject,
 		HTMLContent:     htmlContent,
 		TextContent:     textContent,
-		EmailType:       models.EmailTypeWelcome,
+		EmailType:       emailType,
 		PregnancyID:     pregnancy.ID,
 		VillageMemberID: member.ID,
+		Headers:         headers,
 	}
 
 	err = e.SendEmail(ctx, emailReq)
@@ -230,6 +417,226 @@ func (e *EmailService) SendWelcomeEmail(ctx context.Context, member *models.Vill
 	return nil
 }
 
+// SendTellWaveReminder reminds the parents that a wave of their announcement plan is due
+func (e *EmailService) SendTellWaveReminder(ctx context.Context, wave *models.TellWave, pregnancy *models.Pregnancy) error {
+	if !e.config.EmailEnabled {
+		log.Printf("Email disabled, skipping tell wave reminder for wave %d", wave.ID)
+		return nil
+	}
+
+	var ownerName, ownerEmail string
+	err := db.GetDB().QueryRow(`SELECT name, email FROM users WHERE id = ?`, pregnancy.UserID).Scan(&ownerName, &ownerEmail)
+	if err != nil {
+		return fmt.Errorf("failed to get pregnancy owner: %w", err)
+	}
+
+	var memberNames []string
+	seen := make(map[string]bool)
+	for _, member := range wave.PendingMembers() {
+		if !seen[member.Name] {
+			seen[member.Name] = true
+			memberNames = append(memberNames, member.Name)
+		}
+	}
+
+	baseURL := e.getBaseURL()
+	templateData := &TemplateData{
+		SenderName:      e.config.SenderName,
+		RecipientName:   ownerName,
+		PregnancyID:     pregnancy.ID,
+		ParentNames:     e.getParentNames(pregnancy),
+		DueDate:         pregnancy.DueDate.Format("January 2, 2006"),
+		CurrentWeek:     pregnancy.GetCurrentWeek(),
+		TimelineURL:     fmt.Sprintf("%s/view/%s", baseURL, pregnancy.ShareID),
+		DashboardURL:    fmt.Sprintf("%s/manage/village", baseURL),
+		WaveName:        wave.Name,
+		WaveMemberNames: memberNames,
+	}
+
+	htmlContent, textContent, err := e.TellWaveReminderTemplate(templateData)
+	if err != nil {
+		return fmt.Errorf("failed to generate tell wave reminder template: %w", err)
+	}
+
+	subject := e.GenerateSubject(models.EmailTypeTellWaveReminder, templateData)
+
+	// Remind both parents when a partner email is on file
+	recipients := []string{ownerEmail}
+	if pregnancy.PartnerEmail != nil && *pregnancy.PartnerEmail != "" && !strings.EqualFold(*pregnancy.PartnerEmail, ownerEmail) {
+		recipients = append(recipients, *pregnancy.PartnerEmail)
+	}
+
+	for _, recipient := range recipients {
+		emailReq := &EmailRequest{
+			ToEmail:     recipient,
+			ToName:      templateData.ParentNames,
+			Subject:     subject,
+			HTMLContent: htmlContent,
+			TextContent: textContent,
+			EmailType:   models.EmailTypeTellWaveReminder,
+			PregnancyID: pregnancy.ID,
+		}
+
+		if err := e.SendEmail(ctx, emailReq); err != nil {
+			return fmt.Errorf("failed to send tell wave reminder to %s: %w", recipient, err)
+		}
+	}
+
+	log.Printf("Tell wave reminder sent for wave %d of pregnancy %d", wave.ID, pregnancy.ID)
+	return nil
+}
+
+// SendLeaderInvite emails a village member who was made a leader the link that claims the role
+// for their account
+func (e *EmailService) SendLeaderInvite(ctx context.Context, pregnancy *models.Pregnancy, member *models.VillageMember, claimToken string) error {
+	if !e.config.EmailEnabled {
+		log.Printf("Email disabled, skipping leader invite for member %d", member.ID)
+		return nil
+	}
+
+	baseURL := e.getBaseURL()
+	templateData := &TemplateData{
+		SenderName:        e.config.SenderName,
+		RecipientName:     member.Name,
+		PregnancyID:       pregnancy.ID,
+		ParentNames:       e.getParentNames(pregnancy),
+		CurrentWeek:       pregnancy.GetCurrentWeek(),
+		TimelineURL:       e.getMemberTimelineURL(pregnancy, member),
+		VillageMemberName: member.Name,
+		LeaderClaimURL:    fmt.Sprintf("%s/login?leader_claim=%s", baseURL, url.QueryEscape(claimToken)),
+	}
+
+	htmlContent, textContent, err := e.LeaderInviteTemplate(templateData)
+	if err != nil {
+		return fmt.Errorf("failed to generate leader invite template: %w", err)
+	}
+
+	emailReq := &EmailRequest{
+		ToEmail:         member.Email,
+		ToName:          member.Name,
+		Subject:         e.GenerateSubject(models.EmailTypeLeaderInvite, templateData),
+		HTMLContent:     htmlContent,
+		TextContent:     textContent,
+		EmailType:       models.EmailTypeLeaderInvite,
+		PregnancyID:     pregnancy.ID,
+		VillageMemberID: member.ID,
+	}
+
+	if err := e.SendEmail(ctx, emailReq); err != nil {
+		return fmt.Errorf("failed to send leader invite to %s: %w", member.Email, err)
+	}
+
+	log.Printf("Leader invite sent to %s for pregnancy %d", member.Email, pregnancy.ID)
+	return nil
+}
+
+// SendTimelineLink emails a village member their own link to the timeline, for when they open
+// it without one
+func (e *EmailService) SendTimelineLink(ctx context.Context, pregnancy *models.Pregnancy, member *models.VillageMember) error {
+	if !e.config.EmailEnabled {
+		log.Printf("Email disabled, skipping timeline link for member %d", member.ID)
+		return nil
+	}
+
+	preferencesURL, headers := e.getPreferenceLinks(member)
+	templateData := &TemplateData{
+		SenderName:        e.config.SenderName,
+		RecipientName:     member.Name,
+		PregnancyID:       pregnancy.ID,
+		ParentNames:       e.getParentNames(pregnancy),
+		CurrentWeek:       pregnancy.GetCurrentWeek(),
+		TimelineURL:       e.getMemberTimelineURL(pregnancy, member),
+		VillageMemberName: member.Name,
+		PreferencesURL:    preferencesURL,
+	}
+
+	htmlContent, textContent, err := e.TimelineLinkTemplate(templateData)
+	if err != nil {
+		return fmt.Errorf("failed to generate timeline link template: %w", err)
+	}
+
+	emailReq := &EmailRequest{
+		ToEmail:         member.Email,
+		ToName:          member.Name,
+		Subject:         e.GenerateSubject(models.EmailTypeTimelineLink, templateData),
+		HTMLContent:     htmlContent,
+		TextContent:     textContent,
+		EmailType:       models.EmailTypeTimelineLink,
+		PregnancyID:     pregnancy.ID,
+		VillageMemberID: member.ID,
+		Headers:         headers,
+	}
+
+	if err := e.SendEmail(ctx, emailReq); err != nil {
+		return fmt.Errorf("failed to send timeline link to %s: %w", member.Email, err)
+	}
+
+	log.Printf("Timeline link sent to %s for pregnancy %d", member.Email, pregnancy.ID)
+	return nil
+}
+
+// SendFeedbackNotification tells the parents a village member commented on or reacted to one
+// of their updates. emailType is models.EmailTypeComment, with the comment's body, or
+// models.EmailTypeReaction, with the emoji.
+func (e *EmailService) SendFeedbackNotification(ctx context.Context, pregnancy *models.Pregnancy, updateTitle, emailType, authorName, body, reaction string) error {
+	if !e.config.EmailEnabled {
+		log.Printf("Email disabled, skipping %s notification for pregnancy %d", emailType, pregnancy.ID)
+		return nil
+	}
+
+	var ownerName, ownerEmail string
+	err := db.GetDB().QueryRow(`SELECT name, email FROM users WHERE id = ?`, pregnancy.UserID).Scan(&ownerName, &ownerEmail)
+	if err != nil {
+		return fmt.Errorf("failed to get pregnancy owner: %w", err)
+	}
+
+	baseURL := e.getBaseURL()
+	templateData := &TemplateData{
+		SenderName:    e.config.SenderName,
+		RecipientName: ownerName,
+		PregnancyID:   pregnancy.ID,
+		ParentNames:   e.getParentNames(pregnancy),
+		CurrentWeek:   pregnancy.GetCurrentWeek(),
+		TimelineURL:   fmt.Sprintf("%s/view/%s", baseURL, pregnancy.ShareID),
+		DashboardURL:  fmt.Sprintf("%s/app", baseURL),
+		UpdateTitle:   updateTitle,
+		CommentAuthor: authorName,
+		CommentBody:   body,
+		Reaction:      reaction,
+	}
+
+	htmlContent, textContent, err := e.FeedbackNotificationTemplate(templateData)
+	if err != nil {
+		return fmt.Errorf("failed to generate %s notification template: %w", emailType, err)
+	}
+
+	subject := e.GenerateSubject(emailType, templateData)
+
+	// Tell both parents when a partner email is on file
+	recipients := []string{ownerEmail}
+	if pregnancy.PartnerEmail != nil && *pregnancy.PartnerEmail != "" && !strings.EqualFold(*pregnancy.PartnerEmail, ownerEmail) {
+		recipients = append(recipients, *pregnancy.PartnerEmail)
+	}
+
+	for _, recipient := range recipients {
+		emailReq := &EmailRequest{
+			ToEmail:     recipient,
+			ToName:      templateData.ParentNames,
+			Subject:     subject,
+			HTMLContent: htmlContent,
+			TextContent: textContent,
+			EmailType:   emailType,
+			PregnancyID: pregnancy.ID,
+		}
+
+		if err := e.SendEmail(ctx, emailReq); err != nil {
+			return fmt.Errorf("failed to send %s notification to %s: %w", emailType, recipient, err)
+		}
+	}
+
+	return nil
+}
+
 // SendTestEmail sends a test email to verify configuration
 func (e *EmailService) SendTestEmail(ctx context.Context, toEmail, toName string) error {
 	templateData := &TemplateData{
@@ -277,11 +684,14 @@ Sent at: %s
 
 // Helper functions
 
+// getVillageMembers returns subscribed members who want each email as it happens;
+// digest members hear about it in their next digest instead
 func (e *EmailService) getVillageMembers(pregnancyID int) ([]models.VillageMember, error) {
 	query := `
-		SELECT id, pregnancy_id, name, email, relationship, created_at 
+		SELECT id, pregnancy_id, name, email, relationship, unsubscribe_token, created_at 
 		FROM village_members 
-		WHERE pregnancy_id = ? AND email != '' AND email IS NOT NULL
+		WHERE pregnancy_id = ? AND email != '' AND email IS NOT NULL AND is_subscribed = TRUE
+		AND is_told = TRUE AND delivery_frequency = 'immediate'
 	`
 	
 	rows, err := db.GetDB().Query(query, pregnancyID)
@@ -299,6 +709,7 @@ func (e *EmailService) getVillageMembers(pregnancyID int) ([]models.VillageMembe
 			&member.Name,
 			&member.Email,
 			&member.Relationship,
+			&member.UnsubscribeToken,
 			&member.CreatedAt,
 		)
 		if err != nil {
@@ -310,9 +721,84 @@ func (e *EmailService) getVillageMembers(pregnancyID int) ([]models.VillageMembe
 	return members, nil
 }
 
+// getSharedUpdatesSince returns the updates shared with the village after the given time
+func (e *EmailService) getSharedUpdatesSince(pregnancyID int, since time.Time) ([]DigestItem, error) {
+	query := `
+		SELECT title, content, week_number, update_date, created_at
+		FROM pregnancy_updates
+		WHERE pregnancy_id = ? AND is_shared = TRUE AND deleted_at IS NULL AND COALESCE(shared_at, created_at) > ?
+		ORDER BY COALESCE(shared_at, created_at) ASC
+	`
+
+	rows, err := db.GetDB().Query(query, pregnancyID, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []DigestItem
+	for rows.Next() {
+		var item DigestItem
+		var content *string
+		var weekNumber *int
+		var updateDate *time.Time
+		var createdAt time.Time
+		if err := rows.Scan(&item.Title, &content, &weekNumber, &updateDate, &createdAt); err != nil {
+			return nil, err
+		}
+		item.Description = markdown.ToText(getStringValue(content))
+		item.DescriptionHTML = template.HTML(markdown.ToHTML(getStringValue(content)))
+		if weekNumber != nil {
+			item.Week = *weekNumber
+		}
+		if updateDate != nil {
+			createdAt = *updateDate
+		}
+		item.Date = createdAt.Format("January 2, 2006")
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// getMilestoneEventsSince returns milestone and appointment events recorded after the given time
+func (e *EmailService) getMilestoneEventsSince(pregnancyID int, since time.Time) ([]DigestItem, error) {
+	query := `
+		SELECT event_title, event_description, week_number, created_at
+		FROM pregnancy_events
+		WHERE pregnancy_id = ? AND event_type IN (?, ?) AND created_at > ?
+		ORDER BY created_at ASC
+	`
+
+	rows, err := db.GetDB().Query(query, pregnancyID, models.EventMilestoneReached, models.EventAppointmentCompleted, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []DigestItem
+	for rows.Next() {
+		var item DigestItem
+		var description *string
+		var weekNumber *int
+		var date time.Time
+		if err := rows.Scan(&item.Title, &description, &weekNumber, &date); err != nil {
+			return nil, err
+		}
+		item.Description = getStringValue(description)
+		if weekNumber != nil {
+			item.Week = *weekNumber
+		}
+		item.Date = date.Format("January 2, 2006")
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
 func (e *EmailService) getUpdatePhotoCount(updateID int) int {
 	var count int
-	query := `SELECT COUNT(*) FROM update_photos WHERE update_id = ?`
+	query := `SELECT COUNT(*) FROM update_media WHERE update_id = ? AND kind = 'photo'`
 	err := db.GetDB().QueryRow(query, updateID).Scan(&count)
 	if err != nil {
 		return 0
@@ -320,10 +806,10 @@ func (e *EmailService) getUpdatePhotoCount(updateID int) int {
 	return count
 }
 
-func (e *EmailService) getUpdatePhotos(updateID int) []models.UpdatePhoto {
-	var photos []models.UpdatePhoto
-	query := `SELECT id, update_id, filename, original_filename, file_size, caption, sort_order, created_at 
-			  FROM update_photos WHERE update_id = ? ORDER BY sort_order`
+func (e *EmailService) getUpdatePhotos(updateID int) []models.UpdateMedia {
+	var photos []models.UpdateMedia
+	query := `SELECT id, update_id, kind, mime_type, filename, original_filename, file_size, caption, sort_order, width, height, renditions, captured_at, created_at 
+			  FROM update_media WHERE update_id = ? AND kind = 'photo' ORDER BY sort_order`
 	rows, err := db.GetDB().Query(query, updateID)
 	if err != nil {
 		log.Printf("Error getting update photos: %v", err)
@@ -332,9 +818,9 @@ func (e *EmailService) getUpdatePhotos(updateID int) []models.UpdatePhoto {
 	defer rows.Close()
 
 	for rows.Next() {
-		var photo models.UpdatePhoto
-		err := rows.Scan(&photo.ID, &photo.UpdateID, &photo.Filename, &photo.OriginalFilename, 
-						&photo.FileSize, &photo.Caption, &photo.SortOrder, &photo.CreatedAt)
+		var photo models.UpdateMedia
+		err := rows.Scan(&photo.ID, &photo.UpdateID, &photo.Kind, &photo.MIMEType, &photo.Filename, &photo.OriginalFilename, 
+						&photo.FileSize, &photo.Caption, &photo.SortOrder, &photo.Width, &photo.Height, &photo.Renditions, &photo.CapturedAt, &photo.CreatedAt)
 		if err != nil {
 			log.Printf("Error scanning photo: %v", err)
 			continue
@@ -361,6 +847,40 @@ func (e *EmailService) getParentNames(pregnancy *models.Pregnancy) string {
 	return userName
 }
 
+// getPreferenceLinks returns the member's preference page URL and the RFC 8058 one-click
+// unsubscribe headers for emails sent to them
+func (e *EmailService) getPreferenceLinks(member *models.VillageMember) (string, map[string]string) {
+	if member.UnsubscribeToken == nil || *member.UnsubscribeToken == "" {
+		return "", nil
+	}
+
+	baseURL := e.getBaseURL()
+	preferencesURL := fmt.Sprintf("%s/preferences/%s", baseURL, *member.UnsubscribeToken)
+	headers := map[string]string{
+		"List-Unsubscribe":      fmt.Sprintf("<%s/api/unsubscribe/%s>", baseURL, *member.UnsubscribeToken),
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+
+	return preferencesURL, headers
+}
+
+// getMemberTimelineURL returns the member's own link to the timeline, which carries their signed
+// access token so the timeline knows who is reacting and commenting
+func (e *EmailService) getMemberTimelineURL(pregnancy *models.Pregnancy, member *models.VillageMember) string {
+	timelineURL := fmt.Sprintf("%s/view/%s", e.getBaseURL(), pregnancy.ShareID)
+
+	var nonce sql.NullString
+	err := db.GetDB().QueryRow(`SELECT access_nonce FROM village_members WHERE id = ?`, member.ID).Scan(&nonce)
+	if err != nil {
+		log.Printf("Failed to get access nonce for member %d: %v", member.ID, err)
+		return timelineURL
+	}
+	if nonce.String == "" {
+		return timelineURL
+	}
+	return timelineURL + "?member=" + url.QueryEscape(SignMemberAccess(member.ID, nonce.String))
+}
+
 func (e *EmailService) getBaseURL() string {
 	return e.config.BaseURL
 }
@@ -1,12 +1,17 @@
 package email
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"log"
+	"mime"
+	"net/mail"
 	"simple-go/api/config"
 	"simple-go/api/db"
 	"simple-go/api/models"
+	"sort"
 	"strings"
 	"time"
 
@@ -35,6 +40,8 @@ type EmailRequest struct {
 	VillageMemberID int
 	UpdateID    *int
 	MilestoneID *int
+	Headers     map[string]string // extra headers such as List-Unsubscribe; sent as a raw message
+	TrackingID  string            // set when sending to village members so opens and clicks are recorded
 }
 
 // NewEmailService creates a new email service instance
@@ -78,47 +85,120 @@ func (e *EmailService) SendEmail(ctx context.Context, req *EmailRequest) error {
 		return fmt.Errorf("SES client not initialized")
 	}
 
-	// Create the email input
-	input := &ses.SendEmailInput{
-		Source: aws.String(fmt.Sprintf("%s <%s>", e.config.SenderName, e.config.SenderEmail)),
-		Destination: &types.Destination{
-			ToAddresses: []string{req.ToEmail},
-		},
-		Message: &types.Message{
-			Subject: &types.Content{
-				Data: aws.String(req.Subject),
+	// Track opens and clicks on emails to village members for the parents' engagement view
+	if req.VillageMemberID != 0 && req.HTMLContent != "" {
+		trackingID, err := newTrackingID()
+		if err != nil {
+			log.Printf("Failed to generate email tracking ID: %v", err)
+		} else {
+			req.TrackingID = trackingID
+			req.HTMLContent = e.addTracking(req.HTMLContent, trackingID)
+		}
+	}
+
+	// Send the email. Custom headers need a raw MIME message; otherwise use the simple API.
+	var messageID string
+	if len(req.Headers) > 0 {
+		input := &ses.SendRawEmailInput{
+			RawMessage: &types.RawMessage{
+				Data: e.buildRawMessage(req),
+			},
+		}
+
+		result, err := e.sesClient.SendRawEmail(ctx, input)
+		if err != nil {
+			// Log the email attempt as failed
+			e.logEmailNotification(req, "", models.DeliveryStatusFailed)
+			return fmt.Errorf("failed to send email: %w", err)
+		}
+		if result.MessageId != nil {
+			messageID = *result.MessageId
+		}
+	} else {
+		input := &ses.SendEmailInput{
+			Source: aws.String(fmt.Sprintf("%s <%s>", e.config.SenderName, e.config.SenderEmail)),
+			Destination: &types.Destination{
+				ToAddresses: []string{req.ToEmail},
 			},
-			Body: &types.Body{
-				Html: &types.Content{
-					Data: aws.String(req.HTMLContent),
+			Message: &types.Message{
+				Subject: &types.Content{
+					Data: aws.String(req.Subject),
 				},
-				Text: &types.Content{
-					Data: aws.String(req.TextContent),
+				Body: &types.Body{
+					Html: &types.Content{
+						Data: aws.String(req.HTMLContent),
+					},
+					Text: &types.Content{
+						Data: aws.String(req.TextContent),
+					},
 				},
 			},
-		},
-	}
+		}
 
-	// Send the email
-	result, err := e.sesClient.SendEmail(ctx, input)
-	if err != nil {
-		// Log the email attempt as failed
-		e.logEmailNotification(req, "", models.DeliveryStatusFailed)
-		return fmt.Errorf("failed to send email: %w", err)
+		result, err := e.sesClient.SendEmail(ctx, input)
+		if err != nil {
+			// Log the email attempt as failed
+			e.logEmailNotification(req, "", models.DeliveryStatusFailed)
+			return fmt.Errorf("failed to send email: %w", err)
+		}
+		if result.MessageId != nil {
+			messageID = *result.MessageId
+		}
 	}
 
 	// Log the email as sent
-	messageID := ""
-	if result.MessageId != nil {
-		messageID = *result.MessageId
-	}
-	
 	e.logEmailNotification(req, messageID, models.DeliveryStatusSent)
 	
 	log.Printf("Email sent successfully to %s, MessageID: %s", req.ToEmail, messageID)
 	return nil
 }
 
+// buildRawMessage renders a multipart/alternative MIME message including any custom headers
+func (e *EmailService) buildRawMessage(req *EmailRequest) []byte {
+	boundary := fmt.Sprintf("boundary-%d", time.Now().UnixNano())
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", (&mail.Address{Name: e.config.SenderName, Address: e.config.SenderEmail}).String())
+	fmt.Fprintf(&buf, "To: %s\r\n", (&mail.Address{Name: req.ToName, Address: req.ToEmail}).String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", req.Subject))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	// Sort custom headers so the message is deterministic
+	names := make([]string, 0, len(req.Headers))
+	for name := range req.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, req.Headers[name])
+	}
+
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain", req.TextContent},
+		{"text/html", req.HTMLContent},
+	}
+	for _, part := range parts {
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		fmt.Fprintf(&buf, "Content-Type: %s; charset=UTF-8\r\n", part.contentType)
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+
+		encoded := base64.StdEncoding.EncodeToString([]byte(part.body))
+		for len(encoded) > 76 {
+			buf.WriteString(encoded[:76] + "\r\n")
+			encoded = encoded[76:]
+		}
+		buf.WriteString(encoded + "\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	return buf.Bytes()
+}
+
 // logEmailNotification records the email attempt in the database
 func (e *EmailService) logEmailNotification(req *EmailRequest, messageID, status string) {
 	notification := models.EmailNotification{
@@ -136,13 +216,16 @@ func (e *EmailService) logEmailNotification(req *EmailRequest, messageID, status
 	if messageID != "" {
 		notification.SESMessageID = &messageID
 	}
+	if req.TrackingID != "" {
+		notification.TrackingID = &req.TrackingID
+	}
 
 	// Insert into database
 	query := `
 		INSERT INTO email_notifications (
 			pregnancy_id, village_member_id, update_id, milestone_id,
-			email_type, subject, sent_at, delivery_status, ses_message_id, created_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			email_type, subject, sent_at, delivery_status, ses_message_id, tracking_id, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	
 	_, err := db.GetDB().Exec(query,
@@ -155,6 +238,7 @@ func (e *EmailService) logEmailNotification(req *EmailRequest, messageID, status
 		notification.SentAt,
 		notification.DeliveryStatus,
 		notification.SESMessageID,
+		notification.TrackingID,
 		notification.CreatedAt,
 	)
 	
@@ -214,8 +298,9 @@ func (e *EmailService) GetEmailStatistics(pregnancyID int) (*models.Notification
 	return &summary, nil
 }
 
-// SendAccessRequestNotification sends an email notification when someone requests timeline access
-func (e *EmailService) SendAccessRequestNotification(ctx context.Context, pregnancyID int, requestorName, requestorEmail, requestorRelationship, requestorMessage string) error {
+// SendAccessRequestNotification sends an email notification when someone requests timeline access.
+// approveURL and denyURL are signed single-use links that decide the request without logging in.
+func (e *EmailService) SendAccessRequestNotification(ctx context.Context, pregnancyID int, requestorName, requestorEmail, requestorRelationship, requestorMessage, approveURL, denyURL string) error {
 	// Get pregnancy and owner information
 	var pregnancy struct {
 		ID          int
@@ -271,6 +356,8 @@ func (e *EmailService) SendAccessRequestNotification(ctx context.Context, pregna
 		RequestorEmail:        requestorEmail,
 		RequestorRelationship: requestorRelationship,
 		RequestorMessage:      requestorMessage,
+		ApproveURL:            approveURL,
+		DenyURL:               denyURL,
 	}
 	
 	// Generate email content
@@ -6,6 +6,7 @@ import (
 	"html/template"
 	"simple-go/api/models"
 	"strings"
+	texttemplate "text/template"
 )
 
 // TemplateData contains data for email templates
@@ -23,7 +24,8 @@ type TemplateData struct {
 	// Update-specific data
 	Update          *models.PregnancyUpdate
 	UpdateTitle     string
-	UpdateContent   string
+	UpdateContent   string        // plain text, for text emails
+	UpdateContentHTML template.HTML // rendered from Markdown, for HTML emails
 	UpdateWeek      int
 	UpdateDate      string
 	UpdatePhotos    []string
@@ -39,12 +41,47 @@ type TemplateData struct {
 	// Village-specific data
 	VillageMemberName string
 	InviteURL         string
+	PreferencesURL    string
 	
 	// Access request-specific data
 	RequestorEmail        string
 	RequestorRelationship string
 	RequestorMessage      string
 	DashboardURL          string
+	AccessApproved        bool
+	AccessOutcome         string
+	OwnerNote             string
+	ApproveURL            string
+	DenyURL               string
+	RequestorName         string
+	ConfirmURL            string
+	Vouching              bool // the confirmation goes to the member the requester named as their referrer
+	
+	// Digest-specific data
+	DigestFrequency  string
+	DigestUpdates    []DigestItem
+	DigestMilestones []DigestItem
+	
+	// Tell plan-specific data
+	WaveName        string
+	WaveMemberNames []string
+	
+	// Comment and reaction-specific data
+	CommentAuthor string
+	CommentBody   string
+	Reaction      string
+	
+	// Leader-specific data
+	LeaderClaimURL string
+}
+
+// DigestItem is one update or milestone listed in a digest email
+type DigestItem struct {
+	Title           string
+	Description     string
+	DescriptionHTML template.HTML // set for updates, whose content is rendered from Markdown
+	Week        int
+	Date        string
 }
 
 // UpdateNotificationTemplate generates email content for pregnancy update notifications
@@ -67,7 +104,9 @@ func (e *EmailService) UpdateNotificationTemplate(data *TemplateData) (string, s
         .update-card { padding: 0; margin: 20px 0; }
         .update-week { font-size: 14px; color: #666; margin-bottom: 10px; }
         .update-title { font-size: 22px; font-weight: 600; color: #333; margin-bottom: 15px; }
-        .update-content { font-size: 16px; line-height: 1.7; color: #555; margin: 20px 0; padding-left: 20px; border-left: 3px solid #e5e7eb; font-style: italic; white-space: pre-wrap; }
+        .update-content { font-size: 16px; line-height: 1.7; color: #555; margin: 20px 0; padding-left: 20px; border-left: 3px solid #e5e7eb; }
+        .update-content p, .update-content ul, .update-content ol { margin: 0 0 10px 0; }
+        .update-content a { color: #d97706; }
         .update-date { font-size: 14px; color: #b45309; font-weight: 500; }
         .photo-container { margin-top: 20px; text-align: center; }
         .photo-container img { max-width: 100%; height: auto; border-radius: 8px; box-shadow: 0 4px 12px rgba(0, 0, 0, 0.1); }
@@ -95,7 +134,7 @@ func (e *EmailService) UpdateNotificationTemplate(data *TemplateData) (string, s
             <div class="update-card">
                 {{if .UpdateWeek}}<div class="update-week">Week {{.UpdateWeek}}</div>{{end}}
                 <div class="update-title">{{.UpdateTitle}}</div>
-                <div class="update-content">"{{.UpdateContent}}"</div>
+                {{if .UpdateContentHTML}}<div class="update-content">{{.UpdateContentHTML}}</div>{{end}}
                 {{if .FirstPhotoURL}}
                 <div class="photo-container">
                     <img src="{{.FirstPhotoURL}}" alt="Update photo">
@@ -122,7 +161,7 @@ func (e *EmailService) UpdateNotificationTemplate(data *TemplateData) (string, s
         
         <div class="footer">
             <p>You're receiving this because you're part of {{.ParentNames}}'s pregnancy village.</p>
-            <p><a href="{{.TimelineURL}}">View Timeline</a> | <a href="#">Unsubscribe</a></p>
+            <p><a href="{{.TimelineURL}}">View Timeline</a>{{if .PreferencesURL}} | <a href="{{.PreferencesURL}}">Unsubscribe</a>{{end}}</p>
             <p>© 2024 {{.SenderName}}. All rights reserved.</p>
         </div>
     </div>
@@ -147,9 +186,10 @@ View the timeline: {{.TimelineURL}}
 
 ---
 You're receiving this because you're part of {{.ParentNames}}'s pregnancy village.
-© 2024 {{.SenderName}}. All rights reserved.`
+{{if .PreferencesURL}}Email preferences or unsubscribe: {{.PreferencesURL}}
+{{end}}© 2024 {{.SenderName}}. All rights reserved.`
 
-	return e.renderTemplate("update-html", htmlTemplate, data), e.renderTemplate("update-text", textTemplate, data), nil
+	return e.renderTemplate("update-html", htmlTemplate, data), e.renderTextTemplate("update-text", textTemplate, data), nil
 }
 
 // MilestoneNotificationTemplate generates email content for milestone notifications
@@ -202,7 +242,7 @@ func (e *EmailService) MilestoneNotificationTemplate(data *TemplateData) (string
         
         <div class="footer">
             <p>You're receiving this because you're part of {{.ParentNames}}'s pregnancy village.</p>
-            <p><a href="{{.TimelineURL}}">View Timeline</a> | <a href="#">Unsubscribe</a></p>
+            <p><a href="{{.TimelineURL}}">View Timeline</a>{{if .PreferencesURL}} | <a href="{{.PreferencesURL}}">Unsubscribe</a>{{end}}</p>
             <p>© 2024 {{.SenderName}}. All rights reserved.</p>
         </div>
     </div>
@@ -224,9 +264,10 @@ View the full timeline: {{.TimelineURL}}
 
 ---
 You're receiving this because you're part of {{.ParentNames}}'s pregnancy village.
-© 2024 {{.SenderName}}. All rights reserved.`
+{{if .PreferencesURL}}Email preferences or unsubscribe: {{.PreferencesURL}}
+{{end}}© 2024 {{.SenderName}}. All rights reserved.`
 
-	return e.renderTemplate("milestone-html", htmlTemplate, data), e.renderTemplate("milestone-text", textTemplate, data), nil
+	return e.renderTemplate("milestone-html", htmlTemplate, data), e.renderTextTemplate("milestone-text", textTemplate, data), nil
 }
 
 // WelcomeEmailTemplate generates welcome email content for new village members
@@ -420,7 +461,7 @@ func (e *EmailService) WelcomeEmailTemplate(data *TemplateData) (string, string,
         <!-- Header with gradient -->
         <div class="header">
             <h1>Welcome to {{.ParentNames}}'s Pregnancy!</h1>
-            <p>You've been invited to follow their pregnancy</p>
+            <p>{{if .AccessApproved}}Your request to follow their pregnancy was approved{{else}}You've been invited to follow their pregnancy{{end}}</p>
         </div>
         
         <!-- Cover Photo -->
@@ -433,7 +474,14 @@ func (e *EmailService) WelcomeEmailTemplate(data *TemplateData) (string, string,
         <!-- Main content -->
         <div class="content">
             <h2>Hello {{.RecipientName}}!</h2>
+            {{if .AccessApproved}}
+            <p>Good news! {{.ParentNames}} approved your request to follow their pregnancy and be part of their special moments.</p>
+            {{else}}
             <p>{{.ParentNames}} {{if contains .ParentNames "&"}}have{{else}}has{{end}} invited you to follow their pregnancy and be part of their special moments.</p>
+            {{end}}
+            {{if .OwnerNote}}
+            <p style="font-style: italic;">"{{.OwnerNote}}"</p>
+            {{end}}
             
             <!-- What to expect card -->
             <div class="welcome-card">
@@ -461,7 +509,7 @@ func (e *EmailService) WelcomeEmailTemplate(data *TemplateData) (string, string,
         <!-- Footer -->
         <div class="footer">
             <p>Thanks for being part of {{.ParentNames}}'s pregnancy village!</p>
-            <p><a href="{{.TimelineURL}}">View Timeline</a> | <a href="#">Unsubscribe</a></p>
+            <p><a href="{{.TimelineURL}}">View Timeline</a>{{if .PreferencesURL}} | <a href="{{.PreferencesURL}}">Unsubscribe</a>{{end}}</p>
             <p>© 2024 {{.SenderName}}. All rights reserved.</p>
         </div>
     </div>
@@ -472,8 +520,10 @@ func (e *EmailService) WelcomeEmailTemplate(data *TemplateData) (string, string,
 
 Hi {{.RecipientName}}!
 
-{{.ParentNames}} {{if contains .ParentNames "&"}}have{{else}}has{{end}} invited you to follow their pregnancy and be part of their special moments.
-
+{{if .AccessApproved}}Good news! {{.ParentNames}} approved your request to follow their pregnancy and be part of their special moments.{{else}}{{.ParentNames}} {{if contains .ParentNames "&"}}have{{else}}has{{end}} invited you to follow their pregnancy and be part of their special moments.{{end}}
+{{if .OwnerNote}}
+"{{.OwnerNote}}"
+{{end}}
 What you can expect:
 • Weekly pregnancy updates with photos
 • Important milestone notifications  
@@ -486,9 +536,10 @@ View their timeline: {{.TimelineURL}}
 
 ---
 Thanks for being part of {{.ParentNames}}'s pregnancy village!
-© 2024 {{.SenderName}}. All rights reserved.`
+{{if .PreferencesURL}}Email preferences or unsubscribe: {{.PreferencesURL}}
+{{end}}© 2024 {{.SenderName}}. All rights reserved.`
 
-	return e.renderTemplate("welcome-html", htmlTemplate, data), e.renderTemplate("welcome-text", textTemplate, data), nil
+	return e.renderTemplate("welcome-html", htmlTemplate, data), e.renderTextTemplate("welcome-text", textTemplate, data), nil
 }
 
 // renderTemplate renders a template with the given data
@@ -517,6 +568,31 @@ func (e *EmailService) renderTemplate(name, templateStr string, data *TemplateDa
 	return buf.String()
 }
 
+// renderTextTemplate renders the plain text part of an email, which isn't HTML escaped
+func (e *EmailService) renderTextTemplate(name, templateStr string, data *TemplateData) string {
+	funcMap := texttemplate.FuncMap{
+		"contains": strings.Contains,
+		"minus": func(a, b int) int {
+			return a - b
+		},
+	}
+
+	tmpl, err := texttemplate.New(name).Funcs(funcMap).Parse(templateStr)
+	if err != nil {
+		fmt.Printf("Error parsing template %s: %v\n", name, err)
+		return ""
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		fmt.Printf("Error executing template %s: %v\n", name, err)
+		return ""
+	}
+
+	return buf.String()
+}
+
 // AccessRequestNotificationTemplate generates email content for access request notifications
 func (e *EmailService) AccessRequestNotificationTemplate(data *TemplateData) (string, string, error) {
 	htmlTemplate := `
@@ -540,6 +616,7 @@ func (e *EmailService) AccessRequestNotificationTemplate(data *TemplateData) (st
         .request-message { margin: 15px 0; font-style: italic; }
         .cta-container { text-align: center; margin: 30px 0; }
         .cta-button { display: inline-block; background: linear-gradient(135deg, #fbbf24 0%, #f59e0b 100%); color: #ffffff !important; padding: 15px 30px; text-decoration: none; border-radius: 8px; font-weight: 600; box-shadow: 0 4px 12px rgba(251, 191, 36, 0.3); }
+        .deny-button { display: inline-block; background: #ffffff; color: #92400e !important; padding: 14px 29px; text-decoration: none; border-radius: 8px; font-weight: 600; border: 1px solid #f59e0b; margin-left: 10px; }
         .footer { background-color: #f8f9fa; padding: 30px; text-align: center; color: #666; font-size: 14px; border-top: 1px solid #e9ecef; }
         .footer a { color: #d97706; text-decoration: none; font-weight: 500; }
     </style>
@@ -566,11 +643,20 @@ func (e *EmailService) AccessRequestNotificationTemplate(data *TemplateData) (st
                 {{end}}
             </div>
             
+            {{if .ApproveURL}}
+            <p>Approve or deny this request right from this email, no need to log in. Each link works once.</p>
+            
+            <div class="cta-container">
+                <a href="{{.ApproveURL}}" class="cta-button">Approve</a>
+                <a href="{{.DenyURL}}" class="deny-button">Deny</a>
+            </div>
+            {{else}}
             <p>You can approve or deny this request from your dashboard.</p>
             
             <div class="cta-container">
                 <a href="{{.DashboardURL}}" class="cta-button">Review Request</a>
             </div>
+            {{end}}
         </div>
         
         <div class="footer">
@@ -595,15 +681,540 @@ Relationship: {{.RequestorRelationship}}
 Message: "{{.RequestorMessage}}"
 {{end}}
 
-You can approve or deny this request from your dashboard: {{.DashboardURL}}
-
+{{if .ApproveURL}}Approve: {{.ApproveURL}}
+Deny: {{.DenyURL}}
+(Each link works once, no need to log in.)
+{{else}}You can approve or deny this request from your dashboard: {{.DashboardURL}}
+{{end}}
 View your timeline: {{.TimelineURL}}
 
 ---
 You can manage access requests and your village members from your dashboard.
 © 2024 {{.SenderName}}. All rights reserved.`
 
-	return e.renderTemplate("access-request-html", htmlTemplate, data), e.renderTemplate("access-request-text", textTemplate, data), nil
+	return e.renderTemplate("access-request-html", htmlTemplate, data), e.renderTextTemplate("access-request-text", textTemplate, data), nil
+}
+
+// AccessRequestDeclinedTemplate generates email content telling a requester their access request was denied or expired
+func (e *EmailService) AccessRequestDeclinedTemplate(data *TemplateData) (string, string, error) {
+	htmlTemplate := `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Your Access Request</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; background-color: #f8f9fa; }
+        .container { max-width: 600px; margin: 0 auto; background-color: #ffffff; }
+        .header { background: linear-gradient(135deg, #fbbf24 0%, #fbbf24 50%, #f59e0b 100%); color: white; padding: 30px; text-align: center; }
+        .header h1 { margin: 0; font-size: 28px; font-weight: 600; text-shadow: 0 2px 4px rgba(0,0,0,0.1); }
+        .content { padding: 40px 30px; }
+        .content h2 { color: #d97706; font-weight: 600; margin-bottom: 20px; font-size: 24px; }
+        .owner-note { margin: 15px 0; font-style: italic; }
+        .footer { background-color: #f8f9fa; padding: 30px; text-align: center; color: #666; font-size: 14px; border-top: 1px solid #e9ecef; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>Your Access Request</h1>
+        </div>
+        
+        <div class="content">
+            <h2>Hi {{.RecipientName}},</h2>
+            {{if eq .AccessOutcome "expired"}}
+            <p>Your request to follow {{.ParentNames}}'s pregnancy timeline wasn't answered in time and has expired. If you think this was a mistake, you're welcome to reach out to them directly.</p>
+            {{else}}
+            <p>{{.ParentNames}} {{if contains .ParentNames "&"}}have{{else}}has{{end}} decided not to share their pregnancy timeline with you at this time.</p>
+            {{end}}
+            {{if .OwnerNote}}
+            <div class="owner-note">"{{.OwnerNote}}"</div>
+            {{end}}
+        </div>
+        
+        <div class="footer">
+            <p>You're receiving this because you requested access to {{.ParentNames}}'s pregnancy timeline.</p>
+            <p>© 2024 {{.SenderName}}. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>`
+
+	textTemplate := `Your Access Request
+
+Hi {{.RecipientName}},
+
+{{if eq .AccessOutcome "expired"}}Your request to follow {{.ParentNames}}'s pregnancy timeline wasn't answered in time and has expired. If you think this was a mistake, you're welcome to reach out to them directly.{{else}}{{.ParentNames}} {{if contains .ParentNames "&"}}have{{else}}has{{end}} decided not to share their pregnancy timeline with you at this time.{{end}}
+{{if .OwnerNote}}
+"{{.OwnerNote}}"
+{{end}}
+---
+You're receiving this because you requested access to {{.ParentNames}}'s pregnancy timeline.
+© 2024 {{.SenderName}}. All rights reserved.`
+
+	return e.renderTemplate("access-request-declined-html", htmlTemplate, data), e.renderTextTemplate("access-request-declined-text", textTemplate, data), nil
+}
+
+// AccessRequestConfirmationTemplate generates email content asking a requester to confirm their
+// email address, or the member they named to confirm they referred them, before a rule lets them in
+func (e *EmailService) AccessRequestConfirmationTemplate(data *TemplateData) (string, string, error) {
+	htmlTemplate := `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{if .Vouching}}Do You Know {{.RequestorName}}?{{else}}Confirm Your Email{{end}}</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; background-color: #f8f9fa; }
+        .container { max-width: 600px; margin: 0 auto; background-color: #ffffff; }
+        .header { background: linear-gradient(135deg, #fbbf24 0%, #fbbf24 50%, #f59e0b 100%); color: white; padding: 30px; text-align: center; }
+        .header h1 { margin: 0; font-size: 28px; font-weight: 600; text-shadow: 0 2px 4px rgba(0,0,0,0.1); }
+        .content { padding: 40px 30px; }
+        .content h2 { color: #d97706; font-weight: 600; margin-bottom: 20px; font-size: 24px; }
+        .cta-container { text-align: center; margin: 30px 0; }
+        .cta-button { display: inline-block; background: linear-gradient(135deg, #fbbf24 0%, #f59e0b 100%); color: #ffffff !important; padding: 15px 30px; text-decoration: none; border-radius: 8px; font-weight: 600; box-shadow: 0 4px 12px rgba(251, 191, 36, 0.3); }
+        .footer { background-color: #f8f9fa; padding: 30px; text-align: center; color: #666; font-size: 14px; border-top: 1px solid #e9ecef; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>{{if .Vouching}}Do You Know {{.RequestorName}}?{{else}}Confirm Your Email{{end}}</h1>
+        </div>
+        
+        <div class="content">
+            <h2>Hi {{.RecipientName}},</h2>
+            {{if .Vouching}}
+            <p>{{.RequestorName}} ({{.RequestorEmail}}) asked to follow {{.ParentNames}}'s pregnancy timeline as their {{.RequestorRelationship}} and said you referred them. {{.ParentNames}} let people you vouch for straight in, so they'll join the village once you confirm.</p>
+            <p>If you don't know them, ignore this email and the parents will decide instead.</p>
+            {{else}}
+            <p>Thanks for asking to follow {{.ParentNames}}'s pregnancy timeline. Confirm this is your email address and you'll join the village straight away.</p>
+            {{end}}
+            
+            <div class="cta-container">
+                <a href="{{.ConfirmURL}}" class="cta-button">{{if .Vouching}}Confirm I Know Them{{else}}Confirm My Email{{end}}</a>
+            </div>
+        </div>
+        
+        <div class="footer">
+            <p>{{if .Vouching}}You're receiving this because you're part of {{.ParentNames}}'s pregnancy village.{{else}}You're receiving this because you requested access to {{.ParentNames}}'s pregnancy timeline.{{end}}</p>
+            <p>© 2024 {{.SenderName}}. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>`
+
+	textTemplate := `{{if .Vouching}}Do You Know {{.RequestorName}}?{{else}}Confirm Your Email{{end}}
+
+Hi {{.RecipientName}},
+
+{{if .Vouching}}{{.RequestorName}} ({{.RequestorEmail}}) asked to follow {{.ParentNames}}'s pregnancy timeline as their {{.RequestorRelationship}} and said you referred them. {{.ParentNames}} let people you vouch for straight in, so they'll join the village once you confirm:
+{{.ConfirmURL}}
+
+If you don't know them, ignore this email and the parents will decide instead.{{else}}Thanks for asking to follow {{.ParentNames}}'s pregnancy timeline. Confirm this is your email address and you'll join the village straight away:
+{{.ConfirmURL}}{{end}}
+
+---
+{{if .Vouching}}You're receiving this because you're part of {{.ParentNames}}'s pregnancy village.{{else}}You're receiving this because you requested access to {{.ParentNames}}'s pregnancy timeline.{{end}}
+© 2024 {{.SenderName}}. All rights reserved.`
+
+	return e.renderTemplate("access-request-confirmation-html", htmlTemplate, data), e.renderTextTemplate("access-request-confirmation-text", textTemplate, data), nil
+}
+
+// TellWaveReminderTemplate generates email content reminding parents that an announcement wave is due
+func (e *EmailService) TellWaveReminderTemplate(data *TemplateData) (string, string, error) {
+	htmlTemplate := `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Time to Share Your News</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; background-color: #f8f9fa; }
+        .container { max-width: 600px; margin: 0 auto; background-color: #ffffff; }
+        .header { background: linear-gradient(135deg, #fbbf24 0%, #fbbf24 50%, #f59e0b 100%); color: white; padding: 30px; text-align: center; }
+        .header h1 { margin: 0; font-size: 28px; font-weight: 600; text-shadow: 0 2px 4px rgba(0,0,0,0.1); }
+        .header p { margin: 10px 0 0 0; font-size: 16px; color: #ffffff; opacity: 0.95; font-weight: 500; }
+        .content { padding: 40px 30px; }
+        .content h2 { color: #d97706; font-weight: 600; margin-bottom: 20px; font-size: 24px; }
+        .wave-name { font-size: 22px; font-weight: 600; color: #92400e; margin-bottom: 10px; }
+        .wave-members { font-size: 16px; line-height: 1.7; color: #333; margin: 0 0 15px 0; padding-left: 20px; }
+        .cta-container { text-align: center; margin: 30px 0; }
+        .cta-button { display: inline-block; background: linear-gradient(135deg, #fbbf24 0%, #f59e0b 100%); color: #ffffff !important; padding: 15px 30px; text-decoration: none; border-radius: 8px; font-weight: 600; box-shadow: 0 4px 12px rgba(251, 191, 36, 0.3); }
+        .footer { background-color: #f8f9fa; padding: 30px; text-align: center; color: #666; font-size: 14px; border-top: 1px solid #e9ecef; }
+        .footer a { color: #d97706; text-decoration: none; font-weight: 500; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>Time to Share Your News</h1>
+            <p>Week {{.CurrentWeek}} of your announcement plan</p>
+        </div>
+        
+        <div class="content">
+            <h2>Hi {{.ParentNames}}!</h2>
+            <p>You planned to tell the next group about your pregnancy around now.</p>
+            
+            <div class="wave-name">{{.WaveName}}</div>
+            {{if .WaveMemberNames}}
+            <ul class="wave-members">
+                {{range .WaveMemberNames}}<li>{{.}}</li>{{end}}
+            </ul>
+            {{end}}
+            
+            <p>Once you've shared the news, confirm the wave from your dashboard and we'll mark everyone as told and send them their welcome email.</p>
+            
+            <div class="cta-container">
+                <a href="{{.DashboardURL}}" class="cta-button">Review Plan</a>
+            </div>
+        </div>
+        
+        <div class="footer">
+            <p>You can change your announcement plan at any time from your dashboard.</p>
+            <p><a href="{{.DashboardURL}}">Go to Dashboard</a> | <a href="{{.TimelineURL}}">View Timeline</a></p>
+            <p>© 2024 {{.SenderName}}. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>`
+
+	textTemplate := `Time to Share Your News
+
+Hi {{.ParentNames}}!
+
+You planned to tell the next group about your pregnancy around now.
+
+{{.WaveName}}
+{{range .WaveMemberNames}}- {{.}}
+{{end}}
+Once you've shared the news, confirm the wave from your dashboard and we'll mark everyone as told and send them their welcome email: {{.DashboardURL}}
+
+View your timeline: {{.TimelineURL}}
+
+---
+You can change your announcement plan at any time from your dashboard.
+© 2024 {{.SenderName}}. All rights reserved.`
+
+	return e.renderTemplate("tell-wave-reminder-html", htmlTemplate, data), e.renderTextTemplate("tell-wave-reminder-text", textTemplate, data), nil
+}
+
+// FeedbackNotificationTemplate generates email content telling parents a village member
+// commented on or reacted to one of their updates
+func (e *EmailService) FeedbackNotificationTemplate(data *TemplateData) (string, string, error) {
+	htmlTemplate := `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{if .CommentBody}}New Comment{{else}}New Reaction{{end}}</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; background-color: #f8f9fa; }
+        .container { max-width: 600px; margin: 0 auto; background-color: #ffffff; }
+        .header { background: linear-gradient(135deg, #fbbf24 0%, #fbbf24 50%, #f59e0b 100%); color: white; padding: 30px; text-align: center; }
+        .header h1 { margin: 0; font-size: 28px; font-weight: 600; text-shadow: 0 2px 4px rgba(0,0,0,0.1); }
+        .header p { margin: 10px 0 0 0; font-size: 16px; color: #ffffff; opacity: 0.95; font-weight: 500; }
+        .content { padding: 40px 30px; }
+        .content h2 { color: #d97706; font-weight: 600; margin-bottom: 20px; font-size: 24px; }
+        .reaction { font-size: 48px; text-align: center; margin: 10px 0 20px 0; }
+        .comment { background-color: #fffbeb; border-left: 4px solid #fbbf24; padding: 15px 20px; margin: 20px 0; font-size: 16px; white-space: pre-wrap; }
+        .cta-container { text-align: center; margin: 30px 0; }
+        .cta-button { display: inline-block; background: linear-gradient(135deg, #fbbf24 0%, #f59e0b 100%); color: #ffffff !important; padding: 15px 30px; text-decoration: none; border-radius: 8px; font-weight: 600; box-shadow: 0 4px 12px rgba(251, 191, 36, 0.3); }
+        .footer { background-color: #f8f9fa; padding: 30px; text-align: center; color: #666; font-size: 14px; border-top: 1px solid #e9ecef; }
+        .footer a { color: #d97706; text-decoration: none; font-weight: 500; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>{{if .CommentBody}}New Comment{{else}}New Reaction{{end}}</h1>
+            <p>On "{{.UpdateTitle}}"</p>
+        </div>
+        
+        <div class="content">
+            <h2>Hi {{.ParentNames}}!</h2>
+            {{if .CommentBody}}
+            <p>{{.CommentAuthor}} commented on your update "{{.UpdateTitle}}":</p>
+            <div class="comment">{{.CommentBody}}</div>
+            {{else}}
+            <p>{{.CommentAuthor}} reacted to your update "{{.UpdateTitle}}":</p>
+            <div class="reaction">{{.Reaction}}</div>
+            {{end}}
+            
+            <div class="cta-container">
+                <a href="{{.DashboardURL}}" class="cta-button">{{if .CommentBody}}Reply{{else}}View Update{{end}}</a>
+            </div>
+        </div>
+        
+        <div class="footer">
+            <p>You can hide or delete comments from your dashboard.</p>
+            <p><a href="{{.DashboardURL}}">Go to Dashboard</a> | <a href="{{.TimelineURL}}">View Timeline</a></p>
+            <p>© 2024 {{.SenderName}}. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>`
+
+	textTemplate := `{{if .CommentBody}}New Comment{{else}}New Reaction{{end}}
+
+Hi {{.ParentNames}}!
+
+{{if .CommentBody}}{{.CommentAuthor}} commented on your update "{{.UpdateTitle}}":
+
+{{.CommentBody}}
+{{else}}{{.CommentAuthor}} reacted {{.Reaction}} to your update "{{.UpdateTitle}}".
+{{end}}
+Go to your dashboard: {{.DashboardURL}}
+
+View your timeline: {{.TimelineURL}}
+
+---
+You can hide or delete comments from your dashboard.
+© 2024 {{.SenderName}}. All rights reserved.`
+
+	return e.renderTemplate("feedback-notification-html", htmlTemplate, data), e.renderTextTemplate("feedback-notification-text", textTemplate, data), nil
+}
+
+// DigestTemplate generates email content for a daily or weekly digest of updates and milestones
+func (e *EmailService) DigestTemplate(data *TemplateData) (string, string, error) {
+	htmlTemplate := `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Your Digest from {{.ParentNames}}</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; background-color: #f8f9fa; }
+        .container { max-width: 600px; margin: 0 auto; background-color: #ffffff; }
+        .header { background: linear-gradient(135deg, #fbbf24 0%, #fbbf24 50%, #f59e0b 100%); color: white; padding: 30px; text-align: center; }
+        .header h1 { margin: 0; font-size: 28px; font-weight: 600; text-shadow: 0 2px 4px rgba(0,0,0,0.1); }
+        .header p { margin: 10px 0 0 0; font-size: 16px; color: #ffffff; opacity: 0.95; font-weight: 500; }
+        .content { padding: 40px 30px; }
+        .content h2 { color: #d97706; font-weight: 600; margin-bottom: 20px; font-size: 24px; }
+        .section-title { font-size: 18px; font-weight: 600; color: #92400e; margin: 30px 0 10px 0; }
+        .digest-item { padding: 15px 0; border-bottom: 1px solid #f3f4f6; }
+        .digest-item-title { font-size: 17px; font-weight: 600; color: #333; }
+        .digest-item-meta { font-size: 13px; color: #888; margin-bottom: 6px; }
+        .digest-item-body { font-size: 15px; color: #444; white-space: pre-line; }
+        .digest-item-content { font-size: 15px; color: #444; }
+        .digest-item-content p, .digest-item-content ul, .digest-item-content ol { margin: 0 0 8px 0; }
+        .cta-container { text-align: center; margin: 30px 0; }
+        .cta-button { display: inline-block; background: linear-gradient(135deg, #fbbf24 0%, #f59e0b 100%); color: #ffffff !important; padding: 15px 30px; text-decoration: none; border-radius: 8px; font-weight: 600; box-shadow: 0 4px 12px rgba(251, 191, 36, 0.3); }
+        .footer { background-color: #f8f9fa; padding: 30px; text-align: center; color: #666; font-size: 14px; border-top: 1px solid #e9ecef; }
+        .footer a { color: #d97706; text-decoration: none; font-weight: 500; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>{{if eq .DigestFrequency "weekly"}}Your Weekly Digest{{else}}Your Daily Digest{{end}}</h1>
+            <p>Week {{.CurrentWeek}} · Due {{.DueDate}}</p>
+        </div>
+        
+        <div class="content">
+            <h2>Hi {{.RecipientName}}!</h2>
+            <p>Here's what {{.ParentNames}} shared since your last digest.</p>
+            
+            {{if .DigestUpdates}}
+            <div class="section-title">Updates</div>
+            {{range .DigestUpdates}}
+            <div class="digest-item">
+                <div class="digest-item-title">{{.Title}}</div>
+                <div class="digest-item-meta">{{if .Week}}Week {{.Week}} · {{end}}{{.Date}}</div>
+                {{if .DescriptionHTML}}<div class="digest-item-content">{{.DescriptionHTML}}</div>{{else if .Description}}<div class="digest-item-body">{{.Description}}</div>{{end}}
+            </div>
+            {{end}}
+            {{end}}
+            
+            {{if .DigestMilestones}}
+            <div class="section-title">Milestones</div>
+            {{range .DigestMilestones}}
+            <div class="digest-item">
+                <div class="digest-item-title">{{.Title}}</div>
+                <div class="digest-item-meta">{{if .Week}}Week {{.Week}} · {{end}}{{.Date}}</div>
+                {{if .Description}}<div class="digest-item-body">{{.Description}}</div>{{end}}
+            </div>
+            {{end}}
+            {{end}}
+            
+            <div class="cta-container">
+                <a href="{{.TimelineURL}}" class="cta-button">View Timeline</a>
+            </div>
+        </div>
+        
+        <div class="footer">
+            <p>You're receiving this {{.DigestFrequency}} digest because you're part of {{.ParentNames}}'s pregnancy village.</p>
+            <p><a href="{{.TimelineURL}}">View Timeline</a>{{if .PreferencesURL}} | <a href="{{.PreferencesURL}}">Change frequency or unsubscribe</a>{{end}}</p>
+            <p>© 2024 {{.SenderName}}. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>`
+
+	textTemplate := `{{if eq .DigestFrequency "weekly"}}Your Weekly Digest{{else}}Your Daily Digest{{end}} from {{.ParentNames}}
+
+Hi {{.RecipientName}}!
+
+Here's what {{.ParentNames}} shared since your last digest.
+{{if .DigestUpdates}}
+UPDATES
+{{range .DigestUpdates}}
+{{if .Week}}Week {{.Week}}: {{end}}{{.Title}} ({{.Date}})
+{{if .Description}}{{.Description}}
+{{end}}{{end}}{{end}}{{if .DigestMilestones}}
+MILESTONES
+{{range .DigestMilestones}}
+{{if .Week}}Week {{.Week}}: {{end}}{{.Title}} ({{.Date}})
+{{if .Description}}{{.Description}}
+{{end}}{{end}}{{end}}
+Currently at week {{.CurrentWeek}}, due {{.DueDate}}.
+
+View the timeline: {{.TimelineURL}}
+
+---
+You're receiving this {{.DigestFrequency}} digest because you're part of {{.ParentNames}}'s pregnancy village.
+{{if .PreferencesURL}}Change frequency or unsubscribe: {{.PreferencesURL}}
+{{end}}© 2024 {{.SenderName}}. All rights reserved.`
+
+	return e.renderTemplate("digest-html", htmlTemplate, data), e.renderTextTemplate("digest-text", textTemplate, data), nil
+}
+
+// LeaderInviteTemplate generates email content inviting a village member to claim the leader role
+func (e *EmailService) LeaderInviteTemplate(data *TemplateData) (string, string, error) {
+	htmlTemplate := `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>You're a Village Leader</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; background-color: #f8f9fa; }
+        .container { max-width: 600px; margin: 0 auto; background-color: #ffffff; }
+        .header { background: linear-gradient(135deg, #fbbf24 0%, #fbbf24 50%, #f59e0b 100%); color: white; padding: 30px; text-align: center; }
+        .header h1 { margin: 0; font-size: 28px; font-weight: 600; text-shadow: 0 2px 4px rgba(0,0,0,0.1); }
+        .header p { margin: 10px 0 0 0; font-size: 16px; color: #ffffff; opacity: 0.95; font-weight: 500; }
+        .content { padding: 40px 30px; }
+        .content h2 { color: #d97706; font-weight: 600; margin-bottom: 20px; font-size: 24px; }
+        .cta-container { text-align: center; margin: 30px 0; }
+        .cta-button { display: inline-block; background: linear-gradient(135deg, #fbbf24 0%, #f59e0b 100%); color: #ffffff !important; padding: 15px 30px; text-decoration: none; border-radius: 8px; font-weight: 600; box-shadow: 0 4px 12px rgba(251, 191, 36, 0.3); }
+        .footer { background-color: #f8f9fa; padding: 30px; text-align: center; color: #666; font-size: 14px; border-top: 1px solid #e9ecef; }
+        .footer a { color: #d97706; text-decoration: none; font-weight: 500; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>You're a Village Leader</h1>
+            <p>{{.ParentNames}} asked you to help with their village</p>
+        </div>
+        
+        <div class="content">
+            <h2>Hi {{.VillageMemberName}}!</h2>
+            <p>{{.ParentNames}} made you a leader of their pregnancy village. Leaders can see the village members and help keep everyone in the loop.</p>
+            <p>Sign in, or create an account, through the link below to take on the role. The link only works once, so keep it to yourself.</p>
+            
+            <div class="cta-container">
+                <a href="{{.LeaderClaimURL}}" class="cta-button">Become a Leader</a>
+            </div>
+        </div>
+        
+        <div class="footer">
+            <p>If you weren't expecting this, you can ignore this email.</p>
+            <p><a href="{{.TimelineURL}}">View Timeline</a></p>
+            <p>© 2024 {{.SenderName}}. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>`
+
+	textTemplate := `You're a Village Leader
+
+Hi {{.VillageMemberName}}!
+
+{{.ParentNames}} made you a leader of their pregnancy village. Leaders can see the village members and help keep everyone in the loop.
+
+Sign in, or create an account, through this link to take on the role. The link only works once, so keep it to yourself:
+{{.LeaderClaimURL}}
+
+View the timeline: {{.TimelineURL}}
+
+---
+If you weren't expecting this, you can ignore this email.
+© 2024 {{.SenderName}}. All rights reserved.`
+
+	return e.renderTemplate("leader-invite-html", htmlTemplate, data), e.renderTextTemplate("leader-invite-text", textTemplate, data), nil
+}
+
+// TimelineLinkTemplate generates email content with a village member's own link to the timeline
+func (e *EmailService) TimelineLinkTemplate(data *TemplateData) (string, string, error) {
+	htmlTemplate := `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Your Timeline Link</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; background-color: #f8f9fa; }
+        .container { max-width: 600px; margin: 0 auto; background-color: #ffffff; }
+        .header { background: linear-gradient(135deg, #fbbf24 0%, #fbbf24 50%, #f59e0b 100%); color: white; padding: 30px; text-align: center; }
+        .header h1 { margin: 0; font-size: 28px; font-weight: 600; text-shadow: 0 2px 4px rgba(0,0,0,0.1); }
+        .header p { margin: 10px 0 0 0; font-size: 16px; color: #ffffff; opacity: 0.95; font-weight: 500; }
+        .content { padding: 40px 30px; }
+        .content h2 { color: #d97706; font-weight: 600; margin-bottom: 20px; font-size: 24px; }
+        .cta-container { text-align: center; margin: 30px 0; }
+        .cta-button { display: inline-block; background: linear-gradient(135deg, #fbbf24 0%, #f59e0b 100%); color: #ffffff !important; padding: 15px 30px; text-decoration: none; border-radius: 8px; font-weight: 600; box-shadow: 0 4px 12px rgba(251, 191, 36, 0.3); }
+        .footer { background-color: #f8f9fa; padding: 30px; text-align: center; color: #666; font-size: 14px; border-top: 1px solid #e9ecef; }
+        .footer a { color: #d97706; text-decoration: none; font-weight: 500; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>Your Timeline Link</h1>
+            <p>Follow along with {{.ParentNames}}</p>
+        </div>
+        
+        <div class="content">
+            <h2>Hi {{.VillageMemberName}}!</h2>
+            <p>Here's your own link to {{.ParentNames}}'s pregnancy timeline. Open it to react to updates, leave comments and sign the guestbook as yourself.</p>
+            <p>The link is only for you, so please don't forward this email.</p>
+            
+            <div class="cta-container">
+                <a href="{{.TimelineURL}}" class="cta-button">Open the Timeline</a>
+            </div>
+        </div>
+        
+        <div class="footer">
+            <p>If you didn't ask for this link, you can ignore this email.</p>
+            {{if .PreferencesURL}}<p><a href="{{.PreferencesURL}}">Unsubscribe</a></p>{{end}}
+            <p>© 2024 {{.SenderName}}. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>`
+
+	textTemplate := `Your Timeline Link
+
+Hi {{.VillageMemberName}}!
+
+Here's your own link to {{.ParentNames}}'s pregnancy timeline. Open it to react to updates, leave comments and sign the guestbook as yourself:
+{{.TimelineURL}}
+
+The link is only for you, so please don't forward this email.
+
+---
+If you didn't ask for this link, you can ignore this email.
+{{if .PreferencesURL}}Unsubscribe: {{.PreferencesURL}}
+{{end}}© 2024 {{.SenderName}}. All rights reserved.`
+
+	return e.renderTemplate("timeline-link-html", htmlTemplate, data), e.renderTextTemplate("timeline-link-text", textTemplate, data), nil
 }
 
 // GenerateSubject creates appropriate email subjects
@@ -624,6 +1235,35 @@ func (e *EmailService) GenerateSubject(emailType string, data *TemplateData) str
 		return fmt.Sprintf("Weekly reminder from %s", data.ParentNames)
 	case "access_request":
 		return fmt.Sprintf("New access request for your pregnancy timeline")
+	case models.EmailTypeAccessRequestOutcome:
+		switch data.AccessOutcome {
+		case models.AccessOutcomeApproved:
+			return fmt.Sprintf("You're in! Welcome to %s's pregnancy", data.ParentNames)
+		case models.AccessOutcomeExpired:
+			return fmt.Sprintf("Your request to follow %s's pregnancy has expired", data.ParentNames)
+		default:
+			return fmt.Sprintf("An update on your request to follow %s's pregnancy", data.ParentNames)
+		}
+	case models.EmailTypeAccessRequestConfirmation:
+		if data.Vouching {
+			return fmt.Sprintf("Did you refer %s to %s's pregnancy?", data.RequestorName, data.ParentNames)
+		}
+		return fmt.Sprintf("Confirm your email to follow %s's pregnancy", data.ParentNames)
+	case models.EmailTypeDigest:
+		if data.DigestFrequency == models.DeliveryWeeklyDigest {
+			return fmt.Sprintf("Your weekly digest from %s", data.ParentNames)
+		}
+		return fmt.Sprintf("Your daily digest from %s", data.ParentNames)
+	case models.EmailTypeTellWaveReminder:
+		return fmt.Sprintf("Time to tell %s", data.WaveName)
+	case models.EmailTypeLeaderInvite:
+		return fmt.Sprintf("You've been made a leader of %s's village", data.ParentNames)
+	case models.EmailTypeTimelineLink:
+		return fmt.Sprintf("Your link to %s's timeline", data.ParentNames)
+	case models.EmailTypeComment:
+		return fmt.Sprintf("%s commented on \"%s\"", data.CommentAuthor, data.UpdateTitle)
+	case models.EmailTypeReaction:
+		return fmt.Sprintf("%s reacted %s to \"%s\"", data.CommentAuthor, data.Reaction, data.UpdateTitle)
 	default:
 		return fmt.Sprintf("Update from %s", data.ParentNames)
 	}
@@ -0,0 +1,93 @@
+package email
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"simple-go/api/config"
+)
+
+// hrefPattern matches absolute links in rendered HTML emails
+var hrefPattern = regexp.MustCompile(`href="(https?://[^"]+)"`)
+
+// newTrackingID creates the random ID that ties opens and clicks back to one sent email
+func newTrackingID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// addTracking routes the email's links through the click tracker and appends an open pixel.
+// Preference and unsubscribe links are left alone so opting out never depends on tracking.
+func (e *EmailService) addTracking(htmlContent, trackingID string) string {
+	baseURL := strings.TrimSuffix(e.config.BaseURL, "/")
+
+	tracked := hrefPattern.ReplaceAllStringFunc(htmlContent, func(match string) string {
+		target := html.UnescapeString(hrefPattern.FindStringSubmatch(match)[1])
+		if strings.Contains(target, "/preferences/") || strings.Contains(target, "/unsubscribe/") {
+			return match
+		}
+
+		clickURL := fmt.Sprintf("%s/api/email/click/%s?url=%s&sig=%s",
+			baseURL, trackingID, url.QueryEscape(target), SignTrackedLink(trackingID, target))
+		return fmt.Sprintf(`href="%s"`, html.EscapeString(clickURL))
+	})
+
+	pixel := fmt.Sprintf(`<img src="%s/api/email/open/%s" width="1" height="1" alt="" style="display:none;">`, baseURL, trackingID)
+	if i := strings.LastIndex(tracked, "</body>"); i >= 0 {
+		return tracked[:i] + pixel + tracked[i:]
+	}
+	return tracked + pixel
+}
+
+// SignTrackedLink signs a click-tracking redirect so it can only send people to links that
+// were actually in the email
+func SignTrackedLink(trackingID, target string) string {
+	mac := hmac.New(sha256.New, []byte(config.AppConfig.JWTSecret))
+	fmt.Fprintf(mac, "email-click:%s:%s", trackingID, target)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidTrackedLink checks the signature on a click-tracking redirect
+func ValidTrackedLink(trackingID, target, signature string) bool {
+	expected := SignTrackedLink(trackingID, target)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// SignMemberAccess signs a village member's own link to the timeline. The nonce is kept with
+// the member and replaced to turn off the links sent so far.
+func SignMemberAccess(memberID int, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(config.AppConfig.JWTSecret))
+	fmt.Fprintf(mac, "timeline-access:%d:%s", memberID, nonce)
+	return fmt.Sprintf("%d.%s", memberID, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// MemberAccessID reads which member a timeline access token claims to be for, before it's
+// checked with ValidMemberAccess
+func MemberAccessID(token string) (int, bool) {
+	id, _, found := strings.Cut(token, ".")
+	if !found {
+		return 0, false
+	}
+	memberID, err := strconv.Atoi(id)
+	return memberID, err == nil && memberID > 0
+}
+
+// ValidMemberAccess checks a village member's timeline access token
+func ValidMemberAccess(memberID int, nonce, token string) bool {
+	if nonce == "" || token == "" {
+		return false
+	}
+	expected := SignMemberAccess(memberID, nonce)
+	return hmac.Equal([]byte(expected), []byte(token))
+}
@@ -0,0 +1,173 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+)
+
+// Every frame of an animation is cropped to the same portrait size, so the bump stays in
+// place from one week to the next
+const (
+	AnimationWidth  = 480
+	AnimationHeight = 600
+)
+
+// Frame rates an animation can play at, in frames per second
+const (
+	MinAnimationFPS = 1
+	MaxAnimationFPS = 10
+)
+
+// animationHoldFrames is how many frames' time the last photo stays up before the loop restarts
+const animationHoldFrames = 3
+
+// ErrTooFewFrames is returned when there aren't enough photos to animate
+var ErrTooFewFrames = errors.New("an animation needs at least two photos")
+
+// AnimationFrame is a photo to animate and the week of the pregnancy it was taken in, which is
+// written on the frame
+type AnimationFrame struct {
+	Path string
+	Week *int
+}
+
+// Animate writes an animated GIF of the photos in frames, in order, at fps frames per second.
+// Each photo is cropped around its center to the animation's size and labeled with its week.
+// Photos that can't be decoded are skipped.
+func Animate(w io.Writer, frames []AnimationFrame, fps int) (int, error) {
+	if fps < MinAnimationFPS || fps > MaxAnimationFPS {
+		return 0, fmt.Errorf("frame rate must be between %d and %d", MinAnimationFPS, MaxAnimationFPS)
+	}
+
+	// GIF delays are in hundredths of a second
+	delay := 100 / fps
+
+	anim := &gif.GIF{
+		Config: image.Config{
+			ColorModel: color.Palette(palette.Plan9),
+			Width:      AnimationWidth,
+			Height:     AnimationHeight,
+		},
+	}
+	for _, frame := range frames {
+		img, err := decode(frame.Path)
+		if err != nil {
+			continue
+		}
+
+		img = cropToFill(img, AnimationWidth, AnimationHeight)
+		if frame.Week != nil {
+			drawLabel(img, fmt.Sprintf("WEEK %d", *frame.Week))
+		}
+
+		paletted := image.NewPaletted(img.Bounds(), palette.Plan9)
+		draw.FloydSteinberg.Draw(paletted, img.Bounds(), img, image.Point{})
+
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, delay)
+	}
+	if len(anim.Image) < 2 {
+		return 0, ErrTooFewFrames
+	}
+	anim.Delay[len(anim.Delay)-1] = delay * animationHoldFrames
+
+	if err := gif.EncodeAll(w, anim); err != nil {
+		return 0, err
+	}
+	return len(anim.Image), nil
+}
+
+// cropToFill crops img around its center to the aspect ratio of width x height, then scales it
+// to exactly that size
+func cropToFill(img *image.RGBA, width, height int) *image.RGBA {
+	srcWidth, srcHeight := img.Bounds().Dx(), img.Bounds().Dy()
+
+	cropWidth, cropHeight := srcWidth, srcWidth*height/width
+	if cropHeight > srcHeight {
+		cropWidth, cropHeight = srcHeight*width/height, srcHeight
+	}
+	if cropWidth < 1 {
+		cropWidth = 1
+	}
+	if cropHeight < 1 {
+		cropHeight = 1
+	}
+
+	// scale reads Pix from the origin, so the crop is copied rather than taken as a sub-image
+	cropped := image.NewRGBA(image.Rect(0, 0, cropWidth, cropHeight))
+	offset := image.Pt((srcWidth-cropWidth)/2, (srcHeight-cropHeight)/2)
+	draw.Draw(cropped, cropped.Bounds(), img, offset, draw.Src)
+
+	if cropWidth == width && cropHeight == height {
+		return cropped
+	}
+	return scale(cropped, width, height)
+}
+
+// Label text is drawn from a 5x7 pixel font, each pixel labelScale pixels square
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+	labelScale  = 4
+	labelMargin = 16
+	labelPad    = 10
+)
+
+// glyphs has a row of 5 bits for each line of the characters a week label uses
+var glyphs = map[rune][glyphHeight]uint8{
+	'0': {0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	'1': {0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'2': {0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	'3': {0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	'4': {0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	'5': {0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	'6': {0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	'7': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	'9': {0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+	'W': {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A},
+	'E': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F},
+	'K': {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	' ': {},
+}
+
+// drawLabel writes text in white on a dark band in the bottom left corner of img. Characters
+// the font doesn't have are left as blanks.
+func drawLabel(img *image.RGBA, text string) {
+	runes := []rune(text)
+	advance := (glyphWidth + 1) * labelScale
+	textWidth := len(runes)*advance - labelScale
+	textHeight := glyphHeight * labelScale
+
+	bounds := img.Bounds()
+	band := image.Rect(
+		bounds.Min.X+labelMargin,
+		bounds.Max.Y-labelMargin-textHeight-2*labelPad,
+		bounds.Min.X+labelMargin+textWidth+2*labelPad,
+		bounds.Max.Y-labelMargin,
+	)
+	draw.Draw(img, band, image.NewUniform(color.NRGBA{A: 140}), image.Point{}, draw.Over)
+
+	origin := image.Pt(band.Min.X+labelPad, band.Min.Y+labelPad)
+	for i, r := range runes {
+		glyph := glyphs[r]
+		for row, bits := range glyph {
+			for col := 0; col < glyphWidth; col++ {
+				if bits&(1<<(glyphWidth-1-col)) == 0 {
+					continue
+				}
+				pixel := image.Rect(0, 0, labelScale, labelScale).Add(image.Pt(
+					origin.X+i*advance+col*labelScale,
+					origin.Y+row*labelScale,
+				))
+				draw.Draw(img, pixel, image.White, image.Point{}, draw.Src)
+			}
+		}
+	}
+}
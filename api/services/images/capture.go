@@ -0,0 +1,132 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// exifScanLimit is how much of a JPEG is read looking for EXIF, which always comes first
+const exifScanLimit = 256 << 10
+
+// EarliestCaptureTime is the earliest plausible capture time; anything before it is treated as a
+// camera with an unset clock
+var EarliestCaptureTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	exifTagDateTimeOriginal   = 0x9003
+	exifTagOffsetTimeOriginal = 0x9011
+)
+
+// CaptureTime returns when a JPEG photo was taken, read from EXIF DateTimeOriginal. It returns
+// nil if the photo doesn't record a plausible one.
+func CaptureTime(path string) (*time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, exifScanLimit))
+	if err != nil {
+		return nil, err
+	}
+
+	captured := jpegCaptureTime(data)
+	if captured == nil || !PlausibleCaptureTime(*captured) {
+		return nil, nil
+	}
+	utc := captured.UTC()
+	return &utc, nil
+}
+
+// PlausibleCaptureTime checks a capture time isn't from a camera with an unset or wrong clock
+func PlausibleCaptureTime(captured time.Time) bool {
+	return !captured.Before(EarliestCaptureTime) && !captured.After(time.Now().Add(24*time.Hour))
+}
+
+// jpegCaptureTime reads DateTimeOriginal from the EXIF segment at the start of a JPEG
+func jpegCaptureTime(data []byte) *time.Time {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xFF {
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil
+		}
+		payload := data[pos+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(payload, exifHeader) {
+			return exifCaptureTime(payload[len(exifHeader):])
+		}
+		pos = end
+	}
+	return nil
+}
+
+// exifCaptureTime reads DateTimeOriginal, and its time zone offset if there is one, from a
+// TIFF-structured EXIF block. Without an offset the time is taken to be UTC.
+func exifCaptureTime(tiff []byte) *time.Time {
+	if len(tiff) < 8 {
+		return nil
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil
+	}
+
+	ifd0, err := readIFD(tiff, order, order.Uint32(tiff[4:]))
+	if err != nil {
+		return nil
+	}
+
+	var original, offset string
+	for _, entry := range ifd0 {
+		if entry.tag != exifTagExifIFD || len(entry.value) != 4 {
+			continue
+		}
+		exifIFD, err := readIFD(tiff, order, order.Uint32(entry.value))
+		if err != nil {
+			return nil
+		}
+		for _, e := range exifIFD {
+			switch e.tag {
+			case exifTagDateTimeOriginal:
+				original = strings.TrimRight(string(e.value), "\x00 ")
+			case exifTagOffsetTimeOriginal:
+				offset = strings.TrimRight(string(e.value), "\x00 ")
+			}
+		}
+	}
+
+	if original == "" {
+		return nil
+	}
+
+	var captured time.Time
+	if offset != "" {
+		captured, err = time.Parse("2006:01:02 15:04:05-07:00", original+offset)
+	} else {
+		captured, err = time.Parse("2006:01:02 15:04:05", original)
+	}
+	if err != nil {
+		return nil
+	}
+	return &captured
+}
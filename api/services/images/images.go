@@ -0,0 +1,167 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"simple-go/api/models"
+)
+
+// Size is a rendition generated for every processed photo
+type Size struct {
+	Name         string
+	MaxDimension int
+}
+
+// Sizes are generated largest first so each one can be scaled down from the previous
+var Sizes = []Size{
+	{Name: models.RenditionLarge, MaxDimension: 1600},
+	{Name: models.RenditionMedium, MaxDimension: 800},
+	{Name: models.RenditionThumb, MaxDimension: 320},
+}
+
+// jpegQuality is used for every rendition
+const jpegQuality = 82
+
+// maxPixels guards against decompression bombs; phone photos are well under this
+const maxPixels = 100_000_000
+
+// ErrUnsupportedFormat is returned for files that can't be decoded, such as videos or WebP
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+// Result describes a processed photo
+type Result struct {
+	Width      int
+	Height     int
+	Renditions models.PhotoRenditions
+}
+
+// IsProcessable checks if renditions can be generated for a file, judging by its extension
+func IsProcessable(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	}
+	return false
+}
+
+// RenditionFilename returns the filename of a rendition of filename
+func RenditionFilename(filename, size string) string {
+	return fmt.Sprintf("%s_%s.jpg", strings.TrimSuffix(filename, filepath.Ext(filename)), size)
+}
+
+// Process decodes the photo dir/filename and writes its renditions next to it as JPEGs. Sizes
+// the photo is already smaller than are skipped, since the original serves them as well.
+func Process(dir, filename string) (*Result, error) {
+	if !IsProcessable(filename) {
+		return nil, ErrUnsupportedFormat
+	}
+
+	img, err := decode(filepath.Join(dir, filename))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	result := &Result{Width: bounds.Dx(), Height: bounds.Dy()}
+
+	current := img
+	for _, size := range Sizes {
+		width, height := fit(bounds.Dx(), bounds.Dy(), size.MaxDimension)
+		if width == bounds.Dx() && height == bounds.Dy() {
+			continue
+		}
+
+		current = scale(current, width, height)
+
+		renditionFilename := RenditionFilename(filename, size.Name)
+		if err := writeJPEG(filepath.Join(dir, renditionFilename), current); err != nil {
+			RemoveRenditions(dir, filename)
+			return nil, err
+		}
+
+		result.Renditions = append(result.Renditions, models.PhotoRendition{
+			Size:     size.Name,
+			Filename: renditionFilename,
+			Width:    width,
+			Height:   height,
+		})
+	}
+
+	return result, nil
+}
+
+// RemoveRenditions deletes every rendition of filename from dir, ignoring ones that don't exist
+func RemoveRenditions(dir, filename string) error {
+	for _, size := range Sizes {
+		path := filepath.Join(dir, RenditionFilename(filename, size.Name))
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// decode reads an image into RGBA, flattened onto white since renditions are JPEGs
+func decode(path string) (*image.RGBA, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("image is too large (%dx%d)", cfg.Width, cfg.Height)
+	}
+
+	if _, err := file.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, img.Bounds(), src, bounds.Min, draw.Over)
+	return img, nil
+}
+
+func writeJPEG(path string, img image.Image) error {
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := jpeg.Encode(dst, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		dst.Close()
+		os.Remove(path)
+		return err
+	}
+	return dst.Close()
+}
+
+// fit scales width x height down to fit within maxDimension, keeping the aspect ratio
+func fit(width, height, maxDimension int) (int, int) {
+	if width <= maxDimension && height <= maxDimension {
+		return width, height
+	}
+	if width >= height {
+		return maxDimension, (height*maxDimension + width - 1) / width
+	}
+	return (width*maxDimension + height - 1) / height, maxDimension
+}
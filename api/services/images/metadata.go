@@ -0,0 +1,632 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// sanitizedJPEGQuality is used when a JPEG has to be re-encoded to apply its orientation
+const sanitizedJPEGQuality = 92
+
+// EXIF tags that are kept when a photo is sanitized. Everything else, including GPS, camera
+// make and model, serial numbers, software and embedded thumbnails, is dropped.
+var (
+	keptIFD0Tags = map[uint16]bool{
+		0x0132: true, // DateTime
+	}
+	keptExifTags = map[uint16]bool{
+		0x9003: true, // DateTimeOriginal
+		0x9004: true, // DateTimeDigitized
+		0x9010: true, // OffsetTime
+		0x9011: true, // OffsetTimeOriginal
+		0x9012: true, // OffsetTimeDigitized
+		0x9290: true, // SubSecTime
+		0x9291: true, // SubSecTimeOriginal
+		0x9292: true, // SubSecTimeDigitized
+		0xA001: true, // ColorSpace
+	}
+)
+
+// GIF application extensions that change how the image plays or displays: looping and the ICC
+// profile. Comments and other application extensions, such as XMP, are dropped.
+var keptGIFApplications = map[string]bool{
+	"NETSCAPE2.0": true, "ANIMEXTS1.0": true, "ICCRGBG1012": true,
+}
+
+// Ancillary PNG chunks that describe how to display the pixels; text and eXIf chunks are dropped
+var keptPNGChunks = map[string]bool{
+	"IHDR": true, "PLTE": true, "IDAT": true, "IEND": true,
+	"tRNS": true, "gAMA": true, "cHRM": true, "sRGB": true, "iCCP": true, "sBIT": true, "pHYs": true, "bKGD": true,
+}
+
+const (
+	exifTagOrientation = 0x0112
+	exifTagExifIFD     = 0x8769
+)
+
+var (
+	exifHeader   = []byte("Exif\x00\x00")
+	iccHeader    = []byte("ICC_PROFILE\x00")
+	adobeHeader  = []byte("Adobe")
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// errInvalidImage is returned when a file looks like a JPEG, PNG, GIF or WebP but is malformed
+var errInvalidImage = errors.New("malformed image file")
+
+// SanitizeResult describes what Sanitize did to a photo
+type SanitizeResult struct {
+	Size    int64 // size of the file after sanitizing
+	Changed bool  // the file was rewritten
+	Rotated bool  // the pixels were rotated or flipped to apply the EXIF orientation
+}
+
+// Sanitize strips location, device and other identifying metadata from the photo at path,
+// keeping only capture times and color information. A non-default EXIF orientation is applied
+// to the pixels so the photo still displays the right way up. JPEG, PNG, GIF and WebP files
+// are sanitized; other files are left untouched.
+func Sanitize(path string) (*SanitizeResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var sanitized []byte
+	var rotated bool
+	switch {
+	case len(data) > 2 && data[0] == 0xFF && data[1] == 0xD8:
+		sanitized, rotated, err = sanitizeJPEG(data)
+	case bytes.HasPrefix(data, pngSignature):
+		sanitized, rotated, err = sanitizePNG(data)
+	case len(data) > 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		sanitized, err = sanitizeWebP(data)
+	case len(data) > 6 && (string(data[0:6]) == "GIF87a" || string(data[0:6]) == "GIF89a"):
+		sanitized, err = sanitizeGIF(data)
+	default:
+		return &SanitizeResult{Size: int64(len(data))}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Equal(sanitized, data) {
+		return &SanitizeResult{Size: int64(len(data))}, nil
+	}
+
+	if err := replaceFile(path, sanitized); err != nil {
+		return nil, err
+	}
+	return &SanitizeResult{Size: int64(len(sanitized)), Changed: true, Rotated: rotated}, nil
+}
+
+// replaceFile writes data next to path and renames it into place, so a reader never sees a
+// half-written photo
+func replaceFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".sanitize-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// sanitizeJPEG rewrites a JPEG without its metadata segments. Only JFIF, ICC profile and Adobe
+// color segments survive, plus a rebuilt EXIF segment holding the allowlisted tags. Anything
+// after the end of the image, such as the extra images phones append, is dropped.
+func sanitizeJPEG(data []byte) ([]byte, bool, error) {
+	var out bytes.Buffer
+	out.Write(data[:2])
+
+	var keptExif []byte
+	orientation := 1
+	exifWritten := false
+	var iccSegments [][]byte
+
+	pos := 2
+	for pos < len(data) {
+		if data[pos] != 0xFF {
+			return nil, false, errInvalidImage
+		}
+		for pos < len(data) && data[pos] == 0xFF {
+			pos++
+		}
+		if pos >= len(data) {
+			return nil, false, errInvalidImage
+		}
+		marker := data[pos]
+		start := pos - 1
+		pos++
+
+		if marker == 0xD9 { // EOI
+			out.Write([]byte{0xFF, 0xD9})
+			break
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out.Write(data[start:pos])
+			continue
+		}
+
+		if pos+2 > len(data) {
+			return nil, false, errInvalidImage
+		}
+		length := int(binary.BigEndian.Uint16(data[pos:]))
+		end := pos + length
+		if length < 2 || end > len(data) {
+			return nil, false, errInvalidImage
+		}
+		segment := data[start:end]
+		payload := data[pos+2 : end]
+		pos = end
+
+		switch {
+		case marker == 0xE1: // APP1: EXIF or XMP
+			if bytes.HasPrefix(payload, exifHeader) && keptExif == nil {
+				if exif, o, err := sanitizeExif(payload[len(exifHeader):]); err == nil {
+					keptExif, orientation = exif, o
+				}
+			}
+			continue
+		case marker == 0xE2 && bytes.HasPrefix(payload, iccHeader):
+			iccSegments = append(iccSegments, segment)
+		case marker == 0xEE && bytes.HasPrefix(payload, adobeHeader):
+		case marker == 0xE0: // APP0: JFIF
+		case marker >= 0xE1 && marker <= 0xEF, marker == 0xFE: // other APPn segments and comments
+			continue
+		}
+
+		// The rebuilt EXIF segment goes before the first non-JFIF segment
+		if !exifWritten && marker != 0xE0 {
+			writeExifSegment(&out, keptExif)
+			exifWritten = true
+		}
+		out.Write(segment)
+
+		if marker == 0xDA { // SOS: copy the entropy-coded data up to the next marker
+			scanEnd := pos
+			for scanEnd+1 < len(data) {
+				if data[scanEnd] == 0xFF && data[scanEnd+1] != 0x00 && (data[scanEnd+1] < 0xD0 || data[scanEnd+1] > 0xD7) {
+					break
+				}
+				scanEnd++
+			}
+			if scanEnd+1 >= len(data) {
+				// Truncated file with no end marker; keep what there is
+				scanEnd = len(data)
+			}
+			out.Write(data[pos:scanEnd])
+			pos = scanEnd
+		}
+	}
+
+	if orientation == 1 {
+		return out.Bytes(), false, nil
+	}
+
+	// Orientation can't be kept as a tag, so bake it into the pixels
+	img, err := jpeg.Decode(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, orient(img, orientation), &jpeg.Options{Quality: sanitizedJPEGQuality}); err != nil {
+		return nil, false, err
+	}
+
+	var rotated bytes.Buffer
+	rotated.Write(encoded.Bytes()[:2])
+	writeExifSegment(&rotated, keptExif)
+	for _, segment := range iccSegments {
+		rotated.Write(segment)
+	}
+	rotated.Write(encoded.Bytes()[2:])
+	return rotated.Bytes(), true, nil
+}
+
+func writeExifSegment(out *bytes.Buffer, exif []byte) {
+	if exif == nil {
+		return
+	}
+	length := 2 + len(exifHeader) + len(exif)
+	out.Write([]byte{0xFF, 0xE1, byte(length >> 8), byte(length)})
+	out.Write(exifHeader)
+	out.Write(exif)
+}
+
+// sanitizePNG drops every ancillary chunk that isn't about color or transparency, which removes
+// text, XMP and eXIf metadata
+func sanitizePNG(data []byte) ([]byte, bool, error) {
+	var out bytes.Buffer
+	out.Write(pngSignature)
+
+	orientation := 1
+	pos := len(pngSignature)
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, false, errInvalidImage
+		}
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		chunkType := string(data[pos+4 : pos+8])
+		end := pos + 12 + length
+		if end > len(data) {
+			return nil, false, errInvalidImage
+		}
+
+		if chunkType == "eXIf" {
+			if _, o, err := sanitizeExif(data[pos+8 : pos+8+length]); err == nil {
+				orientation = o
+			}
+		}
+		if keptPNGChunks[chunkType] {
+			out.Write(data[pos:end])
+		}
+		pos = end
+
+		if chunkType == "IEND" {
+			break
+		}
+	}
+
+	if orientation == 1 {
+		return out.Bytes(), false, nil
+	}
+
+	img, err := png.Decode(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, orient(img, orientation)); err != nil {
+		return nil, false, err
+	}
+	return encoded.Bytes(), true, nil
+}
+
+// sanitizeWebP drops the EXIF and XMP chunks of a WebP and clears their flags in the VP8X header
+func sanitizeWebP(data []byte) ([]byte, error) {
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+
+	pos := 12
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, errInvalidImage
+		}
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + size + size%2
+		if end > len(data) {
+			return nil, errInvalidImage
+		}
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[pos:end]...)
+			if size > 0 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF and XMP present flags
+			}
+			body.Write(chunk)
+		default:
+			body.Write(data[pos:end])
+		}
+		pos = end
+	}
+
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(body.Len()))
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+// sanitizeGIF rewrites a GIF without its comment and metadata extensions, keeping the frames,
+// their timing and looping. Anything after the trailer is dropped.
+func sanitizeGIF(data []byte) ([]byte, error) {
+	if len(data) < 13 {
+		return nil, errInvalidImage
+	}
+
+	var out bytes.Buffer
+	pos := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 << (flags&0x07 + 1) // global color table
+	}
+	if pos > len(data) {
+		return nil, errInvalidImage
+	}
+	out.Write(data[:pos])
+
+	for pos < len(data) {
+		start := pos
+		switch data[pos] {
+		case 0x3B: // trailer
+			out.WriteByte(0x3B)
+			return out.Bytes(), nil
+
+		case 0x21: // extension
+			if pos+2 > len(data) {
+				return nil, errInvalidImage
+			}
+			label := data[pos+1]
+			end, err := skipGIFSubBlocks(data, pos+2)
+			if err != nil {
+				return nil, err
+			}
+			pos = end
+
+			keep := label == 0xF9 || label == 0x01 // graphic control and plain text
+			if label == 0xFF && start+3 <= len(data) {
+				if size := int(data[start+2]); size == 11 && start+3+size <= len(data) {
+					keep = keptGIFApplications[string(data[start+3:start+3+size])]
+				}
+			}
+			if keep {
+				out.Write(data[start:end])
+			}
+
+		case 0x2C: // image descriptor, then its color table and image data
+			if pos+10 > len(data) {
+				return nil, errInvalidImage
+			}
+			pos += 10
+			if flags := data[pos-1]; flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1) // local color table
+			}
+			pos++ // LZW minimum code size
+			if pos > len(data) {
+				return nil, errInvalidImage
+			}
+			end, err := skipGIFSubBlocks(data, pos)
+			if err != nil {
+				return nil, err
+			}
+			pos = end
+			out.Write(data[start:end])
+
+		default:
+			return nil, errInvalidImage
+		}
+	}
+	return nil, errInvalidImage
+}
+
+// skipGIFSubBlocks returns where the data sub-blocks starting at pos end, after the terminator
+func skipGIFSubBlocks(data []byte, pos int) (int, error) {
+	for {
+		if pos >= len(data) {
+			return 0, errInvalidImage
+		}
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			return pos, nil
+		}
+		pos += size
+	}
+}
+
+// exifEntry is a raw IFD entry copied from the source EXIF block
+type exifEntry struct {
+	tag      uint16
+	dataType uint16
+	count    uint32
+	value    []byte
+}
+
+// exifTypeSizes is the size in bytes of one value of each TIFF data type
+var exifTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// sanitizeExif reads a TIFF-structured EXIF block and returns a new one holding only the
+// allowlisted tags, or nil if none are present, along with the image orientation
+func sanitizeExif(tiff []byte) ([]byte, int, error) {
+	if len(tiff) < 8 {
+		return nil, 1, errInvalidImage
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 1, errInvalidImage
+	}
+
+	ifd0, err := readIFD(tiff, order, order.Uint32(tiff[4:]))
+	if err != nil {
+		return nil, 1, err
+	}
+
+	orientation := 1
+	var keptIFD0, keptExifIFD []exifEntry
+	for _, entry := range ifd0 {
+		switch {
+		case entry.tag == exifTagOrientation && entry.dataType == 3 && entry.count == 1:
+			orientation = int(order.Uint16(entry.value))
+		case entry.tag == exifTagExifIFD && len(entry.value) == 4:
+			exifIFD, err := readIFD(tiff, order, order.Uint32(entry.value))
+			if err != nil {
+				continue
+			}
+			for _, e := range exifIFD {
+				if keptExifTags[e.tag] {
+					keptExifIFD = append(keptExifIFD, e)
+				}
+			}
+		case keptIFD0Tags[entry.tag]:
+			keptIFD0 = append(keptIFD0, entry)
+		}
+	}
+
+	if orientation < 1 || orientation > 8 {
+		orientation = 1
+	}
+
+	if len(keptIFD0) == 0 && len(keptExifIFD) == 0 {
+		return nil, orientation, nil
+	}
+	return writeExif(order, keptIFD0, keptExifIFD), orientation, nil
+}
+
+// readIFD reads the entries of the image file directory at offset
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) ([]exifEntry, error) {
+	if int(offset)+2 > len(tiff) {
+		return nil, errInvalidImage
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	pos := int(offset) + 2
+	if pos+count*12 > len(tiff) {
+		return nil, errInvalidImage
+	}
+
+	entries := make([]exifEntry, 0, count)
+	for i := 0; i < count; i++ {
+		raw := tiff[pos+i*12 : pos+i*12+12]
+		entry := exifEntry{
+			tag:      order.Uint16(raw[0:]),
+			dataType: order.Uint16(raw[2:]),
+			count:    order.Uint32(raw[4:]),
+		}
+
+		typeSize, ok := exifTypeSizes[entry.dataType]
+		if !ok {
+			continue
+		}
+		size := uint64(typeSize) * uint64(entry.count)
+		if size <= 4 {
+			entry.value = raw[8 : 8+size]
+		} else {
+			valueOffset := uint64(order.Uint32(raw[8:]))
+			if valueOffset+size > uint64(len(tiff)) {
+				continue
+			}
+			entry.value = tiff[valueOffset : valueOffset+size]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// writeExif builds a TIFF block with IFD0 and, if there are any Exif tags, an Exif IFD
+func writeExif(order binary.ByteOrder, ifd0, exifIFD []exifEntry) []byte {
+	if len(exifIFD) > 0 {
+		// The pointer's value is filled in once the position of the Exif IFD is known
+		ifd0 = append(ifd0, exifEntry{tag: exifTagExifIFD, dataType: 4, count: 1, value: make([]byte, 4)})
+	}
+
+	var out bytes.Buffer
+	if order == binary.LittleEndian {
+		out.WriteString("II")
+	} else {
+		out.WriteString("MM")
+	}
+	binary.Write(&out, order, uint16(42))
+	binary.Write(&out, order, uint32(8))
+
+	pointer := writeIFD(&out, order, ifd0, exifTagExifIFD)
+	if len(exifIFD) > 0 {
+		order.PutUint32(out.Bytes()[pointer:], uint32(out.Len()))
+		writeIFD(&out, order, exifIFD, 0)
+	}
+	return out.Bytes()
+}
+
+// writeIFD appends an IFD and its out-of-line values to out. It returns where the value of
+// pointerTag was written, so it can be patched afterwards.
+func writeIFD(out *bytes.Buffer, order binary.ByteOrder, entries []exifEntry, pointerTag uint16) int {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+
+	start := out.Len()
+	dataOffset := start + 2 + len(entries)*12 + 4
+	var data bytes.Buffer
+	pointer := 0
+
+	binary.Write(out, order, uint16(len(entries)))
+	for _, entry := range entries {
+		binary.Write(out, order, entry.tag)
+		binary.Write(out, order, entry.dataType)
+		binary.Write(out, order, entry.count)
+
+		if entry.tag == pointerTag {
+			pointer = out.Len()
+		}
+		if len(entry.value) <= 4 {
+			value := make([]byte, 4)
+			copy(value, entry.value)
+			out.Write(value)
+			continue
+		}
+
+		binary.Write(out, order, uint32(dataOffset+data.Len()))
+		data.Write(entry.value)
+		if data.Len()%2 == 1 {
+			data.WriteByte(0)
+		}
+	}
+	binary.Write(out, order, uint32(0)) // no next IFD
+	out.Write(data.Bytes())
+	return pointer
+}
+
+// orient rotates and flips img so it displays correctly without its EXIF orientation tag
+func orient(img image.Image, orientation int) image.Image {
+	bounds := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		dstWidth, dstHeight = height, width
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored
+				sx, sy = width-1-x, y
+			case 3: // rotated 180°
+				sx, sy = width-1-x, height-1-y
+			case 4: // mirrored vertically
+				sx, sy = x, height-1-y
+			case 5: // mirrored and rotated 270° clockwise
+				sx, sy = y, x
+			case 6: // rotated 90° clockwise
+				sx, sy = y, height-1-x
+			case 7: // mirrored and rotated 90° clockwise
+				sx, sy = width-1-y, height-1-x
+			case 8: // rotated 270° clockwise
+				sx, sy = width-1-y, x
+			default:
+				sx, sy = x, y
+			}
+			copy(dst.Pix[y*dst.Stride+x*4:y*dst.Stride+x*4+4], src.Pix[sy*src.Stride+sx*4:])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,282 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testImage is a 4x2 image, so a rotation shows up in its dimensions
+func testImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 2; y++ {
+			img.Set(x, y, color.RGBA{uint8(x * 60), uint8(y * 120), 0, 255})
+		}
+	}
+	return img
+}
+
+// testExif builds a little-endian EXIF block with an orientation, a DateTime and a GPS IFD
+// holding a map datum of "SECRETLOC"
+func testExif(orientation uint16) []byte {
+	var tiff bytes.Buffer
+	order := binary.LittleEndian
+	tiff.WriteString("II")
+	binary.Write(&tiff, order, uint16(42))
+	binary.Write(&tiff, order, uint32(8))
+
+	// IFD0 at 8: three entries, then the next IFD offset
+	ifd0End := uint32(8 + 2 + 3*12 + 4)
+	dateTime := []byte("2024:05:01 10:00:00\x00")
+	gpsIFD := ifd0End + uint32(len(dateTime))
+	entry := func(tag, dataType uint16, count, value uint32) {
+		binary.Write(&tiff, order, tag)
+		binary.Write(&tiff, order, dataType)
+		binary.Write(&tiff, order, count)
+		binary.Write(&tiff, order, value)
+	}
+	binary.Write(&tiff, order, uint16(3))
+	entry(0x0112, 3, 1, uint32(orientation))
+	entry(0x0132, 2, uint32(len(dateTime)), ifd0End)
+	entry(0x8825, 4, 1, gpsIFD)
+	binary.Write(&tiff, order, uint32(0))
+	tiff.Write(dateTime)
+
+	// GPS IFD: GPSMapDatum, stored after the IFD
+	datum := []byte("SECRETLOC\x00")
+	binary.Write(&tiff, order, uint16(1))
+	entry(0x0012, 2, uint32(len(datum)), gpsIFD+2+12+4)
+	binary.Write(&tiff, order, uint32(0))
+	tiff.Write(datum)
+
+	return append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+}
+
+// testJPEG encodes testImage with the given APP1 payload inserted after SOI and extra appended
+func testJPEG(t *testing.T, app1, extra []byte) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, testImage(), &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+
+	var out bytes.Buffer
+	out.Write(encoded.Bytes()[:2])
+	if app1 != nil {
+		out.Write([]byte{0xFF, 0xE1, byte((len(app1) + 2) >> 8), byte(len(app1) + 2)})
+		out.Write(app1)
+	}
+	out.Write(encoded.Bytes()[2:])
+	out.Write(extra)
+	return out.Bytes()
+}
+
+// testPNG encodes testImage with a tEXt chunk inserted before IEND
+func testPNG(t *testing.T, text string) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, testImage()); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	data := encoded.Bytes()
+	iend := len(data) - 12
+
+	var chunk bytes.Buffer
+	binary.Write(&chunk, binary.BigEndian, uint32(len(text)))
+	chunk.WriteString("tEXt")
+	chunk.WriteString(text)
+	binary.Write(&chunk, binary.BigEndian, crc32.ChecksumIEEE(chunk.Bytes()[4:]))
+
+	return append(append(append([]byte(nil), data[:iend]...), chunk.Bytes()...), data[iend:]...)
+}
+
+// testGIF encodes a two-frame looping GIF with a comment and an XMP extension before the first
+// frame, and extra data after the trailer
+func testGIF(t *testing.T, extra []byte) []byte {
+	t.Helper()
+	palette := color.Palette{color.Black, color.White}
+	frame := image.NewPaletted(image.Rect(0, 0, 4, 2), palette)
+	var encoded bytes.Buffer
+	err := gif.EncodeAll(&encoded, &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{10, 10}})
+	if err != nil {
+		t.Fatalf("Failed to encode GIF: %v", err)
+	}
+	data := encoded.Bytes()
+
+	// The encoder gives each frame a local color table, so the metadata goes right after the
+	// header and screen descriptor
+	headerEnd := 13
+	var metadata bytes.Buffer
+	metadata.Write([]byte{0x21, 0xFE, 9})
+	metadata.WriteString("SECRETCOM")
+	metadata.WriteByte(0)
+	metadata.Write([]byte{0x21, 0xFF, 11})
+	metadata.WriteString("XMP DataXMP")
+	metadata.WriteByte(12)
+	metadata.WriteString("SECRETLOC 51")
+	metadata.WriteByte(0)
+
+	out := append([]byte(nil), data[:headerEnd]...)
+	out = append(out, metadata.Bytes()...)
+	out = append(out, data[headerEnd:]...)
+	return append(out, extra...)
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          []byte
+		changed       bool
+		rotated       bool
+		width, height int
+		stripped      []string
+		kept          []string
+	}{
+		{
+			name:     "JPEG GPS is dropped and capture time kept",
+			data:     testJPEG(t, testExif(1), nil),
+			changed:  true,
+			width:    4,
+			height:   2,
+			stripped: []string{"SECRETLOC"},
+			kept:     []string{"2024:05:01 10:00:00"},
+		},
+		{
+			name:     "JPEG trailing data is dropped",
+			data:     testJPEG(t, nil, []byte("SECRETTRAILER")),
+			changed:  true,
+			width:    4,
+			height:   2,
+			stripped: []string{"SECRETTRAILER"},
+		},
+		{
+			name:     "JPEG orientation is applied to the pixels",
+			data:     testJPEG(t, testExif(6), nil),
+			changed:  true,
+			rotated:  true,
+			width:    2,
+			height:   4,
+			stripped: []string{"SECRETLOC"},
+			kept:     []string{"2024:05:01 10:00:00"},
+		},
+		{
+			name:    "plain JPEG is left alone",
+			data:    testJPEG(t, nil, nil),
+			changed: false,
+			width:   4,
+			height:  2,
+		},
+		{
+			name:     "PNG text is dropped",
+			data:     testPNG(t, "Comment\x00SECRETLOC"),
+			changed:  true,
+			width:    4,
+			height:   2,
+			stripped: []string{"SECRETLOC"},
+		},
+		{
+			name:     "GIF comments, XMP and trailing data are dropped",
+			data:     testGIF(t, []byte("SECRETTRAILER")),
+			changed:  true,
+			width:    4,
+			height:   2,
+			stripped: []string{"SECRETCOM", "SECRETLOC", "SECRETTRAILER"},
+			kept:     []string{"NETSCAPE2.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "photo")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatalf("Failed to write photo: %v", err)
+			}
+
+			result, err := Sanitize(path)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if result.Changed != tt.changed {
+				t.Errorf("Expected changed %v, got %v", tt.changed, result.Changed)
+			}
+			if result.Rotated != tt.rotated {
+				t.Errorf("Expected rotated %v, got %v", tt.rotated, result.Rotated)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read photo: %v", err)
+			}
+			if result.Size != int64(len(data)) {
+				t.Errorf("Expected size %d, got %d", len(data), result.Size)
+			}
+			for _, s := range tt.stripped {
+				if bytes.Contains(data, []byte(s)) {
+					t.Errorf("Expected %q to be stripped", s)
+				}
+			}
+			for _, s := range tt.kept {
+				if !bytes.Contains(data, []byte(s)) {
+					t.Errorf("Expected %q to be kept", s)
+				}
+			}
+
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Expected a decodable image, got %v", err)
+			}
+			if cfg.Width != tt.width || cfg.Height != tt.height {
+				t.Errorf("Expected %dx%d, got %dx%d", tt.width, tt.height, cfg.Width, cfg.Height)
+			}
+		})
+	}
+}
+
+func TestSanitizeGIFKeepsFrames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "animation.gif")
+	if err := os.WriteFile(path, testGIF(t, nil), 0644); err != nil {
+		t.Fatalf("Failed to write GIF: %v", err)
+	}
+
+	if _, err := Sanitize(path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open GIF: %v", err)
+	}
+	defer file.Close()
+
+	decoded, err := gif.DecodeAll(file)
+	if err != nil {
+		t.Fatalf("Expected a decodable GIF, got %v", err)
+	}
+	if len(decoded.Image) != 2 {
+		t.Errorf("Expected 2 frames, got %d", len(decoded.Image))
+	}
+	if decoded.LoopCount != 0 {
+		t.Errorf("Expected loop count 0, got %d", decoded.LoopCount)
+	}
+}
+
+func TestSanitizeRejectsMalformedGIF(t *testing.T) {
+	data := testGIF(t, nil)
+	path := filepath.Join(t.TempDir(), "broken.gif")
+	if err := os.WriteFile(path, data[:len(data)-8], 0644); err != nil {
+		t.Fatalf("Failed to write GIF: %v", err)
+	}
+
+	if _, err := Sanitize(path); err == nil {
+		t.Error("Expected an error for a truncated GIF")
+	}
+}
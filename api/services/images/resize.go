@@ -0,0 +1,87 @@
+package images
+
+import (
+	"image"
+	"math"
+)
+
+// contribution is one source pixel's share of a destination pixel
+type contribution struct {
+	index  int
+	weight float32
+}
+
+// scale shrinks src to width x height by averaging the area of source pixels each destination
+// pixel covers. It is separable, so rows are scaled first and then columns.
+func scale(src *image.RGBA, width, height int) *image.RGBA {
+	srcWidth, srcHeight := src.Bounds().Dx(), src.Bounds().Dy()
+
+	horizontal := image.NewRGBA(image.Rect(0, 0, width, srcHeight))
+	columns := weights(srcWidth, width)
+	for y := 0; y < srcHeight; y++ {
+		srcRow := src.Pix[y*src.Stride:]
+		dstRow := horizontal.Pix[y*horizontal.Stride:]
+		for x, contributions := range columns {
+			accumulate(dstRow[x*4:], srcRow, contributions, 4)
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	rows := weights(srcHeight, height)
+	for y, contributions := range rows {
+		dstRow := dst.Pix[y*dst.Stride:]
+		for x := 0; x < width; x++ {
+			accumulate(dstRow[x*4:], horizontal.Pix[x*4:], contributions, horizontal.Stride)
+		}
+	}
+
+	return dst
+}
+
+// weights works out which source pixels make up each of dstSize destination pixels
+func weights(srcSize, dstSize int) [][]contribution {
+	ratio := float64(srcSize) / float64(dstSize)
+	table := make([][]contribution, dstSize)
+
+	for i := range table {
+		start := float64(i) * ratio
+		end := start + ratio
+		first := int(math.Floor(start))
+		last := int(math.Min(math.Ceil(end), float64(srcSize)))
+
+		for j := first; j < last; j++ {
+			coverage := math.Min(end, float64(j+1)) - math.Max(start, float64(j))
+			if coverage > 0 {
+				table[i] = append(table[i], contribution{index: j, weight: float32(coverage / ratio)})
+			}
+		}
+	}
+
+	return table
+}
+
+// accumulate writes the weighted average of the RGBA pixels in src, stride bytes apart, to dst
+func accumulate(dst, src []uint8, contributions []contribution, stride int) {
+	var r, g, b, a float32
+	for _, c := range contributions {
+		offset := c.index * stride
+		r += float32(src[offset]) * c.weight
+		g += float32(src[offset+1]) * c.weight
+		b += float32(src[offset+2]) * c.weight
+		a += float32(src[offset+3]) * c.weight
+	}
+	dst[0] = clamp(r)
+	dst[1] = clamp(g)
+	dst[2] = clamp(b)
+	dst[3] = clamp(a)
+}
+
+func clamp(v float32) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
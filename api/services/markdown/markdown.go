@@ -0,0 +1,393 @@
+// Package markdown renders the small subset of Markdown that update content can be formatted
+// with: emphasis, bulleted and numbered lists, links and line breaks. Anything else is kept as
+// text, so the HTML it produces is safe to insert into a page or email as it is.
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Kinds of block
+const (
+	blockParagraph = iota
+	blockUnordered
+	blockOrdered
+)
+
+// Kinds of inline element
+const (
+	inlineText = iota
+	inlineEmphasis
+	inlineStrong
+	inlineLink
+	inlineBreak
+)
+
+// block is a paragraph or a list. A paragraph has a single item.
+type block struct {
+	kind  int
+	start int // number of the first item of an ordered list
+	items [][]inline
+}
+
+type inline struct {
+	kind     int
+	text     string
+	href     string
+	children []inline
+}
+
+// safeSchemes are the link schemes that are kept; links to anything else become plain text
+var safeSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// escapable are the characters a backslash keeps from being read as formatting
+const escapable = "\\`*_[]()#+-.!"
+
+// maxLinkLength is the longest a [text](url) link can be. Scanning for the end of a link stops
+// there, or at the end of the line, so text full of brackets is parsed in linear time.
+const maxLinkLength = 2048
+
+// ToHTML renders Markdown as HTML. Every line break is kept, the way it was typed, and all
+// text is escaped.
+func ToHTML(src string) string {
+	var b strings.Builder
+	for _, blk := range parseBlocks(src) {
+		switch blk.kind {
+		case blockParagraph:
+			b.WriteString("<p>")
+			writeHTML(&b, blk.items[0])
+			b.WriteString("</p>\n")
+		case blockUnordered, blockOrdered:
+			tag := "ul"
+			if blk.kind == blockOrdered {
+				tag = "ol"
+			}
+			if blk.kind == blockOrdered && blk.start != 1 {
+				fmt.Fprintf(&b, "<ol start=\"%d\">\n", blk.start)
+			} else {
+				fmt.Fprintf(&b, "<%s>\n", tag)
+			}
+			for _, item := range blk.items {
+				b.WriteString("<li>")
+				writeHTML(&b, item)
+				b.WriteString("</li>\n")
+			}
+			fmt.Fprintf(&b, "</%s>\n", tag)
+		}
+	}
+	return b.String()
+}
+
+// ToText renders Markdown as plain text for text emails: formatting marks are dropped, list
+// items are bulleted or numbered, and links are followed by their URL
+func ToText(src string) string {
+	var paragraphs []string
+	for _, blk := range parseBlocks(src) {
+		var b strings.Builder
+		for i, item := range blk.items {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			switch blk.kind {
+			case blockParagraph:
+				writeText(&b, item, "")
+			case blockUnordered:
+				b.WriteString("- ")
+				writeText(&b, item, "  ")
+			case blockOrdered:
+				marker := fmt.Sprintf("%d. ", blk.start+i)
+				b.WriteString(marker)
+				writeText(&b, item, strings.Repeat(" ", len(marker)))
+			}
+		}
+		paragraphs = append(paragraphs, b.String())
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+func writeHTML(b *strings.Builder, nodes []inline) {
+	for _, node := range nodes {
+		switch node.kind {
+		case inlineText:
+			b.WriteString(html.EscapeString(node.text))
+		case inlineEmphasis:
+			b.WriteString("<em>")
+			writeHTML(b, node.children)
+			b.WriteString("</em>")
+		case inlineStrong:
+			b.WriteString("<strong>")
+			writeHTML(b, node.children)
+			b.WriteString("</strong>")
+		case inlineLink:
+			fmt.Fprintf(b, "<a href=\"%s\" target=\"_blank\" rel=\"noopener noreferrer nofollow\">", html.EscapeString(node.href))
+			writeHTML(b, node.children)
+			b.WriteString("</a>")
+		case inlineBreak:
+			b.WriteString("<br>\n")
+		}
+	}
+}
+
+// writeText writes inline elements as plain text, indenting the lines after a break
+func writeText(b *strings.Builder, nodes []inline, indent string) {
+	for _, node := range nodes {
+		switch node.kind {
+		case inlineText:
+			b.WriteString(node.text)
+		case inlineEmphasis, inlineStrong:
+			writeText(b, node.children, indent)
+		case inlineLink:
+			var label strings.Builder
+			writeText(&label, node.children, indent)
+			b.WriteString(label.String())
+			if target := strings.TrimPrefix(node.href, "mailto:"); target != label.String() {
+				fmt.Fprintf(b, " (%s)", target)
+			}
+		case inlineBreak:
+			b.WriteString("\n" + indent)
+		}
+	}
+}
+
+// parseBlocks splits src into paragraphs and lists. A blank line ends a paragraph, and a list
+// item carries on over indented lines.
+func parseBlocks(src string) []block {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+
+	var blocks []block
+	var lines []string
+	current := -1
+	blank := false
+
+	endItem := func() {
+		if current >= 0 && lines != nil {
+			blocks[current].items = append(blocks[current].items, parseInline(strings.Join(lines, "\n"), true))
+		}
+		lines = nil
+	}
+	startBlock := func(kind, start int) {
+		endItem()
+		blocks = append(blocks, block{kind: kind, start: start})
+		current = len(blocks) - 1
+	}
+
+	for _, line := range strings.Split(src, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			endItem()
+			blank = true
+			continue
+		}
+
+		if kind, start, text, ok := parseListItem(line); ok {
+			if current < 0 || blocks[current].kind != kind {
+				startBlock(kind, start)
+			} else {
+				endItem()
+			}
+			lines = []string{text}
+		} else if current >= 0 && !blank && lines != nil && (blocks[current].kind == blockParagraph || line[0] == ' ' || line[0] == '\t') {
+			lines = append(lines, trimmed)
+		} else {
+			startBlock(blockParagraph, 0)
+			lines = []string{trimmed}
+		}
+		blank = false
+	}
+	endItem()
+
+	return blocks
+}
+
+// parseListItem reports whether line starts a list item, returning the kind of list, the
+// number of an ordered item and the item's text
+func parseListItem(line string) (int, int, string, bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 2 {
+		return 0, 0, "", false
+	}
+
+	if strings.IndexByte("-*+", trimmed[0]) >= 0 && isSpace(trimmed[1]) {
+		return blockUnordered, 0, strings.TrimSpace(trimmed[2:]), true
+	}
+
+	digits := 0
+	for digits < len(trimmed) && digits < 9 && trimmed[digits] >= '0' && trimmed[digits] <= '9' {
+		digits++
+	}
+	if digits > 0 && len(trimmed) > digits+1 && (trimmed[digits] == '.' || trimmed[digits] == ')') && isSpace(trimmed[digits+1]) {
+		start, _ := strconv.Atoi(trimmed[:digits])
+		return blockOrdered, start, strings.TrimSpace(trimmed[digits+2:]), true
+	}
+	return 0, 0, "", false
+}
+
+// parseInline parses emphasis, links and line breaks. Links aren't parsed inside a link's text.
+func parseInline(s string, links bool) []inline {
+	var nodes []inline
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, inline{kind: inlineText, text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(escapable, s[i+1]) >= 0:
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+		case c == '\n':
+			flush()
+			nodes = append(nodes, inline{kind: inlineBreak})
+			i++
+			continue
+		case c == '[' && links:
+			if label, href, n, ok := parseLink(s[i:]); ok {
+				flush()
+				children := parseInline(label, false)
+				if isSafeURL(href) {
+					nodes = append(nodes, inline{kind: inlineLink, href: href, children: children})
+				} else {
+					nodes = append(nodes, children...)
+				}
+				i += n
+				continue
+			}
+		case c == '*' || c == '_':
+			if kind, inner, n, ok := parseEmphasis(s, i); ok {
+				flush()
+				nodes = append(nodes, inline{kind: kind, children: parseInline(inner, links)})
+				i += n
+				continue
+			}
+		}
+		text.WriteByte(c)
+		i++
+	}
+	flush()
+
+	return nodes
+}
+
+// parseLink parses a [text](url) link at the start of s, returning its text, its URL and its
+// length. A link is on a single line and at most maxLinkLength long.
+func parseLink(s string) (string, string, int, bool) {
+	if len(s) > maxLinkLength {
+		s = s[:maxLinkLength]
+	}
+	if end := strings.IndexByte(s, '\n'); end >= 0 {
+		s = s[:end]
+	}
+
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			if i+1 >= len(s) || s[i+1] != '(' {
+				return "", "", 0, false
+			}
+			end := closingParen(s[i+2:])
+			if end < 0 {
+				return "", "", 0, false
+			}
+			href := strings.TrimSpace(s[i+2 : i+2+end])
+			if href == "" || strings.ContainsAny(href, " \t\n") {
+				return "", "", 0, false
+			}
+			return s[1:i], href, i + 3 + end, true
+		}
+	}
+	return "", "", 0, false
+}
+
+// closingParen returns the index of the ")" that closes a link's URL, allowing for balanced
+// parentheses inside it, or -1 if there isn't one
+func closingParen(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// parseEmphasis parses *emphasis*, _emphasis_, **strong** or __strong__ starting at s[i],
+// returning its kind, the text inside and its length. Underscores inside words, as in
+// snake_case, are left alone.
+func parseEmphasis(s string, i int) (int, string, int, bool) {
+	c := s[i]
+	kind, width := inlineEmphasis, 1
+	if i+1 < len(s) && s[i+1] == c {
+		kind, width = inlineStrong, 2
+	}
+	delimiter := s[i : i+width]
+
+	open := i + width
+	if open >= len(s) || isSpace(s[open]) || (c == '_' && i > 0 && isWordByte(s[i-1])) {
+		return 0, "", 0, false
+	}
+
+	for j := open + 1; j+width <= len(s); j++ {
+		if s[j] == '\\' {
+			j++
+			continue
+		}
+		if s[j] != c {
+			continue
+		}
+		// A single delimiter skips over a pair, which is strong text nested inside
+		if width == 1 && j+1 < len(s) && s[j+1] == c {
+			j++
+			continue
+		}
+		if s[j:j+width] != delimiter || isSpace(s[j-1]) {
+			continue
+		}
+		if c == '_' && j+width < len(s) && isWordByte(s[j+width]) {
+			continue
+		}
+		return kind, s[open:j], j + width - i, true
+	}
+	return 0, "", 0, false
+}
+
+// isSafeURL reports whether a link's URL is absolute and uses a scheme that can't run script
+func isSafeURL(href string) bool {
+	u, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+	return safeSchemes[strings.ToLower(u.Scheme)] && (u.Host != "" || u.Opaque != "")
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+// isWordByte reports whether c is part of a word. Bytes of multibyte characters count as letters.
+func isWordByte(c byte) bool {
+	return c >= 0x80 || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
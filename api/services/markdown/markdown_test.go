@@ -0,0 +1,133 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{
+			name:     "plain text is escaped",
+			src:      `<script>alert("hi")</script> & more`,
+			expected: "<p>&lt;script&gt;alert(&#34;hi&#34;)&lt;/script&gt; &amp; more</p>\n",
+		},
+		{
+			name:     "emphasis and strong",
+			src:      "*so* **very** _happy_ __today__",
+			expected: "<p><em>so</em> <strong>very</strong> <em>happy</em> <strong>today</strong></p>\n",
+		},
+		{
+			name:     "underscores inside words",
+			src:      "snake_case_name",
+			expected: "<p>snake_case_name</p>\n",
+		},
+		{
+			name:     "escaped formatting",
+			src:      `\*not emphasis\*`,
+			expected: "<p>*not emphasis*</p>\n",
+		},
+		{
+			name:     "line breaks and paragraphs",
+			src:      "one\ntwo\n\nthree",
+			expected: "<p>one<br>\ntwo</p>\n<p>three</p>\n",
+		},
+		{
+			name:     "bulleted list",
+			src:      "- crib\n- stroller",
+			expected: "<ul>\n<li>crib</li>\n<li>stroller</li>\n</ul>\n",
+		},
+		{
+			name:     "numbered list starting later",
+			src:      "3. third\n4. fourth",
+			expected: "<ol start=\"3\">\n<li>third</li>\n<li>fourth</li>\n</ol>\n",
+		},
+		{
+			name:     "link",
+			src:      "[registry](https://example.com/a_(b))",
+			expected: "<p><a href=\"https://example.com/a_(b)\" target=\"_blank\" rel=\"noopener noreferrer nofollow\">registry</a></p>\n",
+		},
+		{
+			name:     "link with formatted text",
+			src:      "[**big** news](https://example.com)",
+			expected: "<p><a href=\"https://example.com\" target=\"_blank\" rel=\"noopener noreferrer nofollow\"><strong>big</strong> news</a></p>\n",
+		},
+		{
+			name:     "unsafe link is kept as text",
+			src:      "[click](javascript:alert(1))",
+			expected: "<p>click</p>\n",
+		},
+		{
+			name:     "link URL is escaped",
+			src:      `[x](https://example.com/?a="b"&c)`,
+			expected: "<p><a href=\"https://example.com/?a=&#34;b&#34;&amp;c\" target=\"_blank\" rel=\"noopener noreferrer nofollow\">x</a></p>\n",
+		},
+		{
+			name:     "link across lines is text",
+			src:      "[two\nlines](https://example.com)",
+			expected: "<p>[two<br>\nlines](https://example.com)</p>\n",
+		},
+		{
+			name:     "link longer than the limit is text",
+			src:      "[" + strings.Repeat("a", maxLinkLength) + "](https://example.com)",
+			expected: "<p>[" + strings.Repeat("a", maxLinkLength) + "](https://example.com)</p>\n",
+		},
+		{
+			name:     "unclosed brackets",
+			src:      strings.Repeat("[", 5) + "text",
+			expected: "<p>[[[[[text</p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := ToHTML(tt.src); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestToHTMLManyBrackets(t *testing.T) {
+	// Each bracket only looks as far as the end of its line or maxLinkLength, so this finishes
+	// quickly rather than scanning the rest of the text for every bracket
+	src := strings.Repeat("[](", 5000) + "\n" + strings.Repeat("[", 5000)
+	result := ToHTML(src)
+	if !strings.HasPrefix(result, "<p>[](") || !strings.HasSuffix(result, "[[[</p>\n") {
+		t.Errorf("Expected the brackets kept as text, got %q...", result[:20])
+	}
+}
+
+func TestIsSafeURL(t *testing.T) {
+	tests := []struct {
+		href     string
+		expected bool
+	}{
+		{"https://example.com", true},
+		{"http://example.com/path?q=1", true},
+		{"HTTPS://EXAMPLE.COM", true},
+		{"mailto:grandma@example.com", true},
+		{"javascript:alert(1)", false},
+		{"JavaScript:alert(1)", false},
+		{"data:text/html;base64,PHNjcmlwdD4=", false},
+		{"vbscript:msgbox", false},
+		{"/relative/path", false},
+		{"//example.com", false},
+		{"example.com", false},
+		{"https://", false},
+		{"mailto:", false},
+		{"http://[::1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.href, func(t *testing.T) {
+			if result := isSafeURL(tt.href); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
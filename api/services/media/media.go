@@ -0,0 +1,182 @@
+package media
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"simple-go/api/models"
+	"simple-go/api/services/images"
+)
+
+// sniffLength is how much of a file is read to work out its type
+const sniffLength = 512
+
+// ErrUnsupportedType is returned for files that aren't a photo or video type the app accepts
+var ErrUnsupportedType = errors.New("unsupported media type")
+
+// kinds maps every accepted MIME type to the kind of media it is
+var kinds = map[string]string{
+	"image/jpeg":      models.MediaKindPhoto,
+	"image/png":       models.MediaKindPhoto,
+	"image/gif":       models.MediaKindPhoto,
+	"image/webp":      models.MediaKindPhoto,
+	"video/mp4":       models.MediaKindVideo,
+	"video/quicktime": models.MediaKindVideo,
+}
+
+// extensions is the file extension uploads of each MIME type are stored with
+var extensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"video/mp4":       ".mp4",
+	"video/quicktime": ".mov",
+}
+
+// Info describes a stored photo or video
+type Info struct {
+	Kind       string
+	MIMEType   string
+	Size       int64
+	Checksum   string
+	Width      *int
+	Height     *int
+	Duration   *float64
+	CapturedAt *time.Time
+}
+
+// DetectType works out the MIME type of a file from its first bytes, ignoring its name. It
+// returns ErrUnsupportedType unless the file is one of the accepted photo or video types.
+func DetectType(header []byte) (string, string, error) {
+	mimeType := http.DetectContentType(header)
+
+	// DetectContentType only knows MP4 by a few brands, and not QuickTime at all. HEIC and AVIF
+	// photos share the format but aren't accepted, since browsers can't show most of them.
+	if len(header) >= 12 && string(header[4:8]) == "ftyp" {
+		switch string(header[8:12]) {
+		case "qt  ":
+			mimeType = "video/quicktime"
+		case "heic", "heix", "mif1", "msf1", "avif", "avis":
+			return "", "", ErrUnsupportedType
+		default:
+			mimeType = "video/mp4"
+		}
+	}
+
+	kind, ok := kinds[mimeType]
+	if !ok {
+		return "", "", ErrUnsupportedType
+	}
+	return mimeType, kind, nil
+}
+
+// DetectFileType works out the MIME type of an upload, leaving r positioned at the start
+func DetectFileType(r io.ReadSeeker) (string, string, error) {
+	header := make([]byte, sniffLength)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", "", err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+	return DetectType(header[:n])
+}
+
+// Extension returns the file extension media of mimeType is stored with
+func Extension(mimeType string) string {
+	return extensions[mimeType]
+}
+
+// Probe reads the type, size, checksum, dimensions, duration and capture time of a stored
+// photo or video. Details the file doesn't record are left nil.
+func Probe(path string) (*Info, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	mimeType, kind, err := DetectFileType(file)
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{Kind: kind, MIMEType: mimeType}
+
+	hash := sha256.New()
+	info.Size, err = io.Copy(hash, file)
+	if err != nil {
+		return nil, err
+	}
+	info.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	if kind == models.MediaKindVideo {
+		video, err := probeVideo(file)
+		if err != nil {
+			return nil, err
+		}
+		info.Width, info.Height, info.Duration = video.width, video.height, video.duration
+		if video.created != nil && images.PlausibleCaptureTime(*video.created) {
+			info.CapturedAt = video.created
+		}
+		return info, nil
+	}
+
+	if cfg, _, err := image.DecodeConfig(file); err == nil {
+		info.Width, info.Height = &cfg.Width, &cfg.Height
+	} else if mimeType == "image/webp" {
+		info.Width, info.Height = webpDimensions(file)
+	}
+
+	if mimeType == "image/jpeg" {
+		info.CapturedAt, err = images.CaptureTime(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// webpDimensions reads the canvas size from the header of a WebP, which the standard library
+// can't decode
+func webpDimensions(file io.ReadSeeker) (*int, *int) {
+	header := make([]byte, 30)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, nil
+	}
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, nil
+	}
+
+	var width, height int
+	switch {
+	case bytes.Equal(header[12:16], []byte("VP8X")):
+		width = 1 + (int(header[24]) | int(header[25])<<8 | int(header[26])<<16)
+		height = 1 + (int(header[27]) | int(header[28])<<8 | int(header[29])<<16)
+	case bytes.Equal(header[12:16], []byte("VP8 ")):
+		width = int(header[26]) | int(header[27]&0x3F)<<8
+		height = int(header[28]) | int(header[29]&0x3F)<<8
+	case bytes.Equal(header[12:16], []byte("VP8L")):
+		bits := uint32(header[21]) | uint32(header[22])<<8 | uint32(header[23])<<16 | uint32(header[24])<<24
+		width = int(bits&0x3FFF) + 1
+		height = int(bits>>14&0x3FFF) + 1
+	default:
+		return nil, nil
+	}
+	return &width, &height
+}
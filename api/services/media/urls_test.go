@@ -0,0 +1,143 @@
+package media
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"simple-go/api/config"
+)
+
+// useTestSecret signs with a known secret for the rest of the test
+func useTestSecret(t *testing.T) {
+	previous := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = previous })
+	config.AppConfig = &config.Config{JWTSecret: "test-secret"}
+}
+
+func TestSignAndVerifyURL(t *testing.T) {
+	useTestSecret(t)
+	const path = "/images/1/5_photo.jpg"
+
+	signed := func(path, scope string) url.Values {
+		parsed, err := url.Parse(SignURL(path, scope, URLLifetime))
+		if err != nil {
+			t.Fatalf("Failed to parse signed URL: %v", err)
+		}
+		return parsed.Query()
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		query  func() url.Values
+		scope  string
+		expect error
+	}{
+		{
+			name:  "shared URL",
+			path:  path,
+			query: func() url.Values { return signed(path, ScopeShared) },
+			scope: ScopeShared,
+		},
+		{
+			name:  "private URL",
+			path:  path,
+			query: func() url.Values { return signed(path, ScopePrivate) },
+			scope: ScopePrivate,
+		},
+		{
+			name:   "tampered path",
+			path:   "/images/1/6_photo.jpg",
+			query:  func() url.Values { return signed(path, ScopeShared) },
+			expect: ErrInvalidSignature,
+		},
+		{
+			name: "shared URL relabelled as private",
+			path: path,
+			query: func() url.Values {
+				query := signed(path, ScopeShared)
+				query.Set("scope", ScopePrivate)
+				return query
+			},
+			expect: ErrInvalidSignature,
+		},
+		{
+			name: "unknown scope",
+			path: path,
+			query: func() url.Values {
+				query := signed(path, ScopeShared)
+				query.Set("scope", "admin")
+				return query
+			},
+			expect: ErrInvalidSignature,
+		},
+		{
+			name: "extended expiry",
+			path: path,
+			query: func() url.Values {
+				query := signed(path, ScopeShared)
+				query.Set("expires", strconv.FormatInt(time.Now().Add(10*365*24*time.Hour).Unix(), 10))
+				return query
+			},
+			expect: ErrInvalidSignature,
+		},
+		{
+			name: "expired URL",
+			path: path,
+			query: func() url.Values {
+				expires := time.Now().Add(-time.Minute).Unix()
+				query := url.Values{}
+				query.Set("expires", strconv.FormatInt(expires, 10))
+				query.Set("scope", ScopeShared)
+				query.Set("sig", signURL(path, ScopeShared, expires))
+				return query
+			},
+			expect: ErrURLExpired,
+		},
+		{
+			name:   "missing signature",
+			path:   path,
+			query:  func() url.Values { return url.Values{} },
+			expect: ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope, err := VerifyURL(tt.path, tt.query())
+			if err != tt.expect {
+				t.Fatalf("Expected error %v, got %v", tt.expect, err)
+			}
+			if scope != tt.scope {
+				t.Errorf("Expected scope %q, got %q", tt.scope, scope)
+			}
+		})
+	}
+}
+
+func TestSignURLExpiry(t *testing.T) {
+	useTestSecret(t)
+
+	signedURL := SignURL("/videos/1/5_clip.mp4", ScopeShared, URLLifetime)
+	if !strings.HasPrefix(signedURL, "/videos/1/5_clip.mp4?") {
+		t.Errorf("Expected the signature in the query, got %s", signedURL)
+	}
+	parsed, err := url.Parse(signedURL)
+	if err != nil {
+		t.Fatalf("Failed to parse signed URL: %v", err)
+	}
+
+	// Expiry is rounded up, so it lands between one and two lifetimes away
+	remaining := time.Until(ExpiresAt(parsed.Query()))
+	if remaining < URLLifetime || remaining > 2*URLLifetime {
+		t.Errorf("Expected expiry between %v and %v away, got %v", URLLifetime, 2*URLLifetime, remaining)
+	}
+
+	// The same URL is handed out again within the lifetime, so browsers can cache it
+	if again := SignURL("/videos/1/5_clip.mp4", ScopeShared, URLLifetime); again != signedURL {
+		t.Errorf("Expected %s, got %s", signedURL, again)
+	}
+}
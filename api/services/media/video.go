@@ -0,0 +1,278 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+)
+
+// quickTimeEpoch is the zero time of MP4 and MOV timestamps
+var quickTimeEpoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Boxes kept in a udta (user data) box: track names, hint and chapter information. Everything
+// else there, including the ©xyz and loci location boxes, camera make and model and software,
+// is cleared.
+var keptUserDataBoxes = map[string]bool{
+	"name": true, "hnti": true, "hinf": true, "chpl": true, "tsel": true, "kind": true,
+}
+
+// xmpUUID marks a uuid box holding XMP, which can carry GPS coordinates
+var xmpUUID = []byte{0xBE, 0x7A, 0xCF, 0xCB, 0x97, 0xA9, 0x42, 0xE8, 0x9C, 0x71, 0x99, 0x94, 0x91, 0xE3, 0xAF, 0xAC}
+
+// videoInfo is what's read from the movie header of an MP4 or MOV
+type videoInfo struct {
+	created  *time.Time
+	duration *float64
+	width    *int
+	height   *int
+}
+
+// probeVideo reads the movie and track headers in the moov box of an MP4 or MOV file. moov is
+// often after the media data, so boxes are skipped over rather than read. A file without a
+// moov box isn't an error; it just has nothing to report.
+func probeVideo(file io.ReadSeeker) (*videoInfo, error) {
+	info := &videoInfo{}
+
+	moovStart, moovSize, err := findBox(file, 0, -1, "moov")
+	if err != nil || moovSize < 0 {
+		return info, err
+	}
+
+	mvhdStart, mvhdSize, err := findBox(file, moovStart, moovSize, "mvhd")
+	if err != nil {
+		return nil, err
+	}
+	if mvhdSize >= 32 {
+		header := make([]byte, 32)
+		if _, err := file.Seek(mvhdStart, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(file, header); err == nil {
+			readMovieHeader(info, header)
+		}
+	}
+
+	// The first track with a picture gives the video's dimensions
+	pos, end := moovStart, moovStart+moovSize
+	for pos < end {
+		trakStart, trakSize, err := findBox(file, pos, end-pos, "trak")
+		if err != nil {
+			return nil, err
+		}
+		if trakSize < 0 {
+			break
+		}
+		pos = trakStart + trakSize
+
+		tkhdStart, tkhdSize, err := findBox(file, trakStart, trakSize, "tkhd")
+		if err != nil {
+			return nil, err
+		}
+		if tkhdSize < 84 {
+			continue
+		}
+		header := make([]byte, 96)
+		if tkhdSize < 96 {
+			header = header[:tkhdSize]
+		}
+		if _, err := file.Seek(tkhdStart, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(file, header); err != nil {
+			continue
+		}
+		if width, height, ok := readTrackDimensions(header); ok {
+			info.width, info.height = &width, &height
+			break
+		}
+	}
+
+	return info, nil
+}
+
+// readMovieHeader reads the creation time and duration from the contents of an mvhd box
+func readMovieHeader(info *videoInfo, header []byte) {
+	var seconds, duration uint64
+	var timescale uint32
+	if header[0] == 1 {
+		seconds = binary.BigEndian.Uint64(header[4:12])
+		timescale = binary.BigEndian.Uint32(header[20:24])
+		duration = binary.BigEndian.Uint64(header[24:32])
+	} else {
+		seconds = uint64(binary.BigEndian.Uint32(header[4:8]))
+		timescale = binary.BigEndian.Uint32(header[12:16])
+		duration = uint64(binary.BigEndian.Uint32(header[16:20]))
+	}
+
+	if seconds != 0 {
+		created := quickTimeEpoch.Add(time.Duration(seconds) * time.Second)
+		info.created = &created
+	}
+	if timescale != 0 && duration != 0 && duration != 0xFFFFFFFF {
+		d := float64(duration) / float64(timescale)
+		info.duration = &d
+	}
+}
+
+// readTrackDimensions reads the display size from the contents of a tkhd box, swapping it for
+// tracks whose matrix turns them on their side
+func readTrackDimensions(header []byte) (int, int, bool) {
+	offset := 4 + 20
+	if header[0] == 1 {
+		offset = 4 + 32
+	}
+	matrix := offset + 16
+	size := matrix + 36
+	if len(header) < size+8 {
+		return 0, 0, false
+	}
+
+	// Width and height are 16.16 fixed point
+	width := int(binary.BigEndian.Uint32(header[size:]) >> 16)
+	height := int(binary.BigEndian.Uint32(header[size+4:]) >> 16)
+	if width == 0 || height == 0 {
+		return 0, 0, false
+	}
+
+	if binary.BigEndian.Uint32(header[matrix:]) == 0 {
+		width, height = height, width
+	}
+	return width, height, true
+}
+
+// findBox looks for a box of type boxType among the boxes between start and start+size (or the
+// end of the file if size is negative). It returns where the box's contents start and how
+// long they are, or a negative size if there is no such box.
+func findBox(file io.ReadSeeker, start, size int64, boxType string) (int64, int64, error) {
+	header := make([]byte, 16)
+	pos := start
+	for size < 0 || pos+8 <= start+size {
+		if _, err := file.Seek(pos, io.SeekStart); err != nil {
+			return 0, -1, err
+		}
+		if _, err := io.ReadFull(file, header[:8]); err != nil {
+			return 0, -1, nil
+		}
+
+		boxSize := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(8)
+		switch boxSize {
+		case 0: // box runs to the end of the file
+			if string(header[4:8]) == boxType {
+				end, err := file.Seek(0, io.SeekEnd)
+				return pos + headerSize, end - pos - headerSize, err
+			}
+			return 0, -1, nil
+		case 1: // 64-bit size follows the type
+			if _, err := io.ReadFull(file, header[8:16]); err != nil {
+				return 0, -1, nil
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if boxSize < headerSize {
+			return 0, -1, nil
+		}
+
+		if string(header[4:8]) == boxType {
+			return pos + headerSize, boxSize - headerSize, nil
+		}
+		pos += boxSize
+	}
+	return 0, -1, nil
+}
+
+// SanitizeVideo strips location, device and other identifying metadata from the MP4 or MOV at
+// path: meta boxes, such as the QuickTime keys holding com.apple.quicktime.location.ISO6709,
+// most of udta, including ©xyz, and XMP. The movie and track headers, which hold the capture
+// time, are kept. Boxes are overwritten in place as free space rather than removed, so the
+// offsets into the media data stay valid. It reports whether anything was cleared.
+func SanitizeVideo(path string) (bool, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	changed, err := sanitizeBoxes(file, 0, stat.Size(), "")
+	if err != nil {
+		return false, err
+	}
+	return changed, file.Close()
+}
+
+// sanitizeBoxes clears the metadata boxes between start and end, the contents of a box of type
+// parent ("" for the top level of the file), and looks inside the boxes that can hold them
+func sanitizeBoxes(file *os.File, start, end int64, parent string) (bool, error) {
+	changed := false
+	header := make([]byte, 32)
+	pos := start
+	for pos+8 <= end {
+		n, err := file.ReadAt(header, pos)
+		if n < 8 {
+			return changed, err
+		}
+
+		boxSize := int64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:8])
+		headerSize := int64(8)
+		switch boxSize {
+		case 0: // box runs to the end of its parent
+			boxSize = end - pos
+		case 1: // 64-bit size follows the type
+			if n < 16 {
+				return changed, nil
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if boxSize < headerSize || pos+boxSize > end {
+			return changed, nil
+		}
+
+		strip := boxType == "meta" ||
+			(parent == "udta" && !keptUserDataBoxes[boxType]) ||
+			(boxType == "uuid" && int64(n) >= headerSize+16 && bytes.Equal(header[headerSize:headerSize+16], xmpUUID))
+		if strip {
+			if err := clearBox(file, pos, headerSize, boxSize); err != nil {
+				return changed, err
+			}
+			changed = true
+		} else if (parent == "" && boxType == "moov") || (parent == "moov" && (boxType == "trak" || boxType == "udta")) ||
+			(parent == "trak" && boxType == "udta") {
+			cleared, err := sanitizeBoxes(file, pos+headerSize, pos+boxSize, boxType)
+			if err != nil {
+				return changed, err
+			}
+			changed = changed || cleared
+		}
+		pos += boxSize
+	}
+	return changed, nil
+}
+
+// clearBox turns a box into a free box of the same size with its contents zeroed
+func clearBox(file *os.File, pos, headerSize, boxSize int64) error {
+	if _, err := file.WriteAt([]byte("free"), pos+4); err != nil {
+		return err
+	}
+
+	zeros := make([]byte, 32*1024)
+	for offset := pos + headerSize; offset < pos+boxSize; offset += int64(len(zeros)) {
+		chunk := zeros
+		if remaining := pos + boxSize - offset; remaining < int64(len(chunk)) {
+			chunk = chunk[:remaining]
+		}
+		if _, err := file.WriteAt(chunk, offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// box builds an MP4 box of the given type around its contents
+func box(boxType string, contents ...[]byte) []byte {
+	payload := bytes.Join(contents, nil)
+	out := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(out, uint32(8+len(payload)))
+	copy(out[4:], boxType)
+	return append(out, payload...)
+}
+
+func writeVideo(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "video.mov")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write video: %v", err)
+	}
+	return path
+}
+
+func TestSanitizeVideo(t *testing.T) {
+	location := []byte("+51.5074-000.1278/")
+	mvhd := box("mvhd", make([]byte, 100))
+	keys := box("keys", []byte{0, 0, 0, 0, 0, 0, 0, 1}, box("mdta", []byte("com.apple.quicktime.location.ISO6709")))
+	ilst := box("ilst", box("\x00\x00\x00\x01", box("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, location)))
+	xmp := box("uuid", xmpUUID, []byte(`<exif:GPSLatitude>51,30.44N</exif:GPSLatitude>`))
+	mdat := box("mdat", []byte("frames"))
+
+	tests := []struct {
+		name     string
+		video    []byte
+		changed  bool
+		kept     [][]byte
+		stripped [][]byte
+	}{
+		{
+			name:     "udta location",
+			video:    bytes.Join([][]byte{box("ftyp", []byte("qt  ")), box("moov", mvhd, box("udta", box("\xa9xyz", location), box("name", []byte("Clip")))), mdat}, nil),
+			changed:  true,
+			kept:     [][]byte{[]byte("mvhd"), []byte("Clip"), []byte("frames")},
+			stripped: [][]byte{location, []byte("\xa9xyz")},
+		},
+		{
+			name:     "QuickTime keys",
+			video:    bytes.Join([][]byte{box("ftyp", []byte("qt  ")), box("moov", mvhd, box("meta", box("hdlr", make([]byte, 24)), keys, ilst)), mdat}, nil),
+			changed:  true,
+			kept:     [][]byte{[]byte("mvhd"), []byte("frames")},
+			stripped: [][]byte{location, []byte("com.apple.quicktime.location.ISO6709")},
+		},
+		{
+			name:     "track udta and XMP",
+			video:    bytes.Join([][]byte{box("ftyp", []byte("isom")), xmp, box("moov", mvhd, box("trak", box("tkhd", make([]byte, 84)), box("udta", box("loci", location)))), mdat}, nil),
+			changed:  true,
+			kept:     [][]byte{[]byte("tkhd"), []byte("frames")},
+			stripped: [][]byte{location, []byte("GPSLatitude")},
+		},
+		{
+			name:    "nothing to strip",
+			video:   bytes.Join([][]byte{box("ftyp", []byte("isom")), box("moov", mvhd), mdat}, nil),
+			changed: false,
+			kept:    [][]byte{[]byte("mvhd"), []byte("frames")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeVideo(t, tt.video)
+
+			changed, err := SanitizeVideo(path)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if changed != tt.changed {
+				t.Errorf("Expected changed %v, got %v", tt.changed, changed)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read video: %v", err)
+			}
+			if len(data) != len(tt.video) {
+				t.Errorf("Expected size %d, got %d", len(tt.video), len(data))
+			}
+			for _, kept := range tt.kept {
+				if !bytes.Contains(data, kept) {
+					t.Errorf("Expected %q to be kept", kept)
+				}
+			}
+			for _, stripped := range tt.stripped {
+				if bytes.Contains(data, stripped) {
+					t.Errorf("Expected %q to be stripped", stripped)
+				}
+			}
+
+			// The boxes still parse, so the movie can be probed
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatalf("Failed to open video: %v", err)
+			}
+			defer file.Close()
+			if _, size, err := findBox(file, 0, -1, "mdat"); err != nil || size != 6 {
+				t.Errorf("Expected mdat of 6 bytes, got %d (%v)", size, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// unsignedPayload lets uploads be streamed without hashing them first; S3 and MinIO accept it
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Config is how to reach an S3-compatible bucket
+type S3Config struct {
+	Endpoint        string // e.g. http://localhost:9000 for MinIO; defaults to AWS for the region
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PathStyle       bool // address the bucket in the path rather than the host name, as MinIO needs
+}
+
+// S3 stores objects in an S3-compatible bucket, under a prefix so several stores can share one
+// bucket. Requests are signed with AWS Signature Version 4.
+type S3 struct {
+	config S3Config
+	prefix string
+	client *http.Client
+	signer *v4.Signer
+}
+
+// NewS3 returns a store for objects under prefix in a bucket
+func NewS3(cfg S3Config, prefix string) *S3 {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", cfg.Region)
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+
+	return &S3{
+		config: cfg,
+		prefix: strings.Trim(prefix, "/"),
+		client: &http.Client{Timeout: 10 * time.Minute},
+		signer: v4.NewSigner(func(o *v4.SignerOptions) {
+			o.DisableURIPathEscaping = true
+		}),
+	}
+}
+
+// objectURL returns the unsigned URL of the object stored under key
+func (s *S3) objectURL(key string) (*url.URL, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "..") {
+		return nil, ErrInvalidKey
+	}
+
+	endpoint, err := url.Parse(s.config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+
+	objectPath := "/" + key
+	if s.prefix != "" {
+		objectPath = "/" + s.prefix + objectPath
+	}
+	if s.config.PathStyle {
+		objectPath = "/" + s.config.Bucket + objectPath
+	} else {
+		endpoint.Host = s.config.Bucket + "." + endpoint.Host
+	}
+
+	endpoint.Path = objectPath
+	endpoint.RawPath = escapePath(objectPath)
+	return endpoint, nil
+}
+
+// escapePath escapes each segment of an object path the way S3 expects it to be signed
+func escapePath(objectPath string) string {
+	segments := strings.Split(objectPath, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(segment), "+", "%2B")
+	}
+	return strings.Join(segments, "/")
+}
+
+func (s *S3) credentials() aws.Credentials {
+	return aws.Credentials{AccessKeyID: s.config.AccessKeyID, SecretAccessKey: s.config.SecretAccessKey}
+}
+
+// do signs and sends a request for the object stored under key
+func (s *S3) do(ctx context.Context, method, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	objectURL, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, objectURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	err = s.signer.SignHTTP(ctx, s.credentials(), req, unsignedPayload, "s3", s.config.Region, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return s.client.Do(req)
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, r, size, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, responseError(resp)
+	}
+	return resp.Body, objectInfo(resp), nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return responseError(resp)
+	}
+	return nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+	return objectInfo(resp), nil
+}
+
+// URL presigns a download URL. The signing time is rounded down to the lifetime so the same URL
+// is handed out for a while and browsers can cache what it points to; the URL stays valid for
+// between one and two lifetimes.
+func (s *S3) URL(ctx context.Context, key string, lifetime time.Duration) (string, error) {
+	objectURL, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+
+	query := objectURL.Query()
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(2*lifetime/time.Second), 10))
+	objectURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, objectURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	signed, _, err := s.signer.PresignHTTP(ctx, s.credentials(), req, unsignedPayload, "s3", s.config.Region, time.Now().Truncate(lifetime))
+	return signed, err
+}
+
+func objectInfo(resp *http.Response) *ObjectInfo {
+	info := &ObjectInfo{Size: resp.ContentLength, ContentType: resp.Header.Get("Content-Type")}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+	return info
+}
+
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("S3 %s %s returned %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+}
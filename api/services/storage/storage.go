@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"simple-go/api/config"
+)
+
+// Storage backends
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// ErrNotFound is returned when there is no object stored under a key
+var ErrNotFound = errors.New("object not found")
+
+// ErrInvalidKey is returned for keys that are empty or try to escape the store
+var ErrInvalidKey = errors.New("invalid object key")
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage stores media files under slash-separated keys such as "3/12_1700000000_0.jpg"
+type Storage interface {
+	// Put stores size bytes read from r under key, replacing anything already there
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+
+	// Get opens the object stored under key. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+
+	// Delete removes the object stored under key. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// Stat describes the object stored under key without reading it
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+
+	// URL returns a URL the object can be downloaded from directly, valid for at least
+	// lifetime, or "" if it can only be read through Get
+	URL(ctx context.Context, key string, lifetime time.Duration) (string, error)
+}
+
+var imageStore, videoStore Storage
+
+// Init opens the storage backend set in the configuration
+func Init() error {
+	var err error
+	imageStore, videoStore, err = Open(config.AppConfig.StorageBackend)
+	return err
+}
+
+// Open returns the stores for photos and videos on a backend
+func Open(backend string) (Storage, Storage, error) {
+	cfg := config.AppConfig
+	switch backend {
+	case BackendLocal:
+		return NewLocal(cfg.ImagesDirectory), NewLocal(cfg.VideosDirectory), nil
+	case BackendS3:
+		if cfg.S3Bucket == "" {
+			return nil, nil, errors.New("S3_BUCKET must be set to use S3 storage")
+		}
+		s3Config := S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PathStyle:       cfg.S3PathStyle,
+		}
+		return NewS3(s3Config, "images"), NewS3(s3Config, "videos"), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+// Images returns the store for photos, including cover photos and renditions
+func Images() Storage {
+	return imageStore
+}
+
+// Videos returns the store for videos
+func Videos() Storage {
+	return videoStore
+}
+
+// Copy copies the object stored under key from one store to another
+func Copy(ctx context.Context, from, to Storage, key string) error {
+	body, info, err := from.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return to.Put(ctx, key, body, info.Size, info.ContentType)
+}